@@ -0,0 +1,110 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// namespaceProbeResources 在SelfSubjectRulesReview结果不完整时用于探测namespace访问权限的资源
+var namespaceProbeResources = []string{"pods", "configmaps"}
+
+// ListAccessibleNamespaces 确定当前凭证实际可以使用(可get/list其中至少一种资源)的namespace列表,
+// 便于多租户工具避免执行会被拒绝的集群范围list.
+//
+// 未指定candidates时先尝试列出所有namespace; 受限凭证通常无权列出namespace, 此时需由调用方提供候选namespace.
+// 每个namespace通过SelfSubjectRulesReview判断, 当鉴权器无法给出完整规则时退化为SelfSubjectAccessReview探测.
+func (c *GenericK8sClient) ListAccessibleNamespaces(ctx context.Context, candidates ...string) ([]string, error) {
+	if len(candidates) == 0 {
+		nsList, err := c.GetStandardClient().CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if apierrors.IsForbidden(err) {
+				return nil, errors.Wrap(err, "当前凭证无权列出namespace, 请指定候选namespace")
+			}
+			return nil, errors.Wrap(err, "无法列出namespace")
+		}
+		for _, ns := range nsList.Items {
+			candidates = append(candidates, ns.Name)
+		}
+	}
+
+	accessible := make([]bool, len(candidates))
+	var firstErr error
+	var errLock sync.Mutex
+	sem := make(chan struct{}, DefaultListConcurrency)
+	wg := sync.WaitGroup{}
+	for i, ns := range candidates {
+		wg.Add(1)
+		go func(i int, ns string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ok, err := c.namespaceAccessible(ctx, ns)
+			if err != nil {
+				errLock.Lock()
+				if firstErr == nil {
+					firstErr = errors.Wrap(err, "无法判断namespace "+ns+" 的访问权限")
+				}
+				errLock.Unlock()
+				return
+			}
+			accessible[i] = ok
+		}(i, ns)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	var result []string
+	for i, ns := range candidates {
+		if accessible[i] {
+			result = append(result, ns)
+		}
+	}
+	return result, nil
+}
+
+func (c *GenericK8sClient) namespaceAccessible(ctx context.Context, namespace string) (bool, error) {
+	review, err := c.GetStandardClient().AuthorizationV1().SelfSubjectRulesReviews().Create(ctx, &authorizationv1.SelfSubjectRulesReview{
+		Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: namespace},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	for _, rule := range review.Status.ResourceRules {
+		for _, verb := range rule.Verbs {
+			if verb == "get" || verb == "list" || verb == "*" {
+				return true, nil
+			}
+		}
+	}
+	if !review.Status.Incomplete {
+		return false, nil
+	}
+
+	// 规则不完整(如使用webhook鉴权器), 通过SelfSubjectAccessReview探测
+	for _, resource := range namespaceProbeResources {
+		sar, err := c.GetStandardClient().AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: namespace,
+					Verb:      "list",
+					Resource:  resource,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+		if sar.Status.Allowed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,76 @@
+package k8sclientkit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// APIWarningHandler 在APIServer响应中携带警告(如 "networking.k8s.io/v1beta1 Ingress is deprecated")时调用
+type APIWarningHandler func(clusterId, method, path, warning string)
+
+// WithAPIWarningHandler 设置API警告回调, 作用于该客户端发出的所有请求
+func WithAPIWarningHandler(handler APIWarningHandler) Option {
+	return func(o *clientOptions) {
+		o.warningHandler = handler
+	}
+}
+
+type warningCollectorKey struct{}
+
+// warningCollector 收集单次操作期间的API警告
+type warningCollector struct {
+	lock     sync.Mutex
+	warnings []string
+}
+
+func (w *warningCollector) add(warning string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for _, existing := range w.warnings {
+		if existing == warning {
+			return
+		}
+	}
+	w.warnings = append(w.warnings, warning)
+}
+
+func (w *warningCollector) list() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return append([]string{}, w.warnings...)
+}
+
+// ContextWithWarningCollector 返回收集API警告的ctx, 使用该ctx发出的请求收到的警告可通过返回的函数读取(已去重)
+func ContextWithWarningCollector(ctx context.Context) (context.Context, func() []string) {
+	collector := &warningCollector{}
+	return context.WithValue(ctx, warningCollectorKey{}, collector), collector.list
+}
+
+// warningRoundTripper 解析响应中的Warning头, 交给回调及请求ctx中的收集器
+type warningRoundTripper struct {
+	clusterId string
+	handler   APIWarningHandler
+	next      http.RoundTripper
+}
+
+func (rt *warningRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil || len(resp.Header["Warning"]) == 0 {
+		return resp, err
+	}
+
+	warnings, _ := utilnet.ParseWarningHeaders(resp.Header["Warning"])
+	collector, _ := req.Context().Value(warningCollectorKey{}).(*warningCollector)
+	for _, warning := range warnings {
+		if rt.handler != nil {
+			rt.handler(rt.clusterId, req.Method, req.URL.Path, warning.Text)
+		}
+		if collector != nil {
+			collector.add(warning.Text)
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,441 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultPlanFieldManager 为 PlanOptions.FieldManager 为空时使用的field manager
+const DefaultPlanFieldManager = "k8s-client-kit"
+
+// ErrPlanStale 表示对象在制定计划之后被修改, 计划中的变更未执行, 需要重新 Plan
+var ErrPlanStale = errors.New("计划已过期")
+
+// PlanAction 为计划中对单个对象的操作
+type PlanAction string
+
+const (
+	// PlanCreate 对象不存在, 将被创建
+	PlanCreate PlanAction = "create"
+	// PlanPatch 对象存在且内容将发生变化
+	PlanPatch PlanAction = "patch"
+	// PlanDelete 对象匹配prune条件但不在manifest中, 将被删除
+	PlanDelete PlanAction = "delete"
+	// PlanNoOp 对象存在且内容不会变化
+	PlanNoOp PlanAction = "no-op"
+)
+
+// Verb 返回与kubectl apply输出一致的结果描述: created, configured, pruned, unchanged
+func (a PlanAction) Verb() string {
+	switch a {
+	case PlanCreate:
+		return "created"
+	case PlanPatch:
+		return "configured"
+	case PlanDelete:
+		return "pruned"
+	}
+	return "unchanged"
+}
+
+// PlanOptions 为 Plan 的参数
+type PlanOptions struct {
+	// FieldManager 为server-side apply使用的字段管理者, 默认为 DefaultPlanFieldManager
+	FieldManager string
+	// PruneSelector 非空时启用prune: 集群中匹配该label selector, 类型出现在manifest或PruneKinds中,
+	// 但不在manifest中的对象计划删除. 为避免误删, prune必须指定selector
+	PruneSelector string
+	// PruneKinds 为manifest之外需要检查prune的资源类型, 用于清理从manifest中整体移除的类型
+	PruneKinds []schema.GroupVersionKind
+	// ForceConflicts 为true时server-side apply强制接管其他字段管理者拥有的字段(同 `kubectl apply --server-side --force-conflicts`).
+	// 默认与 ApplyUnstructuredObj 一致不强制, 字段冲突时Plan返回错误
+	ForceConflicts bool
+}
+
+// PlannedChange 为计划中对单个对象的操作
+type PlannedChange struct {
+	Action     PlanAction `json:"action"`
+	Identity   string     `json:"identity"`
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Name       string     `json:"name"`
+	// Changes 为patch时的字段变更摘要(见 DiffObjects), 以服务端dry-run结果与集群中的对象比较得出
+	Changes []string `json:"changes,omitempty"`
+	// LiveUID 与 LiveResourceVersion 为制定计划时集群中对象的状态, create时为空. Apply 据此发现计划过期
+	LiveUID             string `json:"liveUid,omitempty"`
+	LiveResourceVersion string `json:"liveResourceVersion,omitempty"`
+	// Object 为create/patch/no-op时将要apply的对象(已执行mutator与策略检查插件), delete时仅包含对象标识
+	Object *unstructured.Unstructured `json:"object"`
+}
+
+// ApplyPlan 为 Plan 计算出的变更计划, 可序列化后交由人工审核, 再通过 Apply 执行
+type ApplyPlan struct {
+	ClusterId     string `json:"clusterId"`
+	FieldManager  string `json:"fieldManager"`
+	PruneSelector string `json:"pruneSelector,omitempty"`
+	// ForceConflicts 同 PlanOptions.ForceConflicts, Apply 执行时沿用
+	ForceConflicts bool             `json:"forceConflicts,omitempty"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	Changes        []*PlannedChange `json:"changes"`
+}
+
+// PlanSummary 为计划中各操作的对象数量
+type PlanSummary struct {
+	Create int `json:"create"`
+	Patch  int `json:"patch"`
+	Delete int `json:"delete"`
+	NoOp   int `json:"noOp"`
+}
+
+// Summary 统计计划中各操作的对象数量
+func (p *ApplyPlan) Summary() PlanSummary {
+	summary := PlanSummary{}
+	for _, change := range p.Changes {
+		switch change.Action {
+		case PlanCreate:
+			summary.Create++
+		case PlanPatch:
+			summary.Patch++
+		case PlanDelete:
+			summary.Delete++
+		default:
+			summary.NoOp++
+		}
+	}
+	return summary
+}
+
+// HasChanges 计划中是否存在no-op以外的操作
+func (p *ApplyPlan) HasChanges() bool {
+	summary := p.Summary()
+	return summary.Create+summary.Patch+summary.Delete > 0
+}
+
+// String 返回便于人工审核的计划描述, 每个对象一行(`+` 创建, `~` 修改, `-` 删除, `=` 不变), patch的字段变更缩进列出
+func (p *ApplyPlan) String() string {
+	b := strings.Builder{}
+	for _, change := range p.Changes {
+		switch change.Action {
+		case PlanCreate:
+			b.WriteString("+ ")
+		case PlanPatch:
+			b.WriteString("~ ")
+		case PlanDelete:
+			b.WriteString("- ")
+		default:
+			b.WriteString("= ")
+		}
+		b.WriteString(change.Identity + " (" + string(change.Action) + ")\n")
+		for _, field := range change.Changes {
+			b.WriteString("    " + field + "\n")
+		}
+	}
+	summary := p.Summary()
+	b.WriteString("计划: " + strconv.Itoa(summary.Create) + " 个创建, " + strconv.Itoa(summary.Patch) + " 个修改, " +
+		strconv.Itoa(summary.Delete) + " 个删除, " + strconv.Itoa(summary.NoOp) + " 个不变\n")
+	return b.String()
+}
+
+// Save 将计划写入文件, 序列化格式根据文件扩展名选择(参见 EncoderForPath)
+func (p *ApplyPlan) Save(path string) error {
+	return encodeToFile(path, EncoderForPath(path), p)
+}
+
+// LoadApplyPlan 从文件读取之前保存的计划
+func LoadApplyPlan(path string) (*ApplyPlan, error) {
+	plan := &ApplyPlan{}
+	if err := decodeFromFile(path, EncoderForPath(path), plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Plan 计算将objs应用到集群所需的操作, 不修改集群. 已存在对象的目标状态通过服务端dry-run apply得到,
+// 因此字段变更摘要包含默认值与mutating webhook的影响. 对象插件(mutator与策略检查)在计划时执行, 计划中保存修改后的对象,
+// 审核的内容即 Apply 提交的内容. 未指定namespace的namespace级别对象使用 `default`.
+// 结果按create, patch, no-op(保持manifest顺序)及delete排列.
+func (c *GenericK8sClient) Plan(ctx context.Context, objs []*unstructured.Unstructured, opts PlanOptions) (*ApplyPlan, error) {
+	ctx, _ = EnsureRequestID(ctx)
+	if len(opts.FieldManager) == 0 {
+		opts.FieldManager = DefaultPlanFieldManager
+	}
+	plan := &ApplyPlan{
+		ClusterId:      c.TargetK8sApiServerId,
+		FieldManager:   opts.FieldManager,
+		PruneSelector:  opts.PruneSelector,
+		ForceConflicts: opts.ForceConflicts,
+		CreatedAt:      time.Now(),
+	}
+
+	var errs []error
+	var creates, patches, noops []*PlannedChange
+	desiredIDs := map[string]bool{}
+	pruneGVRs := map[schema.GroupVersionResource]schema.GroupVersionKind{}
+	for _, obj := range objs {
+		obj = obj.DeepCopy()
+		mapping, err := c.planMapping(obj)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		identity := ObjectIdentity(obj)
+		desiredIDs[identity] = true
+		pruneGVRs[mapping.Resource] = obj.GroupVersionKind()
+
+		change, err := c.planObject(ctx, obj, mapping, opts.FieldManager, opts.ForceConflicts)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, identity))
+			continue
+		}
+		switch change.Action {
+		case PlanCreate:
+			creates = append(creates, change)
+		case PlanPatch:
+			patches = append(patches, change)
+		default:
+			noops = append(noops, change)
+		}
+	}
+	plan.Changes = append(append(creates, patches...), noops...)
+
+	// 存在失败的对象时计划不完整, 不计算prune, 避免删除本应保留的对象
+	if len(opts.PruneSelector) > 0 && len(errs) == 0 {
+		for _, gvk := range opts.PruneKinds {
+			mapping, err := c.GetRuntimeCluster().GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+			if err != nil {
+				errs = append(errs, c.clusterError(errors.Wrap(ErrResourceNotMapped, gvk.String()), gvk))
+				continue
+			}
+			pruneGVRs[mapping.Resource] = gvk
+		}
+		deletes, err := c.planPrune(ctx, pruneGVRs, opts.PruneSelector, desiredIDs)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		plan.Changes = append(plan.Changes, deletes...)
+	}
+	return plan, utilerrors.NewAggregate(errs)
+}
+
+// planMapping 解析对象的资源类型, 并为缺少namespace的namespace级别对象设置 `default`
+func (c *GenericK8sClient) planMapping(obj *unstructured.Unstructured) (*meta.RESTMapping, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.GetRuntimeCluster().GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, c.clusterError(errors.Wrap(ErrResourceNotMapped, gvk.String()), gvk)
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace && len(obj.GetNamespace()) == 0 {
+		obj.SetNamespace(metav1.NamespaceDefault)
+	}
+	return mapping, nil
+}
+
+// planObject 执行对象插件后比较集群中的对象与dry-run apply的结果, 决定create, patch或no-op
+func (c *GenericK8sClient) planObject(ctx context.Context, obj *unstructured.Unstructured, mapping *meta.RESTMapping, fieldManager string, force bool) (*PlannedChange, error) {
+	if err := c.runObjectPlugins(ctx, OperationApply, obj); err != nil {
+		return nil, err
+	}
+	change := newPlannedChange(obj)
+	change.Object = obj
+	live, err := c.GetUnstructuredObj(ctx, obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+	if apierrors.IsNotFound(err) {
+		change.Action = PlanCreate
+		return change, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	change.LiveUID = string(live.GetUID())
+	change.LiveResourceVersion = live.GetResourceVersion()
+
+	applyObj := obj.DeepCopy()
+	applyObj.SetManagedFields(nil)
+	applyObj.SetResourceVersion("")
+	desired, err := applyObject(ctx, namespacedResource(c.GetDynamicClient(), mapping.Resource, obj.GetNamespace()), applyObj, metav1.ApplyOptions{
+		FieldManager: fieldManager,
+		Force:        force,
+		DryRun:       []string{metav1.DryRunAll},
+	}, c.overrides.DisableServerSideApply)
+	if err != nil {
+		return nil, c.clusterError(err, obj.GroupVersionKind())
+	}
+	change.Changes = DiffObjects(live, desired)
+	change.Action = PlanNoOp
+	if len(change.Changes) > 0 {
+		change.Action = PlanPatch
+	}
+	return change, nil
+}
+
+// planPrune 列出匹配selector但不在desiredIDs中的对象, 生成delete操作
+func (c *GenericK8sClient) planPrune(ctx context.Context, gvrs map[schema.GroupVersionResource]schema.GroupVersionKind, selector string, desiredIDs map[string]bool) ([]*PlannedChange, error) {
+	var deletes []*PlannedChange
+	var errs []error
+	for gvr, gvk := range gvrs {
+		list, err := c.GetDynamicClient().Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			errs = append(errs, c.clusterError(errors.Wrap(err, "无法列出 "+gvr.String()), gvk))
+			continue
+		}
+		for i := range list.Items {
+			live := &list.Items[i]
+			if desiredIDs[ObjectIdentity(live)] || live.GetDeletionTimestamp() != nil {
+				continue
+			}
+			target := &unstructured.Unstructured{}
+			target.SetGroupVersionKind(gvk)
+			target.SetNamespace(live.GetNamespace())
+			target.SetName(live.GetName())
+			change := newPlannedChange(target)
+			change.Action = PlanDelete
+			change.LiveUID = string(live.GetUID())
+			change.LiveResourceVersion = live.GetResourceVersion()
+			change.Object = target
+			deletes = append(deletes, change)
+		}
+	}
+	sort.Slice(deletes, func(i, j int) bool {
+		return deletes[i].Identity < deletes[j].Identity
+	})
+	return deletes, utilerrors.NewAggregate(errs)
+}
+
+func newPlannedChange(obj *unstructured.Unstructured) *PlannedChange {
+	return &PlannedChange{
+		Identity:   ObjectIdentity(obj),
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
+
+// PlannedChangeResult 为 Apply 中单个操作的执行结果
+type PlannedChangeResult struct {
+	Change *PlannedChange
+	// ResultObject 为create/patch后APIServer返回的对象
+	ResultObject *unstructured.Unstructured
+	// Err 为执行失败的原因, 对象在制定计划后被修改时为 ErrPlanStale
+	Err error
+}
+
+// String 返回与kubectl apply一致的结果描述, 如 `apps/Deployment/default/web configured`
+func (r *PlannedChangeResult) String() string {
+	if r.Err != nil {
+		return r.Change.Identity + " 失败: " + r.Err.Error()
+	}
+	return r.Change.Identity + " " + r.Change.Action.Verb()
+}
+
+// Apply 执行之前由 Plan 计算的计划, 只执行计划中的操作, 不会重新计算变更. 每个对象执行前检查其是否在制定计划后被修改
+// (patch与delete以resourceVersion为前提条件, create要求对象仍不存在), 已修改的对象返回 ErrPlanStale 且不执行.
+// no-op同样校验对象未被修改. 任一操作失败时返回汇总错误, 结果中包含全部操作的执行情况.
+func (c *GenericK8sClient) Apply(ctx context.Context, plan *ApplyPlan) ([]*PlannedChangeResult, error) {
+	if plan == nil {
+		return nil, errors.New("计划不能为空")
+	}
+	if len(plan.ClusterId) > 0 && plan.ClusterId != c.TargetK8sApiServerId {
+		return nil, errors.New("计划属于集群 " + plan.ClusterId + ", 不能应用到 " + c.TargetK8sApiServerId)
+	}
+	ctx, _ = EnsureRequestID(ctx)
+	fieldManager := plan.FieldManager
+	if len(fieldManager) == 0 {
+		fieldManager = DefaultPlanFieldManager
+	}
+
+	results := make([]*PlannedChangeResult, 0, len(plan.Changes))
+	var errs []error
+	for _, change := range plan.Changes {
+		result := &PlannedChangeResult{Change: change}
+		results = append(results, result)
+		if change.Object == nil {
+			result.Err = errors.New(change.Identity + " 缺少对象内容")
+		} else {
+			result.ResultObject, result.Err = c.applyPlannedChange(ctx, change, fieldManager, plan.ForceConflicts)
+		}
+		if result.Err != nil {
+			errs = append(errs, errors.Wrap(result.Err, change.Identity))
+		}
+	}
+	return results, utilerrors.NewAggregate(errs)
+}
+
+func (c *GenericK8sClient) applyPlannedChange(ctx context.Context, change *PlannedChange, fieldManager string, force bool) (*unstructured.Unstructured, error) {
+	obj := change.Object.DeepCopy()
+	gvk := obj.GroupVersionKind()
+	if change.Action == PlanDelete {
+		uid := types.UID(change.LiveUID)
+		rv := change.LiveResourceVersion
+		err := c.DeleteUnstructuredObj(ctx, obj, client.Preconditions{UID: &uid, ResourceVersion: &rv})
+		if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+			return nil, errors.Wrap(ErrPlanStale, err.Error())
+		}
+		return nil, err
+	}
+
+	live, err := c.GetUnstructuredObj(ctx, gvk, obj.GetNamespace(), obj.GetName())
+	switch {
+	case apierrors.IsNotFound(err):
+		live = nil
+	case err != nil:
+		return nil, err
+	}
+	if live == nil && change.Action != PlanCreate {
+		return nil, errors.Wrap(ErrPlanStale, "对象已被删除")
+	}
+	if live != nil && (string(live.GetUID()) != change.LiveUID || live.GetResourceVersion() != change.LiveResourceVersion) {
+		return nil, errors.Wrap(ErrPlanStale, "对象已被修改")
+	}
+	if change.Action == PlanNoOp {
+		return live, nil
+	}
+
+	mapping, err := c.GetRuntimeCluster().GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, c.clusterError(errors.Wrap(ErrResourceNotMapped, gvk.String()), gvk)
+	}
+	// 对象插件已在 Plan 时执行, 不再重复执行, 以免提交的对象与审核的计划不一致
+	obj.SetManagedFields(nil)
+	// patch以计划时的resourceVersion为前提条件, 期间被修改时APIServer返回Conflict
+	obj.SetResourceVersion(change.LiveResourceVersion)
+	applied, err := applyObject(ctx, namespacedResource(c.GetDynamicClient(), mapping.Resource, obj.GetNamespace()), obj, metav1.ApplyOptions{
+		FieldManager: fieldManager,
+		Force:        force,
+	}, c.overrides.DisableServerSideApply)
+	if apierrors.IsConflict(err) && !isFieldManagerConflict(err) {
+		return nil, errors.Wrap(ErrPlanStale, err.Error())
+	}
+	if err != nil {
+		return nil, c.clusterError(err, gvk)
+	}
+	c.recordOperationEvent(ctx, applied, EventReasonApplied, "对象已由 "+fieldManager+" 按计划应用")
+	return applied, nil
+}
+
+// isFieldManagerConflict 判断Conflict错误是否为server-side apply的字段归属冲突(而非resourceVersion前提条件不满足)
+func isFieldManagerConflict(err error) bool {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return false
+	}
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type == metav1.CauseTypeFieldManagerConflict {
+			return true
+		}
+	}
+	return false
+}
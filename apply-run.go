@@ -0,0 +1,104 @@
+package k8sclientkit
+
+import (
+	"sort"
+	"time"
+)
+
+// ApplyRunSummary 记录一次批量apply的结果摘要(每个对象的内容hash), 可持久化并与之后的运行结果比较
+type ApplyRunSummary struct {
+	RunID string    `json:"runId"`
+	Time  time.Time `json:"time"`
+	// Objects 以ObjectIdentity为key, 值为ObjectHash
+	Objects map[string]string `json:"objects"`
+	// Failed 为本次apply失败的对象标识
+	Failed []string `json:"failed,omitempty"`
+}
+
+// ApplyRunDiff 描述两次apply之间的变更集合, 各列表元素为ObjectIdentity
+type ApplyRunDiff struct {
+	Added     []string `json:"added"`
+	Changed   []string `json:"changed"`
+	Removed   []string `json:"removed"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// HasChanges 是否存在新增, 修改或删除的对象
+func (d *ApplyRunDiff) HasChanges() bool {
+	return len(d.Added)+len(d.Changed)+len(d.Removed) > 0
+}
+
+// SummarizeApplyRun 根据ApplyUnstructuredObjsBatch的结果生成摘要, 对象hash取自提交的期望对象(UnstructuredApplyResult.DesiredHash),
+// 未记录时才使用APIServer返回的对象
+func SummarizeApplyRun(runID string, successfulResults, failedResults []*UnstructuredApplyResult) *ApplyRunSummary {
+	summary := &ApplyRunSummary{
+		RunID:   runID,
+		Time:    time.Now(),
+		Objects: make(map[string]string, len(successfulResults)),
+	}
+	for _, result := range successfulResults {
+		if result.ResultObject == nil {
+			continue
+		}
+		// 以提交的期望对象计算hash, 服务端的默认值, 注解等变化不视为内容变化
+		hash := result.DesiredHash
+		if len(hash) == 0 {
+			hash = ObjectHash(result.ResultObject)
+		}
+		summary.Objects[ObjectIdentity(result.ResultObject)] = hash
+	}
+	for _, result := range failedResults {
+		if result.ResultObject == nil {
+			continue
+		}
+		summary.Failed = append(summary.Failed, ObjectIdentity(result.ResultObject))
+	}
+	sort.Strings(summary.Failed)
+	return summary
+}
+
+// CompareRuns 比较两次apply的摘要, 报告新增, 内容变化, 移除与未变化的对象. prev为nil时所有对象视为新增.
+func CompareRuns(prev, current *ApplyRunSummary) *ApplyRunDiff {
+	diff := &ApplyRunDiff{}
+	prevObjects := map[string]string{}
+	if prev != nil {
+		prevObjects = prev.Objects
+	}
+
+	for id, hash := range current.Objects {
+		prevHash, existed := prevObjects[id]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, id)
+		case prevHash != hash:
+			diff.Changed = append(diff.Changed, id)
+		default:
+			diff.Unchanged = append(diff.Unchanged, id)
+		}
+	}
+	for id := range prevObjects {
+		if _, exists := current.Objects[id]; !exists {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Unchanged)
+	return diff
+}
+
+// Save 将摘要写入文件, 序列化格式根据文件扩展名选择(参见 EncoderForPath)
+func (s *ApplyRunSummary) Save(path string) error {
+	return encodeToFile(path, EncoderForPath(path), s)
+}
+
+// LoadApplyRunSummary 从文件读取之前保存的摘要
+func LoadApplyRunSummary(path string) (*ApplyRunSummary, error) {
+	summary := &ApplyRunSummary{}
+	if err := decodeFromFile(path, EncoderForPath(path), summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,64 @@
+// Package builders 提供常用资源的链式构造器, 用于在Go代码中生成对象而无需拼接YAML字符串.
+// 每个构造器的 Build 返回typed对象(已设置apiVersion与kind), Unstructured 返回可直接传给
+// ApplyUnstructuredObj, ApplyUnstructuredObjsBatch 等apply流程的 *unstructured.Unstructured.
+//
+//	deploy, err := builders.NewDeployment().Name("web").Namespace("default").
+//		Image("nginx:1.25").Replicas(3).Port("http", 80).Unstructured()
+package builders
+
+import (
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AppLabel 为未指定selector时Deployment与Service默认使用的标签
+const AppLabel = "app"
+
+// ToUnstructured 将typed对象转换为unstructured, 并去除转换产生的空creationTimestamp与status.
+// obj 需已设置apiVersion与kind.
+func ToUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "转换为unstructured失败")
+	}
+	u := &unstructured.Unstructured{Object: content}
+	if len(u.GetAPIVersion()) == 0 || len(u.GetKind()) == 0 {
+		return nil, errors.New("对象未设置apiVersion或kind")
+	}
+	if len(u.GetName()) == 0 {
+		return nil, errors.New(u.GetKind() + " 未设置name")
+	}
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(u.Object, "spec", "template", "metadata", "creationTimestamp")
+	// status由控制器维护, apply时不应携带
+	delete(u.Object, "status")
+	return u, nil
+}
+
+// setLabel 与 setAnnotation 在map为nil时初始化
+func setLabel(meta *metav1.ObjectMeta, key, value string) {
+	if meta.Labels == nil {
+		meta.Labels = map[string]string{}
+	}
+	meta.Labels[key] = value
+}
+
+func setAnnotation(meta *metav1.ObjectMeta, key, value string) {
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[key] = value
+}
+
+// mergeLabels 将src合并到dst, 返回合并结果
+func mergeLabels(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = map[string]string{}
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,68 @@
+package builders
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ConfigMapBuilder 构造ConfigMap
+type ConfigMapBuilder struct {
+	obj *corev1.ConfigMap
+}
+
+func NewConfigMap() *ConfigMapBuilder {
+	return &ConfigMapBuilder{obj: &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "ConfigMap"},
+	}}
+}
+
+func (b *ConfigMapBuilder) Name(name string) *ConfigMapBuilder {
+	b.obj.Name = name
+	return b
+}
+
+func (b *ConfigMapBuilder) Namespace(namespace string) *ConfigMapBuilder {
+	b.obj.Namespace = namespace
+	return b
+}
+
+func (b *ConfigMapBuilder) Label(key, value string) *ConfigMapBuilder {
+	setLabel(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *ConfigMapBuilder) Annotation(key, value string) *ConfigMapBuilder {
+	setAnnotation(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *ConfigMapBuilder) Data(key, value string) *ConfigMapBuilder {
+	if b.obj.Data == nil {
+		b.obj.Data = map[string]string{}
+	}
+	b.obj.Data[key] = value
+	return b
+}
+
+func (b *ConfigMapBuilder) BinaryData(key string, value []byte) *ConfigMapBuilder {
+	if b.obj.BinaryData == nil {
+		b.obj.BinaryData = map[string][]byte{}
+	}
+	b.obj.BinaryData[key] = value
+	return b
+}
+
+func (b *ConfigMapBuilder) Immutable() *ConfigMapBuilder {
+	immutable := true
+	b.obj.Immutable = &immutable
+	return b
+}
+
+func (b *ConfigMapBuilder) Build() *corev1.ConfigMap {
+	return b.obj.DeepCopy()
+}
+
+func (b *ConfigMapBuilder) Unstructured() (*unstructured.Unstructured, error) {
+	return ToUnstructured(b.Build())
+}
@@ -0,0 +1,184 @@
+package builders
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// DeploymentBuilder 构造单容器(或多容器)Deployment. Image, Port, Env 等方法作用于主容器,
+// 主容器名称默认与Deployment同名. 未调用 Selector 时使用 app=<name> 作为selector与Pod标签.
+type DeploymentBuilder struct {
+	obj      *appsv1.Deployment
+	main     corev1.Container
+	sidecars []corev1.Container
+}
+
+// NewDeployment 创建Deployment构造器
+func NewDeployment() *DeploymentBuilder {
+	return &DeploymentBuilder{obj: &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
+	}}
+}
+
+func (b *DeploymentBuilder) Name(name string) *DeploymentBuilder {
+	b.obj.Name = name
+	return b
+}
+
+func (b *DeploymentBuilder) Namespace(namespace string) *DeploymentBuilder {
+	b.obj.Namespace = namespace
+	return b
+}
+
+// Label 设置Deployment的标签, 同时添加到Pod模板
+func (b *DeploymentBuilder) Label(key, value string) *DeploymentBuilder {
+	setLabel(&b.obj.ObjectMeta, key, value)
+	setLabel(&b.obj.Spec.Template.ObjectMeta, key, value)
+	return b
+}
+
+func (b *DeploymentBuilder) Annotation(key, value string) *DeploymentBuilder {
+	setAnnotation(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+// PodAnnotation 设置Pod模板的annotation
+func (b *DeploymentBuilder) PodAnnotation(key, value string) *DeploymentBuilder {
+	setAnnotation(&b.obj.Spec.Template.ObjectMeta, key, value)
+	return b
+}
+
+// Selector 设置selector的matchLabels, 同时添加到Pod模板标签
+func (b *DeploymentBuilder) Selector(labels map[string]string) *DeploymentBuilder {
+	b.obj.Spec.Selector = &metav1.LabelSelector{MatchLabels: mergeLabels(nil, labels)}
+	b.obj.Spec.Template.Labels = mergeLabels(b.obj.Spec.Template.Labels, labels)
+	return b
+}
+
+func (b *DeploymentBuilder) Replicas(replicas int32) *DeploymentBuilder {
+	b.obj.Spec.Replicas = &replicas
+	return b
+}
+
+// ContainerName 设置主容器名称
+func (b *DeploymentBuilder) ContainerName(name string) *DeploymentBuilder {
+	b.main.Name = name
+	return b
+}
+
+func (b *DeploymentBuilder) Image(image string) *DeploymentBuilder {
+	b.main.Image = image
+	return b
+}
+
+func (b *DeploymentBuilder) ImagePullPolicy(policy corev1.PullPolicy) *DeploymentBuilder {
+	b.main.ImagePullPolicy = policy
+	return b
+}
+
+func (b *DeploymentBuilder) Command(command ...string) *DeploymentBuilder {
+	b.main.Command = command
+	return b
+}
+
+func (b *DeploymentBuilder) Args(args ...string) *DeploymentBuilder {
+	b.main.Args = args
+	return b
+}
+
+// Port 为主容器添加TCP端口
+func (b *DeploymentBuilder) Port(name string, port int32) *DeploymentBuilder {
+	b.main.Ports = append(b.main.Ports, corev1.ContainerPort{Name: name, ContainerPort: port, Protocol: corev1.ProtocolTCP})
+	return b
+}
+
+func (b *DeploymentBuilder) Env(name, value string) *DeploymentBuilder {
+	b.main.Env = append(b.main.Env, corev1.EnvVar{Name: name, Value: value})
+	return b
+}
+
+// EnvFromConfigMap 将ConfigMap的全部键导入主容器环境变量
+func (b *DeploymentBuilder) EnvFromConfigMap(configMap string) *DeploymentBuilder {
+	b.main.EnvFrom = append(b.main.EnvFrom, corev1.EnvFromSource{
+		ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: configMap}},
+	})
+	return b
+}
+
+// Resources 设置主容器的requests与limits, 参数为resource.Quantity格式(如 "100m", "128Mi"), 为空时不设置.
+// 格式错误时panic, 与 resource.MustParse 一致.
+func (b *DeploymentBuilder) Resources(cpuRequest, memoryRequest, cpuLimit, memoryLimit string) *DeploymentBuilder {
+	b.main.Resources.Requests = resourceList(b.main.Resources.Requests, cpuRequest, memoryRequest)
+	b.main.Resources.Limits = resourceList(b.main.Resources.Limits, cpuLimit, memoryLimit)
+	return b
+}
+
+// VolumeMount 为主容器挂载卷, 卷需通过 Volume 添加
+func (b *DeploymentBuilder) VolumeMount(volume, mountPath string, readOnly bool) *DeploymentBuilder {
+	b.main.VolumeMounts = append(b.main.VolumeMounts, corev1.VolumeMount{Name: volume, MountPath: mountPath, ReadOnly: readOnly})
+	return b
+}
+
+func (b *DeploymentBuilder) Volume(volume corev1.Volume) *DeploymentBuilder {
+	b.obj.Spec.Template.Spec.Volumes = append(b.obj.Spec.Template.Spec.Volumes, volume)
+	return b
+}
+
+// Sidecar 添加额外容器, 位于主容器之后
+func (b *DeploymentBuilder) Sidecar(container corev1.Container) *DeploymentBuilder {
+	b.sidecars = append(b.sidecars, container)
+	return b
+}
+
+func (b *DeploymentBuilder) ServiceAccount(name string) *DeploymentBuilder {
+	b.obj.Spec.Template.Spec.ServiceAccountName = name
+	return b
+}
+
+// PodSpec 在Build前修改Pod模板, 用于构造器未覆盖的字段
+func (b *DeploymentBuilder) PodSpec(mutate func(spec *corev1.PodSpec)) *DeploymentBuilder {
+	mutate(&b.obj.Spec.Template.Spec)
+	return b
+}
+
+// Build 返回Deployment, 构造器可继续修改并再次Build
+func (b *DeploymentBuilder) Build() *appsv1.Deployment {
+	obj := b.obj.DeepCopy()
+	if obj.Spec.Selector == nil && len(obj.Name) > 0 {
+		obj.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{AppLabel: obj.Name}}
+		setLabel(&obj.Spec.Template.ObjectMeta, AppLabel, obj.Name)
+	}
+	main := *b.main.DeepCopy()
+	if len(main.Name) == 0 {
+		main.Name = obj.Name
+	}
+	obj.Spec.Template.Spec.Containers = []corev1.Container{main}
+	for i := range b.sidecars {
+		obj.Spec.Template.Spec.Containers = append(obj.Spec.Template.Spec.Containers, *b.sidecars[i].DeepCopy())
+	}
+	return obj
+}
+
+// Unstructured 返回用于apply的unstructured对象
+func (b *DeploymentBuilder) Unstructured() (*unstructured.Unstructured, error) {
+	return ToUnstructured(b.Build())
+}
+
+func resourceList(list corev1.ResourceList, cpu, memory string) corev1.ResourceList {
+	if len(cpu) == 0 && len(memory) == 0 {
+		return list
+	}
+	if list == nil {
+		list = corev1.ResourceList{}
+	}
+	if len(cpu) > 0 {
+		list[corev1.ResourceCPU] = resource.MustParse(cpu)
+	}
+	if len(memory) > 0 {
+		list[corev1.ResourceMemory] = resource.MustParse(memory)
+	}
+	return list
+}
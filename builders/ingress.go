@@ -0,0 +1,81 @@
+package builders
+
+import (
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// IngressBuilder 构造networking.k8s.io/v1 Ingress
+type IngressBuilder struct {
+	obj *networkingv1.Ingress
+}
+
+func NewIngress() *IngressBuilder {
+	return &IngressBuilder{obj: &networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{APIVersion: networkingv1.SchemeGroupVersion.String(), Kind: "Ingress"},
+	}}
+}
+
+func (b *IngressBuilder) Name(name string) *IngressBuilder {
+	b.obj.Name = name
+	return b
+}
+
+func (b *IngressBuilder) Namespace(namespace string) *IngressBuilder {
+	b.obj.Namespace = namespace
+	return b
+}
+
+func (b *IngressBuilder) Label(key, value string) *IngressBuilder {
+	setLabel(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *IngressBuilder) Annotation(key, value string) *IngressBuilder {
+	setAnnotation(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *IngressBuilder) ClassName(className string) *IngressBuilder {
+	b.obj.Spec.IngressClassName = &className
+	return b
+}
+
+// Rule 将host下以path为前缀的请求转发到service的port端口, 同一host的多条规则合并到同一rule
+func (b *IngressBuilder) Rule(host, path, service string, port int32) *IngressBuilder {
+	pathType := networkingv1.PathTypePrefix
+	httpPath := networkingv1.HTTPIngressPath{
+		Path:     path,
+		PathType: &pathType,
+		Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+			Name: service, Port: networkingv1.ServiceBackendPort{Number: port},
+		}},
+	}
+	for i := range b.obj.Spec.Rules {
+		rule := &b.obj.Spec.Rules[i]
+		if rule.Host == host && rule.HTTP != nil {
+			rule.HTTP.Paths = append(rule.HTTP.Paths, httpPath)
+			return b
+		}
+	}
+	b.obj.Spec.Rules = append(b.obj.Spec.Rules, networkingv1.IngressRule{
+		Host:             host,
+		IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: []networkingv1.HTTPIngressPath{httpPath}}},
+	})
+	return b
+}
+
+// TLS 使用secretName中的证书为hosts启用TLS
+func (b *IngressBuilder) TLS(secretName string, hosts ...string) *IngressBuilder {
+	b.obj.Spec.TLS = append(b.obj.Spec.TLS, networkingv1.IngressTLS{SecretName: secretName, Hosts: hosts})
+	return b
+}
+
+func (b *IngressBuilder) Build() *networkingv1.Ingress {
+	return b.obj.DeepCopy()
+}
+
+func (b *IngressBuilder) Unstructured() (*unstructured.Unstructured, error) {
+	return ToUnstructured(b.Build())
+}
@@ -0,0 +1,226 @@
+package builders
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// RoleBuilder 构造Role
+type RoleBuilder struct {
+	obj *rbacv1.Role
+}
+
+func NewRole() *RoleBuilder {
+	return &RoleBuilder{obj: &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+	}}
+}
+
+func (b *RoleBuilder) Name(name string) *RoleBuilder {
+	b.obj.Name = name
+	return b
+}
+
+func (b *RoleBuilder) Namespace(namespace string) *RoleBuilder {
+	b.obj.Namespace = namespace
+	return b
+}
+
+func (b *RoleBuilder) Label(key, value string) *RoleBuilder {
+	setLabel(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *RoleBuilder) Annotation(key, value string) *RoleBuilder {
+	setAnnotation(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+// Rule 添加授权规则, apiGroups中 "" 表示core组
+func (b *RoleBuilder) Rule(apiGroups, resources, verbs []string) *RoleBuilder {
+	b.obj.Rules = append(b.obj.Rules, rbacv1.PolicyRule{APIGroups: apiGroups, Resources: resources, Verbs: verbs})
+	return b
+}
+
+func (b *RoleBuilder) Build() *rbacv1.Role {
+	return b.obj.DeepCopy()
+}
+
+func (b *RoleBuilder) Unstructured() (*unstructured.Unstructured, error) {
+	return ToUnstructured(b.Build())
+}
+
+// ClusterRoleBuilder 构造ClusterRole
+type ClusterRoleBuilder struct {
+	obj *rbacv1.ClusterRole
+}
+
+func NewClusterRole() *ClusterRoleBuilder {
+	return &ClusterRoleBuilder{obj: &rbacv1.ClusterRole{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+	}}
+}
+
+func (b *ClusterRoleBuilder) Name(name string) *ClusterRoleBuilder {
+	b.obj.Name = name
+	return b
+}
+
+func (b *ClusterRoleBuilder) Label(key, value string) *ClusterRoleBuilder {
+	setLabel(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *ClusterRoleBuilder) Annotation(key, value string) *ClusterRoleBuilder {
+	setAnnotation(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+// Rule 添加授权规则, apiGroups中 "" 表示core组
+func (b *ClusterRoleBuilder) Rule(apiGroups, resources, verbs []string) *ClusterRoleBuilder {
+	b.obj.Rules = append(b.obj.Rules, rbacv1.PolicyRule{APIGroups: apiGroups, Resources: resources, Verbs: verbs})
+	return b
+}
+
+// NonResourceRule 添加非资源URL(如 /healthz)的授权规则
+func (b *ClusterRoleBuilder) NonResourceRule(urls, verbs []string) *ClusterRoleBuilder {
+	b.obj.Rules = append(b.obj.Rules, rbacv1.PolicyRule{NonResourceURLs: urls, Verbs: verbs})
+	return b
+}
+
+func (b *ClusterRoleBuilder) Build() *rbacv1.ClusterRole {
+	return b.obj.DeepCopy()
+}
+
+func (b *ClusterRoleBuilder) Unstructured() (*unstructured.Unstructured, error) {
+	return ToUnstructured(b.Build())
+}
+
+// RoleBindingBuilder 构造RoleBinding, 可绑定Role或ClusterRole
+type RoleBindingBuilder struct {
+	obj *rbacv1.RoleBinding
+}
+
+func NewRoleBinding() *RoleBindingBuilder {
+	return &RoleBindingBuilder{obj: &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+	}}
+}
+
+func (b *RoleBindingBuilder) Name(name string) *RoleBindingBuilder {
+	b.obj.Name = name
+	return b
+}
+
+func (b *RoleBindingBuilder) Namespace(namespace string) *RoleBindingBuilder {
+	b.obj.Namespace = namespace
+	return b
+}
+
+func (b *RoleBindingBuilder) Label(key, value string) *RoleBindingBuilder {
+	setLabel(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *RoleBindingBuilder) Annotation(key, value string) *RoleBindingBuilder {
+	setAnnotation(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+// Role 绑定同namespace下的Role
+func (b *RoleBindingBuilder) Role(name string) *RoleBindingBuilder {
+	b.obj.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name}
+	return b
+}
+
+// ClusterRole 在RoleBinding所在namespace内授予ClusterRole的权限
+func (b *RoleBindingBuilder) ClusterRole(name string) *RoleBindingBuilder {
+	b.obj.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name}
+	return b
+}
+
+// ServiceAccount 添加ServiceAccount主体, namespace为空时使用RoleBinding的namespace
+func (b *RoleBindingBuilder) ServiceAccount(namespace, name string) *RoleBindingBuilder {
+	b.obj.Subjects = append(b.obj.Subjects, rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: namespace, Name: name})
+	return b
+}
+
+func (b *RoleBindingBuilder) User(name string) *RoleBindingBuilder {
+	b.obj.Subjects = append(b.obj.Subjects, rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: name})
+	return b
+}
+
+func (b *RoleBindingBuilder) Group(name string) *RoleBindingBuilder {
+	b.obj.Subjects = append(b.obj.Subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: name})
+	return b
+}
+
+func (b *RoleBindingBuilder) Build() *rbacv1.RoleBinding {
+	obj := b.obj.DeepCopy()
+	for i := range obj.Subjects {
+		if obj.Subjects[i].Kind == rbacv1.ServiceAccountKind && len(obj.Subjects[i].Namespace) == 0 {
+			obj.Subjects[i].Namespace = obj.Namespace
+		}
+	}
+	return obj
+}
+
+func (b *RoleBindingBuilder) Unstructured() (*unstructured.Unstructured, error) {
+	return ToUnstructured(b.Build())
+}
+
+// ClusterRoleBindingBuilder 构造ClusterRoleBinding
+type ClusterRoleBindingBuilder struct {
+	obj *rbacv1.ClusterRoleBinding
+}
+
+func NewClusterRoleBinding() *ClusterRoleBindingBuilder {
+	return &ClusterRoleBindingBuilder{obj: &rbacv1.ClusterRoleBinding{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+	}}
+}
+
+func (b *ClusterRoleBindingBuilder) Name(name string) *ClusterRoleBindingBuilder {
+	b.obj.Name = name
+	return b
+}
+
+func (b *ClusterRoleBindingBuilder) Label(key, value string) *ClusterRoleBindingBuilder {
+	setLabel(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *ClusterRoleBindingBuilder) Annotation(key, value string) *ClusterRoleBindingBuilder {
+	setAnnotation(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *ClusterRoleBindingBuilder) ClusterRole(name string) *ClusterRoleBindingBuilder {
+	b.obj.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name}
+	return b
+}
+
+// ServiceAccount 添加ServiceAccount主体, ClusterRoleBinding的ServiceAccount主体必须指定namespace
+func (b *ClusterRoleBindingBuilder) ServiceAccount(namespace, name string) *ClusterRoleBindingBuilder {
+	b.obj.Subjects = append(b.obj.Subjects, rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: namespace, Name: name})
+	return b
+}
+
+func (b *ClusterRoleBindingBuilder) User(name string) *ClusterRoleBindingBuilder {
+	b.obj.Subjects = append(b.obj.Subjects, rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: name})
+	return b
+}
+
+func (b *ClusterRoleBindingBuilder) Group(name string) *ClusterRoleBindingBuilder {
+	b.obj.Subjects = append(b.obj.Subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: name})
+	return b
+}
+
+func (b *ClusterRoleBindingBuilder) Build() *rbacv1.ClusterRoleBinding {
+	return b.obj.DeepCopy()
+}
+
+func (b *ClusterRoleBindingBuilder) Unstructured() (*unstructured.Unstructured, error) {
+	return ToUnstructured(b.Build())
+}
@@ -0,0 +1,57 @@
+package builders
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ServiceAccountBuilder 构造ServiceAccount
+type ServiceAccountBuilder struct {
+	obj *corev1.ServiceAccount
+}
+
+func NewServiceAccount() *ServiceAccountBuilder {
+	return &ServiceAccountBuilder{obj: &corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "ServiceAccount"},
+	}}
+}
+
+func (b *ServiceAccountBuilder) Name(name string) *ServiceAccountBuilder {
+	b.obj.Name = name
+	return b
+}
+
+func (b *ServiceAccountBuilder) Namespace(namespace string) *ServiceAccountBuilder {
+	b.obj.Namespace = namespace
+	return b
+}
+
+func (b *ServiceAccountBuilder) Label(key, value string) *ServiceAccountBuilder {
+	setLabel(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *ServiceAccountBuilder) Annotation(key, value string) *ServiceAccountBuilder {
+	setAnnotation(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *ServiceAccountBuilder) ImagePullSecret(name string) *ServiceAccountBuilder {
+	b.obj.ImagePullSecrets = append(b.obj.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+	return b
+}
+
+// AutomountToken 设置是否自动挂载token
+func (b *ServiceAccountBuilder) AutomountToken(automount bool) *ServiceAccountBuilder {
+	b.obj.AutomountServiceAccountToken = &automount
+	return b
+}
+
+func (b *ServiceAccountBuilder) Build() *corev1.ServiceAccount {
+	return b.obj.DeepCopy()
+}
+
+func (b *ServiceAccountBuilder) Unstructured() (*unstructured.Unstructured, error) {
+	return ToUnstructured(b.Build())
+}
@@ -0,0 +1,85 @@
+package builders
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ServiceBuilder 构造Service, 未调用 Selector 时使用 app=<name>, 与 DeploymentBuilder 的默认值对应
+type ServiceBuilder struct {
+	obj *corev1.Service
+}
+
+// NewService 创建Service构造器, 默认类型为ClusterIP
+func NewService() *ServiceBuilder {
+	return &ServiceBuilder{obj: &corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Service"},
+		Spec:     corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+	}}
+}
+
+func (b *ServiceBuilder) Name(name string) *ServiceBuilder {
+	b.obj.Name = name
+	return b
+}
+
+func (b *ServiceBuilder) Namespace(namespace string) *ServiceBuilder {
+	b.obj.Namespace = namespace
+	return b
+}
+
+func (b *ServiceBuilder) Label(key, value string) *ServiceBuilder {
+	setLabel(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *ServiceBuilder) Annotation(key, value string) *ServiceBuilder {
+	setAnnotation(&b.obj.ObjectMeta, key, value)
+	return b
+}
+
+func (b *ServiceBuilder) Selector(labels map[string]string) *ServiceBuilder {
+	b.obj.Spec.Selector = mergeLabels(nil, labels)
+	return b
+}
+
+func (b *ServiceBuilder) Type(serviceType corev1.ServiceType) *ServiceBuilder {
+	b.obj.Spec.Type = serviceType
+	return b
+}
+
+// Headless 设置clusterIP为None
+func (b *ServiceBuilder) Headless() *ServiceBuilder {
+	b.obj.Spec.ClusterIP = corev1.ClusterIPNone
+	return b
+}
+
+// Port 添加TCP端口, targetPort为容器端口号
+func (b *ServiceBuilder) Port(name string, port, targetPort int32) *ServiceBuilder {
+	b.obj.Spec.Ports = append(b.obj.Spec.Ports, corev1.ServicePort{
+		Name: name, Port: port, TargetPort: intstr.FromInt32(targetPort), Protocol: corev1.ProtocolTCP,
+	})
+	return b
+}
+
+// NamedPort 添加TCP端口, targetPort为容器端口名称
+func (b *ServiceBuilder) NamedPort(name string, port int32, targetPort string) *ServiceBuilder {
+	b.obj.Spec.Ports = append(b.obj.Spec.Ports, corev1.ServicePort{
+		Name: name, Port: port, TargetPort: intstr.FromString(targetPort), Protocol: corev1.ProtocolTCP,
+	})
+	return b
+}
+
+func (b *ServiceBuilder) Build() *corev1.Service {
+	obj := b.obj.DeepCopy()
+	if obj.Spec.Selector == nil && len(obj.Name) > 0 {
+		obj.Spec.Selector = map[string]string{AppLabel: obj.Name}
+	}
+	return obj
+}
+
+func (b *ServiceBuilder) Unstructured() (*unstructured.Unstructured, error) {
+	return ToUnstructured(b.Build())
+}
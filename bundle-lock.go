@@ -0,0 +1,253 @@
+package k8sclientkit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+const (
+	// DefaultBundleLockLeaseDuration 为bundle锁Lease的默认有效期, 持有者在有效期的1/3处续约
+	DefaultBundleLockLeaseDuration = 30 * time.Second
+	// DefaultBundleLockRetryInterval 为锁被占用时重试获取的默认间隔
+	DefaultBundleLockRetryInterval = 2 * time.Second
+	// BundleLockAnnotation 在Lease上记录对应的bundle标识
+	BundleLockAnnotation = "k8s-client-kit.io/bundle-id"
+)
+
+// ErrBundleLockLost 表示持有期间Lease续约失败或被其他持有者接管, 受保护的操作应立即停止
+var ErrBundleLockLost = errors.New("bundle锁已丢失")
+
+// BundleLockOptions 为 LockBundle 的参数, 零值均使用默认值
+type BundleLockOptions struct {
+	// Namespace 为Lease所在namespace, 默认为 `default`
+	Namespace string
+	// Holder 为持有者标识, 出现在Lease的holderIdentity中, 默认为 `<hostname>-<随机uuid>`
+	Holder string
+	// LeaseDuration 为Lease有效期, 默认为 DefaultBundleLockLeaseDuration. 持有者进程异常退出后, 其他持有者需等待该时长才能接管
+	LeaseDuration time.Duration
+	// RetryInterval 为锁被占用时的重试间隔, 默认为 DefaultBundleLockRetryInterval
+	RetryInterval time.Duration
+}
+
+// BundleLock 是基于coordination.k8s.io Lease的bundle互斥锁, 用于在多个副本或流水线并发apply同一bundle时
+// 保护inventory更新与prune, 避免一方prune掉另一方刚刚apply的资源.
+type BundleLock struct {
+	BundleID  string
+	Namespace string
+	LeaseName string
+	Holder    string
+
+	client   *GenericK8sClient
+	duration time.Duration
+	lost     context.Context
+	markLost context.CancelFunc
+	stopped  chan struct{}
+	once     sync.Once
+}
+
+// LockBundle 获取bundleID对应的锁, 阻塞直至获得锁或ctx结束. 持有期间后台自动续约, 使用完毕后必须调用 Unlock.
+// Lease名称由bundleID的hash生成, 因此任意格式的bundleID均可使用.
+func (c *GenericK8sClient) LockBundle(ctx context.Context, bundleID string, opts BundleLockOptions) (*BundleLock, error) {
+	if len(opts.Namespace) == 0 {
+		opts.Namespace = metav1.NamespaceDefault
+	}
+	if len(opts.Holder) == 0 {
+		hostname, _ := os.Hostname()
+		opts.Holder = hostname + "-" + string(uuid.NewUUID())
+	}
+	if opts.LeaseDuration <= 0 {
+		opts.LeaseDuration = DefaultBundleLockLeaseDuration
+	}
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = DefaultBundleLockRetryInterval
+	}
+	sum := sha256.Sum256([]byte(bundleID))
+	lock := &BundleLock{
+		BundleID:  bundleID,
+		Namespace: opts.Namespace,
+		LeaseName: "bundle-lock-" + hex.EncodeToString(sum[:])[:16],
+		Holder:    opts.Holder,
+		client:    c,
+		duration:  opts.LeaseDuration,
+		stopped:   make(chan struct{}),
+	}
+
+	for {
+		acquired, err := lock.tryAcquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "等待bundle "+bundleID+" 的锁超时")
+		case <-time.After(opts.RetryInterval):
+		}
+	}
+
+	lock.lost, lock.markLost = context.WithCancel(context.Background())
+	c.group.goRoutine("bundle-lock:"+bundleID, lock.renewLoop)
+	if c.group.ctx.Err() != nil {
+		// 客户端已Close, 无法续约
+		lock.markLost()
+	}
+	return lock, nil
+}
+
+// WithBundleLock 持有bundleID对应的锁执行fn, fn返回后释放锁. 锁在执行期间丢失时fn的ctx被取消, 并返回 ErrBundleLockLost.
+func (c *GenericK8sClient) WithBundleLock(ctx context.Context, bundleID string, opts BundleLockOptions, fn func(ctx context.Context) error) error {
+	lock, err := c.LockBundle(ctx, bundleID, opts)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock(context.Background())
+
+	fnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(lock.lost, cancel)
+	defer stop()
+	err = fn(fnCtx)
+	if lock.lost.Err() != nil && ctx.Err() == nil {
+		return errors.Wrap(ErrBundleLockLost, "bundle "+bundleID)
+	}
+	return err
+}
+
+// Lost 返回在锁丢失(续约失败, 被接管, 客户端Close或Unlock)时关闭的channel
+func (l *BundleLock) Lost() <-chan struct{} {
+	return l.lost.Done()
+}
+
+// Unlock 停止续约并释放Lease, 使其他等待者无需等待Lease过期即可获得锁. 可重复调用.
+func (l *BundleLock) Unlock(ctx context.Context) error {
+	var err error
+	l.once.Do(func() {
+		close(l.stopped)
+		l.markLost()
+
+		leases := l.client.GetStandardClient().CoordinationV1().Leases(l.Namespace)
+		lease, getErr := leases.Get(ctx, l.LeaseName, metav1.GetOptions{})
+		if getErr != nil {
+			err = errors.Wrap(getErr, "无法读取bundle锁Lease")
+			return
+		}
+		if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.Holder {
+			// 已被其他持有者接管
+			return
+		}
+		lease.Spec.HolderIdentity = nil
+		lease.Spec.RenewTime = nil
+		if _, updateErr := leases.Update(ctx, lease, metav1.UpdateOptions{}); updateErr != nil && !apierrors.IsConflict(updateErr) {
+			err = errors.Wrap(updateErr, "释放bundle锁失败")
+		}
+	})
+	return err
+}
+
+// tryAcquire 尝试获取或续约Lease, 基于resourceVersion的乐观并发保证同一时刻只有一个持有者
+func (l *BundleLock) tryAcquire(ctx context.Context) (bool, error) {
+	leases := l.client.GetStandardClient().CoordinationV1().Leases(l.Namespace)
+	now := metav1.NewMicroTime(time.Now())
+	durationSeconds := int32(l.duration / time.Second)
+
+	lease, err := leases.Get(ctx, l.LeaseName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        l.LeaseName,
+				Namespace:   l.Namespace,
+				Labels:      map[string]string{ManagedByLabel: ManagedByValue},
+				Annotations: map[string]string{BundleLockAnnotation: l.BundleID},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &l.Holder,
+				LeaseDurationSeconds: &durationSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, errors.Wrap(err, "创建bundle锁Lease失败")
+		}
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "无法读取bundle锁Lease")
+	}
+
+	heldBySelf := lease.Spec.HolderIdentity != nil && *lease.Spec.HolderIdentity == l.Holder
+	if !heldBySelf && !leaseExpired(lease) {
+		return false, nil
+	}
+	if !heldBySelf {
+		lease.Spec.AcquireTime = &now
+	}
+	lease.Spec.HolderIdentity = &l.Holder
+	lease.Spec.LeaseDurationSeconds = &durationSeconds
+	lease.Spec.RenewTime = &now
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "更新bundle锁Lease失败")
+	}
+	return true, nil
+}
+
+// renewLoop 定期续约, 续约在Lease过期前仍未成功或Lease被接管时标记锁丢失. 客户端Close导致续约停止时同样视为锁丢失.
+func (l *BundleLock) renewLoop(ctx context.Context) error {
+	defer l.markLost()
+	interval := l.duration / 3
+	lastRenew := time.Now()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-l.stopped:
+			return nil
+		case <-ticker.C:
+		}
+
+		renewCtx, cancel := context.WithTimeout(ctx, interval)
+		acquired, err := l.tryAcquire(renewCtx)
+		cancel()
+		switch {
+		case acquired:
+			lastRenew = time.Now()
+		case err == nil:
+			// 已被其他持有者接管
+			return errors.Wrap(ErrBundleLockLost, "bundle "+l.BundleID+" 的Lease已被其他持有者接管")
+		case time.Since(lastRenew) >= l.duration:
+			return errors.Wrap(ErrBundleLockLost, "bundle "+l.BundleID+" 续约失败: "+err.Error())
+		}
+	}
+}
+
+// leaseExpired Lease无持有者或超过有效期未续约
+func leaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.HolderIdentity == nil || len(*lease.Spec.HolderIdentity) == 0 {
+		return true
+	}
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return time.Now().After(expiry)
+}
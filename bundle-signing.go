@@ -0,0 +1,183 @@
+package k8sclientkit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// BundleSignatureAlgorithm 为 BundleSignature 使用的签名算法
+const BundleSignatureAlgorithm = "ed25519"
+
+var (
+	// ErrBundleSignatureInvalid 签名与bundle内容不匹配, 或签名不是由任何受信任的公钥签发
+	ErrBundleSignatureInvalid = errors.New("bundle签名校验失败")
+)
+
+// BundleSignature 为bundle(或snapshot)的分离式签名, 可单独保存为文件(如 `<bundle>.sig`)随bundle分发.
+// 签名的内容为 BundleDigest, 与对象的顺序及文件格式(YAML/JSON, 单文件或目录)无关.
+type BundleSignature struct {
+	Algorithm string `json:"algorithm"`
+	// KeyID 为签名公钥的标识(见 Ed25519KeyID), 便于在多个受信任公钥中定位
+	KeyID string `json:"keyId"`
+	// Digest 为签名时bundle的摘要(十六进制)
+	Digest    string    `json:"digest"`
+	Signature []byte    `json:"signature"`
+	SignedAt  time.Time `json:"signedAt"`
+}
+
+// BundleDigest 计算一组对象的摘要: 每个对象以确定的JSON序列化后计算sha256, 排序后再整体计算sha256.
+// 对象的任何字段(包括label, annotation)变化都会改变摘要, 因此应在添加bundle label等修改前计算.
+func BundleDigest(objs []*unstructured.Unstructured) (string, error) {
+	hashes := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		// encoding/json 对map按key排序输出, 结果是确定的
+		data, err := json.Marshal(obj.Object)
+		if err != nil {
+			return "", errors.Wrap(err, "无法序列化对象 "+ObjectIdentity(obj))
+		}
+		sum := sha256.Sum256(data)
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+	sort.Strings(hashes)
+	h := sha256.New()
+	for _, hash := range hashes {
+		h.Write([]byte(hash))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SignBundle 以Ed25519私钥对objs签名
+func SignBundle(objs []*unstructured.Unstructured, key ed25519.PrivateKey) (*BundleSignature, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, errors.New("无效的Ed25519私钥")
+	}
+	digest, err := BundleDigest(objs)
+	if err != nil {
+		return nil, err
+	}
+	return &BundleSignature{
+		Algorithm: BundleSignatureAlgorithm,
+		KeyID:     Ed25519KeyID(key.Public().(ed25519.PublicKey)),
+		Digest:    digest,
+		Signature: ed25519.Sign(key, []byte(digest)),
+		SignedAt:  time.Now().UTC(),
+	}, nil
+}
+
+// VerifyBundle 校验签名与objs的内容匹配, 且由trustedKeys中的任一公钥签发. 校验失败时返回 ErrBundleSignatureInvalid.
+func VerifyBundle(objs []*unstructured.Unstructured, sig *BundleSignature, trustedKeys ...ed25519.PublicKey) error {
+	if sig == nil {
+		return errors.Wrap(ErrBundleSignatureInvalid, "缺少签名")
+	}
+	if sig.Algorithm != BundleSignatureAlgorithm {
+		return errors.Wrap(ErrBundleSignatureInvalid, "不支持的签名算法 "+sig.Algorithm)
+	}
+	digest, err := BundleDigest(objs)
+	if err != nil {
+		return err
+	}
+	if digest != sig.Digest {
+		return errors.Wrap(ErrBundleSignatureInvalid, "bundle内容已被修改")
+	}
+	for _, key := range trustedKeys {
+		if len(key) == ed25519.PublicKeySize && ed25519.Verify(key, []byte(sig.Digest), sig.Signature) {
+			return nil
+		}
+	}
+	return errors.Wrap(ErrBundleSignatureInvalid, "签名不是由受信任的公钥签发, keyId "+sig.KeyID)
+}
+
+// Save 将签名以JSON写入w
+func (s *BundleSignature) Save(w io.Writer) error {
+	return EncoderJSON.Encode(w, s)
+}
+
+// LoadBundleSignature 读取 BundleSignature.Save 写入的签名
+func LoadBundleSignature(r io.Reader) (*BundleSignature, error) {
+	sig := &BundleSignature{}
+	if err := EncoderJSON.Decode(r, sig); err != nil {
+		return nil, errors.Wrap(err, "无法解析bundle签名")
+	}
+	return sig, nil
+}
+
+// Ed25519KeyID 返回公钥的标识: 公钥sha256的前8字节(十六进制)
+func Ed25519KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// GenerateBundleSigningKey 生成Ed25519密钥对, 返回PEM编码的公钥(PKIX)与私钥(PKCS#8)
+func GenerateBundleSigningKey() (publicKeyPEM, privateKeyPEM []byte, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "无法生成Ed25519密钥")
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "无法编码公钥")
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "无法编码私钥")
+	}
+	var pubBuf, privBuf bytes.Buffer
+	_ = pem.Encode(&pubBuf, &pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+	_ = pem.Encode(&privBuf, &pem.Block{Type: "PRIVATE KEY", Bytes: privDER})
+	return pubBuf.Bytes(), privBuf.Bytes(), nil
+}
+
+// ParseEd25519PrivateKey 解析PEM编码(PKCS#8)的Ed25519私钥
+func ParseEd25519PrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("私钥不是PEM格式")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法解析私钥")
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("私钥不是Ed25519私钥")
+	}
+	return edKey, nil
+}
+
+// ParseEd25519PublicKeys 解析PEM编码(PKIX)的Ed25519公钥, data中可包含多个公钥
+func ParseEd25519PublicKeys(data []byte) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法解析公钥")
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("公钥不是Ed25519公钥")
+		}
+		keys = append(keys, edKey)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("未找到PEM格式的公钥")
+	}
+	return keys, nil
+}
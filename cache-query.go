@@ -0,0 +1,365 @@
+package k8sclientkit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// QueryOperator 为Query过滤条件的比较运算符
+type QueryOperator string
+
+const (
+	QueryOpEqual          QueryOperator = "="
+	QueryOpNotEqual       QueryOperator = "!="
+	QueryOpGreater        QueryOperator = ">"
+	QueryOpGreaterOrEqual QueryOperator = ">="
+	QueryOpLess           QueryOperator = "<"
+	QueryOpLessOrEqual    QueryOperator = "<="
+	QueryOpContains       QueryOperator = "contains"
+	QueryOpExists         QueryOperator = "exists"
+)
+
+// QueryRow 为Query的一行结果
+type QueryRow struct {
+	// Key 为源对象的 namespace/name
+	Key string
+	// Fields 为Select指定的字段值, 未调用Select时为空
+	Fields map[string]interface{}
+	// Object 为源对象(*unstructured.Unstructured 或 *ViewRow)
+	Object interface{}
+}
+
+// Query 是在informer缓存或View上执行的简单查询, 支持过滤, 投影, 排序与限制条数, 不会访问APIServer.
+//
+// 字段使用JSONPath表示, 如 `{.status.phase}` 或 `.metadata.namespace`.
+// 对于返回多个值的路径, 可使用聚合函数 sum(), max(), min(), count(). 路径没有数值时 sum(), max(), min() 的结果为nil
+// (与字段不存在相同, 可用 QueryOpExists 过滤, 排序时排在最前), count() 为0. 例如:
+//
+//	NewQuery().
+//		Where(".metadata.namespace", QueryOpEqual, "x").
+//		Select(".metadata.name", "sum(.status.containerStatuses[*].restartCount)").
+//		OrderBy("sum(.status.containerStatuses[*].restartCount)", true).
+//		Limit(20)
+type Query struct {
+	conditions  []queryCondition
+	projections []*queryField
+	orderBy     *queryField
+	descending  bool
+	limit       int
+	err         error
+}
+
+type queryCondition struct {
+	field *queryField
+	op    QueryOperator
+	value interface{}
+}
+
+type queryField struct {
+	expr      string
+	aggregate string
+	// paths 缓存解析后的JSONPath. *jsonpath.JSONPath 求值时会修改内部状态, 不能并发使用,
+	// 因此每次求值从中取得独立的实例, 使同一Query可以在多个goroutine中同时Run
+	paths sync.Pool
+}
+
+var queryAggregateRegexp = regexp.MustCompile(`^(sum|max|min|count)\((.+)\)$`)
+
+// NewQuery 创建一个空查询, 默认返回所有对象
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Where 添加一个过滤条件, 多个条件之间为AND关系
+func (q *Query) Where(path string, op QueryOperator, value interface{}) *Query {
+	field := q.parseField(path)
+	if field != nil {
+		q.conditions = append(q.conditions, queryCondition{field: field, op: op, value: value})
+	}
+	return q
+}
+
+// Select 指定结果中需要投影的字段, 结果字段名为表达式本身
+func (q *Query) Select(paths ...string) *Query {
+	for _, path := range paths {
+		if field := q.parseField(path); field != nil {
+			q.projections = append(q.projections, field)
+		}
+	}
+	return q
+}
+
+// OrderBy 按指定字段排序, 数值按大小比较, 其余按字符串比较
+func (q *Query) OrderBy(path string, descending bool) *Query {
+	q.orderBy = q.parseField(path)
+	q.descending = descending
+	return q
+}
+
+// Limit 限制返回的最大行数, 0表示不限制
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+func (q *Query) parseField(expr string) *queryField {
+	field := &queryField{expr: expr}
+	path := strings.TrimSpace(expr)
+	if m := queryAggregateRegexp.FindStringSubmatch(path); m != nil {
+		field.aggregate = m[1]
+		path = strings.TrimSpace(m[2])
+	}
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+
+	parsed := jsonpath.New(expr).AllowMissingKeys(true)
+	if err := parsed.Parse(path); err != nil {
+		if q.err == nil {
+			q.err = errors.Wrap(err, "无效的查询字段:"+expr)
+		}
+		return nil
+	}
+	field.paths.New = func() interface{} {
+		p := jsonpath.New(expr).AllowMissingKeys(true)
+		// 模板已在parseField中校验
+		_ = p.Parse(path)
+		return p
+	}
+	field.paths.Put(parsed)
+	return field
+}
+
+// Run 在给定对象集合上执行查询, objs 通常来自informer store 或 View
+func (q *Query) Run(objs []interface{}) ([]QueryRow, error) {
+	if q.err != nil {
+		return nil, q.err
+	}
+
+	type candidate struct {
+		row     QueryRow
+		orderBy interface{}
+	}
+	var candidates []candidate
+	for _, obj := range objs {
+		key, data, err := queryData(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		matched := true
+		for _, cond := range q.conditions {
+			if !cond.match(cond.field.eval(data)) {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		row := QueryRow{Key: key, Object: obj}
+		if len(q.projections) > 0 {
+			row.Fields = make(map[string]interface{}, len(q.projections))
+			for _, field := range q.projections {
+				row.Fields[field.expr] = field.eval(data)
+			}
+		}
+		c := candidate{row: row}
+		if q.orderBy != nil {
+			c.orderBy = q.orderBy.eval(data)
+		}
+		candidates = append(candidates, c)
+	}
+
+	if q.orderBy != nil {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if q.descending {
+				return compareQueryValues(candidates[j].orderBy, candidates[i].orderBy) < 0
+			}
+			return compareQueryValues(candidates[i].orderBy, candidates[j].orderBy) < 0
+		})
+	}
+	if q.limit > 0 && len(candidates) > q.limit {
+		candidates = candidates[:q.limit]
+	}
+
+	rows := make([]QueryRow, 0, len(candidates))
+	for _, c := range candidates {
+		rows = append(rows, c.row)
+	}
+	return rows, nil
+}
+
+// Query 在watcher的informer缓存上执行查询
+func (w *K8sResourceWatcher) Query(q *Query) ([]QueryRow, error) {
+	return q.Run(w.informer.Informer().GetStore().List())
+}
+
+// Query 在视图上执行查询, 字段路径相对于ViewRow.Fields, 如 `{.node}`
+func (v *View) Query(q *Query) ([]QueryRow, error) {
+	return q.Run(v.store.List())
+}
+
+// queryData 将缓存中的对象转换为JSONPath可求值的数据
+func queryData(obj interface{}) (string, interface{}, error) {
+	switch o := obj.(type) {
+	case *ViewRow:
+		return o.Key, o.Fields, nil
+	case *unstructured.Unstructured:
+		key, _ := cache.MetaNamespaceKeyFunc(o)
+		return key, o.Object, nil
+	case runtime.Object:
+		key, _ := cache.MetaNamespaceKeyFunc(o)
+		data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "无法转换对象用于查询")
+		}
+		return key, data, nil
+	default:
+		return "", nil, errors.Errorf("不支持查询的对象类型: %T", obj)
+	}
+}
+
+// eval 求字段值: 无结果为nil, 单个结果为该值, 多个结果为切片或聚合值. sum, max, min 没有数值时为nil
+func (f *queryField) eval(data interface{}) interface{} {
+	path := f.paths.Get().(*jsonpath.JSONPath)
+	defer f.paths.Put(path)
+	results, err := path.FindResults(data)
+	if err != nil {
+		return nil
+	}
+	var values []interface{}
+	for _, result := range results {
+		for _, v := range result {
+			if v.IsValid() && v.CanInterface() {
+				values = append(values, v.Interface())
+			}
+		}
+	}
+
+	switch f.aggregate {
+	case "count":
+		return int64(len(values))
+	case "sum", "max", "min":
+		var acc float64
+		first := true
+		for _, v := range values {
+			n, ok := toFloat(v)
+			if !ok {
+				continue
+			}
+			switch {
+			case f.aggregate == "sum":
+				acc += n
+			case first, f.aggregate == "max" && n > acc, f.aggregate == "min" && n < acc:
+				acc = n
+			}
+			first = false
+		}
+		if first {
+			// 没有数值时与字段不存在一致, 不以0表示, 避免与实际为0的结果混淆
+			return nil
+		}
+		return acc
+	}
+
+	switch len(values) {
+	case 0:
+		return nil
+	case 1:
+		return values[0]
+	default:
+		return values
+	}
+}
+
+func (c queryCondition) match(actual interface{}) bool {
+	switch c.op {
+	case QueryOpExists:
+		return actual != nil
+	case QueryOpEqual:
+		return actual != nil && compareQueryValues(actual, c.value) == 0
+	case QueryOpNotEqual:
+		return actual == nil || compareQueryValues(actual, c.value) != 0
+	case QueryOpGreater:
+		return actual != nil && compareQueryValues(actual, c.value) > 0
+	case QueryOpGreaterOrEqual:
+		return actual != nil && compareQueryValues(actual, c.value) >= 0
+	case QueryOpLess:
+		return actual != nil && compareQueryValues(actual, c.value) < 0
+	case QueryOpLessOrEqual:
+		return actual != nil && compareQueryValues(actual, c.value) <= 0
+	case QueryOpContains:
+		if list, ok := actual.([]interface{}); ok {
+			for _, item := range list {
+				if compareQueryValues(item, c.value) == 0 {
+					return true
+				}
+			}
+			return false
+		}
+		return actual != nil && strings.Contains(fmt.Sprintf("%v", actual), fmt.Sprintf("%v", c.value))
+	default:
+		return false
+	}
+}
+
+// compareQueryValues 比较两个值, 均为数值时按数值比较, 否则按字符串比较; nil 小于任何值
+func compareQueryValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if na, ok := toFloat(a); ok {
+		if nb, ok := toFloat(b); ok {
+			switch {
+			case na < nb:
+				return -1
+			case na > nb:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
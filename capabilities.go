@@ -0,0 +1,239 @@
+package k8sclientkit
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+)
+
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// APIGroupCapability 为集群提供的一个API组
+type APIGroupCapability struct {
+	// Name 为组名, core组为 ""
+	Name             string
+	PreferredVersion string
+	// Versions 按APIServer的优先级排列
+	Versions []string
+	// Resources 为各版本提供的资源(不含子资源), key为版本
+	Resources map[string][]string
+}
+
+// CRDCapability 为集群中安装的一个CRD
+type CRDCapability struct {
+	Name   string
+	Group  string
+	Kind   string
+	Plural string
+	// Scope 为 Namespaced 或 Cluster
+	Scope string
+	// ServedVersions 为提供服务的版本, StorageVersion 为存储版本
+	ServedVersions []string
+	StorageVersion string
+	// Established 表示CRD已被APIServer接受并可使用
+	Established bool
+}
+
+// ClusterCapabilities 为集群能力信息, 用于按集群选择manifest的变体(如PodDisruptionBudget使用policy/v1还是v1beta1)
+type ClusterCapabilities struct {
+	TargetK8sApiServerId string
+	ServerVersion        *apimachineryversion.Info
+	// APIGroups key为组名, core组为 ""
+	APIGroups map[string]*APIGroupCapability
+	// CRDs 按名称排序, 无权限list CRD时为nil, 原因记录在 Errors 中
+	CRDs []*CRDCapability
+
+	// 以下为常用资源在该集群中的首选版本(组/版本), 不支持时为空
+	EndpointSliceVersion           string
+	PodDisruptionBudgetVersion     string
+	IngressVersion                 string
+	HorizontalPodAutoscalerVersion string
+	CronJobVersion                 string
+	// GatewayAPIVersion 为Gateway API(gateways资源)的版本, 未安装时为空
+	GatewayAPIVersion string
+
+	// Errors 为部分失败(如某个聚合API不可用, 无权限list CRD), 其余信息仍然有效
+	Errors []error
+	// CollectedAt 为采集时间
+	CollectedAt time.Time
+}
+
+// HasGroupVersion 集群是否提供groupVersion(如 "policy/v1", core组为 "v1")
+func (c *ClusterCapabilities) HasGroupVersion(groupVersion string) bool {
+	gv, err := schema.ParseGroupVersion(groupVersion)
+	if err != nil {
+		return false
+	}
+	group, ok := c.APIGroups[gv.Group]
+	if !ok {
+		return false
+	}
+	for _, version := range group.Versions {
+		if version == gv.Version {
+			return true
+		}
+	}
+	return false
+}
+
+// HasResource 集群是否提供gvr
+func (c *ClusterCapabilities) HasResource(gvr schema.GroupVersionResource) bool {
+	group, ok := c.APIGroups[gvr.Group]
+	if !ok {
+		return false
+	}
+	for _, resource := range group.Resources[gvr.Version] {
+		if resource == gvr.Resource {
+			return true
+		}
+	}
+	return false
+}
+
+// ResourceVersion 返回提供resource的首选组/版本(如 "policy/v1"): 组的首选版本提供该资源时使用首选版本,
+// 否则使用按优先级排列的第一个提供该资源的版本. 不支持时返回空.
+func (c *ClusterCapabilities) ResourceVersion(group, resource string) string {
+	apiGroup, ok := c.APIGroups[group]
+	if !ok {
+		return ""
+	}
+	versions := append([]string{apiGroup.PreferredVersion}, apiGroup.Versions...)
+	for _, version := range versions {
+		if c.HasResource(schema.GroupVersionResource{Group: group, Version: version, Resource: resource}) {
+			return schema.GroupVersion{Group: group, Version: version}.String()
+		}
+	}
+	return ""
+}
+
+// CRD 按名称(如 "certificates.cert-manager.io")查找CRD
+func (c *ClusterCapabilities) CRD(name string) (*CRDCapability, bool) {
+	for _, crd := range c.CRDs {
+		if crd.Name == name {
+			return crd, true
+		}
+	}
+	return nil, false
+}
+
+// Capabilities 采集集群的版本, API组/版本, 已安装的CRD以及常用资源的首选版本.
+// 聚合API不可用或无权限list CRD时仍返回结果, 失败原因记录在 ClusterCapabilities.Errors 中;
+// 无法获取版本或API组时返回错误.
+func (c *GenericK8sClient) Capabilities(ctx context.Context) (*ClusterCapabilities, error) {
+	caps := &ClusterCapabilities{
+		TargetK8sApiServerId: c.TargetK8sApiServerId,
+		APIGroups:            map[string]*APIGroupCapability{},
+		CollectedAt:          time.Now(),
+	}
+	disco := c.GetStandardClient().Discovery()
+
+	body, err := disco.RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return nil, c.clusterError(errors.Wrap(err, "无法获取APIServer版本"), schema.GroupVersionKind{})
+	}
+	caps.ServerVersion = &apimachineryversion.Info{}
+	if err := json.Unmarshal(body, caps.ServerVersion); err != nil {
+		return nil, errors.Wrap(err, "无法解析APIServer版本信息")
+	}
+
+	groups, resourceLists, err := disco.ServerGroupsAndResources()
+	if err != nil {
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			return nil, c.clusterError(errors.Wrap(err, "无法获取集群API组"), schema.GroupVersionKind{})
+		}
+		caps.Errors = append(caps.Errors, err)
+	}
+	for _, group := range groups {
+		apiGroup := &APIGroupCapability{
+			Name:             group.Name,
+			PreferredVersion: group.PreferredVersion.Version,
+			Resources:        map[string][]string{},
+		}
+		for _, version := range group.Versions {
+			apiGroup.Versions = append(apiGroup.Versions, version.Version)
+		}
+		caps.APIGroups[group.Name] = apiGroup
+	}
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		apiGroup, ok := caps.APIGroups[gv.Group]
+		if !ok {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			apiGroup.Resources[gv.Version] = append(apiGroup.Resources[gv.Version], resource.Name)
+		}
+	}
+
+	caps.EndpointSliceVersion = caps.ResourceVersion("discovery.k8s.io", "endpointslices")
+	caps.PodDisruptionBudgetVersion = caps.ResourceVersion("policy", "poddisruptionbudgets")
+	caps.IngressVersion = caps.ResourceVersion("networking.k8s.io", "ingresses")
+	caps.HorizontalPodAutoscalerVersion = caps.ResourceVersion("autoscaling", "horizontalpodautoscalers")
+	caps.CronJobVersion = caps.ResourceVersion("batch", "cronjobs")
+	caps.GatewayAPIVersion = caps.ResourceVersion("gateway.networking.k8s.io", "gateways")
+
+	crds, err := c.listCRDCapabilities(ctx)
+	if err != nil {
+		caps.Errors = append(caps.Errors, err)
+	}
+	caps.CRDs = crds
+	return caps, nil
+}
+
+// listCRDCapabilities 列出集群中的CRD
+func (c *GenericK8sClient) listCRDCapabilities(ctx context.Context) ([]*CRDCapability, error) {
+	list, err := c.GetDynamicClient().Resource(crdGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, c.clusterError(errors.Wrap(err, "无法list CRD"), schema.GroupVersionKind{})
+	}
+	crds := make([]*CRDCapability, 0, len(list.Items))
+	for _, item := range list.Items {
+		crd := &CRDCapability{Name: item.GetName()}
+		crd.Group, _, _ = unstructured.NestedString(item.Object, "spec", "group")
+		crd.Kind, _, _ = unstructured.NestedString(item.Object, "spec", "names", "kind")
+		crd.Plural, _, _ = unstructured.NestedString(item.Object, "spec", "names", "plural")
+		crd.Scope, _, _ = unstructured.NestedString(item.Object, "spec", "scope")
+		versions, _, _ := unstructured.NestedSlice(item.Object, "spec", "versions")
+		for _, v := range versions {
+			version, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(version, "name")
+			if served, _, _ := unstructured.NestedBool(version, "served"); served {
+				crd.ServedVersions = append(crd.ServedVersions, name)
+			}
+			if storage, _, _ := unstructured.NestedBool(version, "storage"); storage {
+				crd.StorageVersion = name
+			}
+		}
+		conditions, _, _ := unstructured.NestedSlice(item.Object, "status", "conditions")
+		for _, cond := range conditions {
+			condition, ok := cond.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if condition["type"] == "Established" && condition["status"] == "True" {
+				crd.Established = true
+			}
+		}
+		crds = append(crds, crd)
+	}
+	sort.Slice(crds, func(i, j int) bool { return crds[i].Name < crds[j].Name })
+	return crds, nil
+}
@@ -0,0 +1,205 @@
+package k8sclientkit
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// TLSCertificateStatus 为证书检查结果
+type TLSCertificateStatus string
+
+const (
+	TLSCertificateValid         TLSCertificateStatus = "Valid"
+	TLSCertificateExpiring      TLSCertificateStatus = "Expiring"
+	TLSCertificateExpired       TLSCertificateStatus = "Expired"
+	TLSCertificateSecretMissing TLSCertificateStatus = "SecretMissing"
+	TLSCertificateInvalid       TLSCertificateStatus = "Invalid"
+)
+
+// gatewayVersions 为探测Gateway API时依次尝试的版本
+var gatewayVersions = []string{"v1", "v1beta1"}
+
+// TLSCertificateReport 描述Ingress或Gateway引用的一个TLS证书Secret的检查结果
+type TLSCertificateReport struct {
+	// Kind 为引用方类型: Ingress 或 Gateway
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Hosts     []string `json:"hosts,omitempty"`
+
+	SecretNamespace string `json:"secretNamespace"`
+	SecretName      string `json:"secretName"`
+
+	Status   TLSCertificateStatus `json:"status"`
+	NotAfter time.Time            `json:"notAfter,omitempty"`
+	DNSNames []string             `json:"dnsNames,omitempty"`
+	Message  string               `json:"message,omitempty"`
+}
+
+// CertificateExpiryReport 扫描namespace(空字符串表示全部namespace)中Ingress以及Gateway API Gateway(集群中存在时)引用的TLS Secret,
+// 报告证书过期时间与缺失的Secret. 距离过期不足warnWithin的证书标记为Expiring.
+// 结果按状态严重程度排序(Expired, SecretMissing, Invalid, Expiring, Valid).
+func (c *GenericK8sClient) CertificateExpiryReport(ctx context.Context, namespace string, warnWithin time.Duration) ([]*TLSCertificateReport, error) {
+	var reports []*TLSCertificateReport
+
+	ingresses, err := c.GetStandardClient().NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出Ingress")
+	}
+	for _, ingress := range ingresses.Items {
+		for _, tls := range ingress.Spec.TLS {
+			if len(tls.SecretName) == 0 {
+				continue
+			}
+			reports = append(reports, &TLSCertificateReport{
+				Kind:            "Ingress",
+				Namespace:       ingress.Namespace,
+				Name:            ingress.Name,
+				Hosts:           tls.Hosts,
+				SecretNamespace: ingress.Namespace,
+				SecretName:      tls.SecretName,
+			})
+		}
+	}
+
+	gatewayReports, err := c.gatewayCertificateRefs(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	reports = append(reports, gatewayReports...)
+
+	for _, report := range reports {
+		if err := c.checkCertificateSecret(ctx, report, warnWithin); err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(reports, func(i, j int) bool {
+		return certificateStatusSeverity[reports[i].Status] > certificateStatusSeverity[reports[j].Status]
+	})
+	return reports, nil
+}
+
+var certificateStatusSeverity = map[TLSCertificateStatus]int{
+	TLSCertificateExpired:       4,
+	TLSCertificateSecretMissing: 3,
+	TLSCertificateInvalid:       2,
+	TLSCertificateExpiring:      1,
+	TLSCertificateValid:         0,
+}
+
+// gatewayCertificateRefs 收集Gateway listener中引用的证书Secret, 集群未安装Gateway API时返回空
+func (c *GenericK8sClient) gatewayCertificateRefs(ctx context.Context, namespace string) ([]*TLSCertificateReport, error) {
+	var gvr schema.GroupVersionResource
+	for _, version := range gatewayVersions {
+		groupVersion := "gateway.networking.k8s.io/" + version
+		if _, err := c.GetStandardClient().Discovery().ServerResourcesForGroupVersion(groupVersion); err == nil {
+			gvr = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: version, Resource: "gateways"}
+			break
+		}
+	}
+	if gvr.Empty() {
+		return nil, nil
+	}
+
+	gateways, err := namespacedResource(c.GetDynamicClient(), gvr, namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出Gateway")
+	}
+	var reports []*TLSCertificateReport
+	for _, gateway := range gateways.Items {
+		listeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+		for _, item := range listeners {
+			listener, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			refs, _, _ := unstructured.NestedSlice(listener, "tls", "certificateRefs")
+			for _, refItem := range refs {
+				ref, ok := refItem.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if kind := nestedString(ref, "kind"); len(kind) > 0 && kind != "Secret" {
+					continue
+				}
+				secretNamespace := nestedString(ref, "namespace")
+				if len(secretNamespace) == 0 {
+					secretNamespace = gateway.GetNamespace()
+				}
+				report := &TLSCertificateReport{
+					Kind:            "Gateway",
+					Namespace:       gateway.GetNamespace(),
+					Name:            gateway.GetName(),
+					SecretNamespace: secretNamespace,
+					SecretName:      nestedString(ref, "name"),
+				}
+				if hostname := nestedString(listener, "hostname"); len(hostname) > 0 {
+					report.Hosts = []string{hostname}
+				}
+				reports = append(reports, report)
+			}
+		}
+	}
+	return reports, nil
+}
+
+// checkCertificateSecret 读取report引用的Secret并填充证书状态
+func (c *GenericK8sClient) checkCertificateSecret(ctx context.Context, report *TLSCertificateReport, warnWithin time.Duration) error {
+	secret, err := c.GetStandardClient().CoreV1().Secrets(report.SecretNamespace).Get(ctx, report.SecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		report.Status = TLSCertificateSecretMissing
+		report.Message = "Secret不存在"
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "无法读取Secret "+report.SecretNamespace+"/"+report.SecretName)
+	}
+
+	cert, err := parseLeafCertificate(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		report.Status = TLSCertificateInvalid
+		report.Message = err.Error()
+		return nil
+	}
+	report.NotAfter = cert.NotAfter
+	report.DNSNames = cert.DNSNames
+	switch remaining := time.Until(cert.NotAfter); {
+	case remaining <= 0:
+		report.Status = TLSCertificateExpired
+	case remaining <= warnWithin:
+		report.Status = TLSCertificateExpiring
+	default:
+		report.Status = TLSCertificateValid
+	}
+	return nil
+}
+
+// parseLeafCertificate 解析PEM证书链中的第一个证书
+func parseLeafCertificate(data []byte) (*x509.Certificate, error) {
+	for len(data) > 0 {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法解析证书")
+		}
+		return cert, nil
+	}
+	return nil, errors.New("Secret中不包含PEM格式的证书(" + corev1.TLSCertKey + ")")
+}
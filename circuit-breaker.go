@@ -0,0 +1,175 @@
+package k8sclientkit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// DefaultCircuitFailureThreshold 为熔断器打开前允许的连续失败次数
+	DefaultCircuitFailureThreshold = 5
+	// DefaultCircuitOpenDuration 为熔断器打开后到允许试探请求的时长
+	DefaultCircuitOpenDuration = 30 * time.Second
+)
+
+// CircuitState 为熔断器状态
+type CircuitState string
+
+const (
+	// CircuitClosed 正常放行请求
+	CircuitClosed CircuitState = "Closed"
+	// CircuitOpen 请求直接以 ErrClusterUnavailable 失败
+	CircuitOpen CircuitState = "Open"
+	// CircuitHalfOpen 放行一个试探请求, 成功则关闭熔断器, 失败则重新打开
+	CircuitHalfOpen CircuitState = "HalfOpen"
+)
+
+// CircuitBreakerOptions 为 WithCircuitBreaker 的参数, 零值字段使用默认值
+type CircuitBreakerOptions struct {
+	// FailureThreshold 为连续失败多少次后打开熔断器, 默认为 DefaultCircuitFailureThreshold
+	FailureThreshold int
+	// OpenDuration 为打开状态持续的时长, 之后放行一个试探请求, 默认为 DefaultCircuitOpenDuration
+	OpenDuration time.Duration
+}
+
+// WithCircuitBreaker 为客户端启用熔断: 连续多次连接失败, 超时或APIServer返回502/503/504后,
+// 后续请求立即以 ErrClusterUnavailable 失败, 直到OpenDuration后的试探请求成功.
+// 用于避免多集群fan-out操作在每个不可用集群上都等待完整超时. 配合 WithHealthProbe 时, 健康探测即可充当试探请求.
+func WithCircuitBreaker(opts CircuitBreakerOptions) Option {
+	return func(o *clientOptions) {
+		if opts.FailureThreshold <= 0 {
+			opts.FailureThreshold = DefaultCircuitFailureThreshold
+		}
+		if opts.OpenDuration <= 0 {
+			opts.OpenDuration = DefaultCircuitOpenDuration
+		}
+		o.circuitBreakerOptions = &opts
+	}
+}
+
+// withCircuitBreakerState 使Clone派生的客户端与原客户端共享熔断状态
+func withCircuitBreakerState(breaker *circuitBreaker) Option {
+	return func(o *clientOptions) {
+		o.breaker = breaker
+	}
+}
+
+// circuitBreaker 记录连续失败次数与状态
+type circuitBreaker struct {
+	opts CircuitBreakerOptions
+
+	lock     sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// probing 为true时已有试探请求在进行中
+	probing bool
+}
+
+func newCircuitBreaker(opts CircuitBreakerOptions) *circuitBreaker {
+	return &circuitBreaker{opts: opts, state: CircuitClosed}
+}
+
+// CircuitState 返回熔断器状态, 未启用 WithCircuitBreaker 时始终为 CircuitClosed
+func (c *GenericK8sClient) CircuitState() CircuitState {
+	if c.breaker == nil {
+		return CircuitClosed
+	}
+	c.breaker.lock.Lock()
+	defer c.breaker.lock.Unlock()
+	if c.breaker.state == CircuitOpen && time.Since(c.breaker.openedAt) >= c.breaker.opts.OpenDuration {
+		return CircuitHalfOpen
+	}
+	return c.breaker.state
+}
+
+// allow 判断请求是否放行, probe 表示该请求为半开状态下的试探请求
+func (b *circuitBreaker) allow() (allowed, probe bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch b.state {
+	case CircuitClosed:
+		return true, false
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.opts.OpenDuration {
+			return false, false
+		}
+		b.state = CircuitHalfOpen
+	}
+	if b.probing {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// record 记录请求结果, probe 为试探请求时根据结果关闭或重新打开熔断器
+func (b *circuitBreaker) record(failed, probe bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if probe {
+		b.probing = false
+	}
+	if !failed {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if probe || b.failures >= b.opts.FailureThreshold {
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// applyCircuitBreaker 安装熔断transport, 位于metrics外层, 被拒绝的请求不计入APIServer请求指标
+func (o *clientOptions) applyCircuitBreaker(clusterId string, config *rest.Config) {
+	if o.circuitBreakerOptions == nil {
+		return
+	}
+	if o.breaker == nil {
+		o.breaker = newCircuitBreaker(*o.circuitBreakerOptions)
+	}
+	breaker := o.breaker
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &circuitBreakerRoundTripper{clusterId: clusterId, breaker: breaker, next: rt}
+	})
+}
+
+// circuitBreakerRoundTripper 在熔断器打开时直接拒绝请求
+type circuitBreakerRoundTripper struct {
+	clusterId string
+	breaker   *circuitBreaker
+	next      http.RoundTripper
+}
+
+func (rt *circuitBreakerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	allowed, probe := rt.breaker.allow()
+	if !allowed {
+		return nil, errors.Wrap(ErrClusterUnavailable, "集群 "+rt.clusterId)
+	}
+	resp, err := rt.next.RoundTrip(req)
+	if err != nil && req.Context().Err() == context.Canceled {
+		// 调用方主动取消不代表集群不可用
+		if probe {
+			rt.breaker.lock.Lock()
+			rt.breaker.probing = false
+			rt.breaker.lock.Unlock()
+		}
+		return resp, err
+	}
+	failed := err != nil && classifyError(err) == ErrClusterUnreachable
+	if resp != nil {
+		switch resp.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			failed = true
+		}
+	}
+	rt.breaker.record(failed, probe)
+	return resp, err
+}
@@ -0,0 +1,89 @@
+package k8sclientkit
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// redactedValue 替换敏感字段的占位符, 与client-go打印rest.Config时的格式一致
+const redactedValue = "--- REDACTED ---"
+
+// withRESTMapper 使controller-runtime cluster复用已有的RESTMapper, 避免重复执行discovery
+func withRESTMapper(mapper meta.RESTMapper) Option {
+	return func(o *clientOptions) {
+		o.restMapper = mapper
+	}
+}
+
+// Clone 在当前客户端的Option基础上追加opts派生一个新客户端, 如切换dry-run(WithDryRun), impersonation(WithImpersonation)
+// 或缓存的namespace范围(WithCacheNamespaces). 派生客户端与原客户端共享RESTMapper(discovery缓存),
+// 熔断状态(WithCircuitBreaker), 凭证与连接配置保持一致, 但拥有独立的生命周期, 需要单独Start/Close.
+// 使用 context.Background(), 需要约束构造超时或派生客户端生命周期时使用 CloneContext.
+func (c *GenericK8sClient) Clone(opts ...Option) (*GenericK8sClient, error) {
+	return c.CloneContext(context.Background(), opts...)
+}
+
+// CloneContext 同 Clone, ctx 含义同 NewGenericK8sClientWithRestConfig: 约束构造过程中的连通性检查, 并决定派生客户端的生命周期.
+func (c *GenericK8sClient) CloneContext(ctx context.Context, opts ...Option) (*GenericK8sClient, error) {
+	cloneOpts := append(append([]Option{}, c.options...), withRESTMapper(c.GetRuntimeCluster().GetRESTMapper()))
+	if c.breaker != nil {
+		cloneOpts = append(cloneOpts, withCircuitBreakerState(c.breaker))
+	}
+	cloneOpts = append(cloneOpts, opts...)
+	c.configLock.RLock()
+	base := rest.CopyConfig(c.baseRestConfig)
+	kubeConfig := c.kubeConfig
+	c.configLock.RUnlock()
+
+	cli, err := newGenericK8sClientWithRestConfig(ctx, c.TargetK8sApiServerId, c.AuthType, base, cloneOpts)
+	if err != nil {
+		return nil, err
+	}
+	cli.kubeConfig = kubeConfig
+	return cli, nil
+}
+
+// GetRESTConfigRedacted 返回当前使用的rest.Config副本, 其中token, 密码, 客户端私钥以及auth/exec插件中的配置值均已被替换,
+// 代理及transport相关的函数字段被清空. 仅用于诊断输出(如打印APIServer地址, TLS设置, 限流参数), 不能用于创建客户端.
+func (c *GenericK8sClient) GetRESTConfigRedacted() *rest.Config {
+	config := rest.CopyConfig(c.currentRestConfig())
+	if len(config.BearerToken) > 0 {
+		config.BearerToken = redactedValue
+	}
+	if len(config.Password) > 0 {
+		config.Password = redactedValue
+	}
+	if len(config.TLSClientConfig.KeyData) > 0 {
+		config.TLSClientConfig.KeyData = []byte(redactedValue)
+	}
+	if config.AuthProvider != nil {
+		// CopyConfig 对插件配置为浅拷贝, 修改前需复制
+		authProvider := *config.AuthProvider
+		authProvider.Config = map[string]string{}
+		for k := range config.AuthProvider.Config {
+			authProvider.Config[k] = redactedValue
+		}
+		config.AuthProvider = &authProvider
+	}
+	if config.ExecProvider != nil {
+		execProvider := *config.ExecProvider
+		execProvider.Args = nil
+		execProvider.Env = nil
+		for _, env := range config.ExecProvider.Env {
+			execProvider.Env = append(execProvider.Env, clientcmdapi.ExecEnvVar{Name: env.Name, Value: redactedValue})
+		}
+		config.ExecProvider = &execProvider
+	}
+	// 代理地址可能包含认证信息
+	config.Proxy = nil
+	config.AuthConfigPersister = nil
+	config.WrapTransport = nil
+	config.Transport = nil
+	config.Dial = nil
+	config.RateLimiter = nil
+	config.WarningHandler = nil
+	return config
+}
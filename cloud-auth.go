@@ -0,0 +1,221 @@
+package k8sclientkit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+const (
+	AuthTypeEKS = "EKS"
+	AuthTypeGKE = "GKE"
+	AuthTypeAKS = "AKS"
+)
+
+const (
+	// eksTokenPrefix 为aws-iam-authenticator token格式前缀
+	eksTokenPrefix = "k8s-aws-v1."
+	// eksClusterIDHeader 为签名中携带EKS集群名称的header
+	eksClusterIDHeader = "x-k8s-aws-id"
+	// eksTokenLifetime 预签名URL有效期为15分钟, 提前1分钟刷新
+	eksTokenLifetime = 14 * time.Minute
+
+	// aksServerApplicationID 为AKS AAD集成中APIServer的固定应用ID
+	aksServerApplicationID = "6dae42f8-4368-4678-94ff-3960e28e3630"
+)
+
+// AWSCredentials 为用于签发EKS token的AWS访问凭证
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken 使用临时凭证(STS AssumeRole, IRSA等)时需要设置
+	SessionToken string
+}
+
+// NewGenericK8sClientWithEKSContext 使用AWS凭证直接签发EKS认证token构建generic client, 与 `aws eks get-token` 生成的token等价,
+// 无需在运行环境中安装aws cli或aws-iam-authenticator. token在过期前自动重新签发.
+//
+//	clusterName: EKS集群名称
+//	region: 集群所在区域, 如 `us-west-2`
+//	apiServerUrl, caPem: 集群endpoint与CA证书, 可通过 DescribeCluster 获得
+func NewGenericK8sClientWithEKSContext(ctx context.Context, id, clusterName, region, apiServerUrl string, caPem []byte, awsCreds AWSCredentials, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	if len(awsCreds.AccessKeyID) == 0 || len(awsCreds.SecretAccessKey) == 0 {
+		return nil, errors.New("AWS AccessKeyID 与 SecretAccessKey 不能为空")
+	}
+	ts := &eksTokenSource{clusterName: clusterName, region: region, creds: awsCreds}
+	return newGenericK8sClientWithTokenSource(ctx, id, AuthTypeEKS, apiServerUrl, caPem, ts, timeout, opts)
+}
+
+// NewGenericK8sClientWithEKS 同 NewGenericK8sClientWithEKSContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientWithEKSContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientWithEKS(id, clusterName, region, apiServerUrl string, caPem []byte, awsCreds AWSCredentials, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientWithEKSContext(context.Background(), id, clusterName, region, apiServerUrl, caPem, awsCreds, timeout, opts...)
+}
+
+// NewGenericK8sClientWithGKEContext 使用Google OAuth2 access token构建访问GKE集群的generic client,
+// 替代 gke-gcloud-auth-plugin. tokenSource 通常由调用方通过
+// `google.DefaultTokenSource(ctx, "https://www.googleapis.com/auth/cloud-platform")` 获得,
+// 从而避免本库直接依赖Google Cloud SDK.
+func NewGenericK8sClientWithGKEContext(ctx context.Context, id, apiServerUrl string, caPem []byte, tokenSource oauth2.TokenSource, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	if tokenSource == nil {
+		return nil, errors.New("GKE tokenSource 不能为空")
+	}
+	return newGenericK8sClientWithTokenSource(ctx, id, AuthTypeGKE, apiServerUrl, caPem, tokenSource, timeout, opts)
+}
+
+// NewGenericK8sClientWithGKE 同 NewGenericK8sClientWithGKEContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientWithGKEContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientWithGKE(id, apiServerUrl string, caPem []byte, tokenSource oauth2.TokenSource, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientWithGKEContext(context.Background(), id, apiServerUrl, caPem, tokenSource, timeout, opts...)
+}
+
+// NewGenericK8sClientWithAKSContext 使用Azure AD服务主体(client credentials)获取token构建访问AKS(AAD集成)集群的generic client,
+// 替代 kubelogin.
+func NewGenericK8sClientWithAKSContext(ctx context.Context, id, apiServerUrl string, caPem []byte, tenantID, clientID, clientSecret string, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	if len(tenantID) == 0 || len(clientID) == 0 || len(clientSecret) == 0 {
+		return nil, errors.New("Azure tenantID, clientID 与 clientSecret 不能为空")
+	}
+	cc := &clientcredentials.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     "https://login.microsoftonline.com/" + tenantID + "/oauth2/v2.0/token",
+		Scopes:       []string{aksServerApplicationID + "/.default"},
+	}
+	return newGenericK8sClientWithTokenSource(ctx, id, AuthTypeAKS, apiServerUrl, caPem, cc.TokenSource(ctx), timeout, opts)
+}
+
+// NewGenericK8sClientWithAKS 同 NewGenericK8sClientWithAKSContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientWithAKSContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientWithAKS(id, apiServerUrl string, caPem []byte, tenantID, clientID, clientSecret string, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientWithAKSContext(context.Background(), id, apiServerUrl, caPem, tenantID, clientID, clientSecret, timeout, opts...)
+}
+
+// newGenericK8sClientWithTokenSource 使用动态token来源创建K8sClient, token在过期前自动刷新
+func newGenericK8sClientWithTokenSource(ctx context.Context, id, authType, apiServerUrl string, caPem []byte, ts oauth2.TokenSource, timeout *time.Duration, opts []Option) (*GenericK8sClient, error) {
+	if timeout == nil {
+		var to = 30 * time.Second
+		timeout = &to
+	}
+
+	config := &rest.Config{
+		Host:            apiServerUrl,
+		TLSClientConfig: rest.TLSClientConfig{CAData: caPem},
+		Timeout:         *timeout,
+	}
+	config.Wrap(transport.TokenSourceWrapTransport(oauth2.ReuseTokenSource(nil, ts)))
+	return newGenericK8sClientWithRestConfig(ctx, id, authType, config, opts)
+}
+
+// eksTokenSource 生成EKS认证token: 经SigV4预签名的STS GetCallerIdentity请求URL
+type eksTokenSource struct {
+	clusterName string
+	region      string
+	creds       AWSCredentials
+}
+
+func (s *eksTokenSource) Token() (*oauth2.Token, error) {
+	now := time.Now().UTC()
+	presigned, err := s.presignGetCallerIdentity(now)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{
+		AccessToken: eksTokenPrefix + base64.RawURLEncoding.EncodeToString([]byte(presigned)),
+		TokenType:   "Bearer",
+		Expiry:      now.Add(eksTokenLifetime),
+	}, nil
+}
+
+func (s *eksTokenSource) presignGetCallerIdentity(now time.Time) (string, error) {
+	if len(s.region) == 0 {
+		return "", errors.New("EKS region 不能为空")
+	}
+	host := "sts." + s.region + ".amazonaws.com"
+	if strings.HasPrefix(s.region, "cn-") {
+		host += ".cn"
+	}
+
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + s.region + "/sts/aws4_request"
+	signedHeaders := "host;" + eksClusterIDHeader
+
+	query := map[string]string{
+		"Action":              "GetCallerIdentity",
+		"Version":             "2011-06-15",
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.creds.AccessKeyID + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       "60",
+		"X-Amz-SignedHeaders": signedHeaders,
+	}
+	if len(s.creds.SessionToken) > 0 {
+		query["X-Amz-Security-Token"] = s.creds.SessionToken
+	}
+	canonicalQuery := sigV4CanonicalQuery(query)
+
+	emptyPayloadHash := sha256.Sum256(nil)
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		"/",
+		canonicalQuery,
+		"host:" + host + "\n" + eksClusterIDHeader + ":" + s.clusterName + "\n",
+		signedHeaders,
+		hex.EncodeToString(emptyPayloadHash[:]),
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.creds.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, "sts")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	return "https://" + host + "/?" + canonicalQuery + "&X-Amz-Signature=" + signature, nil
+}
+
+// sigV4CanonicalQuery 按SigV4要求对查询参数排序并进行RFC3986编码
+func sigV4CanonicalQuery(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, sigV4Escape(k)+"="+sigV4Escape(query[k]))
+	}
+	return strings.Join(pairs, "&")
+}
+
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,276 @@
+package k8sclientkit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// ClusterResource 为 ClusterController 默认watch的Cluster自定义资源
+var ClusterResource = schema.GroupVersionResource{Group: "k8s-client-kit.io", Version: "v1alpha1", Resource: "clusters"}
+
+// DefaultClusterResyncPeriod 为 ClusterController 重新检查全部Cluster(包括其引用的Secret是否变化)的默认间隔
+const DefaultClusterResyncPeriod = time.Minute
+
+// DefaultClusterConnectTimeout 为 ClusterController 检查新客户端连通性的默认超时时间
+const DefaultClusterConnectTimeout = 15 * time.Second
+
+// Cluster自定义资源引用的Secret中的key
+const (
+	// ClusterSecretKeyKubeConfig 保存kubeconfig, 存在时优先使用, 与Cluster API生成的Secret一致
+	ClusterSecretKeyKubeConfig = DefaultKubeConfigSecretKey
+	// ClusterSecretKeyToken 与 ClusterSecretKeyCA 与ServiceAccount token Secret的格式一致
+	ClusterSecretKeyToken = "token"
+	ClusterSecretKeyCA    = "ca.crt"
+)
+
+// ClusterControllerOptions 为 NewClusterController 的参数, 零值均使用默认值
+type ClusterControllerOptions struct {
+	// Resource 为watch的自定义资源, 默认为 ClusterResource
+	Resource schema.GroupVersionResource
+	// Namespace 限定watch的namespace, 为空时watch所有namespace
+	Namespace string
+	// ResyncPeriod 默认为 DefaultClusterResyncPeriod
+	ResyncPeriod time.Duration
+	// ClientOptions 用于创建每个集群的客户端, 包含 WithLazyConnect 时不检查连通性
+	ClientOptions []Option
+	// ConnectTimeout 为检查新客户端连通性的超时时间, 默认为 DefaultClusterConnectTimeout
+	ConnectTimeout time.Duration
+	// DialerFor 可选, 为每个集群(以集群标识区分)返回经由隧道访问APIServer的Dialer, 返回nil时直接连接
+	DialerFor func(clusterId string) Dialer
+}
+
+// ClusterController 根据管理集群中的Cluster自定义资源维护 ClusterRegistry: Cluster创建时注册客户端,
+// spec或其引用的Secret变化时以新客户端替换, Cluster删除时移除并关闭客户端. 集群标识为Cluster的 `namespace/name`.
+//
+// Cluster资源格式:
+//
+//	apiVersion: k8s-client-kit.io/v1alpha1
+//	kind: Cluster
+//	metadata: {name: prod-1, namespace: fleet}
+//	spec:
+//	  apiServerURL: https://10.0.0.1:6443   # 使用kubeconfig时可省略, 指定时覆盖kubeconfig中的地址
+//	  tlsServerName: kubernetes.default      # 可选
+//	  secretRef: {name: prod-1-credentials, namespace: fleet}  # namespace默认为Cluster所在namespace
+//
+// Secret中包含 `value`(kubeconfig) 或 `token` 与可选的 `ca.crt`.
+// Cluster的 `status` 子资源存在时写入 ready, message 与 observedGeneration.
+type ClusterController struct {
+	management *GenericK8sClient
+	registry   *ClusterRegistry
+	opts       ClusterControllerOptions
+
+	informer cache.SharedIndexInformer
+	queue    workqueue.RateLimitingInterface
+	// applied 记录各集群当前客户端对应的spec与Secret版本, 仅由worker访问
+	applied map[string]string
+}
+
+// NewClusterController 创建以management为管理集群, 同步registry的控制器, 调用 Run 后开始工作
+func NewClusterController(management *GenericK8sClient, registry *ClusterRegistry, opts ClusterControllerOptions) *ClusterController {
+	if opts.Resource.Empty() {
+		opts.Resource = ClusterResource
+	}
+	if opts.ResyncPeriod <= 0 {
+		opts.ResyncPeriod = DefaultClusterResyncPeriod
+	}
+	if opts.ConnectTimeout <= 0 {
+		opts.ConnectTimeout = DefaultClusterConnectTimeout
+	}
+	c := &ClusterController{
+		management: management,
+		registry:   registry,
+		opts:       opts,
+		queue:      workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		applied:    map[string]string{},
+	}
+	c.informer = dynamicinformer.NewFilteredDynamicInformer(management.GetDynamicClient(), opts.Resource, opts.Namespace,
+		opts.ResyncPeriod, cache.Indexers{}, nil).Informer()
+	enqueue := func(obj interface{}) {
+		if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+			c.queue.Add(key)
+		}
+	}
+	_, _ = c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			enqueue(newObj)
+		},
+		DeleteFunc: enqueue,
+	})
+	return c
+}
+
+// Run 运行控制器直至ctx结束. 退出时已注册的客户端保留在registry中, 由调用方决定是否 StopAll.
+func (c *ClusterController) Run(ctx context.Context) error {
+	defer c.queue.ShutDown()
+	informerDone := make(chan struct{})
+	go func() {
+		defer close(informerDone)
+		c.informer.Run(ctx.Done())
+	}()
+	defer func() { <-informerDone }()
+
+	if !cache.WaitForCacheSync(ctx.Done(), c.informer.HasSynced) {
+		return errors.New("Cluster资源缓存同步失败")
+	}
+	context.AfterFunc(ctx, c.queue.ShutDown)
+	for c.processNext(ctx) {
+	}
+	return nil
+}
+
+func (c *ClusterController) processNext(ctx context.Context) bool {
+	item, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(item)
+	key := item.(string)
+	if err := c.reconcile(ctx, key); err != nil {
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+// reconcile 使key对应的集群客户端与Cluster资源一致
+func (c *ClusterController) reconcile(ctx context.Context, key string) error {
+	item, exists, err := c.informer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if _, tracked := c.applied[key]; tracked {
+			delete(c.applied, key)
+			if err := c.registry.Remove(key); err != nil && !errors.Is(err, ErrClusterNotRegistered) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	obj := item.(*unstructured.Unstructured)
+	cli, fingerprint, err := c.buildClient(ctx, key, obj)
+	if err != nil {
+		c.updateStatus(ctx, obj, false, err.Error())
+		return err
+	}
+	if cli == nil {
+		// spec与Secret均未变化
+		return nil
+	}
+
+	// 替换在registry中原子完成, 调用方不会观察到集群短暂消失. 关闭旧客户端失败不影响新客户端
+	_ = c.registry.Replace(cli)
+	c.applied[key] = fingerprint
+	c.updateStatus(ctx, obj, true, "")
+	return nil
+}
+
+// buildClient 读取Cluster引用的Secret并创建客户端. spec与Secret均未变化且客户端仍在registry中时返回nil客户端.
+func (c *ClusterController) buildClient(ctx context.Context, id string, obj *unstructured.Unstructured) (*GenericK8sClient, string, error) {
+	spec, _, _ := unstructured.NestedMap(obj.Object, "spec")
+	apiServerURL, _, _ := unstructured.NestedString(spec, "apiServerURL")
+	tlsServerName, _, _ := unstructured.NestedString(spec, "tlsServerName")
+	secretName, _, _ := unstructured.NestedString(spec, "secretRef", "name")
+	secretNamespace, _, _ := unstructured.NestedString(spec, "secretRef", "namespace")
+	if len(secretName) == 0 {
+		return nil, "", errors.New("Cluster " + id + " 未指定spec.secretRef.name")
+	}
+	if len(secretNamespace) == 0 {
+		secretNamespace = obj.GetNamespace()
+	}
+
+	secret, err := c.management.GetStandardClient().CoreV1().Secrets(secretNamespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "无法读取Cluster "+id+" 的Secret "+secretNamespace+"/"+secretName)
+	}
+	specJson, _ := json.Marshal(spec)
+	sum := sha256.Sum256(append(specJson, []byte(secret.ResourceVersion)...))
+	fingerprint := hex.EncodeToString(sum[:])
+	if _, registered := c.registry.Get(id); registered && c.applied[id] == fingerprint {
+		return nil, fingerprint, nil
+	}
+
+	// 客户端的生命周期由registry管理, 不随控制器的ctx结束. 连通性检查在构造后以有超时的ctx单独进行,
+	// 避免不可达的集群阻塞worker
+	clientCtx := context.WithoutCancel(ctx)
+	clientOpts := append(append([]Option{}, c.opts.ClientOptions...), WithLazyConnect())
+	if c.opts.DialerFor != nil {
+		if dialer := c.opts.DialerFor(id); dialer != nil {
+			clientOpts = append(clientOpts, WithDialer(dialer))
+		}
+	}
+	var cli *GenericK8sClient
+	if kubeConfig, ok := secret.Data[ClusterSecretKeyKubeConfig]; ok {
+		config, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "无法解析Cluster "+id+" 的kubeconfig")
+		}
+		if len(apiServerURL) > 0 {
+			config.Host = apiServerURL
+		}
+		if len(tlsServerName) > 0 {
+			config.ServerName = tlsServerName
+		}
+		cli, err = NewGenericK8sClientWithRestConfig(clientCtx, id, config, clientOpts...)
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		token, ok := secret.Data[ClusterSecretKeyToken]
+		if !ok {
+			return nil, "", errors.New("Secret " + secretNamespace + "/" + secretName + " 中既没有kubeconfig也没有token")
+		}
+		if len(apiServerURL) == 0 {
+			return nil, "", errors.New("Cluster " + id + " 使用token认证时必须指定spec.apiServerURL")
+		}
+		cli, err = NewGenericK8sClientWithTokenContext(clientCtx, id, apiServerURL, string(token), secret.Data[ClusterSecretKeyCA],
+			tlsServerName, false, nil, clientOpts...)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if !newClientOptions(c.opts.ClientOptions).lazyConnect {
+		connectCtx, cancel := context.WithTimeout(ctx, c.opts.ConnectTimeout)
+		defer cancel()
+		if err := cli.Connect(connectCtx); err != nil {
+			cli.Close()
+			return nil, "", err
+		}
+	}
+	return cli, fingerprint, nil
+}
+
+// updateStatus 尽力写入Cluster的status, CRD未启用status子资源时忽略错误.
+// status未变化时不写入, 避免status更新触发的事件使失败的Cluster绕过重试退避.
+func (c *ClusterController) updateStatus(ctx context.Context, obj *unstructured.Unstructured, ready bool, message string) {
+	currentReady, _, _ := unstructured.NestedBool(obj.Object, "status", "ready")
+	currentMessage, _, _ := unstructured.NestedString(obj.Object, "status", "message")
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if currentReady == ready && currentMessage == message && observed == obj.GetGeneration() {
+		return
+	}
+
+	obj = obj.DeepCopy()
+	_ = unstructured.SetNestedField(obj.Object, map[string]interface{}{
+		"ready":              ready,
+		"message":            message,
+		"observedGeneration": obj.GetGeneration(),
+	}, "status")
+	_, _ = namespacedResource(c.management.GetDynamicClient(), c.opts.Resource, obj.GetNamespace()).
+		UpdateStatus(ctx, obj, metav1.UpdateOptions{})
+}
@@ -0,0 +1,198 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// clusterSpecificAnnotations 为由集群自动维护, 比较两个集群的对象时忽略的annotation
+var clusterSpecificAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.kubernetes.io/selected-node",
+	SourceClusterAnnotation,
+}
+
+// ClusterDiffEntry 为一个在两个集群间存在差异的对象
+type ClusterDiffEntry struct {
+	Resource  string `json:"resource"`
+	Identity  string `json:"identity"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Changes 为B中对象相对A的字段变更(见 DiffObjects), 仅两个集群都存在的对象设置
+	Changes []string `json:"changes,omitempty"`
+}
+
+// ClusterDiffReport 为 DiffClusters 的结果, 各列表按资源与对象标识排序
+type ClusterDiffReport struct {
+	ClusterA  string             `json:"clusterA"`
+	ClusterB  string             `json:"clusterB"`
+	OnlyInA   []ClusterDiffEntry `json:"onlyInA"`
+	OnlyInB   []ClusterDiffEntry `json:"onlyInB"`
+	Different []ClusterDiffEntry `json:"different"`
+	// Identical 为两个集群中内容一致的对象数
+	Identical int `json:"identical"`
+	// Errors 为比较失败的资源(gvr字符串)及其错误, 这些资源不出现在上述列表中
+	Errors map[string]error `json:"-"`
+}
+
+// HasDifferences 两个集群中是否存在不一致的对象
+func (r *ClusterDiffReport) HasDifferences() bool {
+	return len(r.OnlyInA)+len(r.OnlyInB)+len(r.Different) > 0
+}
+
+// DiffClusters 比较两个集群中gvrs资源的对象, 报告只存在于一个集群, 或两个集群中内容不同的对象. 用于集群迁移(blue/green)时核对.
+// 比较前去除服务端字段(见 StripServerFields)以及由集群分配的字段(Service的clusterIP, PVC绑定的volumeName,
+// ownerReferences中的uid, 集群维护的annotation等), 因此只报告语义上的差异.
+// namespaces 为空时比较所有namespace, 集群级别资源忽略namespaces. 部分资源失败时仍返回其余资源的结果, 同时返回汇总错误.
+func DiffClusters(ctx context.Context, clientA, clientB *GenericK8sClient, gvrs []schema.GroupVersionResource, namespaces []string) (*ClusterDiffReport, error) {
+	ctx, _ = EnsureRequestID(ctx)
+	report := &ClusterDiffReport{
+		ClusterA: clientA.TargetK8sApiServerId,
+		ClusterB: clientB.TargetK8sApiServerId,
+		Errors:   map[string]error{},
+	}
+	var errs []error
+	for _, gvr := range gvrs {
+		if err := report.diffResource(ctx, clientA, clientB, gvr, namespaces); err != nil {
+			report.Errors[gvr.String()] = err
+			errs = append(errs, errors.Wrap(err, gvr.String()))
+		}
+	}
+	for _, entries := range [][]ClusterDiffEntry{report.OnlyInA, report.OnlyInB, report.Different} {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Resource != entries[j].Resource {
+				return entries[i].Resource < entries[j].Resource
+			}
+			return entries[i].Identity < entries[j].Identity
+		})
+	}
+	return report, utilerrors.NewAggregate(errs)
+}
+
+func (r *ClusterDiffReport) diffResource(ctx context.Context, clientA, clientB *GenericK8sClient, gvr schema.GroupVersionResource, namespaces []string) error {
+	namespaced, err := clientA.isNamespaced(gvr)
+	if err != nil {
+		return err
+	}
+	if !namespaced || len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	var objsA, objsB map[string]*unstructured.Unstructured
+	var errA, errB error
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		objsA, errA = listForDiff(ctx, clientA, gvr, namespaces)
+	}()
+	go func() {
+		defer wg.Done()
+		objsB, errB = listForDiff(ctx, clientB, gvr, namespaces)
+	}()
+	wg.Wait()
+	if errA != nil {
+		return errors.Wrap(errA, "集群 "+clientA.TargetK8sApiServerId)
+	}
+	if errB != nil {
+		return errors.Wrap(errB, "集群 "+clientB.TargetK8sApiServerId)
+	}
+
+	for identity, a := range objsA {
+		b, ok := objsB[identity]
+		if !ok {
+			r.OnlyInA = append(r.OnlyInA, newClusterDiffEntry(gvr, a))
+			continue
+		}
+		changes := DiffObjects(normalizeForClusterDiff(a), normalizeForClusterDiff(b))
+		if len(changes) == 0 {
+			r.Identical++
+			continue
+		}
+		entry := newClusterDiffEntry(gvr, a)
+		entry.Changes = changes
+		r.Different = append(r.Different, entry)
+	}
+	for identity, b := range objsB {
+		if _, ok := objsA[identity]; !ok {
+			r.OnlyInB = append(r.OnlyInB, newClusterDiffEntry(gvr, b))
+		}
+	}
+	return nil
+}
+
+// isNamespaced 通过RESTMapper判断资源是否为namespace级别
+func (c *GenericK8sClient) isNamespaced(gvr schema.GroupVersionResource) (bool, error) {
+	mapper := c.GetRuntimeCluster().GetRESTMapper()
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return false, c.clusterError(errors.Wrap(ErrResourceNotMapped, gvr.String()), schema.GroupVersionKind{})
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, c.clusterError(errors.Wrap(ErrResourceNotMapped, gvr.String()), gvk)
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// listForDiff 列出对象, 以ObjectIdentity为key
+func listForDiff(ctx context.Context, c *GenericK8sClient, gvr schema.GroupVersionResource, namespaces []string) (map[string]*unstructured.Unstructured, error) {
+	list, err := c.ListAcrossNamespaces(ctx, gvr, namespaces, ListAcrossNamespacesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	objs := make(map[string]*unstructured.Unstructured, len(list.Items))
+	for i := range list.Items {
+		objs[ObjectIdentity(&list.Items[i])] = &list.Items[i]
+	}
+	return objs, nil
+}
+
+func newClusterDiffEntry(gvr schema.GroupVersionResource, obj *unstructured.Unstructured) ClusterDiffEntry {
+	return ClusterDiffEntry{
+		Resource:  gvr.String(),
+		Identity:  ObjectIdentity(obj),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+}
+
+// normalizeForClusterDiff 去除服务端字段以及由集群分配, 在两个集群间必然不同的字段
+func normalizeForClusterDiff(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	normalized := StripServerFields(obj)
+	annotations := normalized.GetAnnotations()
+	for _, key := range clusterSpecificAnnotations {
+		delete(annotations, key)
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(normalized.Object, "metadata", "annotations")
+	} else {
+		normalized.SetAnnotations(annotations)
+	}
+	if owners := normalized.GetOwnerReferences(); len(owners) > 0 {
+		for i := range owners {
+			owners[i].UID = ""
+		}
+		normalized.SetOwnerReferences(owners)
+	}
+
+	switch normalized.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Kind: "Service"}:
+		unstructured.RemoveNestedField(normalized.Object, "spec", "clusterIP")
+		unstructured.RemoveNestedField(normalized.Object, "spec", "clusterIPs")
+	case schema.GroupKind{Kind: "PersistentVolumeClaim"}:
+		unstructured.RemoveNestedField(normalized.Object, "spec", "volumeName")
+	}
+	return normalized
+}
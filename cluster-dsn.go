@@ -0,0 +1,165 @@
+package k8sclientkit
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DSNSchemeToken     = "token"
+	DSNSchemeInCluster = "incluster"
+)
+
+// ClusterDSN 是由连接字符串解析得到的集群连接信息, 便于通过单个配置项/环境变量传递集群访问参数.
+//
+// 支持的格式:
+//
+//	token://<token>@<host>:<port>?ca=<base64 PEM>&sni=<server name>&insecure=false&timeout=30s
+//	token://<host>:<port>?token_file=/path/to/token&ca_file=/path/to/ca.crt
+//	incluster://?timeout=30s
+//
+// `ca` 推荐使用URL安全的base64(base64.URLEncoding或RawURLEncoding), 无需转义. 标准base64中的 `+` 在query中
+// 会被解码为空格, 解析时将空格还原为 `+`, 因此未转义的标准base64同样可用. `ca` 也可以是转义了换行的原始PEM,
+// 正文中未转义的 `+` 同样会被还原.
+type ClusterDSN struct {
+	// AuthType 对应 AuthTypeToken 或 AuthTypeInCluster
+	AuthType      string
+	ApiServerUrl  string
+	Token         string
+	CaPem         []byte
+	TLSServerName string
+	SkipTLSVerify bool
+	// Timeout 为nil时使用构造函数默认值
+	Timeout *time.Duration
+}
+
+// ParseClusterDSN 解析集群连接字符串
+func ParseClusterDSN(dsn string) (*ClusterDSN, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法解析集群连接字符串")
+	}
+	query := u.Query()
+
+	result := &ClusterDSN{}
+	if to := query.Get("timeout"); len(to) > 0 {
+		timeout, err := time.ParseDuration(to)
+		if err != nil {
+			return nil, errors.Wrap(err, "无效的timeout参数:"+to)
+		}
+		result.Timeout = &timeout
+	}
+
+	switch u.Scheme {
+	case DSNSchemeInCluster:
+		result.AuthType = AuthTypeInCluster
+		return result, nil
+	case DSNSchemeToken:
+		result.AuthType = AuthTypeToken
+	default:
+		return nil, errors.New("不支持的集群连接字符串类型:" + u.Scheme)
+	}
+
+	if len(u.Host) == 0 {
+		return nil, errors.New("集群连接字符串中缺少APIServer地址")
+	}
+	result.ApiServerUrl = "https://" + u.Host + strings.TrimSuffix(u.Path, "/")
+
+	// token 可以通过 userinfo, token 参数或 token_file 参数指定
+	if u.User != nil {
+		result.Token = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			result.Token = password
+		}
+	}
+	if token := query.Get("token"); len(token) > 0 {
+		result.Token = token
+	}
+	if tokenFile := query.Get("token_file"); len(tokenFile) > 0 {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法读取Token文件:"+tokenFile)
+		}
+		result.Token = strings.TrimSpace(string(token))
+	}
+	if len(result.Token) == 0 {
+		return nil, errors.New("集群连接字符串中缺少token")
+	}
+
+	if ca := query.Get("ca"); len(ca) > 0 {
+		caPem, err := decodeDSNCa(ca)
+		if err != nil {
+			return nil, err
+		}
+		result.CaPem = caPem
+	}
+	if caFile := query.Get("ca_file"); len(caFile) > 0 {
+		caPem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法读取CA Cert文件:"+caFile)
+		}
+		result.CaPem = caPem
+	}
+
+	result.TLSServerName = query.Get("sni")
+	if insecure := query.Get("insecure"); len(insecure) > 0 {
+		skip, err := strconv.ParseBool(insecure)
+		if err != nil {
+			return nil, errors.Wrap(err, "无效的insecure参数:"+insecure)
+		}
+		result.SkipTLSVerify = skip
+	}
+
+	return result, nil
+}
+
+// decodeDSNCa 解析 `ca` 参数, 支持原始PEM以及标准/URL安全的base64编码PEM.
+// ca 为query解码后的值, 未转义的 `+` 已变为空格, base64中不会出现空格, 解码前将其还原.
+// 原始PEM的正文同样是base64, 只有边界行(`-----BEGIN ...-----`)与头部行(`Key: value`)中的空格需要保留.
+func decodeDSNCa(ca string) ([]byte, error) {
+	if strings.HasPrefix(ca, "-----BEGIN") {
+		lines := strings.Split(ca, "\n")
+		for i, line := range lines {
+			if !strings.HasPrefix(line, "-----") && !strings.Contains(line, ":") {
+				lines[i] = strings.ReplaceAll(line, " ", "+")
+			}
+		}
+		return []byte(strings.Join(lines, "\n")), nil
+	}
+	ca = strings.ReplaceAll(ca, " ", "+")
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if caPem, err := enc.DecodeString(ca); err == nil {
+			return caPem, nil
+		}
+	}
+	return nil, errors.New("无法解码ca参数, 应为base64编码的PEM证书")
+}
+
+// NewGenericK8sClientWithDSNContext 使用集群连接字符串创建GenericK8sClient, 格式参见 ClusterDSN
+func NewGenericK8sClientWithDSNContext(ctx context.Context, id, dsn string, opts ...Option) (*GenericK8sClient, error) {
+	parsed, err := ParseClusterDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsed.AuthType {
+	case AuthTypeInCluster:
+		return NewGenericK8sClientInClusterContext(ctx, id, parsed.Timeout, opts...)
+	default:
+		return NewGenericK8sClientWithTokenContext(ctx, id, parsed.ApiServerUrl, parsed.Token, parsed.CaPem, parsed.TLSServerName, parsed.SkipTLSVerify, parsed.Timeout, opts...)
+	}
+}
+
+// NewGenericK8sClientWithDSN 同 NewGenericK8sClientWithDSNContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientWithDSNContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientWithDSN(id, dsn string, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientWithDSNContext(context.Background(), id, dsn, opts...)
+}
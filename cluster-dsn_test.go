@@ -0,0 +1,54 @@
+package k8sclientkit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseClusterDSNCa(t *testing.T) {
+	// 0xfb 0xef 0xbe 编码为 `++++`, 保证PEM正文中包含 `+`; 连续的 `~` 保证PEM的标准base64中包含 `+`
+	caPem := pem.EncodeToMemory(&pem.Block{
+		Type:    "CERTIFICATE",
+		Headers: map[string]string{"Comment": "~~~"},
+		Bytes:   bytes.Repeat([]byte{0xfb, 0xef, 0xbe}, 32),
+	})
+	std := base64.StdEncoding.EncodeToString(caPem)
+	if !strings.Contains(std, "+") || !strings.Contains(string(caPem), "+") {
+		t.Fatal("测试数据中应包含 `+`")
+	}
+
+	tests := []struct {
+		name    string
+		ca      string
+		wantErr bool
+	}{
+		{name: "转义的标准base64", ca: url.QueryEscape(std)},
+		{name: "未转义的标准base64", ca: std},
+		{name: "URL安全的base64", ca: base64.URLEncoding.EncodeToString(caPem)},
+		{name: "无填充的URL安全base64", ca: base64.RawURLEncoding.EncodeToString(caPem)},
+		{name: "转义的原始PEM", ca: url.QueryEscape(string(caPem))},
+		{name: "未转义 `+` 的原始PEM", ca: strings.ReplaceAll(url.QueryEscape(string(caPem)), "%2B", "+")},
+		{name: "无效的ca", ca: "not-base64!", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := ParseClusterDSN("token://secret@10.0.0.1:6443?ca=" + tt.ca)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("应返回错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if !bytes.Equal(dsn.CaPem, caPem) {
+				t.Errorf("CaPem = %q, 期望 %q", dsn.CaPem, caPem)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package k8sclientkit
+
+import (
+	"crypto/x509"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// 错误分类, 通过 errors.Is 判断. 各helper返回的错误均为 *ClusterError, 其中携带集群标识, GVK 以及APIServer返回的Status.
+var (
+	// ErrClusterUnreachable 无法与APIServer建立连接或请求超时
+	ErrClusterUnreachable = errors.New("无法连接到集群")
+	// ErrAuthExpired 凭证无效或已过期(HTTP 401)
+	ErrAuthExpired = errors.New("集群凭证无效或已过期")
+	// ErrForbidden 凭证没有执行该操作的权限(HTTP 403)
+	ErrForbidden = errors.New("没有执行该操作的权限")
+	// ErrResourceNotMapped 集群中不存在该GVK对应的资源(CRD未安装或API版本不受支持)
+	ErrResourceNotMapped = errors.New("未找到目标资源")
+	// ErrClusterUnavailable 集群连续请求失败, 熔断器处于打开状态, 请求未发出即失败(见 WithCircuitBreaker)
+	ErrClusterUnavailable = errors.New("集群暂不可用(熔断中)")
+	// ErrUnsupportedVersion 集群版本或提供的API不满足操作要求(见 RequireVersion, RequireAPI)
+	ErrUnsupportedVersion = errors.New("集群版本不满足要求")
+)
+
+// ClusterError 为带有集群上下文的错误. 调用方可通过 errors.Is 判断错误分类(如 ErrAuthExpired),
+// 通过 errors.As 获取 *ClusterError 读取集群标识与GVK, 或获取 apierrors.APIStatus 读取APIServer返回的原始状态.
+type ClusterError struct {
+	TargetK8sApiServerId string
+	// Gvk 为操作涉及的资源类型, 与具体资源无关的操作(如连通性检查)为空
+	Gvk schema.GroupVersionKind
+	// Category 为错误分类(ErrClusterUnreachable 等), 无法归类时为nil
+	Category error
+	// Status 为APIServer返回的状态, 请求未到达APIServer时为nil
+	Status *metav1.Status
+	Err    error
+}
+
+func (e *ClusterError) Error() string {
+	msg := "集群 " + e.TargetK8sApiServerId
+	if !e.Gvk.Empty() {
+		msg += " " + e.Gvk.String()
+	}
+	if e.Category != nil && !strings.Contains(e.Err.Error(), e.Category.Error()) {
+		msg += ": " + e.Category.Error()
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+// Unwrap 同时返回错误分类与原始错误, 使 errors.Is/errors.As 对两者均生效
+func (e *ClusterError) Unwrap() []error {
+	if e.Category == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Category, e.Err}
+}
+
+// Cause 返回原始错误, 兼容 github.com/pkg/errors
+func (e *ClusterError) Cause() error {
+	return e.Err
+}
+
+// clusterError 为err附加集群上下文并分类, err为nil或已是*ClusterError时原样返回
+func (c *GenericK8sClient) clusterError(err error, gvk schema.GroupVersionKind) error {
+	if err == nil {
+		return nil
+	}
+	var existing *ClusterError
+	if errors.As(err, &existing) {
+		return err
+	}
+
+	clusterErr := &ClusterError{
+		TargetK8sApiServerId: c.TargetK8sApiServerId,
+		Gvk:                  gvk,
+		Category:             classifyError(err),
+		Err:                  err,
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		s := status.Status()
+		clusterErr.Status = &s
+	}
+	return clusterErr
+}
+
+// classifyError 将client-go返回的错误映射为错误分类
+func classifyError(err error) error {
+	for _, category := range []error{ErrClusterUnavailable, ErrClusterUnreachable, ErrAuthExpired, ErrForbidden, ErrResourceNotMapped, ErrUnsupportedVersion} {
+		if errors.Is(err, category) {
+			return category
+		}
+	}
+	switch {
+	case meta.IsNoMatchError(err):
+		return ErrResourceNotMapped
+	case apierrors.IsUnauthorized(err):
+		return ErrAuthExpired
+	case apierrors.IsForbidden(err):
+		return ErrForbidden
+	case apierrors.IsServerTimeout(err), apierrors.IsTimeout(err), apierrors.IsServiceUnavailable(err):
+		return ErrClusterUnreachable
+	}
+
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		// 其余APIServer返回的错误(NotFound, Conflict, Invalid等)通过apierrors判断
+		return nil
+	}
+	// 仅连接层面的失败归为不可达, transport中间件返回的错误(如 ErrClusterReadOnly)保持原样
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalid x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &opErr), errors.As(err, &dnsErr),
+		errors.As(err, &netErr) && netErr.Timeout(),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET),
+		errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &certInvalid):
+		return ErrClusterUnreachable
+	}
+	return nil
+}
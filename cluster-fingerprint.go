@@ -0,0 +1,120 @@
+package k8sclientkit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ClusterFingerprint 为集群的稳定标识, 用于判断两个客户端是否指向同一集群
+type ClusterFingerprint struct {
+	// UID 为kube-system namespace的UID, 在集群生命周期内不变
+	UID string
+	// CAFingerprint 为客户端信任的CA证书(第一个)的SHA-256指纹(十六进制), 未配置CA时为空
+	CAFingerprint string
+}
+
+// ClusterUID 返回kube-system namespace的UID作为集群标识. 同一集群的不同入口(如内网与公网地址)返回相同的值,
+// 入口被指向另一个集群后返回值随之变化. 需要get namespace kube-system的权限.
+func (c *GenericK8sClient) ClusterUID(ctx context.Context) (string, error) {
+	ns, err := c.GetStandardClient().CoreV1().Namespaces().Get(ctx, metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return "", c.clusterError(errors.Wrap(err, "无法获取kube-system namespace"), schema.GroupVersionKind{Version: "v1", Kind: "Namespace"})
+	}
+	return string(ns.UID), nil
+}
+
+// Fingerprint 返回集群UID以及当前信任的CA证书指纹
+func (c *GenericK8sClient) Fingerprint(ctx context.Context) (*ClusterFingerprint, error) {
+	uid, err := c.ClusterUID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := &ClusterFingerprint{UID: uid}
+	config := c.currentRestConfig()
+	caData := config.TLSClientConfig.CAData
+	if len(caData) == 0 && len(config.TLSClientConfig.CAFile) > 0 {
+		if caData, err = os.ReadFile(config.TLSClientConfig.CAFile); err != nil {
+			return nil, errors.Wrap(err, "无法读取CA文件:"+config.TLSClientConfig.CAFile)
+		}
+	}
+	if block, _ := pem.Decode(caData); block != nil {
+		sum := sha256.Sum256(block.Bytes)
+		fingerprint.CAFingerprint = hex.EncodeToString(sum[:])
+	}
+	return fingerprint, nil
+}
+
+// Fingerprints 并行获取各集群的 ClusterFingerprint, clusterIDs 为空时获取全部已注册集群. 部分集群失败时返回其余集群的结果与汇总错误.
+// 同一集群标识的UID与上次获取时不同(入口被指向了另一个集群)时, 订阅者收到 ClusterRepointed 事件.
+func (r *ClusterRegistry) Fingerprints(ctx context.Context, clusterIDs ...string) (map[string]*ClusterFingerprint, error) {
+	if len(clusterIDs) == 0 {
+		clusterIDs = r.IDs()
+	}
+	results := make(map[string]*ClusterFingerprint, len(clusterIDs))
+	lock := sync.Mutex{}
+	_, err := r.FleetExec(ctx, clusterIDs, func(ctx context.Context, cli *GenericK8sClient) error {
+		fingerprint, err := r.fingerprint(ctx, cli)
+		if err != nil {
+			return err
+		}
+		lock.Lock()
+		results[cli.TargetK8sApiServerId] = fingerprint
+		lock.Unlock()
+		return nil
+	}, FleetExecOptions{})
+	return results, err
+}
+
+// fingerprint 获取单个集群的标识, 并与此前记录的UID比较
+func (r *ClusterRegistry) fingerprint(ctx context.Context, cli *GenericK8sClient) (*ClusterFingerprint, error) {
+	id := cli.TargetK8sApiServerId
+	fingerprint, err := cli.Fingerprint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if current, registered := r.clients[id]; !registered || current != cli {
+		return fingerprint, nil
+	}
+	state, ok := r.monitorStates[id]
+	if !ok {
+		state = &clusterMonitorState{}
+		r.monitorStates[id] = state
+	}
+	if len(state.uid) > 0 && state.uid != fingerprint.UID {
+		r.publish(ClusterEvent{Type: ClusterRepointed, ClusterId: id,
+			Err: errors.New("集群UID由 " + state.uid + " 变为 " + fingerprint.UID)})
+	}
+	state.uid = fingerprint.UID
+	return fingerprint, nil
+}
+
+// DuplicateClusters 返回指向同一集群(UID相同)的集群标识分组, 每组至少两个标识, 组内与组间均按标识排序.
+// 无法获取标识的集群不参与比较, 其错误通过汇总错误返回.
+func (r *ClusterRegistry) DuplicateClusters(ctx context.Context) ([][]string, error) {
+	fingerprints, err := r.Fingerprints(ctx)
+	byUID := map[string][]string{}
+	for id, fingerprint := range fingerprints {
+		byUID[fingerprint.UID] = append(byUID[fingerprint.UID], id)
+	}
+	var groups [][]string
+	for _, ids := range byUID {
+		if len(ids) > 1 {
+			sort.Strings(ids)
+			groups = append(groups, ids)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups, err
+}
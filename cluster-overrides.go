@@ -0,0 +1,100 @@
+package k8sclientkit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// ErrClusterReadOnly 集群被设置为只读时, 变更类请求返回此错误
+var ErrClusterReadOnly = errors.New("集群处于只读模式, 拒绝变更请求")
+
+// ClusterOverrides 为单个集群的特殊行为设置, 用于处理异构集群舰队中的个别特殊集群.
+// 各helper会自动参考这些设置, 零值表示默认行为.
+type ClusterOverrides struct {
+	// ReadOnly 拒绝所有变更类请求(dry-run请求及SelfSubject*Review等鉴权查询除外), 返回 ErrClusterReadOnly
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// DisableServerSideApply 用于不支持或禁止server-side apply的集群, 相关helper退化为JSON merge patch, ApplyUnstructuredObj 退化为client-side apply
+	DisableServerSideApply bool `json:"disableServerSideApply,omitempty"`
+	// SkipWebhookChecks 跳过依赖准入webhook的预演检查(如WhatIf中的dry-run), 用于webhook不稳定的集群
+	SkipWebhookChecks bool `json:"skipWebhookChecks,omitempty"`
+	// QPS, Burst 大于0时覆盖客户端侧限流设置(包括 WithQPS, WithBurst)
+	QPS   float32 `json:"qps,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+	// Flags 为调用方自定义的特性开关
+	Flags map[string]string `json:"flags,omitempty"`
+}
+
+// Flag 读取自定义特性开关
+func (o ClusterOverrides) Flag(name string) (string, bool) {
+	value, ok := o.Flags[name]
+	return value, ok
+}
+
+// WithClusterOverrides 为客户端设置集群级别的行为覆盖
+func WithClusterOverrides(overrides ClusterOverrides) Option {
+	return func(o *clientOptions) {
+		o.overrides = overrides
+	}
+}
+
+// Overrides 返回客户端的集群级别行为覆盖设置
+func (c *GenericK8sClient) Overrides() ClusterOverrides {
+	return c.overrides
+}
+
+// readOnlyRoundTripper 拒绝只读集群上的变更类请求
+type readOnlyRoundTripper struct {
+	clusterId string
+	next      http.RoundTripper
+}
+
+func (rt *readOnlyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isMutatingMethod(req.Method) && !isReadOnlySafeRequest(req) {
+		return nil, errors.Wrap(ErrClusterReadOnly, rt.clusterId+": "+req.Method+" "+req.URL.Path)
+	}
+	return rt.next.RoundTrip(req)
+}
+
+// isReadOnlySafeRequest 判断变更方法的请求是否实际上不会修改集群: dry-run请求, 以及鉴权/认证查询
+func isReadOnlySafeRequest(req *http.Request) bool {
+	if req.URL.Query().Get("dryRun") == metav1.DryRunAll {
+		return true
+	}
+	return isReviewRequest(req)
+}
+
+// isReviewRequest 判断是否为SelfSubjectAccessReview, TokenReview等鉴权/认证查询, 这类POST请求不会修改集群
+func isReviewRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost &&
+		(strings.HasPrefix(req.URL.Path, "/apis/authorization.k8s.io/") || strings.HasPrefix(req.URL.Path, "/apis/authentication.k8s.io/"))
+}
+
+// applyObject 对obj执行server-side apply. 集群设置了DisableServerSideApply时退化为JSON merge patch,
+// 对象不存在时创建(仅限主资源).
+func applyObject(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured, opts metav1.ApplyOptions, disableServerSideApply bool, subresources ...string) (*unstructured.Unstructured, error) {
+	if !disableServerSideApply {
+		return ri.Apply(ctx, obj.GetName(), obj, opts, subresources...)
+	}
+
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法序列化对象")
+	}
+	patched, err := ri.Patch(ctx, obj.GetName(), types.MergePatchType, data, metav1.PatchOptions{
+		FieldManager: opts.FieldManager,
+		DryRun:       opts.DryRun,
+	}, subresources...)
+	if apierrors.IsNotFound(err) && len(subresources) == 0 {
+		return ri.Create(ctx, obj, metav1.CreateOptions{FieldManager: opts.FieldManager, DryRun: opts.DryRun})
+	}
+	return patched, err
+}
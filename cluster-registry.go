@@ -0,0 +1,212 @@
+package k8sclientkit
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrClusterNotRegistered 请求的集群未在ClusterRegistry中注册
+var ErrClusterNotRegistered = errors.New("集群未注册")
+
+// ErrClusterAlreadyRegistered 注册的集群标识已存在
+var ErrClusterAlreadyRegistered = errors.New("集群已注册")
+
+// ClusterRegistry 以 TargetK8sApiServerId 为key管理多个GenericK8sClient, 并发安全.
+// 注册后客户端的生命周期由registry管理: StartAll 启动全部客户端, Remove 与 StopAll 关闭客户端.
+type ClusterRegistry struct {
+	lock    sync.RWMutex
+	clients map[string]*GenericK8sClient
+	// started 为true时, 新注册的客户端立即启动
+	started bool
+
+	// 生命周期事件订阅者, 以及首次订阅时启动的状态检查
+	subscribers     map[int]chan ClusterEvent
+	hooks           map[int]*registryHook
+	nextSubscriber  int
+	monitor         *runGroup
+	monitorInterval time.Duration
+	monitorStates   map[string]*clusterMonitorState
+	// idleTTL 大于0时回收空闲客户端的runtime cluster, 见 WithIdleEviction
+	idleTTL time.Duration
+	// leading 为当前副本是否为leader, 见 RunLeaderElected
+	leading atomic.Bool
+	// storeLoaded 为通过 LoadFromStore 加载的客户端, WithRegistryStore 不再重复保存
+	storeLoaded map[string]*GenericK8sClient
+}
+
+// NewClusterRegistry 创建空的ClusterRegistry
+func NewClusterRegistry(opts ...RegistryOption) *ClusterRegistry {
+	r := &ClusterRegistry{
+		clients:         map[string]*GenericK8sClient{},
+		subscribers:     map[int]chan ClusterEvent{},
+		hooks:           map[int]*registryHook{},
+		monitorInterval: DefaultRegistryMonitorInterval,
+		monitorStates:   map[string]*clusterMonitorState{},
+		storeLoaded:     map[string]*GenericK8sClient{},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(r)
+		}
+	}
+	return r
+}
+
+// Register 注册客户端, 标识已存在时返回 ErrClusterAlreadyRegistered. 已调用过 StartAll 时客户端立即在后台启动.
+func (r *ClusterRegistry) Register(cli *GenericK8sClient) error {
+	if cli == nil {
+		return errors.New("客户端不能为空")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, exists := r.clients[cli.TargetK8sApiServerId]; exists {
+		return errors.Wrap(ErrClusterAlreadyRegistered, cli.TargetK8sApiServerId)
+	}
+	r.clients[cli.TargetK8sApiServerId] = cli
+	if r.started {
+		cli.startBackground()
+	}
+	r.publish(ClusterEvent{Type: ClusterRegistered, ClusterId: cli.TargetK8sApiServerId, Client: cli})
+	return nil
+}
+
+// Replace 注册客户端, 标识已存在时在同一次加锁中以cli替换旧客户端并关闭旧客户端, 期间 Get 不会找不到该集群.
+// 替换时订阅者只收到 Replaced 为true的 ClusterRegistered 事件, 不会收到 ClusterRemoved. 返回关闭旧客户端的错误.
+func (r *ClusterRegistry) Replace(cli *GenericK8sClient) error {
+	if cli == nil {
+		return errors.New("客户端不能为空")
+	}
+	id := cli.TargetK8sApiServerId
+	r.lock.Lock()
+	old, replaced := r.clients[id]
+	if replaced && old == cli {
+		r.lock.Unlock()
+		return nil
+	}
+	r.clients[id] = cli
+	if replaced {
+		// 健康状态属于旧客户端, 由状态检查为新客户端重新建立
+		delete(r.monitorStates, id)
+		delete(r.storeLoaded, id)
+	}
+	if r.started {
+		cli.startBackground()
+	}
+	r.publish(ClusterEvent{Type: ClusterRegistered, ClusterId: id, Client: cli, Replaced: replaced})
+	r.lock.Unlock()
+	if !replaced {
+		return nil
+	}
+	return old.Close()
+}
+
+// Get 返回指定集群的客户端
+func (r *ClusterRegistry) Get(id string) (*GenericK8sClient, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	cli, ok := r.clients[id]
+	return cli, ok
+}
+
+// MustGet 返回指定集群的客户端, 未注册时返回 ErrClusterNotRegistered
+func (r *ClusterRegistry) MustGet(id string) (*GenericK8sClient, error) {
+	cli, ok := r.Get(id)
+	if !ok {
+		return nil, errors.Wrap(ErrClusterNotRegistered, id)
+	}
+	return cli, nil
+}
+
+// Remove 从registry中移除并关闭指定集群的客户端, 未注册时返回 ErrClusterNotRegistered
+func (r *ClusterRegistry) Remove(id string) error {
+	r.lock.Lock()
+	cli, ok := r.clients[id]
+	delete(r.clients, id)
+	delete(r.monitorStates, id)
+	delete(r.storeLoaded, id)
+	if ok {
+		r.publish(ClusterEvent{Type: ClusterRemoved, ClusterId: id})
+	}
+	r.lock.Unlock()
+	if !ok {
+		return errors.Wrap(ErrClusterNotRegistered, id)
+	}
+	return cli.Close()
+}
+
+// List 返回全部已注册的客户端, 按集群标识排序
+func (r *ClusterRegistry) List() []*GenericK8sClient {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	clients := make([]*GenericK8sClient, 0, len(r.clients))
+	for _, cli := range r.clients {
+		clients = append(clients, cli)
+	}
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].TargetK8sApiServerId < clients[j].TargetK8sApiServerId
+	})
+	return clients
+}
+
+// IDs 返回全部已注册的集群标识, 按字典序排序
+func (r *ClusterRegistry) IDs() []string {
+	clients := r.List()
+	ids := make([]string, 0, len(clients))
+	for _, cli := range clients {
+		ids = append(ids, cli.TargetK8sApiServerId)
+	}
+	return ids
+}
+
+// Len 返回已注册的客户端数量
+func (r *ClusterRegistry) Len() int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return len(r.clients)
+}
+
+// StartAll 在后台启动全部已注册客户端的controller-runtime cluster(见 GenericK8sClient.Start), 不阻塞.
+// 之后注册的客户端同样自动启动. 启动失败通过各客户端的 Errors 报告.
+func (r *ClusterRegistry) StartAll() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.started = true
+	for _, cli := range r.clients {
+		cli.startBackground()
+	}
+	r.ensureMonitor()
+}
+
+// StopAll 关闭并移除全部客户端, 等待各客户端的后台goroutine退出. 订阅者收到各集群的 ClusterRemoved 事件,
+// 订阅本身保持有效, 但状态检查停止, 直到再次 Subscribe 或 StartAll.
+func (r *ClusterRegistry) StopAll() {
+	r.lock.Lock()
+	monitor := r.monitor
+	r.monitor = nil
+	clients := r.clients
+	r.clients = map[string]*GenericK8sClient{}
+	r.monitorStates = map[string]*clusterMonitorState{}
+	r.storeLoaded = map[string]*GenericK8sClient{}
+	r.started = false
+	for id := range clients {
+		r.publish(ClusterEvent{Type: ClusterRemoved, ClusterId: id, Shutdown: true})
+	}
+	r.lock.Unlock()
+
+	if monitor != nil {
+		monitor.stop()
+	}
+	wg := sync.WaitGroup{}
+	for _, cli := range clients {
+		wg.Add(1)
+		go func(cli *GenericK8sClient) {
+			defer wg.Done()
+			cli.Close()
+		}(cli)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,152 @@
+package k8sclientkit
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ClusterSummary 为集群资源概况, 由 Summary 生成
+type ClusterSummary struct {
+	ClusterId string `json:"clusterId"`
+	Nodes     int    `json:"nodes"`
+	// ReadyNodes 为Ready condition为True的节点数
+	ReadyNodes int `json:"readyNodes"`
+	// SchedulableNodes 为未被cordon(spec.unschedulable)的节点数
+	SchedulableNodes int `json:"schedulableNodes"`
+	Namespaces       int `json:"namespaces"`
+
+	// CPUAllocatable, MemoryAllocatable, PodsAllocatable 为所有节点allocatable之和
+	CPUAllocatable    resource.Quantity `json:"cpuAllocatable"`
+	MemoryAllocatable resource.Quantity `json:"memoryAllocatable"`
+	PodsAllocatable   int64             `json:"podsAllocatable"`
+	// CPURequested, MemoryRequested 为已调度到节点且未结束的Pod的资源请求之和, 计算方式与调度器一致(含init容器与overhead)
+	CPURequested    resource.Quantity `json:"cpuRequested"`
+	MemoryRequested resource.Quantity `json:"memoryRequested"`
+
+	Pods        int                     `json:"pods"`
+	PodsByPhase map[corev1.PodPhase]int `json:"podsByPhase"`
+	ComputedAt  time.Time               `json:"computedAt"`
+}
+
+// CPURequestRatio 返回CPU请求占allocatable的比例, allocatable为0时返回0
+func (s *ClusterSummary) CPURequestRatio() float64 {
+	return quantityRatio(s.CPURequested, s.CPUAllocatable)
+}
+
+// MemoryRequestRatio 返回内存请求占allocatable的比例, allocatable为0时返回0
+func (s *ClusterSummary) MemoryRequestRatio() float64 {
+	return quantityRatio(s.MemoryRequested, s.MemoryAllocatable)
+}
+
+func quantityRatio(used, total resource.Quantity) float64 {
+	if total.IsZero() {
+		return 0
+	}
+	return float64(used.MilliValue()) / float64(total.MilliValue())
+}
+
+// Summary 统计集群的节点数, CPU与内存的allocatable及已请求量, 各phase的Pod数与namespace数.
+// 客户端已启动(Start)时从informer缓存读取, 否则直接请求APIServer. 需要nodes, pods, namespaces的list权限.
+func (c *GenericK8sClient) Summary(ctx context.Context) (*ClusterSummary, error) {
+	ctx, _ = EnsureRequestID(ctx)
+	summary := &ClusterSummary{
+		ClusterId:   c.TargetK8sApiServerId,
+		PodsByPhase: map[corev1.PodPhase]int{},
+		ComputedAt:  time.Now().UTC(),
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := c.readList(ctx, nodes); err != nil {
+		return nil, c.clusterError(err, corev1.SchemeGroupVersion.WithKind("Node"))
+	}
+	summary.Nodes = len(nodes.Items)
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if nodeReady(node) {
+			summary.ReadyNodes++
+		}
+		if !node.Spec.Unschedulable {
+			summary.SchedulableNodes++
+		}
+		summary.CPUAllocatable.Add(node.Status.Allocatable[corev1.ResourceCPU])
+		summary.MemoryAllocatable.Add(node.Status.Allocatable[corev1.ResourceMemory])
+		summary.PodsAllocatable += node.Status.Allocatable.Pods().Value()
+	}
+
+	pods := &corev1.PodList{}
+	if err := c.readList(ctx, pods); err != nil {
+		return nil, c.clusterError(err, corev1.SchemeGroupVersion.WithKind("Pod"))
+	}
+	summary.Pods = len(pods.Items)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		summary.PodsByPhase[pod.Status.Phase]++
+		if len(pod.Spec.NodeName) == 0 || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		requests := PodRequests(pod)
+		summary.CPURequested.Add(requests[corev1.ResourceCPU])
+		summary.MemoryRequested.Add(requests[corev1.ResourceMemory])
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := c.readList(ctx, namespaces); err != nil {
+		return nil, c.clusterError(err, corev1.SchemeGroupVersion.WithKind("Namespace"))
+	}
+	summary.Namespaces = len(namespaces.Items)
+	return summary, nil
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// PodRequests 计算Pod的有效资源请求, 与调度器一致: 普通容器与sidecar(restartPolicy为Always的init容器)之和,
+// 与每个普通init容器启动时所需资源取较大值, 再加上 spec.overhead
+func PodRequests(pod *corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		addResourceList(requests, container.Resources.Requests)
+	}
+
+	sidecars := corev1.ResourceList{}
+	initPeak := corev1.ResourceList{}
+	for _, container := range pod.Spec.InitContainers {
+		if container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			addResourceList(requests, container.Resources.Requests)
+			addResourceList(sidecars, container.Resources.Requests)
+			continue
+		}
+		// 普通init容器运行时, 之前启动的sidecar同时在运行
+		running := sidecars.DeepCopy()
+		addResourceList(running, container.Resources.Requests)
+		maxResourceList(initPeak, running)
+	}
+	maxResourceList(requests, initPeak)
+	addResourceList(requests, pod.Spec.Overhead)
+	return requests
+}
+
+func addResourceList(list, add corev1.ResourceList) {
+	for name, quantity := range add {
+		value := list[name]
+		value.Add(quantity)
+		list[name] = value
+	}
+}
+
+func maxResourceList(list, other corev1.ResourceList) {
+	for name, quantity := range other {
+		if value, ok := list[name]; !ok || quantity.Cmp(value) > 0 {
+			list[name] = quantity.DeepCopy()
+		}
+	}
+}
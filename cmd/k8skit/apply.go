@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	kit "github.com/linkinghack/k8s-client-kit"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// bundleLabel 标记对象所属的bundle, prune时据此找出不再属于bundle的对象
+const bundleLabel = "k8s-client-kit.io/bundle"
+
+const defaultFieldManager = "k8skit"
+
+func runApply(ctx context.Context, g *globalFlags, args []string) error {
+	fs := flag.NewFlagSet("apply", flag.ExitOnError)
+	file := fs.String("f", "", "manifest文件或目录, `-` 表示标准输入")
+	bundle := fs.String("bundle", "", "bundle标识, 以label "+bundleLabel+" 记录在对象上")
+	prune := fs.Bool("prune", false, "删除属于该bundle但不在本次manifest中的对象, 需要 -bundle")
+	lock := fs.Bool("lock", true, "指定 -bundle 时持有bundle锁, 避免并发apply相互prune")
+	fieldManager := fs.String("field-manager", defaultFieldManager, "字段管理者名称")
+	planPath := fs.String("plan", "", "执行 `plan -out` 保存的计划, 此时不需要 -f, 也不重新计算变更")
+	verifyKey := fs.String("verify-key", "", "PEM格式的受信任Ed25519公钥, 指定时apply前校验manifest的签名(见sign命令), 不能与 -plan 同时使用")
+	signature := fs.String("signature", "", "签名文件, 默认为 `<-f>.sig`")
+	_ = fs.Parse(args)
+
+	if len(*planPath) > 0 {
+		if len(*verifyKey) > 0 || len(*signature) > 0 {
+			// 计划文件没有签名, 静默忽略 -verify-key 会应用未经校验的内容
+			return errors.New("-verify-key 与 -signature 不能与 -plan 同时使用: 计划文件不包含签名, 应在 `plan` 时以 -f 指定已签名的manifest并校验")
+		}
+		return applyPlans(ctx, g, *planPath, *lock, *bundle)
+	}
+	if len(*file) == 0 {
+		return errors.New("需要通过 -f 指定manifest")
+	}
+	if *prune && len(*bundle) == 0 {
+		return errors.New("-prune 需要同时指定 -bundle")
+	}
+	if len(*bundle) > 0 {
+		if msgs := validation.IsValidLabelValue(*bundle); len(msgs) > 0 {
+			return errors.New("bundle标识不是合法的label值: " + strings.Join(msgs, "; "))
+		}
+	}
+	objs, err := readManifests(*file)
+	if err != nil {
+		return err
+	}
+	if len(*verifyKey) > 0 {
+		if err := verifyManifests(objs, *file, *signature, *verifyKey); err != nil {
+			return err
+		}
+	}
+
+	clients, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeAll(clients)
+
+	return g.fanOut(ctx, clients, func(ctx context.Context, cli *kit.GenericK8sClient, out *printer) error {
+		apply := func(ctx context.Context) error {
+			if len(*bundle) > 0 {
+				ctx = kit.ContextWithBundleID(ctx, *bundle)
+			}
+			applied, err := applyBundle(ctx, cli, objs, *bundle, *fieldManager, out)
+			if err != nil || !*prune {
+				return err
+			}
+			return pruneBundle(ctx, cli, *bundle, applied, out)
+		}
+		if len(*bundle) == 0 || !*lock {
+			return apply(ctx)
+		}
+		return cli.WithBundleLock(ctx, *bundle, kit.BundleLockOptions{}, apply)
+	})
+}
+
+// applyBundle 应用全部对象, 返回成功应用的对象标识及其GVK. 任一对象失败时返回汇总错误, 此时不应prune.
+func applyBundle(ctx context.Context, cli *kit.GenericK8sClient, objs []*unstructured.Unstructured, bundle, fieldManager string, out *printer) (map[string]schema.GroupVersionKind, error) {
+	applied := map[string]schema.GroupVersionKind{}
+	var errs []error
+	for _, obj := range objs {
+		obj = withBundleLabel(obj.DeepCopy(), bundle)
+		if _, err := applyObject(ctx, cli, obj, fieldManager); err != nil {
+			errs = append(errs, err)
+			out.Printf("%s 失败: %v", kit.ObjectIdentity(obj), err)
+			continue
+		}
+		applied[kit.ObjectIdentity(obj)] = obj.GroupVersionKind()
+		out.Printf("%s 已应用", kit.ObjectIdentity(obj))
+	}
+	return applied, utilerrors.NewAggregate(errs)
+}
+
+// pruneBundle 删除带有bundle label但不在applied中的对象. 只检查本次manifest中出现过的资源类型,
+// 从bundle中整体移除的资源类型需手动清理.
+func pruneBundle(ctx context.Context, cli *kit.GenericK8sClient, bundle string, applied map[string]schema.GroupVersionKind, out *printer) error {
+	gvrs := map[schema.GroupVersionResource]bool{}
+	for _, gvk := range applied {
+		gvr, err := cli.GvkToGvr(gvk)
+		if err != nil {
+			return err
+		}
+		gvrs[gvr] = true
+	}
+
+	selector := labels.SelectorFromSet(labels.Set{bundleLabel: bundle}).String()
+	var errs []error
+	for gvr := range gvrs {
+		list, err := cli.GetDynamicClient().Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "无法列出 "+gvr.String()))
+			continue
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if _, ok := applied[kit.ObjectIdentity(obj)]; ok {
+				continue
+			}
+			if err := cli.DeleteUnstructuredObj(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, err)
+				out.Printf("%s 清理失败: %v", kit.ObjectIdentity(obj), err)
+				continue
+			}
+			out.Printf("%s 已清理", kit.ObjectIdentity(obj))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
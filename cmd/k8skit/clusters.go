@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+
+	kit "github.com/linkinghack/k8s-client-kit"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// globalFlags 为所有命令共享的集群选择参数
+type globalFlags struct {
+	kubeconfig  string
+	context     string
+	allContexts bool
+	concurrency int
+	dryRun      bool
+}
+
+func (g *globalFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.kubeconfig, "kubeconfig", "", "kubeconfig文件路径, 为空时使用KUBECONFIG环境变量或 ~/.kube/config")
+	fs.StringVar(&g.context, "context", "", "使用的context, 为空时使用current-context")
+	fs.BoolVar(&g.allContexts, "all-contexts", false, "对kubeconfig中的全部context并行执行命令")
+	fs.IntVar(&g.concurrency, "concurrency", 8, "-all-contexts 时同时操作的集群数")
+	fs.BoolVar(&g.dryRun, "dry-run", false, "所有写操作以服务端dry-run方式执行")
+}
+
+// connect 按全局参数创建客户端, 以context名称为key
+func (g *globalFlags) connect(ctx context.Context, opts ...kit.Option) (map[string]*kit.GenericK8sClient, error) {
+	opts = append([]kit.Option{kit.WithUserAgent("k8skit")}, opts...)
+	if g.dryRun {
+		opts = append(opts, kit.WithDryRun())
+	}
+
+	if !g.allContexts {
+		id := g.context
+		if len(id) == 0 {
+			id = "current"
+		}
+		cli, err := kit.NewGenericK8sClientWithKubeConfigFileContext(ctx, id, g.kubeconfig, g.context, "", opts...)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]*kit.GenericK8sClient{id: cli}, nil
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if len(g.kubeconfig) > 0 {
+		loadingRules = &clientcmd.ClientConfigLoadingRules{ExplicitPath: g.kubeconfig}
+	}
+	config, err := loadingRules.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, "无法加载kubeconfig")
+	}
+	kubeConfig, err := clientcmd.Write(*config)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法序列化kubeconfig")
+	}
+	clients, err := kit.NewGenericK8sClientsFromKubeConfigContext(ctx, kubeConfig, opts...)
+	if err != nil {
+		// 部分context不可用时继续操作其余集群
+		fmt.Fprintln(os.Stderr, "警告:", err)
+	}
+	if len(clients) == 0 {
+		return nil, errors.New("没有可用的集群")
+	}
+	return clients, nil
+}
+
+// fanOut 通过 FleetExec 对每个集群并行执行fn, 返回汇总的错误. 多个集群时输出以 `[集群]` 为前缀.
+func (g *globalFlags) fanOut(ctx context.Context, clients map[string]*kit.GenericK8sClient, fn func(ctx context.Context, cli *kit.GenericK8sClient, out *printer) error) error {
+	// registry仅用于调度, 客户端不会被启动, 仍由调用方关闭
+	registry := kit.NewClusterRegistry()
+	for _, cli := range clients {
+		if err := registry.Register(cli); err != nil {
+			return err
+		}
+	}
+
+	concurrency := g.concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	lock := &sync.Mutex{}
+	_, err := registry.FleetExec(ctx, nil, func(ctx context.Context, cli *kit.GenericK8sClient) error {
+		out := &printer{lock: lock}
+		if len(clients) > 1 {
+			out.prefix = "[" + cli.TargetK8sApiServerId + "] "
+		}
+		return fn(ctx, cli, out)
+	}, kit.FleetExecOptions{MaxConcurrency: concurrency})
+	return err
+}
+
+// closeAll 关闭全部客户端
+func closeAll(clients map[string]*kit.GenericK8sClient) {
+	for _, cli := range clients {
+		cli.Close()
+	}
+}
+
+// printer 保证并发集群的输出按行完整
+type printer struct {
+	lock   *sync.Mutex
+	prefix string
+}
+
+func (p *printer) Printf(format string, args ...interface{}) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	fmt.Fprintln(os.Stdout, p.prefix+fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	kit "github.com/linkinghack/k8s-client-kit"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+func runDiff(ctx context.Context, g *globalFlags, args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	file := fs.String("f", "", "manifest文件或目录, `-` 表示标准输入")
+	fieldManager := fs.String("field-manager", defaultFieldManager, "字段管理者名称")
+	_ = fs.Parse(args)
+
+	if len(*file) == 0 {
+		return errors.New("需要通过 -f 指定manifest")
+	}
+	objs, err := readManifests(*file)
+	if err != nil {
+		return err
+	}
+
+	clients, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeAll(clients)
+
+	return g.fanOut(ctx, clients, func(ctx context.Context, cli *kit.GenericK8sClient, out *printer) error {
+		// 以dry-run方式应用得到包含服务端默认值的期望状态, 与集群中的对象比较
+		dryRunCli, err := cli.Clone(kit.WithDryRun())
+		if err != nil {
+			return err
+		}
+		defer dryRunCli.Close()
+
+		var errs []error
+		for _, obj := range objs {
+			obj = obj.DeepCopy()
+			if err := setDefaultNamespace(cli, obj); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			identity := kit.ObjectIdentity(obj)
+			live, err := cli.GetUnstructuredObj(ctx, obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+			if apierrors.IsNotFound(err) {
+				live, err = nil, nil
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			desired, err := applyObject(ctx, dryRunCli, obj, *fieldManager)
+			if err != nil {
+				errs = append(errs, err)
+				out.Printf("! %s dry-run失败: %v", identity, err)
+				continue
+			}
+			if live == nil {
+				out.Printf("+ %s", identity)
+				continue
+			}
+			changes := kit.DiffObjects(live, desired)
+			if len(changes) == 0 {
+				out.Printf("= %s", identity)
+				continue
+			}
+			out.Printf("~ %s", identity)
+			for _, change := range changes {
+				out.Printf("    %s", change)
+			}
+		}
+		return utilerrors.NewAggregate(errs)
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"time"
+
+	kit "github.com/linkinghack/k8s-client-kit"
+)
+
+func runHealth(ctx context.Context, g *globalFlags, args []string) error {
+	fs := flag.NewFlagSet("health", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 10*time.Second, "每个集群的探测超时")
+	_ = fs.Parse(args)
+
+	// 不可达的集群也应输出探测结果, 因此跳过构造时的连通性检查
+	clients, err := g.connect(ctx, kit.WithLazyConnect())
+	if err != nil {
+		return err
+	}
+	defer closeAll(clients)
+
+	return g.fanOut(ctx, clients, func(ctx context.Context, cli *kit.GenericK8sClient, out *printer) error {
+		ctx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		status := cli.ProbeHealth(ctx)
+		if status.LastError != nil {
+			out.Printf("reachable=%t ready=%t error=%v", status.Reachable, status.Ready, status.LastError)
+			return status.LastError
+		}
+		out.Printf("reachable=%t ready=%t version=%s latency=%s", status.Reachable, status.Ready, status.ServerVersion, status.Latency)
+		return nil
+	})
+}
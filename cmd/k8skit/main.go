@@ -0,0 +1,72 @@
+// k8skit 是基于k8s-client-kit的命令行工具, 用于直接调用库的主要功能(plan/apply/prune, 离线校验, diff, watch转发,
+// 多集群fan-out, 快照与恢复), 便于调试库的行为, 同时作为库功能的可执行参考用法.
+//
+//	k8skit [全局参数] <命令> [命令参数]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// command 为一个子命令
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, g *globalFlags, args []string) error
+}
+
+var commands = []*command{
+	{name: "apply", summary: "应用manifest, 可按bundle清理不再存在的对象", run: runApply},
+	{name: "plan", summary: "计算manifest将执行的操作(创建/修改/清理/不变), 可保存后由apply -plan执行", run: runPlan},
+	{name: "validate", summary: "以CRD中的CEL规则离线校验manifest中的自定义资源", run: runValidate},
+	{name: "diff", summary: "以服务端dry-run对比manifest与集群中的对象", run: runDiff},
+	{name: "watch", summary: "watch资源变化, 输出到标准输出或转发到HTTP地址", run: runWatch},
+	{name: "snapshot", summary: "将资源以一致性快照导出到文件", run: runSnapshot},
+	{name: "sign", summary: "以Ed25519私钥为manifest或快照生成分离式签名, apply/restore可通过 -verify-key 校验", run: runSign},
+	{name: "restore", summary: "将snapshot导出的文件恢复到集群", run: runRestore},
+	{name: "health", summary: "探测集群健康状态", run: runHealth},
+}
+
+func main() {
+	g := &globalFlags{}
+	fs := flag.NewFlagSet("k8skit", flag.ExitOnError)
+	g.register(fs)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintln(out, "用法: k8skit [全局参数] <命令> [命令参数]")
+		fmt.Fprintln(out, "\n命令:")
+		for _, cmd := range commands {
+			fmt.Fprintf(out, "  %-10s %s\n", cmd.name, cmd.summary)
+		}
+		fmt.Fprintln(out, "\n全局参数:")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(os.Args[1:])
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	name := fs.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := cmd.run(ctx, g, fs.Args()[1:])
+		stop()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "错误:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintln(os.Stderr, "未知命令:", name)
+	fs.Usage()
+	os.Exit(2)
+}
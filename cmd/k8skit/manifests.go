@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	kit "github.com/linkinghack/k8s-client-kit"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// readManifests 读取manifest文件, path 可为文件, 目录(读取其中的.yaml/.yml/.json文件)或 `-` 表示标准输入.
+// 支持多文档YAML以及 `kind: List`.
+func readManifests(path string) ([]*unstructured.Unstructured, error) {
+	if path == "-" {
+		return decodeManifests(os.Stdin, "stdin")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法读取 "+path)
+	}
+	if !info.IsDir() {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法读取 "+path)
+		}
+		defer f.Close()
+		return decodeManifests(f, path)
+	}
+
+	var objs []*unstructured.Unstructured
+	err = filepath.WalkDir(path, func(file string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+		fileObjs, err := readManifests(file)
+		objs = append(objs, fileObjs...)
+		return err
+	})
+	return objs, err
+}
+
+func decodeManifests(r io.Reader, source string) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	var objs []*unstructured.Unstructured
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if err == io.EOF {
+				return objs, nil
+			}
+			return nil, errors.Wrap(err, "无法解析 "+source)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if !obj.IsList() {
+			objs = append(objs, obj)
+			continue
+		}
+		list, err := obj.ToList()
+		if err != nil {
+			return nil, errors.Wrap(err, "无法解析 "+source+" 中的List")
+		}
+		for i := range list.Items {
+			objs = append(objs, &list.Items[i])
+		}
+	}
+}
+
+// setDefaultNamespace 为未指定namespace的namespace级别对象设置 `default`
+func setDefaultNamespace(cli *kit.GenericK8sClient, obj *unstructured.Unstructured) error {
+	if len(obj.GetNamespace()) > 0 {
+		return nil
+	}
+	gvk := obj.GroupVersionKind()
+	mapping, err := cli.GetRuntimeCluster().GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return errors.Wrap(err, "无法识别资源类型 "+gvk.String())
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		obj.SetNamespace(metav1.NamespaceDefault)
+	}
+	return nil
+}
+
+// applyObject 以server-side apply创建或更新对象, 返回APIServer返回的对象.
+// 未指定namespace的namespace级别对象会被原地设置为 `default`.
+func applyObject(ctx context.Context, cli *kit.GenericK8sClient, obj *unstructured.Unstructured, fieldManager string) (*unstructured.Unstructured, error) {
+	if err := setDefaultNamespace(cli, obj); err != nil {
+		return nil, err
+	}
+	desired := obj.DeepCopy()
+	desired.SetResourceVersion("")
+	result, err := cli.ApplyUnstructuredObj(ctx, desired, fieldManager)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法应用 "+kit.ObjectIdentity(obj))
+	}
+	return result.ResultObject, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	kit "github.com/linkinghack/k8s-client-kit"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// runPlan 计算manifest在各集群中将执行的操作, 不修改集群. 保存的计划经审核后可由 `apply -plan` 执行
+func runPlan(ctx context.Context, g *globalFlags, args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	file := fs.String("f", "", "manifest文件或目录, `-` 表示标准输入")
+	bundle := fs.String("bundle", "", "bundle标识, 以label "+bundleLabel+" 记录在对象上")
+	prune := fs.Bool("prune", false, "计划删除属于该bundle但不在本次manifest中的对象, 需要 -bundle")
+	fieldManager := fs.String("field-manager", defaultFieldManager, "字段管理者名称")
+	forceConflicts := fs.Bool("force-conflicts", false, "强制接管其他字段管理者拥有的字段, 计划保存该设置, `apply -plan` 时沿用")
+	output := fs.String("out", "", "保存计划的文件, 格式由扩展名决定(json/yaml)")
+	verifyKey := fs.String("verify-key", "", "PEM格式的受信任Ed25519公钥, 指定时计算计划前校验manifest的签名(见sign命令)")
+	signature := fs.String("signature", "", "签名文件, 默认为 `<-f>.sig`")
+	_ = fs.Parse(args)
+
+	if len(*file) == 0 {
+		return errors.New("需要通过 -f 指定manifest")
+	}
+	if *prune && len(*bundle) == 0 {
+		return errors.New("-prune 需要同时指定 -bundle")
+	}
+	if len(*bundle) > 0 {
+		if msgs := validation.IsValidLabelValue(*bundle); len(msgs) > 0 {
+			return errors.New("bundle标识不是合法的label值: " + strings.Join(msgs, "; "))
+		}
+	}
+	objs, err := readManifests(*file)
+	if err != nil {
+		return err
+	}
+	if len(*verifyKey) > 0 {
+		if err := verifyManifests(objs, *file, *signature, *verifyKey); err != nil {
+			return err
+		}
+	}
+	for i, obj := range objs {
+		objs[i] = withBundleLabel(obj, *bundle)
+	}
+	opts := kit.PlanOptions{FieldManager: *fieldManager, ForceConflicts: *forceConflicts}
+	if *prune {
+		opts.PruneSelector = labels.SelectorFromSet(labels.Set{bundleLabel: *bundle}).String()
+	}
+
+	clients, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeAll(clients)
+
+	lock := sync.Mutex{}
+	var plans []*kit.ApplyPlan
+	err = g.fanOut(ctx, clients, func(ctx context.Context, cli *kit.GenericK8sClient, out *printer) error {
+		plan, err := cli.Plan(ctx, objs, opts)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(plan.String(), "\n"), "\n") {
+			out.Printf("%s", line)
+		}
+		lock.Lock()
+		plans = append(plans, plan)
+		lock.Unlock()
+		return nil
+	})
+	if err != nil || len(*output) == 0 {
+		return err
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].ClusterId < plans[j].ClusterId
+	})
+	return savePlans(*output, plans)
+}
+
+// applyPlans 执行 `plan -out` 保存的计划, 每个集群只执行属于它的计划
+func applyPlans(ctx context.Context, g *globalFlags, path string, lock bool, bundle string) error {
+	plans, err := loadPlans(path)
+	if err != nil {
+		return err
+	}
+	byCluster := make(map[string]*kit.ApplyPlan, len(plans))
+	for _, plan := range plans {
+		byCluster[plan.ClusterId] = plan
+	}
+
+	clients, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeAll(clients)
+
+	return g.fanOut(ctx, clients, func(ctx context.Context, cli *kit.GenericK8sClient, out *printer) error {
+		plan, ok := byCluster[cli.TargetK8sApiServerId]
+		if !ok {
+			out.Printf("计划中没有该集群, 跳过")
+			return nil
+		}
+		apply := func(ctx context.Context) error {
+			if len(bundle) > 0 {
+				ctx = kit.ContextWithBundleID(ctx, bundle)
+			}
+			results, err := cli.Apply(ctx, plan)
+			for _, result := range results {
+				out.Printf("%s", result.String())
+			}
+			return err
+		}
+		if len(bundle) == 0 || !lock {
+			return apply(ctx)
+		}
+		return cli.WithBundleLock(ctx, bundle, kit.BundleLockOptions{}, apply)
+	})
+}
+
+// withBundleLabel 返回带有bundle label的对象副本, bundle为空时原样返回
+func withBundleLabel(obj *unstructured.Unstructured, bundle string) *unstructured.Unstructured {
+	if len(bundle) == 0 {
+		return obj
+	}
+	obj = obj.DeepCopy()
+	objLabels := obj.GetLabels()
+	if objLabels == nil {
+		objLabels = map[string]string{}
+	}
+	objLabels[bundleLabel] = bundle
+	obj.SetLabels(objLabels)
+	return obj
+}
+
+// savePlans 以文件扩展名对应的格式保存各集群的计划列表
+func savePlans(path string, plans []*kit.ApplyPlan) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "无法创建文件 "+path)
+	}
+	defer f.Close()
+	if err := kit.EncoderForPath(path).Encode(f, plans); err != nil {
+		return errors.Wrap(err, "无法保存计划")
+	}
+	return nil
+}
+
+// loadPlans 读取 savePlans 保存的计划列表, 或 ApplyPlan.Save 保存的单个计划
+func loadPlans(path string) ([]*kit.ApplyPlan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法读取 "+path)
+	}
+	enc := kit.EncoderForPath(path)
+	var plans []*kit.ApplyPlan
+	if err := enc.Decode(bytes.NewReader(data), &plans); err == nil {
+		return plans, nil
+	}
+	plan := &kit.ApplyPlan{}
+	if err := enc.Decode(bytes.NewReader(data), plan); err != nil {
+		return nil, errors.Wrap(err, "无法解析计划 "+path)
+	}
+	return []*kit.ApplyPlan{plan}, nil
+}
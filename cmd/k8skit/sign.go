@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	kit "github.com/linkinghack/k8s-client-kit"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func runSign(ctx context.Context, g *globalFlags, args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	file := fs.String("f", "", "待签名的manifest文件或目录(如snapshot导出的文件)")
+	keyPath := fs.String("key", "", "PEM格式的Ed25519私钥")
+	out := fs.String("o", "", "签名输出路径, 默认为 `<-f>.sig`")
+	newKey := fs.String("new-key", "", "生成新的密钥对, 写入 `<前缀>.key` 与 `<前缀>.pub` 后退出")
+	_ = fs.Parse(args)
+
+	if len(*newKey) > 0 {
+		pub, priv, err := kit.GenerateBundleSigningKey()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*newKey+".key", priv, 0o600); err != nil {
+			return errors.Wrap(err, "无法写入私钥")
+		}
+		if err := os.WriteFile(*newKey+".pub", pub, 0o644); err != nil {
+			return errors.Wrap(err, "无法写入公钥")
+		}
+		fmt.Printf("已生成 %s.key 与 %s.pub\n", *newKey, *newKey)
+		return nil
+	}
+	if len(*file) == 0 || *file == "-" {
+		return errors.New("需要通过 -f 指定manifest文件或目录")
+	}
+	if len(*keyPath) == 0 {
+		return errors.New("需要通过 -key 指定私钥")
+	}
+	keyData, err := os.ReadFile(*keyPath)
+	if err != nil {
+		return errors.Wrap(err, "无法读取私钥")
+	}
+	key, err := kit.ParseEd25519PrivateKey(keyData)
+	if err != nil {
+		return err
+	}
+	objs, err := readManifests(*file)
+	if err != nil {
+		return err
+	}
+	sig, err := kit.SignBundle(objs, key)
+	if err != nil {
+		return err
+	}
+
+	sigPath := *out
+	if len(sigPath) == 0 {
+		sigPath = signaturePath(*file)
+	}
+	f, err := os.Create(sigPath)
+	if err != nil {
+		return errors.Wrap(err, "无法创建 "+sigPath)
+	}
+	defer f.Close()
+	if err := sig.Save(f); err != nil {
+		return errors.Wrap(err, "无法写入 "+sigPath)
+	}
+	fmt.Printf("已签名 %d 个对象, 摘要 %s, keyId %s, 签名写入 %s\n", len(objs), sig.Digest, sig.KeyID, sigPath)
+	return nil
+}
+
+// signaturePath 返回manifest默认的签名文件路径 `<path>.sig`
+func signaturePath(path string) string {
+	return filepath.Clean(path) + ".sig"
+}
+
+// verifyManifests 以keyPath中的受信任公钥校验objs与签名文件sigPath(为空时使用 `<path>.sig`)
+func verifyManifests(objs []*unstructured.Unstructured, path, sigPath, keyPath string) error {
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return errors.Wrap(err, "无法读取公钥")
+	}
+	keys, err := kit.ParseEd25519PublicKeys(keyData)
+	if err != nil {
+		return err
+	}
+	if len(sigPath) == 0 {
+		if path == "-" {
+			return errors.New("从标准输入读取manifest时需要通过 -signature 指定签名文件")
+		}
+		sigPath = signaturePath(path)
+	}
+	f, err := os.Open(sigPath)
+	if err != nil {
+		return errors.Wrap(err, "无法读取签名 "+sigPath)
+	}
+	defer f.Close()
+	sig, err := kit.LoadBundleSignature(f)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(kit.VerifyBundle(objs, sig, keys...), path)
+}
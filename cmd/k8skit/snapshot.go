@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	kit "github.com/linkinghack/k8s-client-kit"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+func runSnapshot(ctx context.Context, g *globalFlags, args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
+	resources := fs.String("resources", "", "逗号分隔的资源列表, 格式同watch的 -resource, 如 v1/configmaps,apps/v1/deployments")
+	namespaces := fs.String("n", "", "逗号分隔的namespace列表, 为空时导出所有namespace")
+	outDir := fs.String("o", ".", "输出目录, 每个集群写入 `<集群>.<格式扩展名>`")
+	format := fs.String("format", "yaml", "输出格式, 为已注册的Encoder名称")
+	_ = fs.Parse(args)
+
+	if len(*resources) == 0 {
+		return errors.New("需要通过 -resources 指定导出的资源")
+	}
+	var gvrs []string
+	for _, r := range strings.Split(*resources, ",") {
+		if r = strings.TrimSpace(r); len(r) > 0 {
+			gvrs = append(gvrs, r)
+		}
+	}
+	nsList := []string{metav1.NamespaceAll}
+	if len(*namespaces) > 0 {
+		nsList = strings.Split(*namespaces, ",")
+	}
+	encoder, ok := kit.GetEncoder(*format)
+	if !ok {
+		return errors.New("未注册的格式 " + *format)
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		return errors.Wrap(err, "无法创建输出目录")
+	}
+
+	clients, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeAll(clients)
+
+	return g.fanOut(ctx, clients, func(ctx context.Context, cli *kit.GenericK8sClient, out *printer) error {
+		// 所有资源读取同一时刻的集群状态
+		ctx, snapshot := kit.ContextWithReadSnapshot(ctx, nil)
+		items := []interface{}{}
+		for _, r := range gvrs {
+			gvr, err := parseGVR(r)
+			if err != nil {
+				return err
+			}
+			list, err := cli.ListAcrossNamespaces(ctx, gvr, nsList, kit.ListAcrossNamespacesOptions{})
+			if err != nil {
+				return err
+			}
+			for i := range list.Items {
+				items = append(items, kit.StripServerFields(&list.Items[i]).Object)
+			}
+		}
+
+		doc := map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"metadata":   map[string]interface{}{"resourceVersion": snapshot.ResourceVersion()},
+			"items":      items,
+		}
+		path := filepath.Join(*outDir, cli.TargetK8sApiServerId+"."+encoder.Extension())
+		f, err := os.Create(path)
+		if err != nil {
+			return errors.Wrap(err, "无法创建 "+path)
+		}
+		defer f.Close()
+		if err := encoder.Encode(f, doc); err != nil {
+			return errors.Wrap(err, "无法写入 "+path)
+		}
+		out.Printf("已导出 %d 个对象到 %s (resourceVersion %s)", len(items), path, snapshot.ResourceVersion())
+		return nil
+	})
+}
+
+func runRestore(ctx context.Context, g *globalFlags, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	file := fs.String("f", "", "snapshot导出的文件; 为目录时每个集群读取其中的 `<集群>.*` 文件")
+	fieldManager := fs.String("field-manager", defaultFieldManager, "字段管理者名称")
+	verifyKey := fs.String("verify-key", "", "PEM格式的受信任Ed25519公钥, 指定时恢复前校验快照文件的签名 `<快照文件>.sig`")
+	_ = fs.Parse(args)
+
+	if len(*file) == 0 {
+		return errors.New("需要通过 -f 指定快照文件")
+	}
+	info, err := os.Stat(*file)
+	if err != nil {
+		return errors.Wrap(err, "无法读取 "+*file)
+	}
+
+	clients, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeAll(clients)
+
+	return g.fanOut(ctx, clients, func(ctx context.Context, cli *kit.GenericK8sClient, out *printer) error {
+		path := *file
+		if info.IsDir() {
+			var matches []string
+			candidates, _ := filepath.Glob(filepath.Join(*file, cli.TargetK8sApiServerId+".*"))
+			for _, candidate := range candidates {
+				if filepath.Ext(candidate) != ".sig" {
+					matches = append(matches, candidate)
+				}
+			}
+			if len(matches) == 0 {
+				return errors.New("目录 " + *file + " 中没有集群 " + cli.TargetK8sApiServerId + " 的快照")
+			}
+			path = matches[0]
+		}
+		objs, err := readManifests(path)
+		if err != nil {
+			return err
+		}
+		if len(*verifyKey) > 0 {
+			if err := verifyManifests(objs, path, "", *verifyKey); err != nil {
+				return err
+			}
+		}
+		sortForRestore(objs)
+
+		var errs []error
+		for _, obj := range objs {
+			// 由控制器创建的对象(如ReplicaSet, Pod)由其owner重新生成
+			if metav1.GetControllerOfNoCopy(obj) != nil {
+				out.Printf("%s 由控制器管理, 跳过", kit.ObjectIdentity(obj))
+				continue
+			}
+			obj = obj.DeepCopy()
+			obj.SetOwnerReferences(nil)
+			if _, err := applyObject(ctx, cli, obj, *fieldManager); err != nil {
+				errs = append(errs, err)
+				out.Printf("%s 恢复失败: %v", kit.ObjectIdentity(obj), err)
+				continue
+			}
+			out.Printf("%s 已恢复", kit.ObjectIdentity(obj))
+		}
+		return utilerrors.NewAggregate(errs)
+	})
+}
+
+// sortForRestore 将Namespace与CRD排在最前, 保证其余对象恢复时依赖已存在
+func sortForRestore(objs []*unstructured.Unstructured) {
+	rank := func(obj *unstructured.Unstructured) int {
+		switch obj.GetKind() {
+		case "Namespace":
+			return 0
+		case "CustomResourceDefinition":
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		return rank(objs[i]) < rank(objs[j])
+	})
+}
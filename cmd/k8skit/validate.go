@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	kit "github.com/linkinghack/k8s-client-kit"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// runValidate 离线校验manifest中的自定义资源是否满足CRD中的CEL规则, 不连接集群
+func runValidate(ctx context.Context, g *globalFlags, args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	file := fs.String("f", "", "manifest文件或目录, `-` 表示标准输入, 其中的CRD同样用于校验")
+	crdPath := fs.String("crds", "", "额外的CRD文件或目录")
+	_ = fs.Parse(args)
+
+	if len(*file) == 0 {
+		return errors.New("需要通过 -f 指定manifest")
+	}
+	objs, err := readManifests(*file)
+	if err != nil {
+		return err
+	}
+	var crds []*unstructured.Unstructured
+	if len(*crdPath) > 0 {
+		if crds, err = readManifests(*crdPath); err != nil {
+			return err
+		}
+	}
+
+	violations, err := kit.ValidateCustomResources(objs, crds...)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, violation := range violations {
+		if violation.Err != nil {
+			fmt.Fprintln(os.Stderr, "警告:", violation)
+			continue
+		}
+		fmt.Println(violation)
+		failed++
+	}
+	if failed > 0 {
+		return errors.Errorf("%d 处违反CRD校验规则", failed)
+	}
+	return nil
+}
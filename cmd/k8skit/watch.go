@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	kit "github.com/linkinghack/k8s-client-kit"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// watchEvent 为watch输出及转发的事件格式
+type watchEvent struct {
+	Cluster string                     `json:"cluster"`
+	Type    string                     `json:"type"`
+	Time    time.Time                  `json:"time"`
+	Object  *unstructured.Unstructured `json:"object"`
+}
+
+func runWatch(ctx context.Context, g *globalFlags, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	resource := fs.String("resource", "", "资源, 格式为 `group/version/resource`, core组为 `v1/resource`, 如 apps/v1/deployments")
+	namespace := fs.String("n", "", "namespace, 为空时watch所有namespace")
+	selector := fs.String("l", "", "label selector")
+	forward := fs.String("forward", "", "将事件以JSON POST到该HTTP地址, 为空时输出到标准输出")
+	output := fs.String("o", "line", "标准输出格式: line 或 json")
+	_ = fs.Parse(args)
+
+	gvr, err := parseGVR(*resource)
+	if err != nil {
+		return err
+	}
+
+	clients, err := g.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeAll(clients)
+
+	events := make(chan watchEvent, 1024)
+	for id, cli := range clients {
+		id := id
+		emit := func(eventType string, obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return
+			}
+			select {
+			case events <- watchEvent{Cluster: id, Type: eventType, Time: time.Now(), Object: u}:
+			case <-ctx.Done():
+			}
+		}
+		watcher := kit.NewDynamicWatcher(cli.GetDynamicClient(), gvr, *namespace, 0, nil, func(opts *metav1.ListOptions) {
+			opts.LabelSelector = *selector
+		})
+		watcher.AddEventHandler(func(obj interface{}) {
+			emit("Added", obj)
+		}, func(obj interface{}) {
+			emit("Deleted", obj)
+		}, func(oldObj, newObj interface{}) {
+			emit("Updated", newObj)
+		})
+		cli.RunWatcher(watcher)
+	}
+
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	encoder := json.NewEncoder(os.Stdout)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-events:
+			switch {
+			case len(*forward) > 0:
+				if err := forwardEvent(ctx, httpClient, *forward, event); err != nil {
+					fmt.Fprintln(os.Stderr, "转发事件失败:", err)
+				}
+			case *output == "json":
+				_ = encoder.Encode(event)
+			default:
+				fmt.Printf("%s [%s] %s %s\n", event.Time.Format(time.RFC3339), event.Cluster, event.Type, kit.ObjectIdentity(event.Object))
+			}
+		}
+	}
+}
+
+func forwardEvent(ctx context.Context, httpClient *http.Client, url string, event watchEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.New("接收方返回 " + resp.Status)
+	}
+	return nil
+}
+
+// parseGVR 解析 `group/version/resource` 或 `version/resource`(core组)
+func parseGVR(s string) (schema.GroupVersionResource, error) {
+	parts := strings.Split(s, "/")
+	switch len(parts) {
+	case 2:
+		return schema.GroupVersionResource{Version: parts[0], Resource: parts[1]}, nil
+	case 3:
+		return schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}, nil
+	}
+	return schema.GroupVersionResource{}, errors.New("无效的资源 " + s + ", 格式应为 group/version/resource 或 v1/resource")
+}
@@ -0,0 +1,282 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// DefaultCopyFieldManager 为 CopyResources 默认使用的字段管理者名称
+const DefaultCopyFieldManager = "k8s-client-kit"
+
+// ErrCopyConflict 目标集群中已存在同名对象, 且冲突策略为 CopyConflictFail
+var ErrCopyConflict = errors.New("目标集群中已存在同名对象")
+
+// CopyConflictStrategy 为目标集群中已存在同名对象时的处理方式
+type CopyConflictStrategy string
+
+const (
+	// CopyConflictSkip 保留目标集群中的对象, 不做修改(默认)
+	CopyConflictSkip CopyConflictStrategy = "skip"
+	// CopyConflictOverwrite 以源对象覆盖目标对象(server-side apply, 强制获取冲突字段)
+	CopyConflictOverwrite CopyConflictStrategy = "overwrite"
+	// CopyConflictFail 记录 ErrCopyConflict 错误, 不修改目标对象
+	CopyConflictFail CopyConflictStrategy = "fail"
+)
+
+// CopySelector 选择 CopyResources 复制的对象
+type CopySelector struct {
+	// Resources 为复制的资源类型. namespaces与customresourcedefinitions总是最先复制, 其余按给定顺序
+	Resources []schema.GroupVersionResource
+	// Namespaces 为空时复制所有namespace, 集群级别资源忽略此项
+	Namespaces    []string
+	LabelSelector string
+	FieldSelector string
+}
+
+// CopyOptions 为 CopyResources 的参数
+type CopyOptions struct {
+	// Conflict 为目标对象已存在时的处理方式, 默认为 CopyConflictSkip
+	Conflict CopyConflictStrategy
+	// FieldManager 默认为 DefaultCopyFieldManager
+	FieldManager string
+	// NamespaceMapping 将源namespace映射到目标集群中的namespace, 未列出的namespace保持不变.
+	// 复制namespaces资源时同样按此映射重命名
+	NamespaceMapping map[string]string
+	// IncludeControlled 为true时同时复制由控制器管理的对象(如ReplicaSet, Pod), 默认跳过, 由其owner在目标集群中重新生成
+	IncludeControlled bool
+	// Transform 在写入目标集群前修改对象(已经过 SanitizeForCopy), 返回nil时跳过该对象
+	Transform func(obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+	// DryRun 为true时以server-side dry-run写入目标集群, 结果中的分类不变
+	DryRun bool
+}
+
+// CopyResult 为 CopyResources 的结果, 列表元素为目标集群中的ObjectIdentity, 按资源顺序排列
+type CopyResult struct {
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Unchanged []string `json:"unchanged"`
+	// Skipped 为因冲突策略, 由控制器管理, 集群自动维护或Transform返回nil而未复制的对象
+	Skipped []string `json:"skipped"`
+	// Errors 以源集群中的ObjectIdentity(或资源gvr字符串)为key
+	Errors map[string]error `json:"-"`
+}
+
+// CopyResources 将源集群中选中的对象复制到目标集群: 去除status, uid, resourceVersion等服务端字段以及由集群分配的字段(见 SanitizeForCopy)后,
+// 以server-side apply写入目标集群, 集群设置了DisableServerSideApply时退化为merge patch.
+// 部分对象失败时继续复制其余对象, 返回的error为汇总错误, 详情见 CopyResult.Errors.
+func CopyResources(ctx context.Context, src, dst *GenericK8sClient, selector CopySelector, opts CopyOptions) (*CopyResult, error) {
+	if err := opts.complete(); err != nil {
+		return nil, err
+	}
+	ctx, _ = EnsureRequestID(ctx)
+
+	resources := append([]schema.GroupVersionResource(nil), selector.Resources...)
+	sort.SliceStable(resources, func(i, j int) bool {
+		return copyRank(resources[i]) < copyRank(resources[j])
+	})
+
+	result := &CopyResult{Errors: map[string]error{}}
+	for _, gvr := range resources {
+		objs, err := listForCopy(ctx, src, gvr, selector)
+		if err != nil {
+			result.Errors[gvr.String()] = err
+			continue
+		}
+		for i := range objs {
+			result.copyObject(ctx, src.TargetK8sApiServerId, dst, gvr, &objs[i], opts)
+		}
+	}
+
+	var errs []error
+	for key, err := range result.Errors {
+		errs = append(errs, errors.Wrap(err, key))
+	}
+	return result, utilerrors.NewAggregate(errs)
+}
+
+// complete 填充默认值并校验冲突策略
+func (o *CopyOptions) complete() error {
+	if len(o.Conflict) == 0 {
+		o.Conflict = CopyConflictSkip
+	}
+	switch o.Conflict {
+	case CopyConflictSkip, CopyConflictOverwrite, CopyConflictFail:
+	default:
+		return errors.New("未知的冲突策略 " + string(o.Conflict))
+	}
+	if len(o.FieldManager) == 0 {
+		o.FieldManager = DefaultCopyFieldManager
+	}
+	return nil
+}
+
+// copyRank 使namespace与CRD先于依赖它们的对象复制
+func copyRank(gvr schema.GroupVersionResource) int {
+	switch gvr.GroupResource() {
+	case schema.GroupResource{Resource: "namespaces"}:
+		return 0
+	case schema.GroupResource{Group: "apiextensions.k8s.io", Resource: "customresourcedefinitions"}:
+		return 1
+	}
+	return 2
+}
+
+func listForCopy(ctx context.Context, src *GenericK8sClient, gvr schema.GroupVersionResource, selector CopySelector) ([]unstructured.Unstructured, error) {
+	namespaced, err := src.isNamespaced(gvr)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := selector.Namespaces
+	if !namespaced || len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	list, err := src.ListAcrossNamespaces(ctx, gvr, namespaces, ListAcrossNamespacesOptions{
+		ListOptions: metav1.ListOptions{LabelSelector: selector.LabelSelector, FieldSelector: selector.FieldSelector},
+	})
+	if err != nil {
+		return nil, err
+	}
+	items := list.Items
+	// 复制namespaces资源时只复制选中的namespace
+	if gvr.GroupResource() == (schema.GroupResource{Resource: "namespaces"}) && len(selector.Namespaces) > 0 {
+		selected := map[string]bool{}
+		for _, ns := range selector.Namespaces {
+			selected[ns] = true
+		}
+		items = items[:0]
+		for _, item := range list.Items {
+			if selected[item.GetName()] {
+				items = append(items, item)
+			}
+		}
+	}
+	return items, nil
+}
+
+func (r *CopyResult) copyObject(ctx context.Context, sourceCluster string, dst *GenericK8sClient, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, opts CopyOptions) {
+	sourceIdentity := ObjectIdentity(obj)
+	desired := SanitizeForCopy(obj)
+	if mapped, ok := opts.NamespaceMapping[desired.GetNamespace()]; ok && len(desired.GetNamespace()) > 0 {
+		desired.SetNamespace(mapped)
+	}
+	if mapped, ok := opts.NamespaceMapping[desired.GetName()]; ok && desired.GetKind() == "Namespace" {
+		desired.SetName(mapped)
+	}
+	// 跳过的对象同样以映射后在目标集群中的标识记录
+	if (!opts.IncludeControlled && metav1.GetControllerOfNoCopy(obj) != nil) || isClusterMaintained(obj) {
+		r.Skipped = append(r.Skipped, ObjectIdentity(desired))
+		return
+	}
+	if opts.Transform != nil {
+		mappedIdentity := ObjectIdentity(desired)
+		transformed, err := opts.Transform(desired)
+		if err != nil {
+			r.Errors[sourceIdentity] = err
+			return
+		}
+		if transformed == nil {
+			r.Skipped = append(r.Skipped, mappedIdentity)
+			return
+		}
+		desired = transformed
+	}
+	identity := ObjectIdentity(desired)
+
+	ri := namespacedResource(dst.GetDynamicClient(), gvr, desired.GetNamespace())
+	live, err := ri.Get(ctx, desired.GetName(), metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		live = nil
+	case err != nil:
+		r.Errors[sourceIdentity] = dst.clusterError(err, desired.GroupVersionKind())
+		return
+	}
+	if live != nil {
+		switch opts.Conflict {
+		case CopyConflictSkip:
+			r.Skipped = append(r.Skipped, identity)
+			return
+		case CopyConflictFail:
+			r.Errors[sourceIdentity] = errors.Wrap(ErrCopyConflict, identity)
+			return
+		}
+	}
+
+	if err := dst.runObjectPlugins(ctx, OperationApply, desired); err != nil {
+		r.Errors[sourceIdentity] = err
+		return
+	}
+	applyOpts := metav1.ApplyOptions{FieldManager: opts.FieldManager, Force: true}
+	if opts.DryRun {
+		applyOpts.DryRun = []string{metav1.DryRunAll}
+	}
+	applied, err := applyObject(ctx, ri, desired, applyOpts, dst.overrides.DisableServerSideApply)
+	if err != nil {
+		r.Errors[sourceIdentity] = dst.clusterError(err, desired.GroupVersionKind())
+		return
+	}
+	switch {
+	case live == nil:
+		r.Created = append(r.Created, identity)
+	case applied.GetResourceVersion() == live.GetResourceVersion():
+		// 内容未变化时APIServer不会更新resourceVersion
+		r.Unchanged = append(r.Unchanged, identity)
+	default:
+		r.Updated = append(r.Updated, identity)
+	}
+	if !opts.DryRun {
+		dst.recordOperationEvent(ctx, applied, EventReasonApplied, "对象已由 "+opts.FieldManager+" 从集群 "+sourceCluster+" 复制")
+	}
+}
+
+// isClusterMaintained 判断对象是否由集群自动创建维护, 这类对象在目标集群中会自动生成, 不应复制
+func isClusterMaintained(obj *unstructured.Unstructured) bool {
+	switch obj.GetKind() {
+	case "Secret":
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		return secretType == string(corev1.SecretTypeServiceAccountToken)
+	case "ConfigMap":
+		return obj.GetName() == "kube-root-ca.crt"
+	case "Service":
+		return obj.GetNamespace() == metav1.NamespaceDefault && obj.GetName() == "kubernetes"
+	case "Namespace":
+		switch obj.GetName() {
+		case metav1.NamespaceDefault, metav1.NamespaceSystem, metav1.NamespacePublic, corev1.NamespaceNodeLease:
+			return true
+		}
+	}
+	return false
+}
+
+// SanitizeForCopy 返回适合写入其他集群的对象副本: 在 StripServerFields 的基础上去除ownerReferences, finalizers(由目标集群的控制器重新添加),
+// 集群维护的annotation, 以及由集群分配的字段(Service的clusterIP与healthCheckNodePort, PVC绑定的volumeName,
+// Pod调度到的nodeName, Job自动生成的selector与controller-uid label)
+func SanitizeForCopy(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	sanitized := normalizeForClusterDiff(obj)
+	sanitized.SetOwnerReferences(nil)
+	sanitized.SetFinalizers(nil)
+
+	switch sanitized.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Kind: "Service"}:
+		unstructured.RemoveNestedField(sanitized.Object, "spec", "healthCheckNodePort")
+	case schema.GroupKind{Kind: "Pod"}:
+		unstructured.RemoveNestedField(sanitized.Object, "spec", "nodeName")
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		// 未手动指定selector的Job由APIServer生成selector, 复制到其他集群时需重新生成
+		if manual, _, _ := unstructured.NestedBool(sanitized.Object, "spec", "manualSelector"); !manual {
+			unstructured.RemoveNestedField(sanitized.Object, "spec", "selector")
+			for _, key := range []string{"controller-uid", "batch.kubernetes.io/controller-uid"} {
+				unstructured.RemoveNestedField(sanitized.Object, "spec", "template", "metadata", "labels", key)
+			}
+		}
+	}
+	return sanitized
+}
@@ -0,0 +1,257 @@
+package k8sclientkit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// celCostLimit 为单条规则求值的开销上限, 与APIServer的单表达式上限一致, 防止规则在本地陷入过长的计算
+const celCostLimit = 1000000
+
+// CRDRuleViolation 为对象违反CRD中 x-kubernetes-validations 规则的记录
+type CRDRuleViolation struct {
+	// Object 为对象标识(见 ObjectIdentity)
+	Object string
+	// Field 为规则所在的字段路径, 如 `spec.replicas`, 对象根部的规则为空
+	Field   string
+	Rule    string
+	Message string
+	// Err 不为nil时表示规则无法在本地编译或求值(如使用了本地未提供的Kubernetes扩展函数), 该规则未被校验
+	Err error
+}
+
+func (v *CRDRuleViolation) String() string {
+	field := v.Field
+	if len(field) == 0 {
+		field = "<root>"
+	}
+	if v.Err != nil {
+		return fmt.Sprintf("%s %s: 无法校验规则 %q: %v", v.Object, field, v.Rule, v.Err)
+	}
+	return fmt.Sprintf("%s %s: %s", v.Object, field, v.Message)
+}
+
+// CRDValidator 在本地以CRD schema中的 x-kubernetes-validations CEL规则校验自定义资源, 无需访问集群.
+// 仅支持CEL标准库及strings, lists, sets等扩展, 使用quantity, url等Kubernetes专有函数的规则记录为无法校验;
+// 引用oldSelf的transition规则在没有旧对象时不生效, 因此被跳过. 并发安全.
+type CRDValidator struct {
+	env *cel.Env
+
+	// lock 保护schemas与规则编译缓存, schema本身添加后不再修改
+	lock sync.RWMutex
+	// schemas key为 group/version/kind
+	schemas  map[schema.GroupVersionKind]map[string]interface{}
+	programs map[string]cel.Program
+	errs     map[string]error
+}
+
+// NewCRDValidator 以crds(apiextensions.k8s.io/v1 CustomResourceDefinition)创建校验器, crds中的其他对象被忽略
+func NewCRDValidator(crds ...*unstructured.Unstructured) (*CRDValidator, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("self", cel.DynType),
+		ext.Strings(), ext.Lists(), ext.Sets(), ext.Math(), ext.Encoders(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法创建CEL环境")
+	}
+	v := &CRDValidator{
+		env:      env,
+		schemas:  map[schema.GroupVersionKind]map[string]interface{}{},
+		programs: map[string]cel.Program{},
+		errs:     map[string]error{},
+	}
+	for _, crd := range crds {
+		v.AddCRD(crd)
+	}
+	return v, nil
+}
+
+// AddCRD 添加CRD中各版本的schema, 同一GVK的schema会被覆盖. 非CRD对象被忽略.
+func (v *CRDValidator) AddCRD(crd *unstructured.Unstructured) {
+	if crd.GroupVersionKind().GroupKind() != (schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}) {
+		return
+	}
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	for _, item := range versions {
+		version, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(version, "name")
+		openAPISchema, found, _ := unstructured.NestedMap(version, "schema", "openAPIV3Schema")
+		if !found {
+			continue
+		}
+		v.schemas[schema.GroupVersionKind{Group: group, Version: name, Kind: kind}] = openAPISchema
+	}
+}
+
+// Covers 是否有obj对应的schema
+func (v *CRDValidator) Covers(obj *unstructured.Unstructured) bool {
+	_, ok := v.schemaFor(obj.GroupVersionKind())
+	return ok
+}
+
+func (v *CRDValidator) schemaFor(gvk schema.GroupVersionKind) (map[string]interface{}, bool) {
+	v.lock.RLock()
+	defer v.lock.RUnlock()
+	openAPISchema, ok := v.schemas[gvk]
+	return openAPISchema, ok
+}
+
+// Validate 校验obj, 没有对应schema的对象返回nil
+func (v *CRDValidator) Validate(obj *unstructured.Unstructured) []*CRDRuleViolation {
+	openAPISchema, ok := v.schemaFor(obj.GroupVersionKind())
+	if !ok {
+		return nil
+	}
+	var violations []*CRDRuleViolation
+	v.walk(ObjectIdentity(obj), openAPISchema, obj.Object, nil, &violations)
+	return violations
+}
+
+// walk 对value执行schema节点上的规则, 并递归properties, additionalProperties与items
+func (v *CRDValidator) walk(object string, node map[string]interface{}, value interface{}, path []string, violations *[]*CRDRuleViolation) {
+	if value == nil {
+		return
+	}
+	rules, _, _ := unstructured.NestedSlice(node, "x-kubernetes-validations")
+	for _, item := range rules {
+		rule, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if violation := v.evaluate(rule, value); violation != nil {
+			violation.Object = object
+			violation.Field = strings.Join(path, ".")
+			if fieldPath, _ := rule["fieldPath"].(string); len(fieldPath) > 0 {
+				violation.Field += fieldPath
+			}
+			*violations = append(*violations, violation)
+		}
+	}
+
+	switch val := value.(type) {
+	case map[string]interface{}:
+		properties, _ := node["properties"].(map[string]interface{})
+		additional, _ := node["additionalProperties"].(map[string]interface{})
+		for key, child := range val {
+			if childSchema, ok := properties[key].(map[string]interface{}); ok {
+				v.walk(object, childSchema, child, append(path, key), violations)
+			} else if additional != nil {
+				v.walk(object, additional, child, append(path, key), violations)
+			}
+		}
+	case []interface{}:
+		items, ok := node["items"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		for i, child := range val {
+			elemPath := append([]string{}, path...)
+			if len(elemPath) > 0 {
+				elemPath[len(elemPath)-1] += "[" + strconv.Itoa(i) + "]"
+			} else {
+				elemPath = append(elemPath, "["+strconv.Itoa(i)+"]")
+			}
+			v.walk(object, items, child, elemPath, violations)
+		}
+	}
+}
+
+// evaluate 执行单条规则, 通过时返回nil
+func (v *CRDValidator) evaluate(rule map[string]interface{}, self interface{}) *CRDRuleViolation {
+	expression, _ := rule["rule"].(string)
+	if len(expression) == 0 || strings.Contains(expression, "oldSelf") {
+		return nil
+	}
+	program, err := v.program(expression)
+	if err != nil {
+		return &CRDRuleViolation{Rule: expression, Err: err}
+	}
+	out, _, err := program.Eval(map[string]interface{}{"self": self})
+	if err != nil {
+		return &CRDRuleViolation{Rule: expression, Err: errors.Wrap(err, "求值失败")}
+	}
+	if passed, ok := out.Value().(bool); !ok {
+		return &CRDRuleViolation{Rule: expression, Err: errors.New("规则的结果不是bool")}
+	} else if passed {
+		return nil
+	}
+	message, _ := rule["message"].(string)
+	if len(message) == 0 {
+		message = "failed rule: " + expression
+	}
+	return &CRDRuleViolation{Rule: expression, Message: message}
+}
+
+// program 编译并缓存规则. 编译在锁外进行, 并发编译同一规则时保留先完成的结果
+func (v *CRDValidator) program(expression string) (cel.Program, error) {
+	v.lock.RLock()
+	program, compiled := v.programs[expression]
+	err, failed := v.errs[expression]
+	v.lock.RUnlock()
+	if compiled {
+		return program, nil
+	}
+	if failed {
+		return nil, err
+	}
+
+	program, err = v.compile(expression)
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if err != nil {
+		if cached, ok := v.errs[expression]; ok {
+			return nil, cached
+		}
+		v.errs[expression] = err
+		return nil, err
+	}
+	if cached, ok := v.programs[expression]; ok {
+		return cached, nil
+	}
+	v.programs[expression] = program
+	return program, nil
+}
+
+func (v *CRDValidator) compile(expression string) (cel.Program, error) {
+	ast, issues := v.env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, errors.Wrap(issues.Err(), "编译失败")
+	}
+	program, err := v.env.Program(ast, cel.CostLimit(celCostLimit))
+	if err != nil {
+		return nil, errors.Wrap(err, "编译失败")
+	}
+	return program, nil
+}
+
+// ValidateCustomResources 以objs中的CRD以及额外提供的crds校验objs中的自定义资源, 用于在应用bundle之前离线发现
+// 违反CRD校验规则的对象. 没有对应CRD的对象不做校验.
+func ValidateCustomResources(objs []*unstructured.Unstructured, crds ...*unstructured.Unstructured) ([]*CRDRuleViolation, error) {
+	validator, err := NewCRDValidator(crds...)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objs {
+		validator.AddCRD(obj)
+	}
+	var violations []*CRDRuleViolation
+	for _, obj := range objs {
+		violations = append(violations, validator.Validate(obj)...)
+	}
+	return violations, nil
+}
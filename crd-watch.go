@@ -0,0 +1,267 @@
+package k8sclientkit
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// CRDWatchOptions 为 WithCRDWatch 的参数
+type CRDWatchOptions struct {
+	// Selector 按CRD的label选择需要自动启动watcher的CRD, 为nil时只刷新RESTMapper而不启动watcher
+	Selector labels.Selector
+	// Resync 为自动启动的watcher的resync间隔
+	Resync time.Duration
+	// Configure 在watcher启动前调用, 用于注册事件处理(见 K8sResourceWatcher.AddEventHandler)
+	Configure func(gvr schema.GroupVersionResource, watcher *K8sResourceWatcher)
+}
+
+// WithCRDWatch 在后台watch集群中的CRD: CRD安装, 删除或提供的版本变化时刷新RESTMapper, 使新资源无需重建客户端即可使用;
+// 设置 Selector 时, 为新建立(Established)且匹配的CRD自动启动watcher(监视所有namespace), CRD删除时停止.
+// 需要list/watch customresourcedefinitions的权限.
+func WithCRDWatch(opts CRDWatchOptions) Option {
+	return func(o *clientOptions) {
+		o.crdWatch = &opts
+	}
+}
+
+// refreshableRESTMapper 为可整体替换的RESTMapper. controller-runtime的动态RESTMapper对已知API组中新增的版本
+// 不会重新discovery, 替换为新实例即可丢弃全部缓存.
+type refreshableRESTMapper struct {
+	lock      sync.RWMutex
+	delegate  meta.RESTMapper
+	newMapper func() (meta.RESTMapper, error)
+}
+
+func newRefreshableRESTMapper(config *rest.Config, httpClient *http.Client) (*refreshableRESTMapper, error) {
+	m := &refreshableRESTMapper{newMapper: func() (meta.RESTMapper, error) {
+		return apiutil.NewDynamicRESTMapper(config, httpClient)
+	}}
+	return m, m.Reset()
+}
+
+// Reset 丢弃缓存的映射, 之后的查询重新执行discovery
+func (m *refreshableRESTMapper) Reset() error {
+	delegate, err := m.newMapper()
+	if err != nil {
+		return err
+	}
+	m.lock.Lock()
+	m.delegate = delegate
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *refreshableRESTMapper) current() meta.RESTMapper {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.delegate
+}
+
+func (m *refreshableRESTMapper) KindFor(resource schema.GroupVersionResource) (schema.GroupVersionKind, error) {
+	return m.current().KindFor(resource)
+}
+
+func (m *refreshableRESTMapper) KindsFor(resource schema.GroupVersionResource) ([]schema.GroupVersionKind, error) {
+	return m.current().KindsFor(resource)
+}
+
+func (m *refreshableRESTMapper) ResourceFor(input schema.GroupVersionResource) (schema.GroupVersionResource, error) {
+	return m.current().ResourceFor(input)
+}
+
+func (m *refreshableRESTMapper) ResourcesFor(input schema.GroupVersionResource) ([]schema.GroupVersionResource, error) {
+	return m.current().ResourcesFor(input)
+}
+
+func (m *refreshableRESTMapper) RESTMapping(gk schema.GroupKind, versions ...string) (*meta.RESTMapping, error) {
+	return m.current().RESTMapping(gk, versions...)
+}
+
+func (m *refreshableRESTMapper) RESTMappings(gk schema.GroupKind, versions ...string) ([]*meta.RESTMapping, error) {
+	return m.current().RESTMappings(gk, versions...)
+}
+
+func (m *refreshableRESTMapper) ResourceSingularizer(resource string) (string, error) {
+	return m.current().ResourceSingularizer(resource)
+}
+
+// RefreshRESTMapper 丢弃RESTMapper缓存的映射, 用于集群中安装了新的CRD或API版本之后. 设置 WithCRDWatch 时自动执行.
+func (c *GenericK8sClient) RefreshRESTMapper() error {
+	if c.restMapper == nil {
+		return nil
+	}
+	return c.restMapper.Reset()
+}
+
+// crdWatcherSet 为根据CRD自动启动的watcher
+type crdWatcherSet struct {
+	lock     sync.Mutex
+	watchers map[string]*crdWatcher
+	wg       sync.WaitGroup
+}
+
+type crdWatcher struct {
+	gvr     schema.GroupVersionResource
+	watcher *K8sResourceWatcher
+	cancel  context.CancelFunc
+}
+
+// CRDWatchers 返回 WithCRDWatch 自动启动的watcher, key为资源GVR
+func (c *GenericK8sClient) CRDWatchers() map[schema.GroupVersionResource]*K8sResourceWatcher {
+	c.crdWatchers.lock.Lock()
+	defer c.crdWatchers.lock.Unlock()
+	result := make(map[schema.GroupVersionResource]*K8sResourceWatcher, len(c.crdWatchers.watchers))
+	for _, w := range c.crdWatchers.watchers {
+		result[w.gvr] = w.watcher
+	}
+	return result
+}
+
+// watchCRDs watch CRD变化, 刷新RESTMapper并维护自动启动的watcher, 随ctx结束退出
+func (c *GenericK8sClient) watchCRDs(ctx context.Context, opts *CRDWatchOptions) error {
+	informer := dynamicinformer.NewFilteredDynamicInformer(c.dynamicClient, crdGVR, "", 0, cache.Indexers{}, nil).Informer()
+	// signatures 记录各CRD提供的版本, 仅在handler中访问
+	signatures := map[string]string{}
+	handle := func(obj interface{}, deleted bool) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		crd, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		signature := ""
+		if !deleted {
+			signature = crdSignature(crd)
+		}
+		if signatures[crd.GetName()] != signature {
+			if signature == "" {
+				delete(signatures, crd.GetName())
+			} else {
+				signatures[crd.GetName()] = signature
+			}
+			// 首次list期间RESTMapper本身就是新的, 无需刷新
+			if informer.HasSynced() {
+				if err := c.RefreshRESTMapper(); err != nil {
+					c.group.report("crd-watch", err)
+				}
+			}
+		}
+		if opts.Selector != nil {
+			c.syncCRDWatcher(ctx, crd, deleted, opts)
+		}
+	}
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { handle(obj, false) },
+		UpdateFunc: func(_, newObj interface{}) { handle(newObj, false) },
+		DeleteFunc: func(obj interface{}) { handle(obj, true) },
+	})
+	if err != nil {
+		return err
+	}
+	informer.Run(ctx.Done())
+
+	c.crdWatchers.lock.Lock()
+	for name, w := range c.crdWatchers.watchers {
+		w.cancel()
+		delete(c.crdWatchers.watchers, name)
+	}
+	c.crdWatchers.lock.Unlock()
+	c.crdWatchers.wg.Wait()
+	return nil
+}
+
+// syncCRDWatcher 为匹配Selector且已建立的CRD启动watcher, CRD删除, 不再匹配或版本变化时停止原watcher
+func (c *GenericK8sClient) syncCRDWatcher(ctx context.Context, crd *unstructured.Unstructured, deleted bool, opts *CRDWatchOptions) {
+	gvr, established := crdWatchGVR(crd)
+	wanted := !deleted && established && opts.Selector.Matches(labels.Set(crd.GetLabels()))
+
+	c.crdWatchers.lock.Lock()
+	defer c.crdWatchers.lock.Unlock()
+	existing, ok := c.crdWatchers.watchers[crd.GetName()]
+	if ok && (!wanted || existing.gvr != gvr) {
+		existing.cancel()
+		delete(c.crdWatchers.watchers, crd.GetName())
+		ok = false
+	}
+	if !wanted || ok {
+		return
+	}
+
+	watcher := NewDynamicWatcher(c.dynamicClient, gvr, "", opts.Resync, nil, nil)
+	if opts.Configure != nil {
+		opts.Configure(gvr, watcher)
+	}
+	watcherCtx, cancel := context.WithCancel(ctx)
+	c.crdWatchers.watchers[crd.GetName()] = &crdWatcher{gvr: gvr, watcher: watcher, cancel: cancel}
+	c.crdWatchers.wg.Add(1)
+	go func() {
+		defer c.crdWatchers.wg.Done()
+		watcher.informer.Informer().Run(watcherCtx.Done())
+	}()
+}
+
+// crdSignature 由CRD的资源名称, 提供的版本及是否已建立组成, 变化时需要刷新RESTMapper
+func crdSignature(crd *unstructured.Unstructured) string {
+	gvr, established := crdWatchGVR(crd)
+	var served []string
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, item := range versions {
+		version, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if isServed, _, _ := unstructured.NestedBool(version, "served"); isServed {
+			name, _, _ := unstructured.NestedString(version, "name")
+			served = append(served, name)
+		}
+	}
+	sort.Strings(served)
+	if !established {
+		return gvr.GroupResource().String() + ":pending"
+	}
+	return gvr.GroupResource().String() + ":" + strings.Join(served, ",")
+}
+
+// crdWatchGVR 返回watcher使用的GVR: 存储版本提供服务时使用存储版本, 否则使用第一个提供服务的版本
+func crdWatchGVR(crd *unstructured.Unstructured) (schema.GroupVersionResource, bool) {
+	gvr := schema.GroupVersionResource{}
+	gvr.Group, _, _ = unstructured.NestedString(crd.Object, "spec", "group")
+	gvr.Resource, _, _ = unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, item := range versions {
+		version, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if served, _, _ := unstructured.NestedBool(version, "served"); !served {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(version, "name")
+		if storage, _, _ := unstructured.NestedBool(version, "storage"); storage || len(gvr.Version) == 0 {
+			gvr.Version = name
+		}
+	}
+
+	established := false
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, item := range conditions {
+		if condition, ok := item.(map[string]interface{}); ok && condition["type"] == "Established" && condition["status"] == "True" {
+			established = true
+		}
+	}
+	return gvr, established && len(gvr.Version) > 0
+}
@@ -0,0 +1,51 @@
+package k8sclientkit
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// UnstructuredMutateFunc 在 CreateOrUpdateUnstructured/CreateOrPatchUnstructured 中修改对象, obj 为从集群读取的当前对象(不存在时为只含GVK,
+// namespace与name的对象), 不能修改namespace与name
+type UnstructuredMutateFunc func(obj *unstructured.Unstructured) error
+
+// CreateOrUpdateUnstructured 与controllerutil.CreateOrUpdate一致: 按obj的GVK, namespace与name读取当前对象, 执行mutate后
+// 对象不存在时创建, 与读取时相比有变化时以Update写回, 否则不做请求. 返回执行的操作(created, updated, unchanged), obj被更新为最终对象.
+// 适用于无法使用server-side apply的reconciler, Update基于resourceVersion, 并发修改时返回Conflict错误, 调用方应重试.
+func (c *GenericK8sClient) CreateOrUpdateUnstructured(ctx context.Context, obj *unstructured.Unstructured, mutate UnstructuredMutateFunc) (controllerutil.OperationResult, error) {
+	ctx, _ = EnsureRequestID(ctx)
+	result, err := controllerutil.CreateOrUpdate(ctx, c.GetRuntimeCluster().GetClient(), obj, c.mutateWithPlugins(ctx, obj, mutate))
+	return c.finishCreateOrUpdate(ctx, obj, result, err)
+}
+
+// CreateOrPatchUnstructured 与controllerutil.CreateOrPatch一致: 同 CreateOrUpdateUnstructured, 但以merge patch只写回变化的字段,
+// status的变化通过status子资源单独patch. 返回执行的操作(created, updated, updatedStatus, updatedStatusOnly, unchanged).
+func (c *GenericK8sClient) CreateOrPatchUnstructured(ctx context.Context, obj *unstructured.Unstructured, mutate UnstructuredMutateFunc) (controllerutil.OperationResult, error) {
+	ctx, _ = EnsureRequestID(ctx)
+	result, err := controllerutil.CreateOrPatch(ctx, c.GetRuntimeCluster().GetClient(), obj, c.mutateWithPlugins(ctx, obj, mutate))
+	return c.finishCreateOrUpdate(ctx, obj, result, err)
+}
+
+// mutateWithPlugins 在mutate之后执行插件, 使插件看到并可以修改最终写回的对象
+func (c *GenericK8sClient) mutateWithPlugins(ctx context.Context, obj *unstructured.Unstructured, mutate UnstructuredMutateFunc) controllerutil.MutateFn {
+	return func() error {
+		if mutate != nil {
+			if err := mutate(obj); err != nil {
+				return err
+			}
+		}
+		return c.runObjectPlugins(ctx, OperationApply, obj)
+	}
+}
+
+func (c *GenericK8sClient) finishCreateOrUpdate(ctx context.Context, obj *unstructured.Unstructured, result controllerutil.OperationResult, err error) (controllerutil.OperationResult, error) {
+	if err != nil {
+		return result, c.clusterError(err, obj.GroupVersionKind())
+	}
+	if result != controllerutil.OperationResultNone {
+		c.recordOperationEvent(ctx, obj, EventReasonApplied, "对象已写入: "+string(result))
+	}
+	return result, nil
+}
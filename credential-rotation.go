@@ -0,0 +1,187 @@
+package k8sclientkit
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// swappableTransport 允许在运行时原子替换底层transport(包含TLS与认证),
+// 已创建的各类客户端及informer均通过它发送请求, 因此替换凭证后无需重建.
+type swappableTransport struct {
+	current atomic.Pointer[http.RoundTripper]
+}
+
+func (t *swappableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return (*t.current.Load()).RoundTrip(req)
+}
+
+// swap 替换底层transport, 返回旧transport
+func (t *swappableTransport) swap(rt http.RoundTripper) http.RoundTripper {
+	old := t.current.Swap(&rt)
+	if old == nil {
+		return nil
+	}
+	return *old
+}
+
+// newSwappableClientConfig 使用完整配置构建transport, 返回仅包含连接参数和swappable transport的配置, 供各客户端共享.
+// TLS, 认证, 代理及WrapTransport均已包含在transport中, 不再出现在返回的配置里.
+func newSwappableClientConfig(config *rest.Config) (*rest.Config, *swappableTransport, error) {
+	rt, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "创建transport失败")
+	}
+	swappable := &swappableTransport{}
+	swappable.swap(rt)
+	return transportOnlyConfig(config, swappable), swappable, nil
+}
+
+func transportOnlyConfig(config *rest.Config, rt http.RoundTripper) *rest.Config {
+	return &rest.Config{
+		Host:           config.Host,
+		APIPath:        config.APIPath,
+		ContentConfig:  config.ContentConfig,
+		UserAgent:      config.UserAgent,
+		QPS:            config.QPS,
+		Burst:          config.Burst,
+		RateLimiter:    config.RateLimiter,
+		WarningHandler: config.WarningHandler,
+		Timeout:        config.Timeout,
+		Transport:      rt,
+	}
+}
+
+// RotateToken 将客户端使用的bearer token替换为token.
+// 已创建的标准客户端, 动态客户端, metrics客户端, controller-runtime cluster以及基于它们的watcher均保持可用, 后续请求使用新token.
+func (c *GenericK8sClient) RotateToken(token string) error {
+	if c.token != nil {
+		c.token.set(token)
+		return nil
+	}
+	return c.rotateCredentials(func(config *rest.Config) {
+		config.BearerToken = token
+		config.BearerTokenFile = ""
+	})
+}
+
+// RotateCredentials 使用新的kubeconfig(取current-context)替换客户端凭证, 包括token, 客户端证书, CA以及exec/auth插件.
+// kubeconfig指向的APIServer地址必须与当前一致.
+// 新凭证先经过连通性验证, 验证失败时保持原凭证不变; 验证通过后原子替换transport,
+// 已创建的客户端, informer及watcher订阅均保持不变, 进行中的长连接watch在断开重连后使用新凭证.
+func (c *GenericK8sClient) RotateCredentials(kubeConfig []byte) error {
+	kc, err := clientcmd.Load(kubeConfig)
+	if err != nil {
+		return errors.Wrap(err, "无法解析kubeconfig")
+	}
+	newConfig, err := clientcmd.NewDefaultClientConfig(*kc, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return errors.Wrap(err, "使用kubeconfig构建rest client config失败")
+	}
+	current := c.currentRestConfig()
+	if strings.TrimSuffix(newConfig.Host, "/") != strings.TrimSuffix(current.Host, "/") {
+		return errors.New("kubeconfig中的APIServer地址 " + newConfig.Host + " 与当前地址 " + current.Host + " 不一致")
+	}
+
+	if err := c.rotateCredentials(func(config *rest.Config) {
+		copyCredentials(config, newConfig)
+	}); err != nil {
+		return err
+	}
+	c.configLock.Lock()
+	c.kubeConfig = kc
+	c.configLock.Unlock()
+	return nil
+}
+
+// RotateCredentials 以新的kubeconfig替换已注册集群的客户端凭证, 客户端及其watcher订阅保持不变, 见 GenericK8sClient.RotateCredentials.
+// 集群未注册时返回 ErrClusterNotRegistered.
+func (r *ClusterRegistry) RotateCredentials(id string, kubeConfig []byte) error {
+	cli, err := r.MustGet(id)
+	if err != nil {
+		return err
+	}
+	return cli.RotateCredentials(kubeConfig)
+}
+
+// RotateToken 替换已注册集群的客户端使用的bearer token, 见 GenericK8sClient.RotateToken.
+// 集群未注册时返回 ErrClusterNotRegistered.
+func (r *ClusterRegistry) RotateToken(id string, token string) error {
+	cli, err := r.MustGet(id)
+	if err != nil {
+		return err
+	}
+	return cli.RotateToken(token)
+}
+
+// rotateCredentials 在当前配置的副本上应用mutate, 验证后替换transport
+func (c *GenericK8sClient) rotateCredentials(mutate func(config *rest.Config)) error {
+	c.configLock.Lock()
+	defer c.configLock.Unlock()
+
+	config := rest.CopyConfig(c.restConfig)
+	mutate(config)
+	if c.token != nil && len(config.BearerToken) > 0 {
+		// bearer token 由renewableToken接管
+		c.token.set(config.BearerToken)
+		config.BearerToken = ""
+	}
+	if err := newClientOptions(c.options).prepareExecProvider(config); err != nil {
+		return err
+	}
+
+	rt, err := rest.TransportFor(config)
+	if err != nil {
+		return errors.Wrap(err, "使用新凭证创建transport失败")
+	}
+	probe, err := kubernetes.NewForConfig(transportOnlyConfig(config, rt))
+	if err != nil {
+		return errors.Wrap(err, "创建standard client失败")
+	}
+	if _, err := probe.ServerVersion(); err != nil {
+		return errors.Wrap(err, "新凭证无法连接到集群")
+	}
+
+	old := c.transport.swap(rt)
+	utilnet.CloseIdleConnectionsFor(old)
+	c.restConfig = config
+	base := rest.CopyConfig(c.baseRestConfig)
+	mutate(base)
+	c.baseRestConfig = base
+	return nil
+}
+
+// currentRestConfig 返回当前使用的完整rest.Config(含凭证), 调用方不应修改
+func (c *GenericK8sClient) currentRestConfig() *rest.Config {
+	c.configLock.RLock()
+	defer c.configLock.RUnlock()
+	return c.restConfig
+}
+
+// copyCredentials 将src中的认证相关字段复制到dst, 保留dst的连接设置(SNI覆盖, 代理, WrapTransport等)
+func copyCredentials(dst, src *rest.Config) {
+	dst.BearerToken = src.BearerToken
+	dst.BearerTokenFile = src.BearerTokenFile
+	dst.Username = src.Username
+	dst.Password = src.Password
+	dst.ExecProvider = src.ExecProvider
+	dst.AuthProvider = src.AuthProvider
+	dst.AuthConfigPersister = src.AuthConfigPersister
+
+	dst.TLSClientConfig.Insecure = src.TLSClientConfig.Insecure
+	dst.TLSClientConfig.CertFile = src.TLSClientConfig.CertFile
+	dst.TLSClientConfig.KeyFile = src.TLSClientConfig.KeyFile
+	dst.TLSClientConfig.CAFile = src.TLSClientConfig.CAFile
+	dst.TLSClientConfig.CertData = src.TLSClientConfig.CertData
+	dst.TLSClientConfig.KeyData = src.TLSClientConfig.KeyData
+	dst.TLSClientConfig.CAData = src.TLSClientConfig.CAData
+	if len(src.TLSClientConfig.ServerName) > 0 {
+		dst.TLSClientConfig.ServerName = src.TLSClientConfig.ServerName
+	}
+}
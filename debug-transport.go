@@ -0,0 +1,215 @@
+package k8sclientkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDebugMaxBodyBytes 为调试日志中每个body保留的最大字节数
+	DefaultDebugMaxBodyBytes = 4096
+	// debugMaxParseBytes 为脱敏时读取的最大body大小, 超过时不记录body内容, 以免无法完整解析而泄露敏感字段
+	debugMaxParseBytes = 1 << 20
+)
+
+// redactedKeys 为无论出现在何处都会被脱敏的JSON字段名
+var redactedKeys = map[string]bool{
+	"token": true, "password": true, "bearerToken": true, "client-key-data": true,
+	"client-certificate-data": true, "id-token": true, "refresh-token": true,
+}
+
+// DebugRecord 为一次请求的调试记录
+type DebugRecord struct {
+	ClusterId string
+	// RequestID 为请求ID(见 WithRequestID, ContextWithRequestID), 用于关联同一次操作的多个请求
+	RequestID  string
+	Method     string
+	URL        string
+	StatusCode int
+	Duration   time.Duration
+	// RequestBody 与 ResponseBody 仅在 DebugOptions.Bodies 为true时记录, 已脱敏并截断
+	RequestBody  string
+	ResponseBody string
+	Err          error
+}
+
+// DebugLogger 接收请求调试记录
+type DebugLogger func(record *DebugRecord)
+
+// DebugOptions 为调试日志参数
+type DebugOptions struct {
+	// Bodies 为true时记录请求与响应body. Secret的data/stringData以及token, password等字段会被脱敏, watch响应不记录
+	Bodies bool
+	// MaxBodyBytes 为每个body保留的最大字节数, 默认为 DefaultDebugMaxBodyBytes
+	MaxBodyBytes int
+	// Logger 默认使用标准库log输出
+	Logger DebugLogger
+}
+
+func (d *DebugOptions) logger() DebugLogger {
+	if d.Logger != nil {
+		return d.Logger
+	}
+	return defaultDebugLogger
+}
+
+func defaultDebugLogger(r *DebugRecord) {
+	line := "[k8s-client-kit] cluster=" + r.ClusterId + " request_id=" + r.RequestID + " " + r.Method + " " + r.URL
+	if r.Err != nil {
+		line += " error=" + r.Err.Error()
+	} else {
+		line += " status=" + strconv.Itoa(r.StatusCode)
+	}
+	line += " duration=" + r.Duration.String()
+	if len(r.RequestBody) > 0 {
+		line += " request_body=" + r.RequestBody
+	}
+	if len(r.ResponseBody) > 0 {
+		line += " response_body=" + r.ResponseBody
+	}
+	log.Println(line)
+}
+
+// WithDebugLogging 记录客户端发出的每个请求, 用于排查APIServer拒绝请求等问题
+func WithDebugLogging(opts DebugOptions) Option {
+	return func(o *clientOptions) {
+		o.debug = &opts
+	}
+}
+
+type debugOptionsKey struct{}
+
+// ContextWithDebugLogging 仅为使用返回的ctx发出的请求记录调试日志, 优先于 WithDebugLogging 的设置
+func ContextWithDebugLogging(ctx context.Context, opts DebugOptions) context.Context {
+	return context.WithValue(ctx, debugOptionsKey{}, &opts)
+}
+
+// debugRoundTripper 按客户端或请求ctx的设置记录请求
+type debugRoundTripper struct {
+	clusterId       string
+	options         *DebugOptions
+	requestIDHeader string
+	next            http.RoundTripper
+}
+
+func (rt *debugRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	opts, ok := req.Context().Value(debugOptionsKey{}).(*DebugOptions)
+	if !ok {
+		opts = rt.options
+	}
+	if opts == nil {
+		return rt.next.RoundTrip(req)
+	}
+
+	record := &DebugRecord{
+		ClusterId: rt.clusterId,
+		RequestID: requestIDOf(req, rt.requestIDHeader),
+		Method:    req.Method,
+		URL:       req.URL.String(),
+	}
+	secret := strings.Contains(req.URL.Path, "/secrets")
+	maxBytes := opts.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultDebugMaxBodyBytes
+	}
+	if opts.Bodies && req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, debugMaxParseBytes+1))
+			body.Close()
+			record.RequestBody = redactBody(data, maxBytes, secret)
+		}
+	}
+
+	start := time.Now()
+	resp, err := rt.next.RoundTrip(req)
+	record.Duration = time.Since(start)
+	record.Err = err
+	if resp != nil {
+		record.StatusCode = resp.StatusCode
+		if opts.Bodies && resp.Body != nil && req.URL.Query().Get("watch") != "true" {
+			// 只读取有限长度, 之后拼回body, 不影响调用方读取
+			data, _ := io.ReadAll(io.LimitReader(resp.Body, debugMaxParseBytes+1))
+			resp.Body = &debugReadCloser{Reader: io.MultiReader(bytes.NewReader(data), resp.Body), closer: resp.Body}
+			record.ResponseBody = redactBody(data, maxBytes, secret)
+		}
+	}
+	opts.logger()(record)
+	return resp, err
+}
+
+type debugReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (r *debugReadCloser) Close() error {
+	return r.closer.Close()
+}
+
+// redactBody 解析JSON body并脱敏后截断. 无法完整解析的body(protobuf, 过大)只记录长度, 避免泄露敏感内容.
+// secret 表示请求的是secrets资源, 此时不带kind的body(如patch)同样按Secret脱敏.
+func redactBody(data []byte, maxBytes int, secret bool) string {
+	if len(data) == 0 {
+		return ""
+	}
+	if len(data) > debugMaxParseBytes {
+		return "<body过大, 未记录>"
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return "<" + strconv.Itoa(len(data)) + " 字节非JSON内容>"
+	}
+	redactValue(v, secret)
+	redacted, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	if len(redacted) > maxBytes {
+		return string(redacted[:maxBytes]) + "...(已截断)"
+	}
+	return string(redacted)
+}
+
+// redactValue 原地脱敏. inSecret 表示当前对象是Secret(或其patch), 其data与stringData下的所有值均被替换.
+func redactValue(v interface{}, inSecret bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if kind, _ := val["kind"].(string); kind == "Secret" || kind == "SecretList" {
+			inSecret = true
+		}
+		for key, child := range val {
+			switch {
+			case redactedKeys[key] && isScalar(child):
+				val[key] = redactedValue
+			case inSecret && (key == "data" || key == "stringData"):
+				if fields, ok := child.(map[string]interface{}); ok {
+					for field := range fields {
+						fields[field] = redactedValue
+					}
+				}
+			default:
+				redactValue(child, inSecret && key != "metadata")
+			}
+		}
+	case []interface{}:
+		// SecretList的items不带kind, 沿用上层的判断
+		for _, child := range val {
+			redactValue(child, inSecret)
+		}
+	}
+}
+
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package k8sclientkit
+
+import (
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WithDryRun 使客户端的所有写操作均以server-side dry-run方式执行(dryRun=All):
+// 请求经过完整的鉴权, 准入与校验, 但不会持久化. 适用于校验流水线.
+// 作用于标准客户端, 动态客户端以及controller-runtime client.
+// 与 ClusterOverrides.ReadOnly 同时使用时, 写操作以dry-run方式到达APIServer, 不会被只读限制拒绝.
+func WithDryRun() Option {
+	return func(o *clientOptions) {
+		o.dryRun = true
+	}
+}
+
+// DryRun 返回客户端是否处于dry-run模式
+func (c *GenericK8sClient) DryRun() bool {
+	return newClientOptions(c.options).dryRun
+}
+
+// dryRunRoundTripper 为变更类请求添加 `dryRun=All` 查询参数
+type dryRunRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (rt *dryRunRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isMutatingMethod(req.Method) || isReviewRequest(req) {
+		return rt.next.RoundTrip(req)
+	}
+	query := req.URL.Query()
+	if query.Get("dryRun") == metav1.DryRunAll {
+		return rt.next.RoundTrip(req)
+	}
+	query.Set("dryRun", metav1.DryRunAll)
+
+	// RoundTripper不应修改原请求
+	req = req.Clone(req.Context())
+	req.URL.RawQuery = query.Encode()
+	return rt.next.RoundTrip(req)
+}
@@ -4,22 +4,50 @@ import (
 	"context"
 
 	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ApplyUnstructuredObj 以filedManager为字段管理者对obj执行server-side apply(同 `kubectl apply --server-side`), 对象不存在时创建,
+// 重复执行是幂等的. 与其他字段管理者冲突时返回Conflict错误, 不强制接管字段. obj被原地更新为APIServer返回的对象.
+// 集群设置了DisableServerSideApply时退化为client-side apply(见 ApplyUnstructuredObjClientSide).
 func (c *GenericK8sClient) ApplyUnstructuredObj(ctx context.Context, obj *unstructured.Unstructured, filedManager string) (*UnstructuredApplyResult, error) {
-	err := c.GetRuntimeCluster().GetClient().Create(ctx, obj, &client.CreateOptions{FieldManager: filedManager})
+	ctx, _ = EnsureRequestID(ctx)
+	ctx, warnings := ContextWithWarningCollector(ctx)
+	var desiredHash string
+	err := c.runObjectPlugins(ctx, OperationApply, obj)
+	if err == nil {
+		desiredHash = ObjectHash(obj)
+		err = c.applyRuntimeObject(ctx, obj, filedManager)
+	}
+	err = c.clusterError(err, obj.GroupVersionKind())
+	if err == nil {
+		c.recordOperationEvent(ctx, obj, EventReasonApplied, "对象已由 "+filedManager+" 应用")
+	}
 	return &UnstructuredApplyResult{
 		Gvk:          obj.GroupVersionKind(),
 		Error:        err,
 		Success:      err == nil,
 		ResultObject: obj,
+		Warnings:     warnings(),
+		DesiredHash:  desiredHash,
 	}, err
 }
 
+// applyRuntimeObject 通过controller-runtime client执行server-side apply, 集群禁用时执行client-side apply
+func (c *GenericK8sClient) applyRuntimeObject(ctx context.Context, obj *unstructured.Unstructured, fieldManager string) error {
+	if c.overrides.DisableServerSideApply {
+		return c.applyThreeWay(ctx, obj, fieldManager)
+	}
+	// apply请求中不允许携带managedFields
+	obj.SetManagedFields(nil)
+	return c.GetRuntimeCluster().GetClient().Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager))
+}
+
 func (c *GenericK8sClient) ApplyUnstructuredObjsBatch(ctx context.Context, objs []*unstructured.Unstructured, fieldManager string) (successfulResults []*UnstructuredApplyResult, failedResults []*UnstructuredApplyResult) {
+	ctx, _ = EnsureRequestID(ctx)
 	for _, obj := range objs {
 		result, err := c.ApplyUnstructuredObj(ctx, obj, fieldManager)
 		if err != nil {
@@ -32,10 +60,27 @@ func (c *GenericK8sClient) ApplyUnstructuredObjsBatch(ctx context.Context, objs
 	return successfulResults, failedResults
 }
 
+// DeleteUnstructuredObj 删除obj所指的对象(按GVK, namespace, name定位), 对象不存在时返回NotFound错误
+func (c *GenericK8sClient) DeleteUnstructuredObj(ctx context.Context, obj *unstructured.Unstructured, opts ...client.DeleteOption) error {
+	ctx, _ = EnsureRequestID(ctx)
+	if err := c.runObjectPlugins(ctx, OperationDelete, obj); err != nil {
+		return err
+	}
+	if err := c.GetRuntimeCluster().GetClient().Delete(ctx, obj, opts...); err != nil {
+		return c.clusterError(err, obj.GroupVersionKind())
+	}
+	c.recordOperationEvent(ctx, obj, EventReasonDeleted, "对象已被删除")
+	return nil
+}
+
 func (c *GenericK8sClient) GvkToGvr(gvk schema.GroupVersionKind) (schema.GroupVersionResource, error) {
 	resourcesList, err := c.GetStandardClient().DiscoveryClient.ServerResourcesForGroupVersion(gvk.GroupVersion().String())
+	if apierrors.IsNotFound(err) {
+		// 集群不提供该GroupVersion
+		return schema.GroupVersionResource{}, c.clusterError(errors.Wrap(ErrResourceNotMapped, gvk.String()), gvk)
+	}
 	if err != nil {
-		return schema.GroupVersionResource{}, err
+		return schema.GroupVersionResource{}, c.clusterError(err, gvk)
 	}
 
 	for _, resource := range resourcesList.APIResources {
@@ -48,5 +93,5 @@ func (c *GenericK8sClient) GvkToGvr(gvk schema.GroupVersionKind) (schema.GroupVe
 		}
 	}
 
-	return schema.GroupVersionResource{}, errors.New("未找到目标资源:" + gvk.String())
+	return schema.GroupVersionResource{}, c.clusterError(errors.Wrap(ErrResourceNotMapped, gvk.String()), gvk)
 }
@@ -0,0 +1,150 @@
+package k8sclientkit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Encoder 是导出/快照等持久化数据使用的序列化格式.
+// 内置JSON与YAML; 其他格式(如CBOR)可由调用方实现后通过RegisterEncoder注册, 避免本库引入额外依赖.
+type Encoder interface {
+	// Name 为格式名称, 如 "json", 注册时作为唯一标识(不区分大小写)
+	Name() string
+	// Extension 为该格式的文件扩展名(不含点), 用于根据文件路径选择Encoder
+	Extension() string
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+	Decode(r io.Reader, v interface{}) error
+}
+
+var (
+	// EncoderJSON 使用encoding/json, 输出带缩进
+	EncoderJSON Encoder = jsonEncoder{}
+	// EncoderYAML 使用sigs.k8s.io/yaml, 与Kubernetes对象的json tag保持一致
+	EncoderYAML Encoder = yamlEncoder{}
+
+	encodersLock sync.RWMutex
+	// encoders 按注册顺序保存, 多个格式使用相同扩展名时 EncoderForPath 选择最先注册的
+	encoders = []Encoder{EncoderJSON, EncoderYAML}
+)
+
+// RegisterEncoder 注册自定义序列化格式, 名称不区分大小写, 同名格式将被替换(保留原有顺序)
+func RegisterEncoder(enc Encoder) {
+	encodersLock.Lock()
+	defer encodersLock.Unlock()
+	for i, existing := range encoders {
+		if strings.EqualFold(existing.Name(), enc.Name()) {
+			encoders[i] = enc
+			return
+		}
+	}
+	encoders = append(encoders, enc)
+}
+
+// GetEncoder 按名称(不区分大小写)获取已注册的序列化格式
+func GetEncoder(name string) (Encoder, bool) {
+	encodersLock.RLock()
+	defer encodersLock.RUnlock()
+	for _, enc := range encoders {
+		if strings.EqualFold(enc.Name(), name) {
+			return enc, true
+		}
+	}
+	return nil, false
+}
+
+// EncoderForPath 根据文件扩展名(不区分大小写)选择序列化格式, 多个格式使用相同扩展名时选择最先注册的, 无法识别时使用JSON
+func EncoderForPath(path string) Encoder {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	if strings.EqualFold(ext, "yml") {
+		return EncoderYAML
+	}
+
+	encodersLock.RLock()
+	defer encodersLock.RUnlock()
+	for _, enc := range encoders {
+		if strings.EqualFold(enc.Extension(), ext) {
+			return enc
+		}
+	}
+	return EncoderJSON
+}
+
+// registeredEncoders 返回已注册的序列化格式, 按注册顺序排列
+func registeredEncoders() []Encoder {
+	encodersLock.RLock()
+	defer encodersLock.RUnlock()
+	return append([]Encoder{}, encoders...)
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Name() string        { return "json" }
+func (jsonEncoder) Extension() string   { return "json" }
+func (jsonEncoder) ContentType() string { return "application/json" }
+
+func (jsonEncoder) Encode(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func (jsonEncoder) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+type yamlEncoder struct{}
+
+func (yamlEncoder) Name() string        { return "yaml" }
+func (yamlEncoder) Extension() string   { return "yaml" }
+func (yamlEncoder) ContentType() string { return "application/yaml" }
+
+func (yamlEncoder) Encode(w io.Writer, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "YAML序列化失败")
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (yamlEncoder) Decode(r io.Reader, v interface{}) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// encodeToFile 使用enc将v写入文件, 关闭文件失败(如写入缓冲无法落盘)同样返回错误
+func encodeToFile(path string, enc Encoder, v interface{}) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "无法创建文件:"+path)
+	}
+	if err := enc.Encode(f, v); err != nil {
+		f.Close()
+		return errors.Wrap(err, "无法以"+enc.Name()+"格式写入文件:"+path)
+	}
+	return errors.Wrap(f.Close(), "无法写入文件:"+path)
+}
+
+// decodeFromFile 使用enc从文件读取v
+func decodeFromFile(path string, enc Encoder, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "无法读取文件:"+path)
+	}
+	defer f.Close()
+	if err := enc.Decode(f, v); err != nil {
+		return errors.Wrap(err, "无法以"+enc.Name()+"格式解析文件:"+path)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package k8sclientkit
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+// WithFailoverEndpoints 为同一集群设置额外的APIServer地址, 用于没有负载均衡的高可用控制面.
+// 构造函数中的地址(或kubeconfig中的server)作为首选地址, 连接失败时依次尝试其余地址, 之后的请求优先使用最近一次成功的地址.
+// 无法建立连接的请求对所有方法均切换地址; 连接建立后的失败(超时, 连接被重置)只对GET/HEAD/OPTIONS重试, 避免写操作被重复执行.
+// 各地址使用相同的凭证与CA, APIServer证书需包含所有地址(或通过SNI覆盖指定统一的ServerName). 可多次调用追加地址.
+func WithFailoverEndpoints(urls ...string) Option {
+	return func(o *clientOptions) {
+		o.failoverEndpoints = append(o.failoverEndpoints, urls...)
+	}
+}
+
+// endpointFailover 记录可用地址与当前优先使用的地址
+type endpointFailover struct {
+	endpoints []*url.URL
+	current   atomic.Int32
+}
+
+// APIServerEndpoint 返回当前优先使用的APIServer地址, 未设置 WithFailoverEndpoints 时为rest.Config中的Host
+func (c *GenericK8sClient) APIServerEndpoint() string {
+	if c.failover != nil {
+		return c.failover.active().String()
+	}
+	return c.currentRestConfig().Host
+}
+
+func (f *endpointFailover) active() *url.URL {
+	return f.endpoints[f.current.Load()]
+}
+
+// applyEndpointFailover 安装地址切换transport, 位于调试日志, 指标与熔断之内, 切换地址的重试对外层表现为一次请求
+func (o *clientOptions) applyEndpointFailover(config *rest.Config) error {
+	if len(o.failoverEndpoints) == 0 {
+		return nil
+	}
+	primary, err := parseEndpoint(config.Host)
+	if err != nil {
+		return err
+	}
+	failover := &endpointFailover{endpoints: []*url.URL{primary}}
+	seen := map[string]bool{primary.String(): true}
+	for _, raw := range o.failoverEndpoints {
+		endpoint, err := parseEndpoint(raw)
+		if err != nil {
+			return err
+		}
+		if !seen[endpoint.String()] {
+			seen[endpoint.String()] = true
+			failover.endpoints = append(failover.endpoints, endpoint)
+		}
+	}
+	o.failover = failover
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &failoverRoundTripper{failover: failover, next: rt}
+	})
+	return nil
+}
+
+// parseEndpoint 解析APIServer地址, 未指定协议时使用https
+func parseEndpoint(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "无效的APIServer地址:"+raw)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+		return nil, errors.New("无效的APIServer地址:" + raw)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u, nil
+}
+
+// failoverRoundTripper 将请求发往当前优先地址, 连接失败时切换到下一个地址
+type failoverRoundTripper struct {
+	failover *endpointFailover
+	next     http.RoundTripper
+}
+
+func (rt *failoverRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoints := rt.failover.endpoints
+	start := int(rt.failover.current.Load())
+	var lastErr error
+	for i := range endpoints {
+		index := (start + i) % len(endpoints)
+		attempt := rt.rewrite(req, endpoints[index])
+		if i > 0 && req.Body != nil && req.Body != http.NoBody {
+			if attempt == req {
+				attempt = req.Clone(req.Context())
+			}
+			if req.GetBody == nil {
+				return nil, lastErr
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, lastErr
+			}
+			attempt.Body = body
+		}
+		resp, err := rt.next.RoundTrip(attempt)
+		if err == nil {
+			if index != start {
+				rt.failover.current.Store(int32(index))
+			}
+			return resp, nil
+		}
+		lastErr = err
+		if req.Context().Err() != nil || !failoverRetriable(req, err) {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// rewrite 将请求地址中首选地址的部分替换为endpoint, 保留请求路径中首选地址path前缀之后的部分
+func (rt *failoverRoundTripper) rewrite(req *http.Request, endpoint *url.URL) *http.Request {
+	primary := rt.failover.endpoints[0]
+	if endpoint == primary {
+		return req
+	}
+	u := *req.URL
+	u.Scheme = endpoint.Scheme
+	u.Host = endpoint.Host
+	if strings.HasPrefix(u.Path, primary.Path) {
+		u.Path = endpoint.Path + strings.TrimPrefix(u.Path, primary.Path)
+		u.RawPath = ""
+	}
+	attempt := req.Clone(req.Context())
+	attempt.URL = &u
+	attempt.Host = u.Host
+	return attempt
+}
+
+// failoverRetriable 无法建立连接时任何请求均可切换地址重试, 其他连接层面的失败只重试幂等的读请求
+func failoverRetriable(req *http.Request, err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if (errors.As(err, &opErr) && opErr.Op == "dial") || errors.As(err, &dnsErr) {
+		return true
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return classifyError(err) == ErrClusterUnreachable
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package k8sclientkit
+
+import (
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+// WithExecPluginAllowlist 限制kubeconfig中exec凭证插件(client.authentication.k8s.io)可执行的命令.
+// 命令以完整路径或文件名指定, 如 "aws", "gke-gcloud-auth-plugin", "kubelogin", "/usr/local/bin/aws-iam-authenticator",
+// 仅指定文件名时按PATH解析, 与kubeconfig中的命令解析后的路径相同才允许执行.
+// 未设置时允许任意命令.
+func WithExecPluginAllowlist(commands ...string) Option {
+	return func(o *clientOptions) {
+		o.execPluginAllowlist = append(o.execPluginAllowlist, commands...)
+	}
+}
+
+// prepareExecProvider 校验并调整exec凭证插件配置.
+//
+// 本库通常运行在无终端的服务进程中, 若插件尝试从标准输入交互(如等待MFA输入)将导致构建客户端时无限阻塞,
+// 因此始终禁止插件使用标准输入, 需要交互的插件将直接返回错误.
+// 同时提前检查插件命令是否存在, 避免在首次请求时才得到难以理解的错误.
+func (o *clientOptions) prepareExecProvider(config *rest.Config) error {
+	if config.ExecProvider == nil {
+		return nil
+	}
+
+	command := config.ExecProvider.Command
+	if len(o.execPluginAllowlist) > 0 && !execPluginAllowed(command, o.execPluginAllowlist) {
+		return errors.New("kubeconfig中的exec凭证插件不在允许列表中:" + command)
+	}
+
+	if _, err := exec.LookPath(command); err != nil {
+		return errors.Wrap(err, "未找到exec凭证插件命令:"+command)
+	}
+
+	// rest.CopyConfig 只复制ExecProvider指针, 修改前深拷贝, 避免影响调用方及派生客户端共享的配置
+	config.ExecProvider = config.ExecProvider.DeepCopy()
+	config.ExecProvider.StdinUnavailable = true
+	config.ExecProvider.StdinUnavailableMessage = "k8s-client-kit 不支持交互式exec凭证插件"
+	return nil
+}
+
+// execPluginAllowed 以 exec.LookPath 将命令与允许项解析为绝对路径后比较.
+// 仅指定文件名的允许项按PATH解析, 因此 "aws" 只允许PATH中的aws, 不会放行 "/tmp/x/aws" 等其他路径下的同名命令.
+func execPluginAllowed(command string, allowlist []string) bool {
+	resolved, err := resolveCommandPath(command)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range allowlist {
+		if path, err := resolveCommandPath(allowed); err == nil && path == resolved {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveCommandPath(command string) (string, error) {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(path)
+}
@@ -0,0 +1,143 @@
+package k8sclientkit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// FleetFunc 为 FleetExec 在每个集群上执行的操作, ctx 带有 FleetExecOptions.Timeout 指定的超时
+type FleetFunc func(ctx context.Context, cli *GenericK8sClient) error
+
+// FleetExecOptions 为 FleetExec 的参数
+type FleetExecOptions struct {
+	// MaxConcurrency 为同时执行的最大集群数, 默认为 DefaultMultiClusterConcurrency
+	MaxConcurrency int
+	// FailFast 为true时任一集群失败即取消正在执行的操作, 尚未开始的集群不再执行
+	FailFast bool
+	// Timeout 为每个集群的超时, 为0时不限制
+	Timeout time.Duration
+}
+
+// FleetExecResult 为 FleetExec 的结果, 各列表按clusterIDs的顺序排列
+type FleetExecResult struct {
+	Succeeded []string
+	// Failed 为执行失败(包括集群未注册, 超时, panic)的集群及其错误
+	Failed map[string]error
+	// NotRun 为因FailFast或ctx结束而未执行的集群
+	NotRun []string
+	// Durations 为每个已执行集群的耗时
+	Durations map[string]time.Duration
+
+	order []string
+}
+
+// Err 返回汇总错误, 所有集群均成功时为nil
+func (r *FleetExecResult) Err() error {
+	var errs []error
+	for _, id := range r.order {
+		if err, ok := r.Failed[id]; ok {
+			errs = append(errs, errors.Wrap(err, "集群 "+id))
+		}
+	}
+	if len(r.NotRun) > 0 {
+		errs = append(errs, errors.New("未执行的集群: "+strings.Join(r.NotRun, ", ")))
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// FleetExec 以有界并发在多个已注册集群上执行fn, 是多集群fan-out操作的基础. clusterIDs 为空时在全部已注册集群上执行.
+// 每个集群的执行带有独立超时, fn的panic被转换为该集群的错误. 返回的error为 FleetExecResult.Err.
+func (r *ClusterRegistry) FleetExec(ctx context.Context, clusterIDs []string, fn FleetFunc, opts FleetExecOptions) (*FleetExecResult, error) {
+	if len(clusterIDs) == 0 {
+		clusterIDs = r.IDs()
+	}
+	concurrency := opts.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultMultiClusterConcurrency
+	}
+	if concurrency > len(clusterIDs) {
+		concurrency = len(clusterIDs)
+	}
+	// 各集群共享同一请求ID
+	ctx, _ = EnsureRequestID(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		ran      bool
+		err      error
+		duration time.Duration
+	}
+	outcomes := make([]outcome, len(clusterIDs))
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if ctx.Err() != nil {
+					continue
+				}
+				start := time.Now()
+				err := r.fleetExecOne(ctx, clusterIDs[i], fn, opts.Timeout)
+				outcomes[i] = outcome{ran: true, err: err, duration: time.Since(start)}
+				if err != nil && opts.FailFast {
+					cancel()
+				}
+			}
+		}()
+	}
+dispatch:
+	for i := range clusterIDs {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	result := &FleetExecResult{
+		Failed:    map[string]error{},
+		Durations: map[string]time.Duration{},
+		order:     clusterIDs,
+	}
+	for i, id := range clusterIDs {
+		switch o := outcomes[i]; {
+		case !o.ran:
+			result.NotRun = append(result.NotRun, id)
+		case o.err != nil:
+			result.Failed[id] = o.err
+			result.Durations[id] = o.duration
+		default:
+			result.Succeeded = append(result.Succeeded, id)
+			result.Durations[id] = o.duration
+		}
+	}
+	return result, result.Err()
+}
+
+func (r *ClusterRegistry) fleetExecOne(ctx context.Context, id string, fn FleetFunc, timeout time.Duration) (err error) {
+	cli, err := r.MustGet(id)
+	if err != nil {
+		return err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = errors.Errorf("集群操作panic: %v", p)
+		}
+	}()
+	return fn(ctx, cli)
+}
@@ -0,0 +1,366 @@
+package k8sclientkit
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FleetMetricsNamespace 为所有指标名称的前缀
+const FleetMetricsNamespace = "k8s_client_kit"
+
+// FleetMetrics 以Prometheus指标的形式暴露各集群客户端的状态, 除registered_clusters外每个指标均带有值为 TargetK8sApiServerId 的 `cluster` 标签:
+//
+//	k8s_client_kit_registered_clusters             已注册的集群数, 仅在指定ClusterRegistry时
+//	k8s_client_kit_cluster_up                      集群是否可用(1/0), 启用 WithHealthProbe 时为探测结果, 否则为最近一次请求是否成功到达APIServer
+//	k8s_client_kit_request_duration_seconds        API请求耗时, 按method与code区分
+//	k8s_client_kit_inflight_requests               正在执行的API请求数
+//	k8s_client_kit_throttled_requests_total        被限流的请求次数, 按source(client/server)区分
+//	k8s_client_kit_throttle_wait_seconds           限流等待时长, 按source区分
+//	k8s_client_kit_construction_failures_total     客户端创建失败次数, 按reason区分
+//	k8s_client_kit_token_expiry_seconds            bearer token距离过期的秒数, 仅对可解析过期时间的token
+//	k8s_client_kit_informers                       通过 RunWatcher 运行中的watcher数
+//	k8s_client_kit_watcher_cache_objects           watcher缓存中的对象数, 按resource区分, 包括 WithCRDWatch 自动启动的watcher
+//	k8s_client_kit_watcher_queue_depth             watcher工作队列长度, 按resource区分
+//	k8s_client_kit_circuit_state                   熔断器状态, 当前状态为1, 仅在启用 WithCircuitBreaker 时
+//	k8s_client_kit_last_success_timestamp_seconds  最近一次成功请求的时间
+//
+// 描述的集群为registry中的集群与所有以 WithFleetMetrics(m) 创建且尚未Close的客户端的并集, 后者以集群标识为key记录,
+// 因此registry可以为nil. 使用方式: 创建客户端时传入 WithFleetMetrics(m), 并将m注册到prometheus.Registerer.
+type FleetMetrics struct {
+	registry *ClusterRegistry
+
+	requestDuration      *prometheus.HistogramVec
+	throttledRequests    *prometheus.CounterVec
+	throttleWait         *prometheus.HistogramVec
+	constructionFailures *prometheus.CounterVec
+
+	registeredDesc   *prometheus.Desc
+	upDesc           *prometheus.Desc
+	inflightDesc     *prometheus.Desc
+	tokenExpiryDesc  *prometheus.Desc
+	informersDesc    *prometheus.Desc
+	cacheObjectsDesc *prometheus.Desc
+	queueDepthDesc   *prometheus.Desc
+	circuitDesc      *prometheus.Desc
+	lastSuccessDesc  *prometheus.Desc
+
+	lock   sync.Mutex
+	states map[string]*clusterMetricState
+	// clients 为以 WithFleetMetrics 创建且未关闭的客户端, key为集群标识, 按创建顺序排列
+	clients map[string][]*GenericK8sClient
+}
+
+// clusterMetricState 为由请求结果更新的单集群状态
+type clusterMetricState struct {
+	up          bool
+	lastSuccess time.Time
+	inflight    int
+}
+
+// NewFleetMetrics 创建描述registry中集群及以 WithFleetMetrics 创建的客户端的指标集合, registry 可以为nil
+func NewFleetMetrics(registry *ClusterRegistry) *FleetMetrics {
+	clusterLabel := []string{"cluster"}
+	resourceLabels := []string{"cluster", "resource"}
+	return &FleetMetrics{
+		registry: registry,
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: FleetMetricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of API requests sent to the cluster.",
+			Buckets:   []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"cluster", "method", "code"}),
+		throttledRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: FleetMetricsNamespace,
+			Name:      "throttled_requests_total",
+			Help:      "Number of requests throttled by the client rate limiter or the API server.",
+		}, []string{"cluster", "source"}),
+		throttleWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: FleetMetricsNamespace,
+			Name:      "throttle_wait_seconds",
+			Help:      "Time spent waiting because of client or server side throttling.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		}, []string{"cluster", "source"}),
+		constructionFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: FleetMetricsNamespace,
+			Name:      "construction_failures_total",
+			Help:      "Number of failed client constructions.",
+		}, []string{"cluster", "reason"}),
+		registeredDesc: prometheus.NewDesc(prometheus.BuildFQName(FleetMetricsNamespace, "", "registered_clusters"),
+			"Number of clusters in the registry.", nil, nil),
+		upDesc: prometheus.NewDesc(prometheus.BuildFQName(FleetMetricsNamespace, "", "cluster_up"),
+			"Whether the cluster is reachable: the health probe result, or whether the last request reached the API server.", clusterLabel, nil),
+		inflightDesc: prometheus.NewDesc(prometheus.BuildFQName(FleetMetricsNamespace, "", "inflight_requests"),
+			"Number of API requests currently in flight.", clusterLabel, nil),
+		tokenExpiryDesc: prometheus.NewDesc(prometheus.BuildFQName(FleetMetricsNamespace, "", "token_expiry_seconds"),
+			"Seconds until the bearer token expires.", clusterLabel, nil),
+		informersDesc: prometheus.NewDesc(prometheus.BuildFQName(FleetMetricsNamespace, "", "informers"),
+			"Number of running watchers started through RunWatcher.", clusterLabel, nil),
+		cacheObjectsDesc: prometheus.NewDesc(prometheus.BuildFQName(FleetMetricsNamespace, "", "watcher_cache_objects"),
+			"Number of objects in the watcher caches.", resourceLabels, nil),
+		queueDepthDesc: prometheus.NewDesc(prometheus.BuildFQName(FleetMetricsNamespace, "", "watcher_queue_depth"),
+			"Number of items waiting in the watcher work queues.", resourceLabels, nil),
+		circuitDesc: prometheus.NewDesc(prometheus.BuildFQName(FleetMetricsNamespace, "", "circuit_state"),
+			"Circuit breaker state, 1 for the current state.", []string{"cluster", "state"}, nil),
+		lastSuccessDesc: prometheus.NewDesc(prometheus.BuildFQName(FleetMetricsNamespace, "", "last_success_timestamp_seconds"),
+			"Unix time of the last successful request.", clusterLabel, nil),
+		states:  map[string]*clusterMetricState{},
+		clients: map[string][]*GenericK8sClient{},
+	}
+}
+
+// WithFleetMetrics 使客户端的请求耗时, 请求结果, 限流及创建失败计入m, 客户端在Close之前由m描述.
+// 与 WithThrottleObserver 相同, 未通过 WithRateLimiter 指定限流器时将根据QPS/Burst创建共享的限流器以便观察等待时间.
+func WithFleetMetrics(m *FleetMetrics) Option {
+	return func(o *clientOptions) {
+		o.fleetMetrics = m
+	}
+}
+
+// ClusterRegisterer 返回为注册的所有指标附加 `cluster` 标签的Registerer, 用于在调用方自定义的指标上使用与 FleetMetrics 一致的集群标签
+func ClusterRegisterer(reg prometheus.Registerer, clusterId string) prometheus.Registerer {
+	return prometheus.WrapRegistererWith(prometheus.Labels{"cluster": clusterId}, reg)
+}
+
+// track 记录以 WithFleetMetrics 创建的客户端. 同一集群标识可能同时存在多个客户端(如 Clone, AsUser 派生的客户端),
+// 按创建顺序全部记录
+func (m *FleetMetrics) track(cli *GenericK8sClient) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.clients[cli.TargetK8sApiServerId] = append(m.clients[cli.TargetK8sApiServerId], cli)
+}
+
+// untrack 在客户端Close时移除记录, 该集群标识的最后一个客户端移除后其指标才会被清理
+func (m *FleetMetrics) untrack(cli *GenericK8sClient) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	id := cli.TargetK8sApiServerId
+	clients := m.clients[id]
+	for i, tracked := range clients {
+		if tracked == cli {
+			clients = append(clients[:i:i], clients[i+1:]...)
+			break
+		}
+	}
+	if len(clients) == 0 {
+		delete(m.clients, id)
+		return
+	}
+	m.clients[id] = clients
+}
+
+// describedClients 返回registry中的客户端与记录的客户端的并集, 按集群标识排序
+func (m *FleetMetrics) describedClients() (clients []*GenericK8sClient, registered int) {
+	byID := map[string]*GenericK8sClient{}
+	if m.registry != nil {
+		for _, cli := range m.registry.List() {
+			byID[cli.TargetK8sApiServerId] = cli
+		}
+		registered = len(byID)
+	}
+	m.lock.Lock()
+	for id, tracked := range m.clients {
+		// 同一集群标识以最早创建且仍未关闭的客户端为准, 派生客户端不替换原客户端
+		if _, ok := byID[id]; !ok {
+			byID[id] = tracked[0]
+		}
+	}
+	m.lock.Unlock()
+	for _, id := range sortedKeys(byID) {
+		clients = append(clients, byID[id])
+	}
+	return clients, registered
+}
+
+func (m *FleetMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.requestDuration.Describe(ch)
+	m.throttledRequests.Describe(ch)
+	m.throttleWait.Describe(ch)
+	m.constructionFailures.Describe(ch)
+	ch <- m.registeredDesc
+	ch <- m.upDesc
+	ch <- m.inflightDesc
+	ch <- m.tokenExpiryDesc
+	ch <- m.informersDesc
+	ch <- m.cacheObjectsDesc
+	ch <- m.queueDepthDesc
+	ch <- m.circuitDesc
+	ch <- m.lastSuccessDesc
+}
+
+func (m *FleetMetrics) Collect(ch chan<- prometheus.Metric) {
+	clients, registeredCount := m.describedClients()
+	registered := map[string]bool{}
+	if m.registry != nil {
+		ch <- prometheus.MustNewConstMetric(m.registeredDesc, prometheus.GaugeValue, float64(registeredCount))
+	}
+
+	m.lock.Lock()
+	for _, cli := range clients {
+		id := cli.TargetK8sApiServerId
+		registered[id] = true
+		if health := cli.HealthStatus(); !health.LastProbe.IsZero() {
+			// 启用了健康探测时以探测结果为准
+			ch <- prometheus.MustNewConstMetric(m.upDesc, prometheus.GaugeValue, boolToFloat(health.Reachable && health.Ready), id)
+			if !health.LastSuccess.IsZero() {
+				ch <- prometheus.MustNewConstMetric(m.lastSuccessDesc, prometheus.GaugeValue, float64(health.LastSuccess.Unix()), id)
+			}
+		} else if state, ok := m.states[id]; ok {
+			ch <- prometheus.MustNewConstMetric(m.upDesc, prometheus.GaugeValue, boolToFloat(state.up), id)
+			if !state.lastSuccess.IsZero() {
+				ch <- prometheus.MustNewConstMetric(m.lastSuccessDesc, prometheus.GaugeValue, float64(state.lastSuccess.Unix()), id)
+			}
+		}
+		inflight := 0
+		if state, ok := m.states[id]; ok {
+			inflight = state.inflight
+		}
+		ch <- prometheus.MustNewConstMetric(m.inflightDesc, prometheus.GaugeValue, float64(inflight), id)
+	}
+	// 清理已移除集群的指标
+	for id := range m.states {
+		if !registered[id] {
+			delete(m.states, id)
+			m.requestDuration.DeletePartialMatch(prometheus.Labels{"cluster": id})
+			m.throttledRequests.DeletePartialMatch(prometheus.Labels{"cluster": id})
+			m.throttleWait.DeletePartialMatch(prometheus.Labels{"cluster": id})
+		}
+	}
+	m.lock.Unlock()
+
+	for _, cli := range clients {
+		id := cli.TargetK8sApiServerId
+		if expiresAt, err := cli.TokenExpiresAt(); err == nil {
+			ch <- prometheus.MustNewConstMetric(m.tokenExpiryDesc, prometheus.GaugeValue, time.Until(expiresAt).Seconds(), id)
+		}
+		informers := 0
+		for _, name := range cli.BackgroundRoutines() {
+			if strings.HasPrefix(name, "watcher:") {
+				informers++
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(m.informersDesc, prometheus.GaugeValue, float64(informers), id)
+
+		// 同一资源可能有多个watcher(如不同namespace), 按资源汇总
+		cacheObjects, queueDepth := map[string]int{}, map[string]int{}
+		watchers := cli.watchers.list()
+		for _, w := range cli.CRDWatchers() {
+			watchers = append(watchers, w)
+		}
+		for _, w := range watchers {
+			resource := w.Gvr.String()
+			cacheObjects[resource] += len(w.informer.Informer().GetStore().ListKeys())
+			queueDepth[resource] += w.queue.Len()
+		}
+		for _, resource := range sortedKeys(cacheObjects) {
+			ch <- prometheus.MustNewConstMetric(m.cacheObjectsDesc, prometheus.GaugeValue, float64(cacheObjects[resource]), id, resource)
+			ch <- prometheus.MustNewConstMetric(m.queueDepthDesc, prometheus.GaugeValue, float64(queueDepth[resource]), id, resource)
+		}
+
+		if cli.breaker != nil {
+			current := cli.CircuitState()
+			for _, state := range []CircuitState{CircuitClosed, CircuitOpen, CircuitHalfOpen} {
+				ch <- prometheus.MustNewConstMetric(m.circuitDesc, prometheus.GaugeValue, boolToFloat(state == current), id, string(state))
+			}
+		}
+	}
+
+	m.requestDuration.Collect(ch)
+	m.throttledRequests.Collect(ch)
+	m.throttleWait.Collect(ch)
+	m.constructionFailures.Collect(ch)
+}
+
+// requestStarted 记录一个开始执行的请求, 由 observeRequest 结束
+func (m *FleetMetrics) requestStarted(clusterId string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.state(clusterId).inflight++
+}
+
+// observeThrottle 记录一次限流
+func (m *FleetMetrics) observeThrottle(event ThrottleEvent) {
+	m.throttledRequests.WithLabelValues(event.ClusterId, event.Source).Inc()
+	m.throttleWait.WithLabelValues(event.ClusterId, event.Source).Observe(event.Wait.Seconds())
+}
+
+// state 返回集群状态, 不存在时创建, 调用方需持有lock
+func (m *FleetMetrics) state(clusterId string) *clusterMetricState {
+	state, ok := m.states[clusterId]
+	if !ok {
+		state = &clusterMetricState{}
+		m.states[clusterId] = state
+	}
+	return state
+}
+
+// observeRequest 记录一次请求的耗时与结果, requestID 不为空时作为exemplar附加到耗时指标上
+func (m *FleetMetrics) observeRequest(clusterId, method, requestID string, resp *http.Response, err error, duration time.Duration) {
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	observer := m.requestDuration.WithLabelValues(clusterId, method, code)
+	if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && len(requestID) > 0 {
+		exemplarObserver.ObserveWithExemplar(duration.Seconds(), prometheus.Labels{"request_id": requestID})
+	} else {
+		observer.Observe(duration.Seconds())
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	state := m.state(clusterId)
+	// 请求执行期间集群状态可能已被清理
+	if state.inflight > 0 {
+		state.inflight--
+	}
+	state.up = err == nil && resp.StatusCode < http.StatusInternalServerError
+	if state.up && resp.StatusCode < http.StatusBadRequest {
+		state.lastSuccess = time.Now()
+	}
+}
+
+// constructionFailed 记录一次客户端创建失败, reason 为错误分类
+func (m *FleetMetrics) constructionFailed(clusterId string, err error) {
+	reason := "other"
+	switch classifyError(err) {
+	case ErrClusterUnavailable:
+		reason = "unavailable"
+	case ErrClusterUnreachable:
+		reason = "unreachable"
+	case ErrAuthExpired:
+		reason = "auth_expired"
+	case ErrForbidden:
+		reason = "forbidden"
+	case ErrResourceNotMapped:
+		reason = "resource_not_mapped"
+	}
+	m.constructionFailures.WithLabelValues(clusterId, reason).Inc()
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// metricsRoundTripper 记录请求耗时与结果
+type metricsRoundTripper struct {
+	clusterId       string
+	metrics         *FleetMetrics
+	requestIDHeader string
+	next            http.RoundTripper
+}
+
+func (rt *metricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.metrics.requestStarted(rt.clusterId)
+	start := time.Now()
+	resp, err := rt.next.RoundTrip(req)
+	rt.metrics.observeRequest(rt.clusterId, req.Method, requestIDOf(req, rt.requestIDHeader), resp, err, time.Since(start))
+	return resp, err
+}
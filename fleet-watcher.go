@@ -0,0 +1,267 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// DefaultFleetWatcherBuffer 为 FleetWatcher 事件channel的缓冲大小, 缓冲区满时各集群的informer等待消费
+	DefaultFleetWatcherBuffer = 1024
+	// fleetWatcherResync 为与registry成员重新对齐的间隔, 弥补registry事件因订阅缓冲区满而丢失的情况
+	fleetWatcherResync = 30 * time.Second
+)
+
+// FleetEventType 为 FleetEvent 的类型
+type FleetEventType string
+
+const (
+	FleetEventAdded   FleetEventType = "Added"
+	FleetEventUpdated FleetEventType = "Updated"
+	FleetEventDeleted FleetEventType = "Deleted"
+)
+
+// FleetEvent 为某个集群中对象的变更事件
+type FleetEvent struct {
+	TargetK8sApiServerId string
+	Type                 FleetEventType
+	Object               *unstructured.Unstructured
+	// OldObject 仅Updated事件设置
+	OldObject *unstructured.Unstructured
+}
+
+// FleetWatcher 在registry的所有集群中watch同一资源, 通过一个channel输出带有集群标识的事件.
+// 集群注册到registry后自动开始watch, 移除(或被替换)时停止, 调用方无需管理各集群的watcher.
+//
+// 同一集群的客户端被替换(如凭证轮换)后重新watch时, 新informer的首次list与此前输出的对象对比,
+// 仅输出差异: 新对象为Added, resourceVersion变化的为Updated, 期间消失的对象补发Deleted, 未变化的对象不再重复输出.
+// resync为0时, informer重新list产生的resourceVersion未变化的Updated同样被过滤.
+type FleetWatcher struct {
+	registry        *ClusterRegistry
+	gvr             schema.GroupVersionResource
+	namespace       string
+	resync          time.Duration
+	listOptionsFunc dynamicinformer.TweakListOptionsFunc
+
+	events chan FleetEvent
+
+	lock     sync.Mutex
+	watchers map[string]*fleetMember
+	// known 为各集群最近一次输出的对象, 跨informer重建保留, 集群移出registry时清除
+	known map[string]*fleetKnownObjects
+	wg    sync.WaitGroup
+}
+
+// fleetMember 为单个集群的informer
+type fleetMember struct {
+	client *GenericK8sClient
+	cancel context.CancelFunc
+}
+
+// fleetKnownObjects 记录已输出的对象, key为namespace/name
+type fleetKnownObjects struct {
+	lock    sync.Mutex
+	objects map[string]*unstructured.Unstructured
+}
+
+// NewFleetWatcher 创建watch registry中所有集群的gvr资源的FleetWatcher, 参数含义同 NewDynamicWatcher. 调用 Run 后开始watch.
+func NewFleetWatcher(registry *ClusterRegistry, gvr schema.GroupVersionResource, namespace string, resync time.Duration, listOptionsFunc dynamicinformer.TweakListOptionsFunc) *FleetWatcher {
+	return &FleetWatcher{
+		registry:        registry,
+		gvr:             gvr,
+		namespace:       namespace,
+		resync:          resync,
+		listOptionsFunc: listOptionsFunc,
+		events:          make(chan FleetEvent, DefaultFleetWatcherBuffer),
+		watchers:        map[string]*fleetMember{},
+		known:           map[string]*fleetKnownObjects{},
+	}
+}
+
+// Events 返回事件channel, Run 返回后关闭
+func (w *FleetWatcher) Events() <-chan FleetEvent {
+	return w.events
+}
+
+// Clusters 返回正在watch的集群标识
+func (w *FleetWatcher) Clusters() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	ids := make([]string, 0, len(w.watchers))
+	for id := range w.watchers {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Run 开始watch并跟随registry成员变化, 阻塞直至ctx结束. 返回前停止全部informer并关闭 Events.
+func (w *FleetWatcher) Run(ctx context.Context) error {
+	registryEvents, unsubscribe := w.registry.Subscribe(64)
+	defer func() {
+		unsubscribe()
+		w.lock.Lock()
+		for id, member := range w.watchers {
+			member.cancel()
+			delete(w.watchers, id)
+		}
+		w.lock.Unlock()
+		w.wg.Wait()
+		close(w.events)
+	}()
+
+	ticker := time.NewTicker(fleetWatcherResync)
+	defer ticker.Stop()
+	for {
+		w.syncMembers(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-registryEvents:
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncMembers 使正在watch的集群与registry一致, 同一标识下客户端被替换时重新watch
+func (w *FleetWatcher) syncMembers(ctx context.Context) {
+	current := map[string]*GenericK8sClient{}
+	for _, cli := range w.registry.List() {
+		current[cli.TargetK8sApiServerId] = cli
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for id, member := range w.watchers {
+		if cli, ok := current[id]; !ok || cli != member.client {
+			member.cancel()
+			delete(w.watchers, id)
+		}
+	}
+	for id := range w.known {
+		if _, ok := current[id]; !ok {
+			delete(w.known, id)
+		}
+	}
+	for id, cli := range current {
+		if _, ok := w.watchers[id]; ok {
+			continue
+		}
+		known, ok := w.known[id]
+		if !ok {
+			known = &fleetKnownObjects{objects: map[string]*unstructured.Unstructured{}}
+			w.known[id] = known
+		}
+		memberCtx, cancel := context.WithCancel(ctx)
+		member := &fleetMember{client: cli, cancel: cancel}
+		w.watchers[id] = member
+		w.wg.Add(1)
+		go func(id string, cli *GenericK8sClient) {
+			defer w.wg.Done()
+			if err := w.watchCluster(memberCtx, id, cli, known); err != nil {
+				// 通过该集群客户端的 Errors 报告, 并移出watchers以便下次与registry对齐时重试
+				cli.group.report("fleet-watcher:"+w.gvr.String(), err)
+				w.lock.Lock()
+				if w.watchers[id] == member {
+					delete(w.watchers, id)
+				}
+				w.lock.Unlock()
+				cancel()
+			}
+		}(id, cli)
+	}
+}
+
+// watchCluster 运行单个集群的informer直至ctx结束. known 中为此前informer输出过的对象, 用于重建后只输出差异.
+// 无法启动informer时返回错误.
+func (w *FleetWatcher) watchCluster(ctx context.Context, id string, cli *GenericK8sClient, known *fleetKnownObjects) error {
+	informer := dynamicinformer.NewFilteredDynamicInformer(cli.GetDynamicClient(), w.gvr, w.namespace, w.resync,
+		cache.Indexers{}, w.listOptionsFunc).Informer()
+	send := func(event FleetEvent) {
+		select {
+		case w.events <- event:
+		case <-ctx.Done():
+		}
+	}
+	emit := func(eventType FleetEventType, obj, oldObj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			return
+		}
+		event := FleetEvent{TargetK8sApiServerId: id, Type: eventType, Object: u}
+		if old, ok := oldObj.(*unstructured.Unstructured); ok {
+			event.OldObject = old
+		}
+
+		key := cache.NewObjectName(u.GetNamespace(), u.GetName()).String()
+		known.lock.Lock()
+		previous, seen := known.objects[key]
+		switch {
+		case eventType == FleetEventDeleted:
+			delete(known.objects, key)
+		case seen && previous.GetResourceVersion() == u.GetResourceVersion() &&
+			(eventType == FleetEventAdded || w.resync == 0):
+			// 重建后的首次list或重新list中未变化的对象
+			known.lock.Unlock()
+			return
+		case eventType == FleetEventAdded && seen:
+			// 重建期间发生了变化, 以此前输出的对象作为OldObject
+			event.Type = FleetEventUpdated
+			event.OldObject = previous
+			known.objects[key] = u
+		default:
+			known.objects[key] = u
+		}
+		known.lock.Unlock()
+		send(event)
+	}
+	registration, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			emit(FleetEventAdded, obj, nil)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			emit(FleetEventUpdated, newObj, oldObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			emit(FleetEventDeleted, obj, nil)
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "无法为集群 "+id+" 注册 "+w.gvr.String()+" 事件处理")
+	}
+	// informer.Run 返回时handler已全部退出, 等待其返回以免Run返回后仍向已关闭的 Events 发送
+	stopped := make(chan struct{})
+	defer func() { <-stopped }()
+	go func() {
+		defer close(stopped)
+		informer.Run(ctx.Done())
+	}()
+	if !cache.WaitForCacheSync(ctx.Done(), registration.HasSynced) {
+		return nil
+	}
+
+	// 首次list已全部交给handler, 此前输出过但已不存在的对象在重建期间被删除
+	var deleted []*unstructured.Unstructured
+	known.lock.Lock()
+	for key, obj := range known.objects {
+		if _, exists, _ := informer.GetStore().GetByKey(key); !exists {
+			deleted = append(deleted, obj)
+			delete(known.objects, key)
+		}
+	}
+	known.lock.Unlock()
+	for _, obj := range deleted {
+		send(FleetEvent{TargetK8sApiServerId: id, Type: FleetEventDeleted, Object: obj})
+	}
+	<-ctx.Done()
+	return nil
+}
@@ -2,20 +2,26 @@ package k8sclientkit
 
 import (
 	"context"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	metricsclient "k8s.io/metrics/pkg/client/clientset/versioned"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/cluster"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
@@ -24,6 +30,9 @@ const (
 	AuthTypeToken           = "TOKEN"
 	AuthTypeKubeConfigBytes = "KUBECONFIG_BYTES"
 	AuthTypeInCluster       = "IN_CLUSTER"
+	AuthTypeKubeConfigFile  = "KUBECONFIG_FILE"
+	AuthTypeClientCert      = "CLIENT_CERT"
+	AuthTypeRestConfig      = "REST_CONFIG"
 )
 
 // GenericK8sClient 用于与一个指定的Kubernetes APIServer通信。
@@ -36,11 +45,22 @@ type GenericK8sClient struct {
 	// 客户端配置KubeConfig
 	kubeConfig *clientcmdapi.Config
 	restConfig *rest.Config
+	// 应用Option之前的原始配置及构建时使用的Option, 用于派生新客户端
+	baseRestConfig *rest.Config
+	options        []Option
+	// 各客户端共享的可替换transport, 用于运行时轮换凭证
+	transport  *swappableTransport
+	configLock *sync.RWMutex
 
 	// controller-runtime Cluster 超级客户端工具实现
 	runtimeCluster cluster.Cluster
-	mgrCtx         context.Context
-	stopMgr        context.CancelFunc
+	// 跟踪所有后台goroutine, 其ctx随构造时传入的ctx或Close结束
+	group *runGroup
+	// cluster只启动一次, clusterDone 在cluster停止后关闭
+	startOnce   *sync.Once
+	clusterDone <-chan struct{}
+	// runtimeCluster 的使用时间与空闲回收状态
+	runtime *runtimeClusterState
 
 	// 用于标准对象的客户端单例
 	standardClient *kubernetes.Clientset
@@ -49,6 +69,33 @@ type GenericK8sClient struct {
 	// K8s metrics API client
 	metricsClient metricsclient.Interface
 
+	// 可续期的bearer token, 仅在设置了CredentialProvider时存在
+	token *renewableToken
+	// Close时执行的资源释放函数
+	cleanups []func()
+	// 保证cleanups只执行一次(Close或ctx结束)
+	stopOnce *sync.Once
+	// 最近一次健康探测结果
+	health *healthProber
+	// 缓存的APIServer版本
+	versionCache *serverVersionCache
+	// 可刷新的RESTMapper, 与Clone派生的客户端共享
+	restMapper *refreshableRESTMapper
+	// WithCRDWatch 自动启动的watcher
+	crdWatchers *crdWatcherSet
+	// 通过 RunWatcher 运行中的watcher
+	watchers *runningWatchers
+	// 隧道健康检查, 未使用隧道或隧道不支持检查时为nil
+	tunnelHealth DialerHealthChecker
+	// 熔断状态, 未启用 WithCircuitBreaker 时为nil
+	breaker *circuitBreaker
+	// APIServer地址切换状态, 未设置 WithFailoverEndpoints 时为nil
+	failover *endpointFailover
+	// 集群级别的行为覆盖
+	overrides ClusterOverrides
+	// 生效的插件
+	plugins []Plugin
+
 	// scheme register lock
 	schemeLock *sync.Mutex
 }
@@ -59,8 +106,11 @@ func (c *GenericK8sClient) GetDynamicClient() dynamic.Interface {
 func (c *GenericK8sClient) GetStandardClient() *kubernetes.Clientset {
 	return c.standardClient
 }
+
+// GetRuntimeCluster 返回controller-runtime Cluster. runtime cluster被空闲回收(见 WithIdleEviction)后,
+// 调用时重新创建并在后台启动, 因此调用方不应长期持有返回值, 而应在每次使用时重新获取.
 func (c *GenericK8sClient) GetRuntimeCluster() cluster.Cluster {
-	return c.runtimeCluster
+	return c.useRuntimeCluster()
 }
 func (c *GenericK8sClient) GetMetricsClient() metricsclient.Interface {
 	return c.metricsClient
@@ -72,24 +122,72 @@ func (c *GenericK8sClient) AddScheme(gv *schema.GroupVersion, addSchemeFunc func
 	c.schemeLock.Lock()
 	defer c.schemeLock.Unlock()
 
-	if !c.runtimeCluster.GetScheme().IsVersionRegistered(*gv) {
-		addSchemeFunc(c.runtimeCluster.GetScheme())
+	if scheme := c.GetRuntimeCluster().GetScheme(); !scheme.IsVersionRegistered(*gv) {
+		addSchemeFunc(scheme)
 	}
 }
 
-// 启动内置manager watcher
+// 启动内置manager watcher, 阻塞直到客户端Close或构造时传入的ctx结束.
+// cluster运行失败的错误通过 Errors 及 WithBackgroundErrorHandler 报告. 可多次调用, cluster只会启动一次.
 func (c *GenericK8sClient) Start() {
-	if c.runtimeCluster == nil {
-		return
+	<-c.startBackground()
+}
+
+// startBackground 在后台启动cluster, 重复调用时不会再次启动. 返回的channel在cluster停止后关闭.
+func (c *GenericK8sClient) startBackground() <-chan struct{} {
+	c.startOnce.Do(func() {
+		if c.runtimeCluster == nil {
+			done := make(chan struct{})
+			close(done)
+			c.clusterDone = done
+			return
+		}
+		c.runtime.lock.Lock()
+		c.runtime.started = true
+		c.runtime.lock.Unlock()
+		c.clusterDone = c.group.goRoutine("cluster", c.runRuntimeCluster)
+	})
+	return c.clusterDone
+}
+
+// Connect 请求APIServer的 `/version` 检查连通性, 失败时返回 *ClusterError(如 ErrClusterUnreachable, ErrAuthExpired). 构造函数默认会调用一次, 设置 WithLazyConnect 时可由调用方按需调用.
+func (c *GenericK8sClient) Connect(ctx context.Context) error {
+	if _, err := c.standardClient.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw(); err != nil {
+		return c.clusterError(err, schema.GroupVersionKind{})
 	}
-	c.runtimeCluster.Start(c.mgrCtx)
+	return nil
+}
+
+// Close 停止客户端启动的所有后台goroutine(cluster, token续期, 认证目录重新加载, RunWatcher启动的watcher等),
+// 等待它们全部退出后释放选项创建的资源(如SSH隧道). 可重复调用.
+func (c *GenericK8sClient) Close() error {
+	c.group.stop()
+	c.runCleanups()
+	return nil
 }
+
+// Stop 同 Close
 func (c *GenericK8sClient) Stop() {
-	c.stopMgr()
+	c.Close()
+}
+
+// runCleanups 释放选项创建的资源, 仅执行一次
+func (c *GenericK8sClient) runCleanups() {
+	c.stopOnce.Do(func() {
+		for _, cleanup := range c.cleanups {
+			cleanup()
+		}
+	})
+}
+
+// AsUser 派生一个以指定用户(及用户组)身份执行所有请求的新客户端, 原客户端的其他Option保持不变.
+// 调用方凭证需具备impersonate权限. 派生客户端拥有独立的生命周期, 需要单独Start/Stop.
+func (c *GenericK8sClient) AsUser(user string, groups ...string) (*GenericK8sClient, error) {
+	return c.Clone(WithImpersonation(user, groups, "", nil))
 }
 
 // newGenericK8sClientWithKubeConfigObj 使用指定的kube config实例创建GenericK8sClient
-func newGenericK8sClientWithKubeConfigObj(id, authType string, config *clientcmdapi.Config, timeout *time.Duration) (*GenericK8sClient, error) {
+func newGenericK8sClientWithKubeConfigObj(ctx context.Context, id, authType string, config *clientcmdapi.Config, timeout *time.Duration, opts []Option) (*GenericK8sClient, error) {
 	// 构建rest client config
 	clientConfig := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{Timeout: timeout.String()})
 	restClientConfig, err := clientConfig.ClientConfig()
@@ -97,7 +195,7 @@ func newGenericK8sClientWithKubeConfigObj(id, authType string, config *clientcmd
 		return nil, errors.Wrap(err, "使用clientcmdapi.Config方式构建rest client config失败")
 	}
 
-	cli, err := newGenericK8sClientWithRestConfig(id, authType, restClientConfig)
+	cli, err := newGenericK8sClientWithRestConfig(ctx, id, authType, restClientConfig, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -105,31 +203,136 @@ func newGenericK8sClientWithKubeConfigObj(id, authType string, config *clientcmd
 	return cli, nil
 }
 
-// newGenericK8sClientWithRestConfig 使用rest client config 创建K8sClient
-func newGenericK8sClientWithRestConfig(id, authType string, config *rest.Config) (*GenericK8sClient, error) {
+// NewGenericK8sClientWithRestConfig 使用调用方已有的rest.Config创建客户端, config 会被复制, 调用方可继续修改原对象.
+// ctx 用于约束构造过程中的连通性检查(见 WithLazyConnect), 同时决定客户端的生命周期: ctx 结束时等同于调用 Stop,
+// 因此不应传入仅用于构造的短超时ctx.
+func NewGenericK8sClientWithRestConfig(ctx context.Context, id string, config *rest.Config, opts ...Option) (*GenericK8sClient, error) {
+	return newGenericK8sClientWithRestConfig(ctx, id, AuthTypeRestConfig, rest.CopyConfig(config), opts)
+}
+
+// newGenericK8sClientWithRestConfig 使用rest client config 创建K8sClient, ctx 用于构造过程中的连通性检查, 并作为runtime cluster等后台任务的父context
+func newGenericK8sClientWithRestConfig(ctx context.Context, id, authType string, config *rest.Config, opts []Option) (*GenericK8sClient, error) {
+	cli, err := buildGenericK8sClient(ctx, id, authType, config, opts)
+	if err != nil {
+		if m := newClientOptions(opts).fleetMetrics; m != nil {
+			m.constructionFailed(id, err)
+		}
+	}
+	return cli, err
+}
+
+func buildGenericK8sClient(ctx context.Context, id, authType string, config *rest.Config, opts []Option) (*GenericK8sClient, error) {
+	baseConfig := rest.CopyConfig(config)
+	o := newClientOptions(opts)
+	if err := o.applyToRestConfig(id, config); err != nil {
+		return nil, err
+	}
+	// 各客户端共享同一个可替换transport
+	clientConfig, swappable, err := newSwappableClientConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
 	// dynamic client
-	dc, err := dynamic.NewForConfig(config)
+	dc, err := dynamic.NewForConfig(clientConfig)
 	if err != nil {
 		return nil, errors.Wrap(err, "创建dynamic client失败")
 	}
 
 	// standard client
-	sc, err := kubernetes.NewForConfig(config)
+	standardConfig := clientConfig
+	if o.protobuf {
+		standardConfig = rest.CopyConfig(clientConfig)
+		standardConfig.ContentType = runtime.ContentTypeProtobuf
+		standardConfig.AcceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+	}
+	sc, err := kubernetes.NewForConfig(standardConfig)
 	if err != nil {
 		return nil, errors.Wrap(err, "创建standard client失败")
 	}
 
-	_, err = sc.ServerVersion()
-	if err != nil {
-		return nil, errors.Wrap(err, "无法连接到集群")
+	if o.gvkRules != nil {
+		o.gvkRules.discovery = sc.Discovery()
 	}
 
 	// metrics client
-	metricsCli, err := metricsclient.NewForConfig(config)
+	metricsCli, err := metricsclient.NewForConfig(clientConfig)
 	if err != nil {
 		return nil, errors.Wrap(err, "无法创建metrics client")
 	}
 
+	clusterCli, err := newRuntimeCluster(clientConfig, o)
+	if err != nil {
+		return nil, err
+	}
+
+	cli := &GenericK8sClient{
+		TargetK8sApiServerId: id,
+		AuthType:             authType,
+		kubeConfig:           nil,
+		restConfig:           config,
+		baseRestConfig:       baseConfig,
+		options:              opts,
+		transport:            swappable,
+		configLock:           &sync.RWMutex{},
+		metricsClient:        metricsCli,
+		standardClient:       sc,
+		dynamicClient:        dc,
+		group:                newRunGroup(ctx, id, o.backgroundErrorHandler),
+		runtimeCluster:       clusterCli,
+		runtime:              newRuntimeClusterState(clientConfig, o),
+		schemeLock:           &sync.Mutex{},
+		token:                o.token,
+		cleanups:             o.cleanups,
+		stopOnce:             &sync.Once{},
+		startOnce:            &sync.Once{},
+		health:               &healthProber{},
+		versionCache:         &serverVersionCache{},
+		crdWatchers:          &crdWatcherSet{watchers: map[string]*crdWatcher{}},
+		watchers:             &runningWatchers{watchers: map[*K8sResourceWatcher]struct{}{}},
+		tunnelHealth:         o.tunnelHealth,
+		breaker:              o.breaker,
+		failover:             o.failover,
+		overrides:            o.overrides,
+		plugins:              o.plugins,
+	}
+	if !o.lazyConnect {
+		if err := cli.Connect(ctx); err != nil {
+			cli.Stop()
+			return nil, err
+		}
+	}
+	if m := o.fleetMetrics; m != nil {
+		m.track(cli)
+		cli.cleanups = append(cli.cleanups, func() { m.untrack(cli) })
+	}
+	cli.group.goRoutine("cleanup", func(ctx context.Context) error {
+		<-ctx.Done()
+		cli.runCleanups()
+		return nil
+	})
+	cli.restMapper, _ = o.restMapper.(*refreshableRESTMapper)
+	if o.crdWatch != nil {
+		cli.group.goRoutine("crd-watch", func(ctx context.Context) error {
+			return cli.watchCRDs(ctx, o.crdWatch)
+		})
+	}
+	if o.healthProbeInterval > 0 {
+		cli.group.goRoutine("health-probe", func(ctx context.Context) error {
+			return cli.probeHealthLoop(ctx, o.healthProbeInterval)
+		})
+	}
+	if o.needTokenWatch() {
+		cli.group.goRoutine("token-expiry", func(ctx context.Context) error {
+			cli.watchTokenExpiry(ctx, o.tokenExpiryLead, o.tokenExpiryNotifier)
+			return nil
+		})
+	}
+	return cli, nil
+}
+
+// newRuntimeCluster 创建controller-runtime Cluster, 空闲回收后重新创建时同样使用
+func newRuntimeCluster(clientConfig *rest.Config, o *clientOptions) (cluster.Cluster, error) {
 	// controller-runtime Client
 	// mgr, err := manager.New(config, manager.Options{})
 	// if err != nil {
@@ -138,69 +341,121 @@ func newGenericK8sClientWithRestConfig(id, authType string, config *rest.Config)
 	// }
 
 	opt := manager.Options{}
-	clusterCli, err := cluster.New(config, func(clusterOptions *cluster.Options) {
+	clusterCli, err := cluster.New(clientConfig, func(clusterOptions *cluster.Options) {
 		clusterOptions.Scheme = opt.Scheme
 		clusterOptions.MapperProvider = opt.MapperProvider
 		clusterOptions.Logger = opt.Logger
 		clusterOptions.NewCache = opt.NewCache
 		clusterOptions.NewClient = opt.NewClient
+		clusterOptions.MapperProvider = func(config *rest.Config, httpClient *http.Client) (meta.RESTMapper, error) {
+			if o.restMapper != nil {
+				return o.restMapper, nil
+			}
+			mapper, err := newRefreshableRESTMapper(config, httpClient)
+			o.restMapper = mapper
+			return mapper, err
+		}
+		if o.dryRun {
+			clusterOptions.Client.DryRun = &o.dryRun
+		}
+		if len(o.uncachedObjects) > 0 {
+			clusterOptions.Client.Cache = &client.CacheOptions{DisableFor: o.uncachedObjects}
+		}
+		if len(o.cacheNamespaces) > 0 {
+			clusterOptions.Cache.DefaultNamespaces = map[string]cache.Config{}
+			for _, ns := range o.cacheNamespaces {
+				clusterOptions.Cache.DefaultNamespaces[ns] = cache.Config{}
+			}
+		}
 	})
 	if err != nil {
 		return nil, errors.Wrap(err, "创建runtime-controller.Cluster")
 	}
-
-	mgrCtx, stop := context.WithCancel(context.Background())
-	return &GenericK8sClient{
-		TargetK8sApiServerId: id,
-		AuthType:             authType,
-		kubeConfig:           nil,
-		restConfig:           config,
-		metricsClient:        metricsCli,
-		standardClient:       sc,
-		dynamicClient:        dc,
-		mgrCtx:               mgrCtx,
-		stopMgr:              stop,
-		runtimeCluster:       clusterCli,
-		schemeLock:           &sync.Mutex{},
-	}, nil
+	return clusterCli, nil
 }
 
-// NewGenericK8sClientWithToken 使用目标集群的ApiServer url和具有一定访问权限的bearer token来构建一个generic client.
+// NewGenericK8sClientWithTokenContext 使用目标集群的ApiServer url和具有一定访问权限的bearer token来构建一个generic client.
 // token 可以通过创建ServiceAccount并获取对应的Secret来得到(从v1.24开始需要开启相关的特性门控才会自动创建Secret).
 //
 //	apiServerUrl: 目标apiserver的访问地址，如`https://cluster-1.dc1.example.com:6443`, 或`https://10.2.0.121:6443`
 //	optionalTLSServerName: 用于校验服务端证书是否与此名称一致，默认同APIServerURL保持一致; 同时也影响TLS SNI, 在client hello 中将传递给server
 //	caPem: PEM编码的信任CA，应该设置为目标APIServer的CA证书
-func NewGenericK8sClientWithToken(id, apiServerUrl, token string, caPem []byte, optionalTLSServerName string, skipTLSVerify bool, timeout *time.Duration) (*GenericK8sClient, error) {
+func NewGenericK8sClientWithTokenContext(ctx context.Context, id, apiServerUrl, token string, caPem []byte, optionalTLSServerName string, skipTLSVerify bool, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
 	if timeout == nil {
 		var to = 30 * time.Second
 		timeout = &to
 	}
 
-	// 准备kubeconfig模板
-	config := clientcmdapi.NewConfig()
-	config.Clusters["cluster"] = &clientcmdapi.Cluster{
+	config := singleClusterKubeConfig(&clientcmdapi.Cluster{
 		Server:                   apiServerUrl,
 		TLSServerName:            optionalTLSServerName,
 		InsecureSkipTLSVerify:    skipTLSVerify,
 		CertificateAuthorityData: caPem,
+	}, &clientcmdapi.AuthInfo{
+		Token: token,
+	})
+
+	return newGenericK8sClientWithKubeConfigObj(ctx, id, AuthTypeToken, config, timeout, opts)
+}
+
+// NewGenericK8sClientWithToken 同 NewGenericK8sClientWithTokenContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientWithTokenContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientWithToken(id, apiServerUrl, token string, caPem []byte, optionalTLSServerName string, skipTLSVerify bool, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientWithTokenContext(context.Background(), id, apiServerUrl, token, caPem, optionalTLSServerName, skipTLSVerify, timeout, opts...)
+}
+
+// NewGenericK8sClientWithClientCertContext 使用客户端证书(mTLS)认证方式构建generic client.
+//
+//	clientCertPEM, clientKeyPEM: PEM编码的客户端证书与私钥, 证书CN/O将作为用户名/用户组
+//	caPEM: PEM编码的信任CA，应该设置为目标APIServer的CA证书
+//	optionalTLSServerName: 用于校验服务端证书是否与此名称一致，默认同APIServerURL保持一致
+func NewGenericK8sClientWithClientCertContext(ctx context.Context, id, apiServerUrl string, clientCertPEM, clientKeyPEM, caPEM []byte, optionalTLSServerName string, skipTLSVerify bool, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	if timeout == nil {
+		var to = 30 * time.Second
+		timeout = &to
 	}
-	config.AuthInfos["cluster"] = &clientcmdapi.AuthInfo{
-		ClientCertificateData: nil,
-		ClientKeyData:         nil,
-		Token:                 token,
+	if len(clientCertPEM) == 0 || len(clientKeyPEM) == 0 {
+		return nil, errors.New("客户端证书与私钥不能为空")
 	}
+
+	config := singleClusterKubeConfig(&clientcmdapi.Cluster{
+		Server:                   apiServerUrl,
+		TLSServerName:            optionalTLSServerName,
+		InsecureSkipTLSVerify:    skipTLSVerify,
+		CertificateAuthorityData: caPEM,
+	}, &clientcmdapi.AuthInfo{
+		ClientCertificateData: clientCertPEM,
+		ClientKeyData:         clientKeyPEM,
+	})
+
+	return newGenericK8sClientWithKubeConfigObj(ctx, id, AuthTypeClientCert, config, timeout, opts)
+}
+
+// NewGenericK8sClientWithClientCert 同 NewGenericK8sClientWithClientCertContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientWithClientCertContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientWithClientCert(id, apiServerUrl string, clientCertPEM, clientKeyPEM, caPEM []byte, optionalTLSServerName string, skipTLSVerify bool, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientWithClientCertContext(context.Background(), id, apiServerUrl, clientCertPEM, clientKeyPEM, caPEM, optionalTLSServerName, skipTLSVerify, timeout, opts...)
+}
+
+// singleClusterKubeConfig 使用一组cluster与认证信息生成只包含一个context的kubeconfig模板
+func singleClusterKubeConfig(cluster *clientcmdapi.Cluster, authInfo *clientcmdapi.AuthInfo) *clientcmdapi.Config {
+	config := clientcmdapi.NewConfig()
+	config.Clusters["cluster"] = cluster
+	config.AuthInfos["cluster"] = authInfo
 	config.Contexts["cluster"] = &clientcmdapi.Context{
 		Cluster:   "cluster",
 		AuthInfo:  "cluster",
 		Namespace: "default",
 	}
 	config.CurrentContext = "cluster"
-
-	return newGenericK8sClientWithKubeConfigObj(id, AuthTypeToken, config, timeout)
+	return config
 }
 
-func NewGenericK8sClientWithSecretDir(id, authSecretDir, apiServerUrl, sni string, timeout *time.Duration) (*GenericK8sClient, error) {
+// NewGenericK8sClientWithSecretDirContext 使用认证目录(如ServiceAccount挂载目录)中的token与ca.crt创建客户端,
+// 客户端运行期间定期重新读取这两个文件, 轮换后的凭证无需重建客户端即可生效(见 WithSecretReloadInterval).
+func NewGenericK8sClientWithSecretDirContext(ctx context.Context, id, authSecretDir, apiServerUrl, sni string, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
 	if timeout == nil {
 		var to = 30 * time.Second
 		timeout = &to
@@ -218,17 +473,33 @@ func NewGenericK8sClientWithSecretDir(id, authSecretDir, apiServerUrl, sni strin
 		return nil, errors.Wrap(err, "无法读取Token文件:"+dir+"token")
 	}
 
-	return NewGenericK8sClientWithToken(id, apiServerUrl, string(token), caCert, sni, false, timeout)
+	cli, err := NewGenericK8sClientWithTokenContext(ctx, id, apiServerUrl, string(token), caCert, sni, false, timeout, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// projected service-account token会定期轮换, 后台重新读取以避免token过期
+	cli.group.goRoutine("secret-dir-reload", func(ctx context.Context) error {
+		cli.watchSecretDir(ctx, dir, token, caCert, newClientOptions(opts).secretReloadInterval)
+		return nil
+	})
+	return cli, nil
 }
 
-// NewGenericK8sClientWithKubeConfigBytes 使用指定的KubeConfig bytes创建K8sClient
+// NewGenericK8sClientWithSecretDir 同 NewGenericK8sClientWithSecretDirContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientWithSecretDirContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientWithSecretDir(id, authSecretDir, apiServerUrl, sni string, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientWithSecretDirContext(context.Background(), id, authSecretDir, apiServerUrl, sni, timeout, opts...)
+}
+
+// NewGenericK8sClientWithKubeConfigBytesContext 使用指定的KubeConfig bytes创建K8sClient
 //
 //	param: overrideServerName string 可选的指定APIServer TLS 域名SNI
 //
 // Kubeconfig中cluster server支持使用IP地址端口方式指定可保证正确访问到的路由，若目标ApiServer在TLS SNI代理服务器之后，
 // 可以通过指定overrideServerName来使代理服务器正常工作。
 // 另外可选的方式是在部署k8s-provisioner时外部环境中解决域名解析问题，则可以直接在kubeconfig中使用域名方式指定APIServer地址。
-func NewGenericK8sClientWithKubeConfigBytes(id string, kubeConfig []byte, overrideSNIServerName string, timeout *time.Duration) (*GenericK8sClient, error) {
+func NewGenericK8sClientWithKubeConfigBytesContext(ctx context.Context, id string, kubeConfig []byte, overrideSNIServerName string, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
 	if timeout == nil {
 		var to = 30 * time.Second
 		timeout = &to
@@ -247,11 +518,90 @@ func NewGenericK8sClientWithKubeConfigBytes(id string, kubeConfig []byte, overri
 		config.ServerName = overrideSNIServerName
 	}
 	config.Timeout = *timeout
-	return newGenericK8sClientWithRestConfig(id, AuthTypeKubeConfigBytes, config)
+	return newGenericK8sClientWithRestConfig(ctx, id, AuthTypeKubeConfigBytes, config, opts)
+}
+
+// NewGenericK8sClientWithKubeConfigBytes 同 NewGenericK8sClientWithKubeConfigBytesContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientWithKubeConfigBytesContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientWithKubeConfigBytes(id string, kubeConfig []byte, overrideSNIServerName string, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientWithKubeConfigBytesContext(context.Background(), id, kubeConfig, overrideSNIServerName, timeout, opts...)
+}
+
+// NewGenericK8sClientsFromKubeConfigContext 为kubeconfig中的每个context分别创建一个K8sClient, 以context名称为key(同时作为client id).
+// 部分context创建失败时, 返回成功创建的客户端以及汇总的错误.
+func NewGenericK8sClientsFromKubeConfigContext(ctx context.Context, kubeConfig []byte, opts ...Option) (map[string]*GenericK8sClient, error) {
+	config, err := clientcmd.Load(kubeConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法解析KubeConfig bytes")
+	}
+
+	clients := make(map[string]*GenericK8sClient, len(config.Contexts))
+	var errs []error
+	for contextName := range config.Contexts {
+		restConfig, err := clientcmd.NewNonInteractiveClientConfig(*config, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "无法为context "+contextName+" 构建rest client config"))
+			continue
+		}
+		restConfig.Timeout = 30 * time.Second
+
+		cli, err := newGenericK8sClientWithRestConfig(ctx, contextName, AuthTypeKubeConfigBytes, restConfig, opts)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "无法为context "+contextName+" 创建客户端"))
+			continue
+		}
+		clients[contextName] = cli
+	}
+	return clients, utilerrors.NewAggregate(errs)
+}
+
+// NewGenericK8sClientsFromKubeConfig 同 NewGenericK8sClientsFromKubeConfigContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientsFromKubeConfigContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientsFromKubeConfig(kubeConfig []byte, opts ...Option) (map[string]*GenericK8sClient, error) {
+	return NewGenericK8sClientsFromKubeConfigContext(context.Background(), kubeConfig, opts...)
+}
+
+// NewGenericK8sClientWithKubeConfigFileContext 使用kubeconfig文件创建K8sClient, 可指定非current-context的context.
+//
+//	path: kubeconfig文件路径, 支持 `~/` 开头; 为空时使用默认加载规则(KUBECONFIG环境变量或 `~/.kube/config`)
+//	contextName: 使用的context名称, 为空时使用current-context
+//	overrideSNI: 可选的指定APIServer TLS 域名SNI
+func NewGenericK8sClientWithKubeConfigFileContext(ctx context.Context, id, path, contextName, overrideSNI string, opts ...Option) (*GenericK8sClient, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if len(path) > 0 {
+		if strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, errors.Wrap(err, "无法获取用户主目录")
+			}
+			path = filepath.Join(home, path[2:])
+		}
+		loadingRules = &clientcmd.ClientConfigLoadingRules{ExplicitPath: path}
+	}
+
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: contextName}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "无法使用kubeconfig文件 "+path+" (context: "+contextName+") 构建rest client config")
+	}
+	if len(overrideSNI) > 0 {
+		config.ServerName = overrideSNI
+	}
+	config.Timeout = 30 * time.Second
+	return newGenericK8sClientWithRestConfig(ctx, id, AuthTypeKubeConfigFile, config, opts)
+}
+
+// NewGenericK8sClientWithKubeConfigFile 同 NewGenericK8sClientWithKubeConfigFileContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientWithKubeConfigFileContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientWithKubeConfigFile(id, path, contextName, overrideSNI string, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientWithKubeConfigFileContext(context.Background(), id, path, contextName, overrideSNI, opts...)
 }
 
-// NewGenericK8sClientInCluster 使用当前集群SA创建K8sClient. 仅在Kubernetes集群内部署时可用
-func NewGenericK8sClientInCluster(id string, timeout *time.Duration) (*GenericK8sClient, error) {
+// NewGenericK8sClientInClusterContext 使用当前集群SA创建K8sClient. 仅在Kubernetes集群内部署时可用
+func NewGenericK8sClientInClusterContext(ctx context.Context, id string, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
 	if timeout == nil {
 		var to = 30 * time.Second
 		timeout = &to
@@ -262,5 +612,53 @@ func NewGenericK8sClientInCluster(id string, timeout *time.Duration) (*GenericK8
 	}
 
 	config.Timeout = *timeout
-	return newGenericK8sClientWithRestConfig(id, AuthTypeInCluster, config)
+	return newGenericK8sClientWithRestConfig(ctx, id, AuthTypeInCluster, config, opts)
+}
+
+// NewGenericK8sClientInCluster 同 NewGenericK8sClientInClusterContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientInClusterContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientInCluster(id string, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientInClusterContext(context.Background(), id, timeout, opts...)
+}
+
+// NewGenericK8sClientAutoContext 按以下顺序自动选择集群配置创建K8sClient, 与controller-runtime的GetConfig行为保持一致:
+//
+//  1. KUBECONFIG 环境变量指定的kubeconfig文件(可使用路径分隔符指定多个文件)
+//  2. 集群内配置(挂载的ServiceAccount token)
+//  3. 默认的 `~/.kube/config`
+//
+// 便于命令行工具与集群内部署使用同一套构建方式.
+func NewGenericK8sClientAutoContext(ctx context.Context, id string, opts ...Option) (*GenericK8sClient, error) {
+	timeout := 30 * time.Second
+
+	if kubeConfigEnv := os.Getenv(clientcmd.RecommendedConfigPathEnvVar); len(kubeConfigEnv) > 0 {
+		loadingRules := &clientcmd.ClientConfigLoadingRules{Precedence: filepath.SplitList(kubeConfigEnv)}
+		config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "无法使用KUBECONFIG环境变量指定的文件构建rest client config")
+		}
+		config.Timeout = timeout
+		return newGenericK8sClientWithRestConfig(ctx, id, AuthTypeKubeConfigFile, config, opts)
+	}
+
+	if config, err := rest.InClusterConfig(); err == nil {
+		config.Timeout = timeout
+		return newGenericK8sClientWithRestConfig(ctx, id, AuthTypeInCluster, config, opts)
+	}
+
+	loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: clientcmd.RecommendedHomeFile}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "未找到可用的集群配置(KUBECONFIG, 集群内配置, "+clientcmd.RecommendedHomeFile+")")
+	}
+	config.Timeout = timeout
+	return newGenericK8sClientWithRestConfig(ctx, id, AuthTypeKubeConfigFile, config, opts)
+}
+
+// NewGenericK8sClientAuto 同 NewGenericK8sClientAutoContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientAutoContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientAuto(id string, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientAutoContext(context.Background(), id, opts...)
 }
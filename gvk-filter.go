@@ -0,0 +1,141 @@
+package k8sclientkit
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// ErrGVKNotAllowed 请求的资源类型被 WithAllowedGVKs/WithDeniedGVKs 禁止时返回此错误
+var ErrGVKNotAllowed = errors.New("客户端不允许访问该资源类型")
+
+// WithAllowedGVKs 仅允许访问列表中的资源类型, 其他资源的请求(包括各helper与watcher发出的请求)均返回 ErrGVKNotAllowed.
+// Version 为空表示匹配任意版本, Kind 为空表示匹配该group下的所有类型. 可多次调用以追加.
+// 与RBAC互补, 用于在半可信的插件中嵌入客户端时提供额外防护.
+func WithAllowedGVKs(gvks ...schema.GroupVersionKind) Option {
+	return func(o *clientOptions) {
+		o.gvkFilter().allowed = append(o.gvkFilter().allowed, gvks...)
+	}
+}
+
+// WithDeniedGVKs 禁止访问列表中的资源类型, 如 `WithDeniedGVKs(corev1.SchemeGroupVersion.WithKind("Secret"))`.
+// 匹配规则同 WithAllowedGVKs, 同时设置时禁止列表优先.
+func WithDeniedGVKs(gvks ...schema.GroupVersionKind) Option {
+	return func(o *clientOptions) {
+		o.gvkFilter().denied = append(o.gvkFilter().denied, gvks...)
+	}
+}
+
+func (o *clientOptions) gvkFilter() *gvkFilter {
+	if o.gvkRules == nil {
+		o.gvkRules = &gvkFilter{kinds: map[schema.GroupVersionResource]string{}}
+	}
+	return o.gvkRules
+}
+
+// gvkFilter 根据请求路径解析出的资源判断是否允许访问. 资源到Kind的映射通过discovery获取并缓存.
+type gvkFilter struct {
+	allowed []schema.GroupVersionKind
+	denied  []schema.GroupVersionKind
+
+	// discovery 在客户端创建后设置
+	discovery discovery.DiscoveryInterface
+	lock      sync.Mutex
+	kinds     map[schema.GroupVersionResource]string
+}
+
+func (f *gvkFilter) check(gvr schema.GroupVersionResource) error {
+	kind, err := f.kindFor(gvr)
+	if err != nil {
+		// 无法确定资源类型时拒绝请求
+		return errors.Wrap(err, "无法确定资源 "+gvr.String()+" 的类型")
+	}
+	gvk := gvr.GroupVersion().WithKind(kind)
+	if gvkMatchesAny(gvk, f.denied) || (len(f.allowed) > 0 && !gvkMatchesAny(gvk, f.allowed)) {
+		return errors.Wrap(ErrGVKNotAllowed, gvk.String())
+	}
+	return nil
+}
+
+func (f *gvkFilter) kindFor(gvr schema.GroupVersionResource) (string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if kind, ok := f.kinds[gvr]; ok {
+		return kind, nil
+	}
+	if f.discovery == nil {
+		return "", errors.New("客户端尚未初始化")
+	}
+	resources, err := f.discovery.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return "", err
+	}
+	for _, resource := range resources.APIResources {
+		if !strings.Contains(resource.Name, "/") {
+			f.kinds[gvr.GroupVersion().WithResource(resource.Name)] = resource.Kind
+		}
+	}
+	kind, ok := f.kinds[gvr]
+	if !ok {
+		return "", errors.New("未找到目标资源:" + gvr.String())
+	}
+	return kind, nil
+}
+
+func gvkMatchesAny(gvk schema.GroupVersionKind, rules []schema.GroupVersionKind) bool {
+	for _, rule := range rules {
+		if rule.Group == gvk.Group &&
+			(len(rule.Version) == 0 || rule.Version == gvk.Version) &&
+			(len(rule.Kind) == 0 || rule.Kind == gvk.Kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// gvkFilterRoundTripper 拒绝访问不被允许的资源类型, discovery等非资源请求不受影响
+type gvkFilterRoundTripper struct {
+	filter *gvkFilter
+	next   http.RoundTripper
+}
+
+func (rt *gvkFilterRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if gvr, ok := resourceFromPath(req.URL.Path); ok {
+		if err := rt.filter.check(gvr); err != nil {
+			return nil, err
+		}
+	}
+	return rt.next.RoundTrip(req)
+}
+
+// resourceFromPath 从APIServer请求路径中解析GVR, 如 `/api/v1/namespaces/ns/pods/name/log`, `/apis/apps/v1/deployments`.
+// 非资源请求(discovery, /version等)返回false.
+func resourceFromPath(path string) (schema.GroupVersionResource, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	var gv schema.GroupVersion
+	switch {
+	case len(segments) >= 3 && segments[0] == "api":
+		gv = schema.GroupVersion{Version: segments[1]}
+		segments = segments[2:]
+	case len(segments) >= 4 && segments[0] == "apis":
+		gv = schema.GroupVersion{Group: segments[1], Version: segments[2]}
+		segments = segments[3:]
+	default:
+		return schema.GroupVersionResource{}, false
+	}
+	// 旧式watch路径 `/api/v1/watch/...`
+	if segments[0] == "watch" {
+		segments = segments[1:]
+	}
+	if len(segments) >= 3 && segments[0] == "namespaces" {
+		segments = segments[2:]
+	}
+	if len(segments) == 0 || len(segments[0]) == 0 {
+		return schema.GroupVersionResource{}, false
+	}
+	return gv.WithResource(segments[0]), true
+}
@@ -0,0 +1,136 @@
+package k8sclientkit
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
+)
+
+// DefaultHealthProbeInterval 为 WithHealthProbe 未指定间隔时的探测间隔
+const DefaultHealthProbeInterval = 30 * time.Second
+
+// HealthStatus 为集群最近一次健康探测的结果
+type HealthStatus struct {
+	// Reachable 表示APIServer可访问且凭证有效(ServerVersion请求成功)
+	Reachable bool
+	// Ready 表示 `/readyz` 返回成功. 凭证无权访问 `/readyz` 时以Reachable为准
+	Ready bool
+	// ServerVersion 为APIServer的gitVersion, 如 `v1.29.2`
+	ServerVersion string
+	// Latency 为ServerVersion请求的耗时
+	Latency time.Duration
+	// LastProbe 为最近一次探测的时间, 尚未探测时为零值
+	LastProbe time.Time
+	// LastSuccess 为最近一次探测成功(Reachable)的时间
+	LastSuccess time.Time
+	// LastError 为最近一次探测的错误(*ClusterError), 成功时为nil
+	LastError error
+	// ConsecutiveFailures 为连续探测失败次数
+	ConsecutiveFailures int
+	// TunnelError 为隧道(WithDialer, WithSSHTunnel)健康检查的错误, 隧道正常或不支持检查时为nil.
+	// 不为nil时未请求APIServer, Reachable与Ready均为false
+	TunnelError error
+}
+
+// WithHealthProbe 在后台定期探测集群健康状态(`/readyz` 与 ServerVersion), 结果通过 HealthStatus 读取.
+// interval 小于等于0时使用 DefaultHealthProbeInterval.
+func WithHealthProbe(interval time.Duration) Option {
+	return func(o *clientOptions) {
+		if interval <= 0 {
+			interval = DefaultHealthProbeInterval
+		}
+		o.healthProbeInterval = interval
+	}
+}
+
+// healthProber 保存最近一次探测结果
+type healthProber struct {
+	lock   sync.RWMutex
+	status HealthStatus
+}
+
+// HealthStatus 返回最近一次健康探测的结果. 未设置 WithHealthProbe 且从未调用 ProbeHealth 时, LastProbe为零值.
+func (c *GenericK8sClient) HealthStatus() HealthStatus {
+	c.health.lock.RLock()
+	defer c.health.lock.RUnlock()
+	return c.health.status
+}
+
+// ProbeHealth 立即执行一次健康探测, 更新并返回 HealthStatus
+func (c *GenericK8sClient) ProbeHealth(ctx context.Context) HealthStatus {
+	restClient := c.GetStandardClient().Discovery().RESTClient()
+
+	start := time.Now()
+	var tunnelErr error
+	if c.tunnelHealth != nil {
+		tunnelErr = c.tunnelHealth.CheckHealth(ctx)
+	}
+	var version *apimachineryversion.Info
+	var err error
+	var body []byte
+	if tunnelErr != nil {
+		err = errors.Wrap(tunnelErr, "隧道不可用")
+	} else {
+		body, err = restClient.Get().AbsPath("/version").Do(ctx).Raw()
+	}
+	latency := time.Since(start)
+	if err == nil {
+		version = &apimachineryversion.Info{}
+		if err = json.Unmarshal(body, version); err != nil {
+			version = nil
+			err = errors.Wrap(err, "无法解析APIServer版本信息")
+		}
+	}
+
+	ready := false
+	if err == nil {
+		_, readyErr := restClient.Get().AbsPath("/readyz").Do(ctx).Raw()
+		ready = readyErr == nil || apierrors.IsForbidden(readyErr)
+		if !ready {
+			err = errors.Wrap(readyErr, "APIServer未就绪")
+		}
+	}
+
+	c.health.lock.Lock()
+	defer c.health.lock.Unlock()
+	status := &c.health.status
+	status.LastProbe = start
+	status.Latency = latency
+	status.Ready = ready
+	status.Reachable = version != nil
+	status.TunnelError = tunnelErr
+	if version != nil {
+		status.ServerVersion = version.GitVersion
+		status.LastSuccess = start
+	}
+	if err != nil {
+		status.LastError = c.clusterError(err, schema.GroupVersionKind{})
+		status.ConsecutiveFailures++
+	} else {
+		status.LastError = nil
+		status.ConsecutiveFailures = 0
+	}
+	return *status
+}
+
+// probeHealthLoop 按interval执行健康探测, 随ctx结束退出
+func (c *GenericK8sClient) probeHealthLoop(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		probeCtx, cancel := context.WithTimeout(ctx, interval)
+		c.ProbeHealth(probeCtx)
+		cancel()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package k8sclientkit
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+// WithHostAlias 将连接host的请求改为连接ip, 类似/etc/hosts条目, 可多次调用设置多个别名.
+// 适用于kubeconfig使用内部DNS名称而当前环境无法解析的场景. 请求URL与TLS SNI仍使用host, 证书校验不受影响.
+// 设置了 WithProxyURL 时, 别名仅作用于与代理服务器的连接.
+func WithHostAlias(host, ip string) Option {
+	return func(o *clientOptions) {
+		if o.hostAliases == nil {
+			o.hostAliases = map[string]string{}
+		}
+		o.hostAliases[strings.ToLower(host)] = ip
+	}
+}
+
+// applyHostAliases 包装config.Dial, 在拨号前替换地址中的host. 包装位于SSH隧道与 WithTransportSettings 拨号参数之外,
+// 经SSH隧道时由跳板机连接别名ip.
+func (o *clientOptions) applyHostAliases(config *rest.Config) error {
+	if len(o.hostAliases) == 0 {
+		return nil
+	}
+	aliases := make(map[string]string, len(o.hostAliases))
+	for host, ip := range o.hostAliases {
+		if net.ParseIP(ip) == nil {
+			return errors.New("host别名 " + host + " 的地址不是有效的IP: " + ip)
+		}
+		aliases[host] = ip
+	}
+
+	dial := config.Dial
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	config.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(address); err == nil {
+			if ip, ok := aliases[strings.ToLower(host)]; ok {
+				address = net.JoinHostPort(ip, port)
+			}
+		}
+		return dial(ctx, network, address)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// WithIdleEviction 使registry停止超过ttl未被使用(未调用 GetRuntimeCluster 及基于它的helper)的客户端的runtime cluster,
+// 释放其informer缓存; 客户端本身保持注册, 下次使用时重新创建并启动runtime cluster, 缓存随之重新同步.
+// 回收时订阅者收到 ClusterIdleEvicted 事件. 适用于注册了大量但很少访问的集群的场景.
+func WithIdleEviction(ttl time.Duration) RegistryOption {
+	return func(r *ClusterRegistry) {
+		r.idleTTL = ttl
+	}
+}
+
+// runtimeClusterState 记录runtime cluster的使用时间, 并在空闲回收后按需重建
+type runtimeClusterState struct {
+	// lastUsed 为最近一次使用的UnixNano
+	lastUsed atomic.Int64
+	build    func() (cluster.Cluster, error)
+
+	lock sync.Mutex
+	// started 为true时runtime cluster已通过Start启动, 未启动的cluster没有可回收的缓存
+	started bool
+	evicted bool
+	// generation 在每次回收时递增, 用于区分cluster是被回收还是自身运行失败
+	generation int
+	cancel     context.CancelFunc
+	// wake 在回收后重建时通知 runRuntimeCluster 启动新的cluster
+	wake chan struct{}
+}
+
+func newRuntimeClusterState(clientConfig *rest.Config, o *clientOptions) *runtimeClusterState {
+	s := &runtimeClusterState{
+		build: func() (cluster.Cluster, error) {
+			return newRuntimeCluster(clientConfig, o)
+		},
+		wake: make(chan struct{}, 1),
+	}
+	s.lastUsed.Store(time.Now().UnixNano())
+	return s
+}
+
+// LastUsed 返回runtime cluster最近一次被使用的时间
+func (c *GenericK8sClient) LastUsed() time.Time {
+	return time.Unix(0, c.runtime.lastUsed.Load())
+}
+
+// useRuntimeCluster 记录使用时间, 已被回收时重建runtime cluster
+func (c *GenericK8sClient) useRuntimeCluster() cluster.Cluster {
+	c.runtime.lastUsed.Store(time.Now().UnixNano())
+	c.runtime.lock.Lock()
+	defer c.runtime.lock.Unlock()
+	if !c.runtime.evicted {
+		return c.runtimeCluster
+	}
+	rebuilt, err := c.runtime.build()
+	if err != nil {
+		// 重建失败时返回已停止的cluster, 下次使用时重试
+		c.group.report("cluster", err)
+		return c.runtimeCluster
+	}
+	c.runtimeCluster = rebuilt
+	c.runtime.evicted = false
+	select {
+	case c.runtime.wake <- struct{}{}:
+	default:
+	}
+	return rebuilt
+}
+
+// evictRuntimeCluster 停止正在运行的runtime cluster, 返回是否执行了回收
+func (c *GenericK8sClient) evictRuntimeCluster() bool {
+	c.runtime.lock.Lock()
+	defer c.runtime.lock.Unlock()
+	if !c.runtime.started || c.runtime.evicted || c.runtime.cancel == nil {
+		return false
+	}
+	c.runtime.evicted = true
+	c.runtime.generation++
+	c.runtime.cancel()
+	return true
+}
+
+// runRuntimeCluster 运行runtime cluster直至ctx结束; cluster被回收后等待重建, 再运行新的cluster
+func (c *GenericK8sClient) runRuntimeCluster(ctx context.Context) error {
+	for {
+		c.runtime.lock.Lock()
+		if c.runtime.evicted {
+			c.runtime.lock.Unlock()
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-c.runtime.wake:
+			}
+			continue
+		}
+		current := c.runtimeCluster
+		generation := c.runtime.generation
+		runCtx, cancel := context.WithCancel(ctx)
+		c.runtime.cancel = cancel
+		c.runtime.lock.Unlock()
+
+		err := current.Start(runCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return err
+		}
+		c.runtime.lock.Lock()
+		evicted := c.runtime.generation != generation
+		c.runtime.lock.Unlock()
+		if !evicted {
+			return err
+		}
+	}
+}
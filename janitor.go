@@ -0,0 +1,187 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const (
+	// TemporaryLabel 标记由工具包(或调用方)创建的临时对象, 值为 `true`
+	TemporaryLabel = "k8s-client-kit.io/temporary"
+	// ExpiresAtAnnotation 记录临时对象的过期时间(RFC3339), 过期后由 CleanupExpiredTemporary 或 Janitor 删除
+	ExpiresAtAnnotation = "k8s-client-kit.io/expires-at"
+	// DefaultJanitorInterval 为 Janitor 清理的默认间隔
+	DefaultJanitorInterval = 10 * time.Minute
+	// janitorClusterTimeout 为 Janitor 清理单个集群的超时
+	janitorClusterTimeout = 2 * time.Minute
+)
+
+// DefaultTemporaryResources 为默认检查的临时对象资源类型: 一次性的Pod, Job, 以及临时的ConfigMap, Secret与CSR
+var DefaultTemporaryResources = []schema.GroupVersionResource{
+	{Version: "v1", Resource: "pods"},
+	{Version: "v1", Resource: "configmaps"},
+	{Version: "v1", Resource: "secrets"},
+	{Group: "batch", Version: "v1", Resource: "jobs"},
+	{Group: "certificates.k8s.io", Version: "v1", Resource: "certificatesigningrequests"},
+}
+
+// MarkTemporary 将obj标记为临时对象, 创建后ttl时间过期. 进程异常退出而未能清理的临时对象由 Janitor 在过期后删除
+func MarkTemporary(obj metav1.Object, ttl time.Duration) {
+	objLabels := obj.GetLabels()
+	if objLabels == nil {
+		objLabels = map[string]string{}
+	}
+	objLabels[TemporaryLabel] = "true"
+	objLabels[ManagedByLabel] = ManagedByValue
+	obj.SetLabels(objLabels)
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ExpiresAtAnnotation] = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+	obj.SetAnnotations(annotations)
+}
+
+// TemporaryExpiresAt 返回临时对象的过期时间, 对象未标记或过期时间无效时返回false
+func TemporaryExpiresAt(obj metav1.Object) (time.Time, bool) {
+	if obj.GetLabels()[TemporaryLabel] != "true" {
+		return time.Time{}, false
+	}
+	expiresAt, err := time.Parse(time.RFC3339, obj.GetAnnotations()[ExpiresAtAnnotation])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expiresAt, true
+}
+
+// CleanupResult 为一次清理的结果, 列表元素为ObjectIdentity
+type CleanupResult struct {
+	Deleted []string `json:"deleted"`
+	// Pending 为尚未过期的临时对象数
+	Pending int `json:"pending"`
+}
+
+// CleanupExpiredTemporary 删除集群中resources类型(为空时使用 DefaultTemporaryResources)已过期的临时对象.
+// 仅处理同时带有 ManagedByLabel 的对象(见 MarkTemporary), 过期时间缺失或无效的对象不会被删除. 集群不提供的资源类型被忽略. 部分删除失败时返回已删除的对象与汇总错误.
+func (c *GenericK8sClient) CleanupExpiredTemporary(ctx context.Context, resources []schema.GroupVersionResource) (*CleanupResult, error) {
+	if len(resources) == 0 {
+		resources = DefaultTemporaryResources
+	}
+	ctx, _ = EnsureRequestID(ctx)
+	// 仅清理 MarkTemporary 标记的对象, 不删除其他工具恰好使用了同名标签的对象
+	selector := labels.SelectorFromSet(labels.Set{TemporaryLabel: "true", ManagedByLabel: ManagedByValue}).String()
+	background := metav1.DeletePropagationBackground
+	now := time.Now()
+
+	result := &CleanupResult{}
+	var errs []error
+	for _, gvr := range resources {
+		list, err := c.GetDynamicClient().Resource(gvr).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, c.clusterError(errors.Wrap(err, "无法列出临时对象 "+gvr.String()), schema.GroupVersionKind{}))
+			continue
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			expiresAt, ok := TemporaryExpiresAt(obj)
+			if !ok || obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+			if now.Before(expiresAt) {
+				result.Pending++
+				continue
+			}
+			if err := c.deleteTemporary(ctx, gvr, obj, background); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			result.Deleted = append(result.Deleted, ObjectIdentity(obj))
+		}
+	}
+	sort.Strings(result.Deleted)
+	return result, utilerrors.NewAggregate(errs)
+}
+
+func (c *GenericK8sClient) deleteTemporary(ctx context.Context, gvr schema.GroupVersionResource, obj *unstructured.Unstructured, propagation metav1.DeletionPropagation) error {
+	uid := obj.GetUID()
+	err := namespacedResource(c.GetDynamicClient(), gvr, obj.GetNamespace()).Delete(ctx, obj.GetName(), metav1.DeleteOptions{
+		Preconditions:     &metav1.Preconditions{UID: &uid},
+		PropagationPolicy: &propagation,
+	})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return c.clusterError(errors.Wrap(err, "无法删除临时对象 "+ObjectIdentity(obj)), obj.GroupVersionKind())
+	}
+	if err == nil {
+		c.recordOperationEvent(ctx, obj, EventReasonDeleted, "临时对象已过期, 由janitor删除")
+	}
+	return nil
+}
+
+// JanitorOptions 为 NewJanitor 的参数
+type JanitorOptions struct {
+	// Interval 为清理间隔, 默认为 DefaultJanitorInterval
+	Interval time.Duration
+	// Resources 为检查的资源类型, 默认为 DefaultTemporaryResources
+	Resources []schema.GroupVersionResource
+	// OnCleanup 在每个集群清理后调用, err为该集群的清理错误. 多个集群并行清理, 可能被并发调用
+	OnCleanup func(clusterId string, result *CleanupResult, err error)
+}
+
+// Janitor 定期清理registry中所有集群已过期的临时对象(见 MarkTemporary), 用于回收进程崩溃后遗留的临时资源
+type Janitor struct {
+	registry *ClusterRegistry
+	opts     JanitorOptions
+}
+
+// NewJanitor 创建清理registry中所有集群的Janitor, 调用 Run 后开始清理
+func NewJanitor(registry *ClusterRegistry, opts JanitorOptions) *Janitor {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultJanitorInterval
+	}
+	return &Janitor{registry: registry, opts: opts}
+}
+
+// Run 立即清理一次, 之后每隔Interval清理, 阻塞直至ctx结束
+func (j *Janitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(j.opts.Interval)
+	defer ticker.Stop()
+	for {
+		j.CleanupOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// CleanupOnce 以有界并发清理registry中的每个集群(见 FleetExec), 返回以集群标识为key的结果
+func (j *Janitor) CleanupOnce(ctx context.Context) map[string]*CleanupResult {
+	results := map[string]*CleanupResult{}
+	lock := sync.Mutex{}
+	_, _ = j.registry.FleetExec(ctx, nil, func(ctx context.Context, cli *GenericK8sClient) error {
+		result, err := cli.CleanupExpiredTemporary(ctx, j.opts.Resources)
+		lock.Lock()
+		results[cli.TargetK8sApiServerId] = result
+		lock.Unlock()
+		if j.opts.OnCleanup != nil {
+			j.opts.OnCleanup(cli.TargetK8sApiServerId, result, err)
+		}
+		return err
+	}, FleetExecOptions{Timeout: janitorClusterTimeout})
+	return results
+}
@@ -0,0 +1,237 @@
+package k8sclientkit
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultJournalMaxEntries 为 NewJournal 未指定容量时保留的最大变更记录数
+const DefaultJournalMaxEntries = 100000
+
+// JournalEventType 为变更记录的类型
+type JournalEventType string
+
+const (
+	JournalAdded   JournalEventType = "Added"
+	JournalUpdated JournalEventType = "Updated"
+	JournalDeleted JournalEventType = "Deleted"
+)
+
+// JournalEntry 为一条对象变更记录
+type JournalEntry struct {
+	Time      time.Time                   `json:"time"`
+	Type      JournalEventType            `json:"type"`
+	Gvr       schema.GroupVersionResource `json:"gvr"`
+	Namespace string                      `json:"namespace,omitempty"`
+	Name      string                      `json:"name"`
+	// Object 为变更后的对象内容, Deleted 时为删除前的最后状态
+	Object *unstructured.Unstructured `json:"object"`
+}
+
+// JournalExport 为 Journal.Export 导出的内容, 可通过 LoadJournal 恢复后继续查询
+type JournalExport struct {
+	// BaselineTime 为最早可重建的时间点, Baseline 为该时间点的对象状态(以Added记录表示)
+	BaselineTime time.Time      `json:"baselineTime"`
+	Baseline     []JournalEntry `json:"baseline,omitempty"`
+	Entries      []JournalEntry `json:"entries"`
+}
+
+// journalKey 唯一标识一个对象
+type journalKey struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+}
+
+// Journal 记录watcher观察到的对象变更, 可按时间点重建对象集合, 用于事后分析如 "故障发生前该namespace是什么状态".
+// 超出容量时最旧的记录被合并进基线状态, 因此最早可重建的时间点随之后移.
+type Journal struct {
+	maxEntries int
+
+	lock    sync.RWMutex
+	entries []JournalEntry
+	// head 为entries中第一条有效记录的下标, 已合并进基线的记录在head之前, 累计达到maxEntries条时才整体搬移,
+	// 避免容量满后每条事件都复制整个切片
+	head int
+	// baseline 为entries之前(含已合并记录)的对象状态, baselineTime 为最早可重建的时间点
+	baseline     map[journalKey]*unstructured.Unstructured
+	baselineTime time.Time
+}
+
+// NewJournal 创建变更日志, maxEntries 小于等于0时使用 DefaultJournalMaxEntries
+func NewJournal(maxEntries int) *Journal {
+	if maxEntries <= 0 {
+		maxEntries = DefaultJournalMaxEntries
+	}
+	return &Journal{
+		maxEntries:   maxEntries,
+		baseline:     map[journalKey]*unstructured.Unstructured{},
+		baselineTime: time.Now(),
+	}
+}
+
+// Record 记录watcher的全部事件, 应在watcher.Start 之前调用.
+// watcher启动时已存在的对象记录为该时刻的Added事件, 因此只能重建Record之后的时间点.
+func (j *Journal) Record(watcher *K8sResourceWatcher) {
+	gvr := watcher.Gvr
+	watcher.AddEventHandler(func(obj interface{}) {
+		j.append(gvr, JournalAdded, obj)
+	}, func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		j.append(gvr, JournalDeleted, obj)
+	}, func(oldObj, newObj interface{}) {
+		j.append(gvr, JournalUpdated, newObj)
+	})
+}
+
+func (j *Journal) append(gvr schema.GroupVersionResource, eventType JournalEventType, obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	j.appendEntry(JournalEntry{
+		Time:      time.Now(),
+		Type:      eventType,
+		Gvr:       gvr,
+		Namespace: u.GetNamespace(),
+		Name:      u.GetName(),
+		Object:    u.DeepCopy(),
+	})
+}
+
+func (j *Journal) appendEntry(entry JournalEntry) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.entries = append(j.entries, entry)
+	if len(j.entries)-j.head > j.maxEntries {
+		entry := j.entries[j.head]
+		applyJournalEntry(j.baseline, entry)
+		j.baselineTime = entry.Time
+		// 释放已合并记录持有的对象
+		j.entries[j.head] = JournalEntry{}
+		j.head++
+	}
+	if j.head >= j.maxEntries {
+		j.entries = append(make([]JournalEntry, 0, 2*j.maxEntries), j.entries[j.head:]...)
+		j.head = 0
+	}
+}
+
+// Entries 返回时间区间 [since, until) 内的变更记录, until 为零值表示不限制
+func (j *Journal) Entries(since, until time.Time) []JournalEntry {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	var result []JournalEntry
+	for _, entry := range j.entries[j.head:] {
+		if entry.Time.Before(since) || (!until.IsZero() && !entry.Time.Before(until)) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// ListAt 重建时间点t时gvr类型的对象集合及其内容, namespace为空表示所有namespace. 结果按namespace/name排序.
+// t早于最早可重建的时间点(Journal创建时间或容量淘汰后的基线时间)时返回错误.
+func (j *Journal) ListAt(gvr schema.GroupVersionResource, namespace string, t time.Time) ([]*unstructured.Unstructured, error) {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	if t.Before(j.baselineTime) {
+		return nil, errors.New("日志最早只能重建到 " + j.baselineTime.Format(time.RFC3339))
+	}
+
+	state := map[journalKey]*unstructured.Unstructured{}
+	for key, obj := range j.baseline {
+		state[key] = obj
+	}
+	for _, entry := range j.entries[j.head:] {
+		if entry.Time.After(t) {
+			break
+		}
+		applyJournalEntry(state, entry)
+	}
+
+	var result []*unstructured.Unstructured
+	for key, obj := range state {
+		if key.gvr != gvr || (len(namespace) > 0 && key.namespace != namespace) {
+			continue
+		}
+		result = append(result, obj.DeepCopy())
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return ObjectIdentity(result[a]) < ObjectIdentity(result[b])
+	})
+	return result, nil
+}
+
+func applyJournalEntry(state map[journalKey]*unstructured.Unstructured, entry JournalEntry) {
+	key := journalKey{gvr: entry.Gvr, namespace: entry.Namespace, name: entry.Name}
+	if entry.Type == JournalDeleted {
+		delete(state, key)
+		return
+	}
+	state[key] = entry.Object
+}
+
+// Export 使用enc导出基线与全部变更记录, 用于持久化事后分析的数据
+func (j *Journal) Export(w io.Writer, enc Encoder) error {
+	return enc.Encode(w, j.export())
+}
+
+// Save 将 Export 的结果写入文件, 序列化格式根据文件扩展名选择(参见 EncoderForPath)
+func (j *Journal) Save(path string) error {
+	return encodeToFile(path, EncoderForPath(path), j.export())
+}
+
+func (j *Journal) export() *JournalExport {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	export := &JournalExport{
+		BaselineTime: j.baselineTime,
+		Entries:      append([]JournalEntry{}, j.entries[j.head:]...),
+	}
+	for key, obj := range j.baseline {
+		export.Baseline = append(export.Baseline, JournalEntry{
+			Time:      j.baselineTime,
+			Type:      JournalAdded,
+			Gvr:       key.gvr,
+			Namespace: key.namespace,
+			Name:      key.name,
+			Object:    obj,
+		})
+	}
+	sort.Slice(export.Baseline, func(a, b int) bool {
+		return ObjectIdentity(export.Baseline[a].Object) < ObjectIdentity(export.Baseline[b].Object)
+	})
+	return export
+}
+
+// LoadJournal 读取 Journal.Save 保存的文件, 返回可继续查询与记录的变更日志. maxEntries 含义同 NewJournal,
+// 文件中的记录超出容量时最旧的记录被合并进基线.
+func LoadJournal(path string, maxEntries int) (*Journal, error) {
+	export := &JournalExport{}
+	if err := decodeFromFile(path, EncoderForPath(path), export); err != nil {
+		return nil, err
+	}
+	j := NewJournal(maxEntries)
+	j.baselineTime = export.BaselineTime
+	for _, entry := range export.Baseline {
+		applyJournalEntry(j.baseline, entry)
+	}
+	for _, entry := range export.Entries {
+		if entry.Object == nil {
+			continue
+		}
+		j.appendEntry(entry)
+	}
+	return j, nil
+}
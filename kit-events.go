@@ -0,0 +1,105 @@
+package k8sclientkit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+)
+
+// 工具包自身操作产生的Event原因
+const (
+	EventReasonApplied = "AppliedByKit"
+	EventReasonDeleted = "DeletedByKit"
+)
+
+// DefaultEventComponent 为Event的默认来源组件名
+const DefaultEventComponent = "k8s-client-kit"
+
+type bundleIDKey struct{}
+
+// ContextWithBundleID 在ctx中记录当前操作所属的bundle标识, 该标识会出现在工具包产生的Event消息中
+func ContextWithBundleID(ctx context.Context, bundleID string) context.Context {
+	return context.WithValue(ctx, bundleIDKey{}, bundleID)
+}
+
+// BundleIDFromContext 读取ctx中记录的bundle标识
+func BundleIDFromContext(ctx context.Context) (string, bool) {
+	bundleID, ok := ctx.Value(bundleIDKey{}).(string)
+	return bundleID, ok && len(bundleID) > 0
+}
+
+// WithOperationEvents 使apply/delete操作成功后在目标对象上产生Event(Reason为AppliedByKit/DeletedByKit),
+// 便于通过 `kubectl describe` 看到自动化操作记录. component 为Event来源组件名, 为空时使用 DefaultEventComponent.
+func WithOperationEvents(component string) Option {
+	return func(o *clientOptions) {
+		if len(component) == 0 {
+			component = DefaultEventComponent
+		}
+		o.eventComponent = component
+	}
+}
+
+// GetEventRecorder 返回以name为来源组件的EventRecorder
+func (c *GenericK8sClient) GetEventRecorder(name string) record.EventRecorder {
+	return c.GetRuntimeCluster().GetEventRecorderFor(name)
+}
+
+// recordOperationEvent 将操作事件发送给EventSink插件, 并在启用了WithOperationEvents时为obj产生一条Normal类型的Event
+func (c *GenericK8sClient) recordOperationEvent(ctx context.Context, obj *unstructured.Unstructured, reason, message string) {
+	bundleID, _ := BundleIDFromContext(ctx)
+	c.dispatchKitEvent(ctx, KitEvent{
+		ClusterId: c.TargetK8sApiServerId,
+		Reason:    reason,
+		Message:   message,
+		BundleID:  bundleID,
+		RequestID: RequestIDFromContext(ctx),
+		Object:    obj,
+		Time:      time.Now(),
+	})
+
+	component := newClientOptions(c.options).eventComponent
+	if len(component) == 0 {
+		return
+	}
+	if len(bundleID) > 0 {
+		message += ", bundle: " + bundleID
+	}
+	if requestID := RequestIDFromContext(ctx); len(requestID) > 0 {
+		message += ", request: " + requestID
+	}
+	c.GetEventRecorder(component).Event(obj, corev1.EventTypeNormal, reason, message)
+}
+
+// NewEncoderEventSink 创建将每个 KitEvent 以enc序列化写入w的EventSink插件, 用于将操作记录持久化到文件或日志管道.
+// 每个事件单独调用一次 enc.Encode, YAML格式的事件之间以 `---` 分隔. 写入失败的事件被丢弃, 不影响操作本身.
+func NewEncoderEventSink(name string, w io.Writer, enc Encoder) EventSink {
+	return &encoderEventSink{name: name, w: w, enc: enc}
+}
+
+type encoderEventSink struct {
+	name string
+	enc  Encoder
+
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (s *encoderEventSink) Name() string {
+	return s.name
+}
+
+func (s *encoderEventSink) HandleEvent(ctx context.Context, event KitEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.enc.Name() == EncoderYAML.Name() {
+		if _, err := io.WriteString(s.w, "---\n"); err != nil {
+			return
+		}
+	}
+	_ = s.enc.Encode(s.w, event)
+}
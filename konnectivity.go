@@ -0,0 +1,165 @@
+package k8sclientkit
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Konnectivity(apiserver-network-proxy) 的传输模式
+const (
+	// KonnectivityModeHTTPConnect 使用HTTP CONNECT请求建立隧道, 对应konnectivity server的 `--mode=http-connect`
+	KonnectivityModeHTTPConnect = "http-connect"
+	// KonnectivityModeGRPC 使用gRPC隧道, 对应konnectivity server的 `--mode=grpc`, 需要通过 KonnectivityOptions.GRPCTunnel 提供隧道实现
+	KonnectivityModeGRPC = "grpc"
+)
+
+// defaultKonnectivityDialTimeout 为连接konnectivity server的默认超时时间
+const defaultKonnectivityDialTimeout = 15 * time.Second
+
+// KonnectivityOptions 为经由konnectivity server访问APIServer的参数, UDSPath 与 Address 二选一
+type KonnectivityOptions struct {
+	// Mode 为 KonnectivityModeHTTPConnect(默认) 或 KonnectivityModeGRPC
+	Mode string
+	// UDSPath 为konnectivity server的unix domain socket路径, 用于与server部署在同一节点的场景
+	UDSPath string
+	// Address 为konnectivity server的 `host:port`, 通过mTLS连接
+	Address string
+	// TLSConfig 为连接Address时使用的TLS配置, 应包含客户端证书与server的CA, 使用UDSPath时忽略
+	TLSConfig *tls.Config
+	// GRPCTunnel 在grpc模式下为每个连接创建一次性隧道, 可直接使用konnectivity-client:
+	//
+	//	func(ctx context.Context) (Dialer, error) {
+	//		return client.CreateSingleUseGrpcTunnel(ctx, address, grpc.WithTransportCredentials(creds))
+	//	}
+	GRPCTunnel func(ctx context.Context) (Dialer, error)
+	// DialTimeout 为连接konnectivity server的超时时间, 默认15秒
+	DialTimeout time.Duration
+}
+
+// WithKonnectivity 使到APIServer的所有连接经由konnectivity server(apiserver-network-proxy)建立,
+// 用于APIServer没有入站路由, 仅能通过集群内agent反向连接的边缘集群. 不能与 WithDialer 或 WithSSHTunnel 同时使用.
+func WithKonnectivity(opts KonnectivityOptions) Option {
+	return func(o *clientOptions) {
+		o.konnectivity = &opts
+	}
+}
+
+// newKonnectivityDialer 校验参数并创建拨号器
+func newKonnectivityDialer(opts KonnectivityOptions) (*konnectivityDialer, error) {
+	if len(opts.Mode) == 0 {
+		opts.Mode = KonnectivityModeHTTPConnect
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = defaultKonnectivityDialTimeout
+	}
+	switch opts.Mode {
+	case KonnectivityModeHTTPConnect:
+		if (len(opts.UDSPath) == 0) == (len(opts.Address) == 0) {
+			return nil, errors.New("konnectivity UDSPath 与 Address 必须且只能指定一个")
+		}
+		if len(opts.Address) > 0 && opts.TLSConfig == nil {
+			return nil, errors.New("通过Address连接konnectivity server时必须指定TLSConfig")
+		}
+	case KonnectivityModeGRPC:
+		if opts.GRPCTunnel == nil {
+			return nil, errors.New("konnectivity grpc模式必须指定GRPCTunnel")
+		}
+	default:
+		return nil, errors.New("不支持的konnectivity模式: " + opts.Mode)
+	}
+	return &konnectivityDialer{opts: opts}, nil
+}
+
+// konnectivityDialer 经由konnectivity server建立到APIServer的连接
+type konnectivityDialer struct {
+	opts KonnectivityOptions
+}
+
+// DialContext 经由konnectivity server建立到addr的连接, 可直接用作rest.Config.Dial
+func (d *konnectivityDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.opts.Mode == KonnectivityModeGRPC {
+		tunnel, err := d.opts.GRPCTunnel(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法创建konnectivity gRPC隧道")
+		}
+		conn, err := tunnel.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法通过konnectivity gRPC隧道连接 "+addr)
+		}
+		return conn, nil
+	}
+
+	conn, err := d.dialServer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(d.opts.DialTimeout))
+	}
+	request := "CONNECT " + addr + " HTTP/1.1\r\nHost: " + addr + "\r\nUser-Agent: k8s-client-kit\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "无法向konnectivity server发送CONNECT请求")
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "无法读取konnectivity server的CONNECT响应")
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, errors.Errorf("konnectivity server拒绝连接 %s: %s", addr, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// dialServer 连接konnectivity server: UDS或mTLS
+func (d *konnectivityDialer) dialServer(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: d.opts.DialTimeout}
+	if len(d.opts.UDSPath) > 0 {
+		conn, err := dialer.DialContext(ctx, "unix", d.opts.UDSPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法连接konnectivity server:"+d.opts.UDSPath)
+		}
+		return conn, nil
+	}
+	tlsDialer := &tls.Dialer{NetDialer: dialer, Config: d.opts.TLSConfig}
+	conn, err := tlsDialer.DialContext(ctx, "tcp", d.opts.Address)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法连接konnectivity server:"+d.opts.Address)
+	}
+	return conn, nil
+}
+
+// CheckHealth 确认konnectivity server可连接(mTLS时包括握手), grpc模式下由隧道实现负责, 不做检查
+func (d *konnectivityDialer) CheckHealth(ctx context.Context) error {
+	if d.opts.Mode == KonnectivityModeGRPC {
+		return nil
+	}
+	conn, err := d.dialServer(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// bufferedConn 在读取CONNECT响应后继续从缓冲区读取已到达的数据
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
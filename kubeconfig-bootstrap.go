@@ -0,0 +1,77 @@
+package k8sclientkit
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ManagedByLabel 标记由k8s-client-kit创建的资源
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedByValue = "k8s-client-kit"
+)
+
+// GenerateKubeConfig 在namespace中创建(或更新)名为saName的ServiceAccount, 以及授予rules权限的同名Role与RoleBinding,
+// 然后通过TokenRequest API签发有效期为ttl的token, 返回可直接使用的kubeconfig.
+// 重复调用是幂等的: 已存在的Role会被更新为新的rules.
+func (c *GenericK8sClient) GenerateKubeConfig(ctx context.Context, namespace, saName string, rules []rbacv1.PolicyRule, ttl time.Duration) ([]byte, error) {
+	if err := c.ensureServiceAccountWithRole(ctx, namespace, saName, rules); err != nil {
+		return nil, err
+	}
+	kubeConfig, _, err := c.ServiceAccountKubeConfig(ctx, namespace, saName, ttl)
+	return kubeConfig, err
+}
+
+// GenerateServiceAccountClient 同 GenerateKubeConfig 创建ServiceAccount及其权限, 返回以该ServiceAccount身份访问集群的客户端,
+// token在过期前自动续签(参见 AsServiceAccount).
+func (c *GenericK8sClient) GenerateServiceAccountClient(ctx context.Context, namespace, saName string, rules []rbacv1.PolicyRule, ttl time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	if err := c.ensureServiceAccountWithRole(ctx, namespace, saName, rules); err != nil {
+		return nil, err
+	}
+	return c.AsServiceAccount(ctx, namespace, saName, ttl, opts...)
+}
+
+func (c *GenericK8sClient) ensureServiceAccountWithRole(ctx context.Context, namespace, saName string, rules []rbacv1.PolicyRule) error {
+	meta := metav1.ObjectMeta{
+		Name:      saName,
+		Namespace: namespace,
+		Labels:    map[string]string{ManagedByLabel: ManagedByValue},
+	}
+	sc := c.GetStandardClient()
+
+	_, err := sc.CoreV1().ServiceAccounts(namespace).Create(ctx, &corev1.ServiceAccount{ObjectMeta: meta}, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "无法创建ServiceAccount "+namespace+"/"+saName)
+	}
+
+	role := &rbacv1.Role{ObjectMeta: meta, Rules: rules}
+	_, err = sc.RbacV1().Roles(namespace).Create(ctx, role, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		existing, getErr := sc.RbacV1().Roles(namespace).Get(ctx, saName, metav1.GetOptions{})
+		if getErr != nil {
+			return errors.Wrap(getErr, "无法读取Role "+namespace+"/"+saName)
+		}
+		existing.Rules = rules
+		_, err = sc.RbacV1().Roles(namespace).Update(ctx, existing, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return errors.Wrap(err, "无法创建或更新Role "+namespace+"/"+saName)
+	}
+
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: meta,
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: saName},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: saName, Namespace: namespace}},
+	}
+	_, err = sc.RbacV1().RoleBindings(namespace).Create(ctx, binding, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "无法创建RoleBinding "+namespace+"/"+saName)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package k8sclientkit
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// KubeConfigSource 为待合并的一个kubeconfig
+type KubeConfigSource struct {
+	// Name 标识来源, 名称冲突时作为前缀(`<Name>-<原名称>`), 为空时使用 `kubeconfig<序号>`
+	Name string
+	Data []byte
+}
+
+// KubeConfigMergeOptions 为 MergeKubeConfigs 的参数
+type KubeConfigMergeOptions struct {
+	// PrefixAll 为true时所有context都以来源名称为前缀, 否则仅在名称冲突时添加前缀
+	PrefixAll bool
+}
+
+// KubeConfigOrigin 记录合并后的context来自哪个来源的哪个context
+type KubeConfigOrigin struct {
+	Source  string
+	Context string
+}
+
+// MergedKubeConfig 为 MergeKubeConfigs 的结果
+type MergedKubeConfig struct {
+	Config *clientcmdapi.Config
+	// Origins 以合并后的context名称为key
+	Origins map[string]KubeConfigOrigin
+}
+
+// MergeKubeConfigs 合并多个kubeconfig. 内容相同的cluster与user只保留一份(即使名称不同),
+// 名称相同但内容不同的cluster, user与context以来源名称为前缀重命名, context中的引用随之更新.
+// current-context取第一个设置了current-context的来源. 证书等文件路径保持原样, 不会读取或转换为相对于其他位置的路径.
+func MergeKubeConfigs(sources []KubeConfigSource, opts KubeConfigMergeOptions) (*MergedKubeConfig, error) {
+	merged := &MergedKubeConfig{Config: clientcmdapi.NewConfig(), Origins: map[string]KubeConfigOrigin{}}
+	for i, source := range sources {
+		if len(source.Name) == 0 {
+			source.Name = "kubeconfig" + strconv.Itoa(i+1)
+		}
+		config, err := clientcmd.Load(source.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法解析kubeconfig "+source.Name)
+		}
+		merged.add(source.Name, config, opts)
+	}
+	return merged, nil
+}
+
+func (m *MergedKubeConfig) add(source string, config *clientcmdapi.Config, opts KubeConfigMergeOptions) {
+	clusterNames := map[string]string{}
+	for _, name := range sortedKeys(config.Clusters) {
+		cluster := *config.Clusters[name]
+		cluster.LocationOfOrigin = ""
+		clusterNames[name] = mergeNamed(m.Config.Clusters, name, source, &cluster, false, true)
+	}
+	userNames := map[string]string{}
+	for _, name := range sortedKeys(config.AuthInfos) {
+		user := *config.AuthInfos[name]
+		user.LocationOfOrigin = ""
+		userNames[name] = mergeNamed(m.Config.AuthInfos, name, source, &user, false, true)
+	}
+	contextNames := map[string]string{}
+	for _, name := range sortedKeys(config.Contexts) {
+		kubeContext := *config.Contexts[name]
+		kubeContext.LocationOfOrigin = ""
+		if renamed, ok := clusterNames[kubeContext.Cluster]; ok {
+			kubeContext.Cluster = renamed
+		}
+		if renamed, ok := userNames[kubeContext.AuthInfo]; ok {
+			kubeContext.AuthInfo = renamed
+		}
+		mergedName := mergeNamed(m.Config.Contexts, name, source, &kubeContext, opts.PrefixAll, false)
+		contextNames[name] = mergedName
+		if _, exists := m.Origins[mergedName]; !exists {
+			m.Origins[mergedName] = KubeConfigOrigin{Source: source, Context: name}
+		}
+	}
+	if len(m.Config.CurrentContext) == 0 && len(config.CurrentContext) > 0 {
+		m.Config.CurrentContext = contextNames[config.CurrentContext]
+	}
+}
+
+// mergeNamed 将value以name加入entries, 返回实际使用的名称: 同名条目内容相同时复用, dedupe 为true时
+// 复用任意名称下内容相同的条目. 名称被占用时添加前缀, 仍冲突时追加序号
+func mergeNamed[T any](entries map[string]*T, name, prefix string, value *T, forcePrefix, dedupe bool) string {
+	if existing, ok := entries[name]; ok && !forcePrefix && reflect.DeepEqual(existing, value) {
+		return name
+	}
+	if dedupe {
+		for _, existingName := range sortedKeys(entries) {
+			if reflect.DeepEqual(entries[existingName], value) {
+				return existingName
+			}
+		}
+	}
+	candidate := name
+	if _, taken := entries[candidate]; taken || forcePrefix {
+		candidate = prefix + "-" + name
+	}
+	for i := 2; ; i++ {
+		existing, taken := entries[candidate]
+		if !taken {
+			break
+		}
+		if reflect.DeepEqual(existing, value) {
+			return candidate
+		}
+		candidate = prefix + "-" + name + "-" + strconv.Itoa(i)
+	}
+	entries[candidate] = value
+	return candidate
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Bytes 将合并后的kubeconfig序列化为YAML
+func (m *MergedKubeConfig) Bytes() ([]byte, error) {
+	data, err := clientcmd.Write(*m.Config)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法序列化合并后的kubeconfig")
+	}
+	return data, nil
+}
+
+// NewClients 为合并后的每个context创建客户端, 以context名称为key(同时作为client id), 参见 NewGenericK8sClientsFromKubeConfigContext
+func (m *MergedKubeConfig) NewClients(ctx context.Context, opts ...Option) (map[string]*GenericK8sClient, error) {
+	data, err := m.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return NewGenericK8sClientsFromKubeConfigContext(ctx, data, opts...)
+}
@@ -0,0 +1,88 @@
+package k8sclientkit
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultKubeConfigSecretKey 为kubeconfig在Secret中的默认key, 与Cluster API生成的 `<cluster>-kubeconfig` Secret一致
+const DefaultKubeConfigSecretKey = "value"
+
+// NewGenericK8sClientWithKubeConfigSecretContext 使用管理集群中Secret保存的kubeconfig创建客户端.
+// 客户端在后台watch该Secret, kubeconfig变化时通过 RotateCredentials 原地替换凭证, 已创建的客户端与watcher保持可用.
+// 替换失败(如kubeconfig无效或指向其他APIServer)或Secret被删除时保留原凭证, 错误通过 Errors 报告.
+//
+//	param: management *GenericK8sClient 可访问Secret的管理集群客户端, 其生命周期需覆盖本客户端
+//	param: key string kubeconfig在Secret data中的key, 为空时使用 DefaultKubeConfigSecretKey
+func NewGenericK8sClientWithKubeConfigSecretContext(ctx context.Context, id string, management *GenericK8sClient, namespace, name, key, overrideSNIServerName string, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	if management == nil {
+		return nil, errors.New("管理集群客户端不能为空")
+	}
+	if len(key) == 0 {
+		key = DefaultKubeConfigSecretKey
+	}
+	secret, err := management.GetStandardClient().CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法读取kubeconfig Secret "+namespace+"/"+name)
+	}
+	kubeConfig, ok := secret.Data[key]
+	if !ok {
+		return nil, errors.New("Secret " + namespace + "/" + name + " 中不存在key " + key)
+	}
+
+	cli, err := NewGenericK8sClientWithKubeConfigBytesContext(ctx, id, kubeConfig, overrideSNIServerName, timeout, opts...)
+	if err != nil {
+		return nil, err
+	}
+	cli.group.goRoutine("kubeconfig-secret-watch", func(ctx context.Context) error {
+		cli.watchKubeConfigSecret(ctx, management, namespace, name, key, kubeConfig)
+		return nil
+	})
+	return cli, nil
+}
+
+// watchKubeConfigSecret watch管理集群中的kubeconfig Secret, 内容变化时替换客户端凭证. 随ctx结束退出.
+func (c *GenericK8sClient) watchKubeConfigSecret(ctx context.Context, management *GenericK8sClient, namespace, name, key string, applied []byte) {
+	const routine = "kubeconfig-secret-watch"
+	lw := cache.NewListWatchFromClient(management.GetStandardClient().CoreV1().RESTClient(), "secrets", namespace,
+		fields.OneTermEqualSelector("metadata.name", name))
+	informer := cache.NewSharedIndexInformer(lw, &corev1.Secret{}, 0, cache.Indexers{})
+
+	// 事件处理函数由informer串行调用, applied无需加锁
+	reload := func(obj interface{}) {
+		secret, ok := obj.(*corev1.Secret)
+		if !ok {
+			return
+		}
+		kubeConfig, ok := secret.Data[key]
+		if !ok {
+			c.group.report(routine, errors.New("Secret "+namespace+"/"+name+" 中不存在key "+key+", 保留当前凭证"))
+			return
+		}
+		if bytes.Equal(kubeConfig, applied) {
+			return
+		}
+		if err := c.RotateCredentials(kubeConfig); err != nil {
+			c.group.report(routine, errors.Wrap(err, "使用Secret "+namespace+"/"+name+" 更新凭证失败"))
+			return
+		}
+		applied = kubeConfig
+	}
+	_, _ = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: reload,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			reload(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			c.group.report(routine, errors.New("Secret "+namespace+"/"+name+" 已被删除, 保留当前凭证"))
+		},
+	})
+	informer.Run(ctx.Done())
+}
@@ -0,0 +1,346 @@
+package k8sclientkit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// DefaultNodeStatsConcurrency 为 ListNodeUsage 同时请求的最大节点数, 请求经由APIServer代理到各节点的kubelet
+const DefaultNodeStatsConcurrency = 8
+
+// nodeGVK 为kubelet接口相关错误中的资源类型
+var nodeGVK = corev1.SchemeGroupVersion.WithKind("Node")
+
+// 以下为kubelet /stats/summary 响应(k8s.io/kubelet/pkg/apis/stats/v1alpha1)中节点工具常用的字段, 未引入kubelet模块以减少依赖
+
+// NodeStatsSummary 为kubelet /stats/summary 的响应
+type NodeStatsSummary struct {
+	Node NodeStats  `json:"node"`
+	Pods []PodStats `json:"pods"`
+}
+
+// NodeStats 为节点级别的资源使用
+type NodeStats struct {
+	NodeName  string        `json:"nodeName"`
+	StartTime metav1.Time   `json:"startTime"`
+	CPU       *CPUStats     `json:"cpu,omitempty"`
+	Memory    *MemoryStats  `json:"memory,omitempty"`
+	Fs        *FsStats      `json:"fs,omitempty"`
+	Runtime   *RuntimeStats `json:"runtime,omitempty"`
+	Rlimit    *RlimitStats  `json:"rlimit,omitempty"`
+}
+
+// RuntimeStats 为容器运行时的文件系统使用
+type RuntimeStats struct {
+	ImageFs     *FsStats `json:"imageFs,omitempty"`
+	ContainerFs *FsStats `json:"containerFs,omitempty"`
+}
+
+// RlimitStats 为节点的进程数限制与使用
+type RlimitStats struct {
+	Time                  metav1.Time `json:"time"`
+	MaxPID                *int64      `json:"maxpid,omitempty"`
+	NumOfRunningProcesses *int64      `json:"curproc,omitempty"`
+}
+
+// PodReference 标识 PodStats 所属的Pod
+type PodReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	UID       string `json:"uid"`
+}
+
+// PodStats 为Pod级别的资源使用
+type PodStats struct {
+	PodRef           PodReference     `json:"podRef"`
+	StartTime        metav1.Time      `json:"startTime"`
+	Containers       []ContainerStats `json:"containers"`
+	CPU              *CPUStats        `json:"cpu,omitempty"`
+	Memory           *MemoryStats     `json:"memory,omitempty"`
+	VolumeStats      []VolumeStats    `json:"volume,omitempty"`
+	EphemeralStorage *FsStats         `json:"ephemeral-storage,omitempty"`
+	ProcessStats     *ProcessStats    `json:"process_stats,omitempty"`
+}
+
+// ContainerStats 为容器级别的资源使用
+type ContainerStats struct {
+	Name      string       `json:"name"`
+	StartTime metav1.Time  `json:"startTime"`
+	CPU       *CPUStats    `json:"cpu,omitempty"`
+	Memory    *MemoryStats `json:"memory,omitempty"`
+	Rootfs    *FsStats     `json:"rootfs,omitempty"`
+	Logs      *FsStats     `json:"logs,omitempty"`
+}
+
+// VolumeStats 为Pod卷的使用, PVCRef 仅PVC卷设置
+type VolumeStats struct {
+	FsStats `json:",inline"`
+	Name    string        `json:"name,omitempty"`
+	PVCRef  *PVCReference `json:"pvcRef,omitempty"`
+}
+
+// PVCReference 标识卷对应的PVC
+type PVCReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// ProcessStats 为Pod中的进程数
+type ProcessStats struct {
+	ProcessCount *uint64 `json:"process_count,omitempty"`
+}
+
+// CPUStats 为CPU使用
+type CPUStats struct {
+	Time                 metav1.Time `json:"time"`
+	UsageNanoCores       *uint64     `json:"usageNanoCores,omitempty"`
+	UsageCoreNanoSeconds *uint64     `json:"usageCoreNanoSeconds,omitempty"`
+}
+
+// MemoryStats 为内存使用
+type MemoryStats struct {
+	Time            metav1.Time `json:"time"`
+	AvailableBytes  *uint64     `json:"availableBytes,omitempty"`
+	UsageBytes      *uint64     `json:"usageBytes,omitempty"`
+	WorkingSetBytes *uint64     `json:"workingSetBytes,omitempty"`
+	RSSBytes        *uint64     `json:"rssBytes,omitempty"`
+}
+
+// FsStats 为文件系统使用
+type FsStats struct {
+	Time           metav1.Time `json:"time"`
+	AvailableBytes *uint64     `json:"availableBytes,omitempty"`
+	CapacityBytes  *uint64     `json:"capacityBytes,omitempty"`
+	UsedBytes      *uint64     `json:"usedBytes,omitempty"`
+	InodesFree     *uint64     `json:"inodesFree,omitempty"`
+	Inodes         *uint64     `json:"inodes,omitempty"`
+	InodesUsed     *uint64     `json:"inodesUsed,omitempty"`
+}
+
+// ResourceMetricSample 为kubelet /metrics/resource 中的一个样本
+type ResourceMetricSample struct {
+	// Name 如 node_cpu_usage_seconds_total, container_memory_working_set_bytes
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+	// TimestampMs 为样本时间(毫秒), kubelet未提供时为0
+	TimestampMs int64 `json:"timestampMs,omitempty"`
+}
+
+// PodStorageUsage 为单个Pod的临时存储与进程数
+type PodStorageUsage struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// EphemeralStorageUsedBytes 包括容器可写层, 日志与emptyDir等本地卷
+	EphemeralStorageUsedBytes uint64 `json:"ephemeralStorageUsedBytes"`
+	ProcessCount              uint64 `json:"processCount"`
+}
+
+// NodeUsage 为从 /stats/summary 汇总的节点临时存储与PID使用, 这些数据metrics-server不提供
+type NodeUsage struct {
+	NodeName string `json:"nodeName"`
+	// 节点根文件系统(kubelet的nodefs)
+	FsCapacityBytes  uint64 `json:"fsCapacityBytes"`
+	FsAvailableBytes uint64 `json:"fsAvailableBytes"`
+	FsUsedBytes      uint64 `json:"fsUsedBytes"`
+	// ImageFsUsedBytes 为镜像文件系统的使用, 与nodefs为同一文件系统时两者重叠
+	ImageFsUsedBytes uint64 `json:"imageFsUsedBytes"`
+	// PodsEphemeralStorageUsedBytes 为所有Pod临时存储使用之和
+	PodsEphemeralStorageUsedBytes uint64 `json:"podsEphemeralStorageUsedBytes"`
+	// MaxPID 与 RunningProcesses 来自节点rlimit, kubelet未上报时为0
+	MaxPID           int64 `json:"maxPid"`
+	RunningProcesses int64 `json:"runningProcesses"`
+	// PodsProcessCount 为所有Pod进程数之和
+	PodsProcessCount uint64 `json:"podsProcessCount"`
+	// Pods 按临时存储使用量降序排列
+	Pods []PodStorageUsage `json:"pods"`
+}
+
+// PIDUsageRatio 返回已使用PID占上限的比例, 未上报上限时返回0
+func (u *NodeUsage) PIDUsageRatio() float64 {
+	if u.MaxPID <= 0 {
+		return 0
+	}
+	return float64(u.RunningProcesses) / float64(u.MaxPID)
+}
+
+// FsUsageRatio 返回节点根文件系统的使用比例, 未上报容量时返回0
+func (u *NodeUsage) FsUsageRatio() float64 {
+	if u.FsCapacityBytes == 0 {
+		return 0
+	}
+	return float64(u.FsCapacityBytes-u.FsAvailableBytes) / float64(u.FsCapacityBytes)
+}
+
+// nodeProxyGet 通过节点的proxy子资源访问kubelet接口, 需要nodes/proxy权限
+func (c *GenericK8sClient) nodeProxyGet(ctx context.Context, nodeName, path string) ([]byte, error) {
+	data, err := c.GetStandardClient().CoreV1().RESTClient().Get().
+		Resource("nodes").Name(nodeName).SubResource("proxy").Suffix(path).
+		DoRaw(ctx)
+	if err != nil {
+		return nil, c.clusterError(errors.Wrap(err, "无法访问节点 "+nodeName+" 的kubelet接口 "+path), nodeGVK)
+	}
+	return data, nil
+}
+
+// GetNodeStatsSummary 通过节点proxy子资源读取kubelet的 /stats/summary, 需要nodes/proxy权限
+func (c *GenericK8sClient) GetNodeStatsSummary(ctx context.Context, nodeName string) (*NodeStatsSummary, error) {
+	data, err := c.nodeProxyGet(ctx, nodeName, "stats/summary")
+	if err != nil {
+		return nil, err
+	}
+	summary := &NodeStatsSummary{}
+	if err := json.Unmarshal(data, summary); err != nil {
+		return nil, errors.Wrap(err, "无法解析节点 "+nodeName+" 的stats summary")
+	}
+	return summary, nil
+}
+
+// GetNodeResourceMetrics 通过节点proxy子资源读取kubelet的 /metrics/resource, 返回按名称排序的全部样本
+func (c *GenericK8sClient) GetNodeResourceMetrics(ctx context.Context, nodeName string) ([]ResourceMetricSample, error) {
+	data, err := c.nodeProxyGet(ctx, nodeName, "metrics/resource")
+	if err != nil {
+		return nil, err
+	}
+	families, err := (&expfmt.TextParser{}).TextToMetricFamilies(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrap(err, "无法解析节点 "+nodeName+" 的resource metrics")
+	}
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var samples []ResourceMetricSample
+	for _, name := range names {
+		for _, metric := range families[name].GetMetric() {
+			sample := ResourceMetricSample{Name: name, Value: metricValue(metric), TimestampMs: metric.GetTimestampMs()}
+			for _, label := range metric.GetLabel() {
+				if sample.Labels == nil {
+					sample.Labels = map[string]string{}
+				}
+				sample.Labels[label.GetName()] = label.GetValue()
+			}
+			samples = append(samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// metricValue 返回gauge, counter或untyped样本的值, /metrics/resource 中不包含其他类型
+func metricValue(metric *dto.Metric) float64 {
+	switch {
+	case metric.Gauge != nil:
+		return metric.Gauge.GetValue()
+	case metric.Counter != nil:
+		return metric.Counter.GetValue()
+	case metric.Untyped != nil:
+		return metric.Untyped.GetValue()
+	}
+	return 0
+}
+
+// GetNodeUsage 汇总节点的临时存储与PID使用
+func (c *GenericK8sClient) GetNodeUsage(ctx context.Context, nodeName string) (*NodeUsage, error) {
+	summary, err := c.GetNodeStatsSummary(ctx, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	return SummarizeNodeUsage(summary), nil
+}
+
+// ListNodeUsage 并行汇总全部节点(或匹配labelSelector的节点)的临时存储与PID使用, 结果按节点名称排序.
+// 部分节点失败(如kubelet不可达)时仍返回其余节点的结果, 同时返回汇总错误.
+func (c *GenericK8sClient) ListNodeUsage(ctx context.Context, labelSelector string) ([]*NodeUsage, error) {
+	nodes, err := c.GetStandardClient().CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, c.clusterError(err, nodeGVK)
+	}
+
+	usages := make([]*NodeUsage, len(nodes.Items))
+	nodeErrs := make([]error, len(nodes.Items))
+	sem := make(chan struct{}, DefaultNodeStatsConcurrency)
+	wg := sync.WaitGroup{}
+	for i := range nodes.Items {
+		// 先获取并发槽位再启动goroutine, 大集群中不会为每个节点同时创建goroutine
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			nodeErrs[i] = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			usages[i], nodeErrs[i] = c.GetNodeUsage(ctx, nodes.Items[i].Name)
+		}(i)
+	}
+	wg.Wait()
+
+	result := make([]*NodeUsage, 0, len(usages))
+	for _, usage := range usages {
+		if usage != nil {
+			result = append(result, usage)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NodeName < result[j].NodeName
+	})
+	return result, utilerrors.NewAggregate(nodeErrs)
+}
+
+// SummarizeNodeUsage 从 /stats/summary 汇总节点的临时存储与PID使用
+func SummarizeNodeUsage(summary *NodeStatsSummary) *NodeUsage {
+	usage := &NodeUsage{NodeName: summary.Node.NodeName}
+	if fs := summary.Node.Fs; fs != nil {
+		usage.FsCapacityBytes = uint64Value(fs.CapacityBytes)
+		usage.FsAvailableBytes = uint64Value(fs.AvailableBytes)
+		usage.FsUsedBytes = uint64Value(fs.UsedBytes)
+	}
+	if summary.Node.Runtime != nil && summary.Node.Runtime.ImageFs != nil {
+		usage.ImageFsUsedBytes = uint64Value(summary.Node.Runtime.ImageFs.UsedBytes)
+	}
+	if rlimit := summary.Node.Rlimit; rlimit != nil {
+		if rlimit.MaxPID != nil {
+			usage.MaxPID = *rlimit.MaxPID
+		}
+		if rlimit.NumOfRunningProcesses != nil {
+			usage.RunningProcesses = *rlimit.NumOfRunningProcesses
+		}
+	}
+	for _, pod := range summary.Pods {
+		podUsage := PodStorageUsage{Namespace: pod.PodRef.Namespace, Name: pod.PodRef.Name}
+		if pod.EphemeralStorage != nil {
+			podUsage.EphemeralStorageUsedBytes = uint64Value(pod.EphemeralStorage.UsedBytes)
+		}
+		if pod.ProcessStats != nil {
+			podUsage.ProcessCount = uint64Value(pod.ProcessStats.ProcessCount)
+		}
+		usage.PodsEphemeralStorageUsedBytes += podUsage.EphemeralStorageUsedBytes
+		usage.PodsProcessCount += podUsage.ProcessCount
+		usage.Pods = append(usage.Pods, podUsage)
+	}
+	sort.SliceStable(usage.Pods, func(i, j int) bool {
+		return usage.Pods[i].EphemeralStorageUsedBytes > usage.Pods[j].EphemeralStorageUsedBytes
+	})
+	return usage
+}
+
+func uint64Value(v *uint64) uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,150 @@
+package k8sclientkit
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ViewRow 是被监视对象经过投影后在视图中的一行数据
+type ViewRow struct {
+	// Key 为源对象的 namespace/name
+	Key    string
+	Fields map[string]interface{}
+}
+
+// ProjectionFunc 将监视到的对象投影为视图中的一行字段, 返回false表示该对象不进入视图
+type ProjectionFunc func(obj *unstructured.Unstructured) (fields map[string]interface{}, ok bool)
+
+// ViewIndexFunc 为视图中的行计算二级索引值
+type ViewIndexFunc func(row *ViewRow) []string
+
+// View 是基于K8sResourceWatcher维护的物化视图: 每个对象经ProjectionFunc投影为一行并持续保持最新,
+// 可通过主键或二级索引查询, 避免使用方反复扫描完整的unstructured对象.
+//
+// 例如将Pod投影为 {node, phase, owner} 并按node建立索引.
+type View struct {
+	Name string
+
+	project ProjectionFunc
+	store   cache.Indexer
+}
+
+// NewView 在watcher上创建物化视图, 视图内容随watcher事件实时更新.
+// 应在watcher.Start 之前创建以接收全部初始对象; 若watcher已运行, informer会重放已有对象.
+func NewView(name string, watcher *K8sResourceWatcher, project ProjectionFunc, indexers map[string]ViewIndexFunc) *View {
+	storeIndexers := cache.Indexers{}
+	for indexName, indexFunc := range indexers {
+		fn := indexFunc
+		storeIndexers[indexName] = func(obj interface{}) ([]string, error) {
+			row, ok := obj.(*ViewRow)
+			if !ok {
+				return nil, errors.New("视图中存在非ViewRow对象")
+			}
+			return fn(row), nil
+		}
+	}
+
+	v := &View{
+		Name:    name,
+		project: project,
+		store: cache.NewIndexer(func(obj interface{}) (string, error) {
+			row, ok := obj.(*ViewRow)
+			if !ok {
+				return "", errors.New("视图中存在非ViewRow对象")
+			}
+			return row.Key, nil
+		}, storeIndexers),
+	}
+
+	watcher.AddEventHandler(v.onUpsert, v.onDelete, func(oldObj, newObj interface{}) {
+		v.onUpsert(newObj)
+	})
+	return v
+}
+
+// FieldIndex 返回按指定字段值建立索引的ViewIndexFunc, 字段值以 `%v` 格式化
+func FieldIndex(field string) ViewIndexFunc {
+	return func(row *ViewRow) []string {
+		value, ok := row.Fields[field]
+		if !ok || value == nil {
+			return nil
+		}
+		return []string{fmt.Sprintf("%v", value)}
+	}
+}
+
+func (v *View) onUpsert(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	key, err := cache.MetaNamespaceKeyFunc(u)
+	if err != nil {
+		return
+	}
+
+	fields, ok := v.project(u)
+	if !ok {
+		// 对象不再满足投影条件时从视图中移除
+		if existing, exists, _ := v.store.GetByKey(key); exists {
+			v.store.Delete(existing)
+		}
+		return
+	}
+	v.store.Update(&ViewRow{Key: key, Fields: fields})
+}
+
+func (v *View) onDelete(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	if existing, exists, _ := v.store.GetByKey(key); exists {
+		v.store.Delete(existing)
+	}
+}
+
+// Get 按源对象的 namespace/name 获取一行
+func (v *View) Get(key string) (*ViewRow, bool) {
+	obj, exists, err := v.store.GetByKey(key)
+	if err != nil || !exists {
+		return nil, false
+	}
+	return obj.(*ViewRow), true
+}
+
+// List 返回视图中全部行
+func (v *View) List() []*ViewRow {
+	objs := v.store.List()
+	rows := make([]*ViewRow, 0, len(objs))
+	for _, obj := range objs {
+		rows = append(rows, obj.(*ViewRow))
+	}
+	return rows
+}
+
+// ByIndex 返回二级索引 `indexName` 取值为 `value` 的所有行
+func (v *View) ByIndex(indexName, value string) ([]*ViewRow, error) {
+	objs, err := v.store.ByIndex(indexName, value)
+	if err != nil {
+		return nil, errors.Wrap(err, "视图索引查询失败")
+	}
+	rows := make([]*ViewRow, 0, len(objs))
+	for _, obj := range objs {
+		rows = append(rows, obj.(*ViewRow))
+	}
+	return rows, nil
+}
+
+// IndexValues 返回二级索引 `indexName` 当前的所有取值
+func (v *View) IndexValues(indexName string) []string {
+	return v.store.ListIndexFuncValues(indexName)
+}
+
+// Len 返回视图中的行数
+func (v *View) Len() int {
+	return len(v.store.ListKeys())
+}
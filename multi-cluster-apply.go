@@ -0,0 +1,71 @@
+package k8sclientkit
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// DefaultMultiClusterConcurrency 为多集群操作同时处理的最大集群数
+const DefaultMultiClusterConcurrency = 16
+
+// MultiClusterApplyResult 为单个集群的apply结果
+type MultiClusterApplyResult struct {
+	ClusterId         string
+	SuccessfulResults []*UnstructuredApplyResult
+	FailedResults     []*UnstructuredApplyResult
+	// Err 为集群级别的错误(如集群未注册), 此时没有对象结果
+	Err error
+}
+
+// Succeeded 集群中所有对象均apply成功
+func (r *MultiClusterApplyResult) Succeeded() bool {
+	return r.Err == nil && len(r.FailedResults) == 0
+}
+
+// ApplyUnstructuredObjsMultiCluster 将同一组对象并行apply到多个已注册集群, 返回以集群标识为key的结果.
+// clusterIDs 为空时apply到全部已注册集群. 每个集群使用objs的独立副本, objs本身不会被修改.
+// 任一集群存在失败时返回汇总错误, 结果中仍包含全部集群的部分成功信息.
+func (r *ClusterRegistry) ApplyUnstructuredObjsMultiCluster(ctx context.Context, clusterIDs []string, objs []*unstructured.Unstructured, fieldManager string) (map[string]*MultiClusterApplyResult, error) {
+	if len(clusterIDs) == 0 {
+		clusterIDs = r.IDs()
+	}
+	results := make(map[string]*MultiClusterApplyResult, len(clusterIDs))
+	for _, id := range clusterIDs {
+		results[id] = &MultiClusterApplyResult{ClusterId: id}
+	}
+	// 对象级别的失败记录在结果中, 不作为集群的错误
+	exec, _ := r.FleetExec(ctx, clusterIDs, func(ctx context.Context, cli *GenericK8sClient) error {
+		copies := make([]*unstructured.Unstructured, 0, len(objs))
+		for _, obj := range objs {
+			copies = append(copies, obj.DeepCopy())
+		}
+		result := results[cli.TargetK8sApiServerId]
+		result.SuccessfulResults, result.FailedResults = cli.ApplyUnstructuredObjsBatch(ctx, copies, fieldManager)
+		return nil
+	}, FleetExecOptions{})
+	for id, err := range exec.Failed {
+		results[id].Err = err
+	}
+	for _, id := range exec.NotRun {
+		results[id].Err = ctx.Err()
+	}
+
+	var errs []error
+	for _, id := range clusterIDs {
+		result := results[id]
+		switch {
+		case result.Err != nil:
+			errs = append(errs, errors.Wrap(result.Err, "集群 "+id))
+		case len(result.FailedResults) > 0:
+			failed := make([]error, 0, len(result.FailedResults))
+			for _, failure := range result.FailedResults {
+				failed = append(failed, failure.Error)
+			}
+			errs = append(errs, errors.Wrapf(utilerrors.NewAggregate(failed), "集群 %s 中 %d 个对象apply失败", id, len(failed)))
+		}
+	}
+	return results, utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,104 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SourceClusterAnnotation 为多集群查询结果中标注对象来源集群标识的annotation
+const SourceClusterAnnotation = "k8s-client-kit.io/source-cluster"
+
+// MultiClusterListOptions 为 ListMultiCluster 的参数
+type MultiClusterListOptions struct {
+	metav1.ListOptions
+	// Namespaces 为查询的namespace, 为空时查询所有namespace(需要集群范围的list权限)
+	Namespaces []string
+	// Sort 为true时结果按 集群/namespace/name 排序, 否则按clusterIDs顺序排列, 集群内保持APIServer返回顺序
+	Sort bool
+}
+
+// MultiClusterList 为多集群查询结果
+type MultiClusterList struct {
+	// Items 为合并后的对象, 每个对象带有 SourceClusterAnnotation
+	Items []*unstructured.Unstructured
+	// Errors 为查询失败的集群及其错误, 这些集群的对象不在Items中
+	Errors map[string]error
+}
+
+// ByCluster 按来源集群分组返回对象
+func (l *MultiClusterList) ByCluster() map[string][]*unstructured.Unstructured {
+	grouped := map[string][]*unstructured.Unstructured{}
+	for _, obj := range l.Items {
+		id := SourceCluster(obj)
+		grouped[id] = append(grouped[id], obj)
+	}
+	return grouped
+}
+
+// SourceCluster 返回多集群查询结果中对象的来源集群标识
+func SourceCluster(obj *unstructured.Unstructured) string {
+	return obj.GetAnnotations()[SourceClusterAnnotation]
+}
+
+// ListMultiCluster 在多个已注册集群中并行list同一资源, 合并结果并以 SourceClusterAnnotation 标注来源集群.
+// clusterIDs 为空时查询全部已注册集群. 部分集群失败时仍返回其余集群的结果, 同时返回汇总错误.
+func (r *ClusterRegistry) ListMultiCluster(ctx context.Context, clusterIDs []string, gvr schema.GroupVersionResource, opts MultiClusterListOptions) (*MultiClusterList, error) {
+	if len(clusterIDs) == 0 {
+		clusterIDs = r.IDs()
+	}
+	ctx, _ = EnsureRequestID(ctx)
+	namespaces := opts.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	perCluster := make(map[string][]*unstructured.Unstructured, len(clusterIDs))
+	var lock sync.Mutex
+	exec, _ := r.FleetExec(ctx, clusterIDs, func(ctx context.Context, cli *GenericK8sClient) error {
+		list, err := cli.ListAcrossNamespaces(ctx, gvr, namespaces, ListAcrossNamespacesOptions{ListOptions: opts.ListOptions})
+		if err != nil {
+			return err
+		}
+		items := make([]*unstructured.Unstructured, 0, len(list.Items))
+		for j := range list.Items {
+			obj := &list.Items[j]
+			annotations := obj.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[SourceClusterAnnotation] = cli.TargetK8sApiServerId
+			obj.SetAnnotations(annotations)
+			items = append(items, obj)
+		}
+		lock.Lock()
+		perCluster[cli.TargetK8sApiServerId] = items
+		lock.Unlock()
+		return nil
+	}, FleetExecOptions{})
+
+	result := &MultiClusterList{Errors: exec.Failed}
+	for _, id := range exec.NotRun {
+		result.Errors[id] = ctx.Err()
+	}
+	for _, id := range exec.Succeeded {
+		result.Items = append(result.Items, perCluster[id]...)
+	}
+	if opts.Sort {
+		sort.SliceStable(result.Items, func(a, b int) bool {
+			x, y := result.Items[a], result.Items[b]
+			if SourceCluster(x) != SourceCluster(y) {
+				return SourceCluster(x) < SourceCluster(y)
+			}
+			if x.GetNamespace() != y.GetNamespace() {
+				return x.GetNamespace() < y.GetNamespace()
+			}
+			return x.GetName() < y.GetName()
+		})
+	}
+	return result, exec.Err()
+}
@@ -0,0 +1,386 @@
+package k8sclientkit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/discovery"
+)
+
+// NamespaceSnapshotFormatVersion 为 NamespaceSnapshot 归档格式的版本, 读取不同版本的归档时返回错误
+const NamespaceSnapshotFormatVersion = "v1"
+
+const (
+	namespaceSnapshotIndexFile     = "snapshot.yaml"
+	namespaceSnapshotNamespaceFile = "namespace.yaml"
+	namespaceSnapshotPageSize      = 500
+)
+
+// namespaceSnapshotSkipped 为由集群根据其他对象自动生成, 快照中不包含的资源
+var namespaceSnapshotSkipped = sets.New(
+	schema.GroupResource{Resource: "events"},
+	schema.GroupResource{Group: "events.k8s.io", Resource: "events"},
+	schema.GroupResource{Resource: "endpoints"},
+	schema.GroupResource{Group: "discovery.k8s.io", Resource: "endpointslices"},
+)
+
+// NamespaceSnapshotResource 为快照中一种资源的对象
+type NamespaceSnapshotResource struct {
+	Group    string `json:"group,omitempty"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+	Kind     string `json:"kind"`
+	// File 为归档中保存该资源对象的文件
+	File  string                      `json:"file"`
+	Count int                         `json:"count"`
+	Items []unstructured.Unstructured `json:"-"`
+}
+
+// GroupVersionResource 返回资源的GVR
+func (r *NamespaceSnapshotResource) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: r.Group, Version: r.Version, Resource: r.Resource}
+}
+
+// NamespaceSnapshot 为一个namespace中所有资源的快照, 由 SnapshotNamespace 生成, 可通过 RestoreNamespace 恢复到相同或其他集群.
+// 对象已去除服务端字段(见 StripServerFields). 使用 WriteArchive 保存为tar.gz归档(WriteArchiveWithEncoder 可选择其他格式):
+// 根目录为索引 `snapshot.yaml` 与 `namespace.yaml`, 每种资源的对象以List保存在 `resources/<group>/<version>/<resource>.yaml`.
+type NamespaceSnapshot struct {
+	FormatVersion string    `json:"formatVersion"`
+	ClusterId     string    `json:"clusterId"`
+	Namespace     string    `json:"namespace"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// ResourceVersion 为所有资源读取时固定的resourceVersion(见 ContextWithReadSnapshot)
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// NamespaceObject 为Namespace对象本身
+	NamespaceObject *unstructured.Unstructured  `json:"-"`
+	Resources       []NamespaceSnapshotResource `json:"resources"`
+	// Errors 为读取失败而未包含在快照中的资源(gvr字符串)及失败原因
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// ObjectCount 返回快照中的对象总数(不含Namespace对象)
+func (s *NamespaceSnapshot) ObjectCount() int {
+	count := 0
+	for _, resource := range s.Resources {
+		count += len(resource.Items)
+	}
+	return count
+}
+
+// SnapshotNamespace 导出namespace中所有资源的对象. 资源类型通过discovery获取, 包括CRD定义的资源,
+// 只包含同时支持list与create的资源的首选版本, 不包含events, endpoints等由集群自动生成的资源.
+// 所有资源读取同一时刻的状态. 部分资源(如不可用的聚合API)读取失败时记录在 NamespaceSnapshot.Errors 中, 不返回错误.
+func (c *GenericK8sClient) SnapshotNamespace(ctx context.Context, namespace string) (*NamespaceSnapshot, error) {
+	ctx, _ = EnsureRequestID(ctx)
+	namespaceGVR := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	nsObj, err := c.GetDynamicClient().Resource(namespaceGVR).Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, c.clusterError(err, schema.GroupVersionKind{Version: "v1", Kind: "Namespace"})
+	}
+
+	snapshot := &NamespaceSnapshot{
+		FormatVersion:   NamespaceSnapshotFormatVersion,
+		ClusterId:       c.TargetK8sApiServerId,
+		Namespace:       namespace,
+		CreatedAt:       time.Now().UTC(),
+		NamespaceObject: StripServerFields(nsObj),
+		Errors:          map[string]string{},
+	}
+	resourceLists, err := c.GetStandardClient().Discovery().ServerPreferredNamespacedResources()
+	if err != nil {
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			return nil, c.clusterError(errors.Wrap(err, "无法获取集群API资源"), schema.GroupVersionKind{})
+		}
+		for gv, groupErr := range err.(*discovery.ErrGroupDiscoveryFailed).Groups {
+			snapshot.Errors[gv.String()] = groupErr.Error()
+		}
+	}
+
+	ctx, readSnapshot := ContextWithReadSnapshot(ctx, nil)
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, apiResource := range list.APIResources {
+			verbs := sets.New(apiResource.Verbs...)
+			gvr := gv.WithResource(apiResource.Name)
+			if !verbs.HasAll("list", "create") || namespaceSnapshotSkipped.Has(gvr.GroupResource()) {
+				continue
+			}
+			items, err := c.ListAcrossNamespaces(ctx, gvr, []string{namespace}, ListAcrossNamespacesOptions{
+				ListOptions: metav1.ListOptions{Limit: namespaceSnapshotPageSize},
+			})
+			if err != nil {
+				snapshot.Errors[gvr.String()] = err.Error()
+				continue
+			}
+			if len(items.Items) == 0 {
+				continue
+			}
+			resource := NamespaceSnapshotResource{
+				Group:    gv.Group,
+				Version:  gv.Version,
+				Resource: apiResource.Name,
+				Kind:     apiResource.Kind,
+				File:     snapshotResourceFile(gvr),
+				Count:    len(items.Items),
+			}
+			for i := range items.Items {
+				resource.Items = append(resource.Items, *StripServerFields(&items.Items[i]))
+			}
+			snapshot.Resources = append(snapshot.Resources, resource)
+		}
+	}
+	snapshot.ResourceVersion = readSnapshot.ResourceVersion()
+	sort.Slice(snapshot.Resources, func(i, j int) bool {
+		return snapshot.Resources[i].File < snapshot.Resources[j].File
+	})
+	return snapshot, nil
+}
+
+func snapshotResourceFile(gvr schema.GroupVersionResource) string {
+	group := gvr.Group
+	if len(group) == 0 {
+		group = "core"
+	}
+	return path.Join("resources", group, gvr.Version, gvr.Resource+".yaml")
+}
+
+// WriteArchive 将快照以tar.gz归档写入w, 归档中的文件使用YAML格式
+func (s *NamespaceSnapshot) WriteArchive(w io.Writer) error {
+	return s.WriteArchiveWithEncoder(w, EncoderYAML)
+}
+
+// WriteArchiveWithEncoder 同 WriteArchive, 归档中的索引与资源文件使用enc序列化, 文件扩展名为 enc.Extension().
+// 读取时 ReadNamespaceSnapshotArchive 根据扩展名选择已注册的Encoder.
+func (s *NamespaceSnapshot) WriteArchiveWithEncoder(w io.Writer, enc Encoder) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	writeFile := func(name string, v interface{}) error {
+		buf := &bytes.Buffer{}
+		if err := enc.Encode(buf, v); err != nil {
+			return errors.Wrap(err, "无法序列化 "+name)
+		}
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(buf.Len()), ModTime: s.CreatedAt, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return errors.Wrap(err, "无法写入归档")
+		}
+		_, err := tw.Write(buf.Bytes())
+		return errors.Wrap(err, "无法写入归档")
+	}
+
+	// 索引中记录的文件名与实际使用的格式一致
+	index := *s
+	index.Resources = make([]NamespaceSnapshotResource, len(s.Resources))
+	for i, resource := range s.Resources {
+		resource.File = withEncoderExtension(resource.File, enc)
+		index.Resources[i] = resource
+	}
+	if err := writeFile(withEncoderExtension(namespaceSnapshotIndexFile, enc), &index); err != nil {
+		return err
+	}
+	if s.NamespaceObject != nil {
+		if err := writeFile(withEncoderExtension(namespaceSnapshotNamespaceFile, enc), s.NamespaceObject.Object); err != nil {
+			return err
+		}
+	}
+	for _, resource := range index.Resources {
+		items := make([]interface{}, 0, len(resource.Items))
+		for _, item := range resource.Items {
+			items = append(items, item.Object)
+		}
+		if err := writeFile(resource.File, map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": items}); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "无法写入归档")
+	}
+	return errors.Wrap(gz.Close(), "无法写入归档")
+}
+
+// withEncoderExtension 将文件名的扩展名替换为enc的扩展名
+func withEncoderExtension(name string, enc Encoder) string {
+	return strings.TrimSuffix(name, path.Ext(name)) + "." + enc.Extension()
+}
+
+// ReadNamespaceSnapshotArchive 读取 NamespaceSnapshot.WriteArchive 或 WriteArchiveWithEncoder 写入的归档,
+// 各文件按扩展名选择已注册的Encoder解析(见 EncoderForPath)
+func ReadNamespaceSnapshotArchive(r io.Reader) (*NamespaceSnapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法读取快照归档")
+	}
+	defer gz.Close()
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "无法读取快照归档")
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法读取快照归档中的 "+header.Name)
+		}
+		files[path.Clean(header.Name)] = data
+	}
+
+	var enc Encoder
+	var index []byte
+	for _, candidate := range registeredEncoders() {
+		if data, ok := files[withEncoderExtension(namespaceSnapshotIndexFile, candidate)]; ok {
+			enc, index = candidate, data
+			break
+		}
+	}
+	if enc == nil {
+		return nil, errors.New("快照归档中缺少索引 " + namespaceSnapshotIndexFile)
+	}
+	snapshot := &NamespaceSnapshot{}
+	if err := enc.Decode(bytes.NewReader(index), snapshot); err != nil {
+		return nil, errors.Wrap(err, "无法解析快照索引")
+	}
+	if snapshot.FormatVersion != NamespaceSnapshotFormatVersion {
+		return nil, errors.New("不支持的快照格式版本 " + snapshot.FormatVersion)
+	}
+	namespaceFile := withEncoderExtension(namespaceSnapshotNamespaceFile, enc)
+	if data, ok := files[namespaceFile]; ok {
+		snapshot.NamespaceObject = &unstructured.Unstructured{}
+		if err := decodeSnapshotObject(enc, data, snapshot.NamespaceObject); err != nil {
+			return nil, errors.Wrap(err, "无法解析 "+namespaceFile)
+		}
+	}
+	for i := range snapshot.Resources {
+		resource := &snapshot.Resources[i]
+		data, ok := files[path.Clean(resource.File)]
+		if !ok {
+			return nil, errors.New("快照归档中缺少 " + resource.File)
+		}
+		list := &unstructured.UnstructuredList{}
+		if err := decodeSnapshotObject(EncoderForPath(resource.File), data, list); err != nil {
+			return nil, errors.Wrap(err, "无法解析 "+resource.File)
+		}
+		resource.Items = list.Items
+	}
+	return snapshot, nil
+}
+
+// decodeSnapshotObject 以enc解析data后经JSON转换为对象, 使整数字段与直接解析JSON时一致(int64而非float64)
+func decodeSnapshotObject(enc Encoder, data []byte, into json.Unmarshaler) error {
+	var v interface{}
+	if err := enc.Decode(bytes.NewReader(data), &v); err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return into.UnmarshalJSON(jsonData)
+}
+
+// RestoreNamespaceOptions 为 RestoreNamespace 的参数
+type RestoreNamespaceOptions struct {
+	// TargetNamespace 为恢复到的namespace, 为空时恢复到快照的原namespace
+	TargetNamespace string
+	// Conflict 为对象已存在时的处理方式, 默认为 CopyConflictSkip. Namespace对象本身已存在时总是保留
+	Conflict CopyConflictStrategy
+	// FieldManager 默认为 DefaultCopyFieldManager
+	FieldManager string
+	// IncludeControlled 为true时同时恢复由控制器管理的对象(如ReplicaSet, Pod), 默认跳过, 由其owner重新生成
+	IncludeControlled bool
+	// Transform 在写入集群前修改对象, 返回nil时跳过该对象
+	Transform func(obj *unstructured.Unstructured) (*unstructured.Unstructured, error)
+	// DryRun 为true时以server-side dry-run写入. 目标namespace不存在时其中的对象无法dry-run, 将记录为错误
+	DryRun bool
+}
+
+// RestoreNamespace 将快照恢复到集群(可以不是快照来源的集群), 对象的处理与 CopyResources 相同(见 SanitizeForCopy).
+// 先恢复Namespace对象, 之后依次恢复ServiceAccount, Secret, ConfigMap, PVC, RBAC等被依赖的资源, Service, 最后是其余资源.
+// 集群中没有对应资源类型(如未安装CRD)的对象记录为错误. 部分对象失败时继续恢复其余对象, 返回汇总错误.
+func (c *GenericK8sClient) RestoreNamespace(ctx context.Context, snapshot *NamespaceSnapshot, opts RestoreNamespaceOptions) (*CopyResult, error) {
+	copyOpts := CopyOptions{
+		Conflict:          opts.Conflict,
+		FieldManager:      opts.FieldManager,
+		IncludeControlled: opts.IncludeControlled,
+		Transform:         opts.Transform,
+		DryRun:            opts.DryRun,
+	}
+	if len(opts.TargetNamespace) > 0 && opts.TargetNamespace != snapshot.Namespace {
+		copyOpts.NamespaceMapping = map[string]string{snapshot.Namespace: opts.TargetNamespace}
+	}
+	if err := copyOpts.complete(); err != nil {
+		return nil, err
+	}
+	ctx, _ = EnsureRequestID(ctx)
+
+	result := &CopyResult{Errors: map[string]error{}}
+	nsObj := snapshot.NamespaceObject
+	if nsObj == nil {
+		nsObj = &unstructured.Unstructured{}
+		nsObj.SetAPIVersion("v1")
+		nsObj.SetKind("Namespace")
+		nsObj.SetName(snapshot.Namespace)
+	}
+	nsOpts := copyOpts
+	nsOpts.Conflict = CopyConflictSkip
+	result.copyObject(ctx, snapshot.ClusterId, c, schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, nsObj, nsOpts)
+
+	resources := make([]*NamespaceSnapshotResource, 0, len(snapshot.Resources))
+	for i := range snapshot.Resources {
+		resources = append(resources, &snapshot.Resources[i])
+	}
+	sort.SliceStable(resources, func(i, j int) bool {
+		return restoreRank(resources[i].GroupVersionResource()) < restoreRank(resources[j].GroupVersionResource())
+	})
+	for _, resource := range resources {
+		for i := range resource.Items {
+			result.copyObject(ctx, snapshot.ClusterId, c, resource.GroupVersionResource(), &resource.Items[i], copyOpts)
+		}
+	}
+
+	var errs []error
+	for key, err := range result.Errors {
+		errs = append(errs, errors.Wrap(err, key))
+	}
+	return result, utilerrors.NewAggregate(errs)
+}
+
+// restoreRank 使被其他对象依赖的资源先恢复
+func restoreRank(gvr schema.GroupVersionResource) int {
+	switch gvr.GroupResource() {
+	case schema.GroupResource{Resource: "serviceaccounts"},
+		schema.GroupResource{Resource: "secrets"},
+		schema.GroupResource{Resource: "configmaps"},
+		schema.GroupResource{Resource: "persistentvolumeclaims"},
+		schema.GroupResource{Resource: "limitranges"},
+		schema.GroupResource{Resource: "resourcequotas"},
+		schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "roles"},
+		schema.GroupResource{Group: "rbac.authorization.k8s.io", Resource: "rolebindings"}:
+		return 0
+	case schema.GroupResource{Resource: "services"}:
+		return 1
+	}
+	return 2
+}
@@ -0,0 +1,86 @@
+package k8sclientkit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ObjectIdentity 返回对象与API版本无关的唯一标识: `group/Kind/namespace/name`, 集群级别对象namespace为空
+func ObjectIdentity(obj *unstructured.Unstructured) string {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group + "/" + gvk.Kind + "/" + obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// StripServerFields 返回去除了status以及由APIServer填充的元数据(uid, resourceVersion, generation,
+// creationTimestamp, managedFields等)的对象副本, 用于对象内容比较或迁移到其他集群
+func StripServerFields(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	stripped := obj.DeepCopy()
+	delete(stripped.Object, "status")
+	for _, field := range []string{"uid", "resourceVersion", "generation", "creationTimestamp", "deletionTimestamp",
+		"deletionGracePeriodSeconds", "managedFields", "selfLink"} {
+		unstructured.RemoveNestedField(stripped.Object, "metadata", field)
+	}
+	return stripped
+}
+
+// ObjectHash 计算对象内容(去除服务端字段后)的sha256, 内容相同的对象hash一致
+func ObjectHash(obj *unstructured.Unstructured) string {
+	// encoding/json 对map按key排序输出, 结果是确定的
+	data, err := json.Marshal(StripServerFields(obj).Object)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// DiffObjects 比较两个对象去除服务端字段后的内容, 返回按路径排序的变更描述,
+// 如 `spec.replicas: 1 -> 3`, `metadata.labels.tier: + "web"`, 内容一致时返回空
+func DiffObjects(live, desired *unstructured.Unstructured) []string {
+	before := map[string]string{}
+	after := map[string]string{}
+	flattenObject("", StripServerFields(live).Object, before)
+	flattenObject("", StripServerFields(desired).Object, after)
+
+	var changes []string
+	for path, value := range after {
+		if old, ok := before[path]; !ok {
+			changes = append(changes, path+": + "+value)
+		} else if old != value {
+			changes = append(changes, path+": "+old+" -> "+value)
+		}
+	}
+	for path, old := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, path+": - "+old)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// flattenObject 将对象展开为 `路径 -> JSON值` 的形式, 如 `spec.containers[0].image`
+func flattenObject(prefix string, value interface{}, result map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			path := key
+			if len(prefix) > 0 {
+				path = prefix + "." + key
+			}
+			flattenObject(path, child, result)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenObject(prefix+"["+strconv.Itoa(i)+"]", child, result)
+		}
+	default:
+		data, _ := json.Marshal(v)
+		result[prefix] = string(data)
+	}
+}
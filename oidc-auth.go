@@ -0,0 +1,106 @@
+package k8sclientkit
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	// 注册client-go内置的oidc auth provider
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+)
+
+const AuthTypeOIDC = "OIDC"
+
+// OIDCConfig 描述通过OIDC(如Dex, Keycloak)获取ID token所需的信息
+type OIDCConfig struct {
+	// IssuerURL 为OIDC provider的issuer地址, 需与APIServer的 --oidc-issuer-url 一致
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	// IDToken 可选的初始ID token, 为空或过期时将使用RefreshToken刷新
+	IDToken string
+	// IssuerCaPem 可选的OIDC provider CA证书(PEM)
+	IssuerCaPem []byte
+	// OnTokenRefresh 可选回调, 在ID token刷新后调用. OIDC provider通常会轮换refresh token, 调用方可借此持久化新的token.
+	OnTokenRefresh func(idToken, refreshToken string)
+}
+
+// NewGenericK8sClientWithOIDCContext 使用OIDC认证方式构建generic client.
+// 客户端在ID token过期前自动使用refresh token刷新, 无需重建transport.
+//
+//	apiServerUrl: 目标apiserver的访问地址
+//	caPem: PEM编码的信任CA，应该设置为目标APIServer的CA证书
+//	optionalTLSServerName: 用于校验服务端证书是否与此名称一致，默认同APIServerURL保持一致
+func NewGenericK8sClientWithOIDCContext(ctx context.Context, id, apiServerUrl string, caPem []byte, optionalTLSServerName string, oidc OIDCConfig, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	if timeout == nil {
+		var to = 30 * time.Second
+		timeout = &to
+	}
+	if len(oidc.IssuerURL) == 0 || len(oidc.ClientID) == 0 {
+		return nil, errors.New("OIDC IssuerURL 与 ClientID 不能为空")
+	}
+	if len(oidc.RefreshToken) == 0 && len(oidc.IDToken) == 0 {
+		return nil, errors.New("OIDC RefreshToken 与 IDToken 不能同时为空")
+	}
+
+	providerConfig := map[string]string{
+		"idp-issuer-url": strings.TrimSuffix(oidc.IssuerURL, "/"),
+		"client-id":      oidc.ClientID,
+		"client-secret":  oidc.ClientSecret,
+		"refresh-token":  oidc.RefreshToken,
+	}
+	if len(oidc.IDToken) > 0 {
+		providerConfig["id-token"] = oidc.IDToken
+	}
+	if len(oidc.IssuerCaPem) > 0 {
+		providerConfig["idp-certificate-authority-data"] = base64.StdEncoding.EncodeToString(oidc.IssuerCaPem)
+	}
+
+	config := &rest.Config{
+		Host: apiServerUrl,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData:     caPem,
+			ServerName: optionalTLSServerName,
+		},
+		AuthProvider: &clientcmdapi.AuthProviderConfig{
+			Name:   "oidc",
+			Config: providerConfig,
+		},
+		AuthConfigPersister: &oidcConfigPersister{onRefresh: oidc.OnTokenRefresh},
+		Timeout:             *timeout,
+	}
+	return newGenericK8sClientWithRestConfig(ctx, id, AuthTypeOIDC, config, opts)
+}
+
+// NewGenericK8sClientWithOIDC 同 NewGenericK8sClientWithOIDCContext, 使用 context.Background().
+//
+// Deprecated: 使用 NewGenericK8sClientWithOIDCContext, 以便控制构造期间网络请求的超时以及客户端的生命周期.
+func NewGenericK8sClientWithOIDC(id, apiServerUrl string, caPem []byte, optionalTLSServerName string, oidc OIDCConfig, timeout *time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	return NewGenericK8sClientWithOIDCContext(context.Background(), id, apiServerUrl, caPem, optionalTLSServerName, oidc, timeout, opts...)
+}
+
+// oidcConfigPersister 接收oidc auth provider刷新后的配置.
+// client-go 默认将其写回kubeconfig文件, 此处仅保存在内存中并通知调用方.
+type oidcConfigPersister struct {
+	lock      sync.Mutex
+	config    map[string]string
+	onRefresh func(idToken, refreshToken string)
+}
+
+func (p *oidcConfigPersister) Persist(config map[string]string) error {
+	p.lock.Lock()
+	p.config = config
+	p.lock.Unlock()
+
+	if p.onRefresh != nil {
+		p.onRefresh(config["id-token"], config["refresh-token"])
+	}
+	return nil
+}
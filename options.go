@@ -0,0 +1,365 @@
+package k8sclientkit
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Option 用于在构建GenericK8sClient时调整底层rest.Config及客户端行为, 所有 `NewGenericK8sClient*` 构造函数均接受可变数量的Option.
+type Option func(o *clientOptions)
+
+// clientOptions 汇总所有Option的设置结果, 零值表示保持client-go默认行为
+type clientOptions struct {
+	qps       float32
+	burst     int
+	timeout   *time.Duration
+	userAgent string
+
+	credentialProvider  CredentialProvider
+	tokenExpiryLead     time.Duration
+	tokenExpiryNotifier TokenExpiryNotifier
+
+	execPluginAllowlist []string
+
+	impersonate *rest.ImpersonationConfig
+
+	writeBudget *WriteBudget
+
+	tenantQuota *TenantQuota
+
+	overrides ClusterOverrides
+
+	dryRun bool
+
+	uncachedObjects []client.Object
+	cacheNamespaces []string
+
+	eventComponent string
+
+	gvkRules *gvkFilter
+
+	protobuf bool
+
+	plugins []Plugin
+
+	// restMapper 由Clone设置, 与原客户端共享
+	restMapper meta.RESTMapper
+
+	rateLimiter        flowcontrol.RateLimiter
+	throttleMaxRetries int
+	throttleMaxWait    time.Duration
+	throttleObserver   ThrottleObserver
+
+	warningHandler APIWarningHandler
+
+	debug *DebugOptions
+
+	fleetMetrics *FleetMetrics
+
+	backgroundErrorHandler BackgroundErrorHandler
+
+	requestHeaders  http.Header
+	requestIDHeader string
+	requestIDFunc   RequestIDFunc
+
+	transportMiddlewares []func(http.RoundTripper) http.RoundTripper
+
+	proxyURL string
+
+	transportSettings TransportSettings
+
+	hostAliases map[string]string
+
+	lazyConnect bool
+
+	healthProbeInterval time.Duration
+
+	secretReloadInterval time.Duration
+
+	sshHost            string
+	sshUser            string
+	sshKeyPEM          []byte
+	sshHostKeyCallback ssh.HostKeyCallback
+
+	dialer       Dialer
+	konnectivity *KonnectivityOptions
+
+	circuitBreakerOptions *CircuitBreakerOptions
+
+	crdWatch *CRDWatchOptions
+
+	failoverEndpoints []string
+
+	// 以下为applyToRestConfig过程中生成的运行时状态
+	token *renewableToken
+	// tunnelHealth 为 WithDialer, WithSSHTunnel 或 WithKonnectivity 提供的隧道健康检查
+	tunnelHealth DialerHealthChecker
+	// breaker 为熔断状态, Clone时由原客户端传入以共享
+	breaker *circuitBreaker
+	// failover 为 WithFailoverEndpoints 的地址切换状态
+	failover *endpointFailover
+	// cleanups 在客户端Close时执行, 用于释放选项创建的资源
+	cleanups []func()
+}
+
+func newClientOptions(opts []Option) *clientOptions {
+	o := &clientOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// needTokenWatch 是否需要后台监视token过期
+func (o *clientOptions) needTokenWatch() bool {
+	return o.tokenExpiryNotifier != nil || o.credentialProvider != nil
+}
+
+// applyToRestConfig 将选项写入rest.Config, 在创建各类客户端之前调用
+func (o *clientOptions) applyToRestConfig(clusterId string, config *rest.Config) error {
+	o.resolvePlugins()
+	if err := o.prepareExecProvider(config); err != nil {
+		return err
+	}
+	if o.credentialProvider != nil {
+		// 由renewableToken 接管bearer token, 以便运行时续期而无需重建transport
+		o.token = &renewableToken{token: config.BearerToken, provider: o.credentialProvider}
+		if len(config.BearerToken) == 0 && len(config.BearerTokenFile) > 0 {
+			if token, err := os.ReadFile(config.BearerTokenFile); err == nil {
+				o.token.set(string(token))
+			}
+		}
+		config.BearerToken = ""
+		config.BearerTokenFile = ""
+		config.Wrap(transport.TokenSourceWrapTransport(o.token))
+	}
+	if o.qps > 0 {
+		config.QPS = o.qps
+	}
+	if o.burst > 0 {
+		config.Burst = o.burst
+	}
+	if o.timeout != nil {
+		config.Timeout = *o.timeout
+	}
+	if len(o.userAgent) > 0 {
+		config.UserAgent = o.userAgent
+	}
+	if o.overrides.QPS > 0 {
+		config.QPS = o.overrides.QPS
+	}
+	if o.overrides.Burst > 0 {
+		config.Burst = o.overrides.Burst
+	}
+	o.applyThrottle(clusterId, config)
+	if o.impersonate != nil {
+		config.Impersonate = *o.impersonate
+	}
+	if len(o.proxyURL) > 0 {
+		u, err := url.Parse(o.proxyURL)
+		if err != nil {
+			return errors.Wrap(err, "无效的代理地址:"+o.proxyURL)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return errors.New("不支持的代理协议:" + u.Scheme + ", 仅支持http, https, socks5")
+		}
+		config.Proxy = http.ProxyURL(u)
+	}
+	if err := o.applyDialer(config); err != nil {
+		return err
+	}
+	o.applyTransportSettings(config)
+	if err := o.applyHostAliases(config); err != nil {
+		return err
+	}
+	if err := o.applyEndpointFailover(config); err != nil {
+		return err
+	}
+	// 始终安装, 以便通过 ContextWithDebugLogging 按请求开启
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &debugRoundTripper{clusterId: clusterId, options: o.debug, requestIDHeader: o.requestIDHeader, next: rt}
+	})
+	// 始终解析警告头, 以便通过 ContextWithWarningCollector 按操作收集警告
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &warningRoundTripper{clusterId: clusterId, handler: o.warningHandler, next: rt}
+	})
+	if o.fleetMetrics != nil {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &metricsRoundTripper{clusterId: clusterId, metrics: o.fleetMetrics, requestIDHeader: o.requestIDHeader, next: rt}
+		})
+	}
+	o.applyCircuitBreaker(clusterId, config)
+	if o.gvkRules != nil {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &gvkFilterRoundTripper{filter: o.gvkRules, next: rt}
+		})
+	}
+	if o.overrides.ReadOnly {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &readOnlyRoundTripper{clusterId: clusterId, next: rt}
+		})
+	}
+	// 位于只读限制外层, 只读集群上的客户端同时开启dry-run时, 写请求先被改写为dry-run再由只读限制放行
+	if o.dryRun {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &dryRunRoundTripper{next: rt}
+		})
+	}
+	if o.writeBudget != nil {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &writeBudgetRoundTripper{budget: o.writeBudget, clusterId: clusterId, next: rt}
+		})
+	}
+	// 位于写入预算外层, 被租户配额拒绝的请求不占用写入并发
+	if o.tenantQuota != nil {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &tenantQuotaRoundTripper{quota: o.tenantQuota, next: rt}
+		})
+	}
+	config.Wrap(o.wrapPluginTransports(clusterId))
+	// config.Wrap 后添加的在外层, 逆序添加使先注册的中间件最先处理请求
+	for i := len(o.transportMiddlewares) - 1; i >= 0; i-- {
+		config.Wrap(o.transportMiddlewares[i])
+	}
+	// 位于最外层, 使插件及用户中间件也能读取这些header. 始终安装, 以便转发ctx中的请求ID(见 EnsureRequestID)
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &requestHeaderRoundTripper{headers: o.requestHeaders, requestIDHeader: o.requestIDHeader, requestIDFunc: o.requestIDFunc, next: rt}
+	})
+	return nil
+}
+
+// WithQPS 设置客户端侧限流的每秒请求数, client-go 默认为5
+func WithQPS(qps float32) Option {
+	return func(o *clientOptions) {
+		o.qps = qps
+	}
+}
+
+// WithBurst 设置客户端侧限流允许的突发请求数, client-go 默认为10
+func WithBurst(burst int) Option {
+	return func(o *clientOptions) {
+		o.burst = burst
+	}
+}
+
+// WithTimeout 设置单个请求的超时时间, 优先于构造函数的timeout参数
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.timeout = &timeout
+	}
+}
+
+// WithUserAgent 设置请求的User-Agent, 便于在APIServer审计日志中识别调用方
+func WithUserAgent(userAgent string) Option {
+	return func(o *clientOptions) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithCredentialProvider 设置token续期来源. 客户端将在token过期前(默认提前5分钟, 可通过WithTokenExpiryNotifier调整)
+// 从provider获取新token并原地替换, 无需重建客户端. 构造时已有的bearer token作为初始token使用.
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return func(o *clientOptions) {
+		o.credentialProvider = provider
+		if o.tokenExpiryLead == 0 {
+			o.tokenExpiryLead = 5 * time.Minute
+		}
+	}
+}
+
+// WithTokenExpiryNotifier 在bearer token(JWT)过期前 `lead` 时间调用notifier, 用于告警或主动更换凭证.
+// 仅对能解析出exp声明的token生效.
+func WithTokenExpiryNotifier(lead time.Duration, notifier TokenExpiryNotifier) Option {
+	return func(o *clientOptions) {
+		o.tokenExpiryLead = lead
+		o.tokenExpiryNotifier = notifier
+	}
+}
+
+// WithImpersonation 使客户端的所有请求以指定用户身份执行(需要调用方凭证具备impersonate权限).
+// groups, uid, extra 均为可选.
+func WithImpersonation(user string, groups []string, uid string, extra map[string][]string) Option {
+	return func(o *clientOptions) {
+		o.impersonate = &rest.ImpersonationConfig{
+			UserName: user,
+			UID:      uid,
+			Groups:   groups,
+			Extra:    extra,
+		}
+	}
+}
+
+// withoutImpersonation 清除此前的 WithImpersonation, 用于以其他身份派生客户端
+func withoutImpersonation() Option {
+	return func(o *clientOptions) {
+		o.impersonate = nil
+	}
+}
+
+// WithProxyURL 通过HTTP CONNECT或SOCKS5代理访问APIServer, 如 `http://proxy.example.com:3128`, `socks5://127.0.0.1:1080`.
+// 代理设置作用于标准客户端, 动态客户端, metrics客户端以及controller-runtime cluster.
+func WithProxyURL(proxyURL string) Option {
+	return func(o *clientOptions) {
+		o.proxyURL = proxyURL
+	}
+}
+
+// WithTransportMiddleware 注册http.RoundTripper中间件, 用于请求签名, 添加企业认证头等.
+// 可多次调用, 按注册顺序组成调用链, 先注册的最先处理请求.
+// 中间件作用于该GenericK8sClient创建的所有客户端, 位于client-go认证处理之后, 因此可以读取最终的认证头.
+func WithTransportMiddleware(middlewares ...func(http.RoundTripper) http.RoundTripper) Option {
+	return func(o *clientOptions) {
+		for _, m := range middlewares {
+			if m != nil {
+				o.transportMiddlewares = append(o.transportMiddlewares, m)
+			}
+		}
+	}
+}
+
+// WithLazyConnect 跳过构造时的连通性检查, 使不可达集群的客户端也能立即创建.
+// 连接错误将在首次请求或Start时暴露, 也可调用 Connect 主动检查.
+func WithLazyConnect() Option {
+	return func(o *clientOptions) {
+		o.lazyConnect = true
+	}
+}
+
+// WithUncachedObjects 使controller-runtime client读取指定类型的对象时直接请求APIServer, 不为其建立informer缓存,
+// 如 `WithUncachedObjects(&corev1.Secret{}, &corev1.ConfigMap{})`, 避免大量Secret/ConfigMap常驻内存.
+func WithUncachedObjects(objs ...client.Object) Option {
+	return func(o *clientOptions) {
+		o.uncachedObjects = append(o.uncachedObjects, objs...)
+	}
+}
+
+// WithCacheNamespaces 将controller-runtime cache限制在指定的namespace集合内, 而非缓存整个集群的对象,
+// 以降低大型共享集群上的内存占用. 集群级别对象不受影响. 读取其他namespace中的对象将返回错误.
+func WithCacheNamespaces(namespaces ...string) Option {
+	return func(o *clientOptions) {
+		o.cacheNamespaces = append(o.cacheNamespaces, namespaces...)
+	}
+}
+
+// WithProtobuf 使标准客户端(kubernetes.Clientset)使用protobuf编码与APIServer通信, 在大规模集群上显著降低list/watch的带宽与CPU开销.
+// controller-runtime client 对内置类型默认已使用protobuf, 对CR及unstructured对象始终使用JSON; 动态客户端与metrics客户端不受影响.
+func WithProtobuf() Option {
+	return func(o *clientOptions) {
+		o.protobuf = true
+	}
+}
@@ -0,0 +1,158 @@
+package k8sclientkit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// 插件扩展点可观察到的工具包操作
+const (
+	OperationApply  = "apply"
+	OperationDelete = "delete"
+)
+
+// Plugin 是第三方扩展的基础接口. 插件通过实现以下一个或多个扩展接口接入客户端:
+//
+//   - TransportMiddleware: 包装发往APIServer的请求
+//   - ObjectMutator: apply前修改对象
+//   - PolicyChecker: apply/delete前检查并可拒绝操作
+//   - EventSink: 接收工具包操作事件
+//   - CredentialProvider: 提供bearer token(未通过 WithCredentialProvider 指定时生效)
+//
+// 插件可通过 RegisterPlugin 全局注册(作用于之后创建的所有客户端), 或通过 WithPlugins 为单个客户端注册.
+type Plugin interface {
+	// Name 为插件名称, 全局注册时作为唯一标识
+	Name() string
+}
+
+// TransportMiddleware 插件包装客户端的http.RoundTripper, 作用同 WithTransportMiddleware
+type TransportMiddleware interface {
+	Plugin
+	WrapTransport(clusterId string, rt http.RoundTripper) http.RoundTripper
+}
+
+// ObjectMutator 插件在apply前修改对象, 如注入标签或注解. 返回错误将中止apply.
+type ObjectMutator interface {
+	Plugin
+	MutateObject(ctx context.Context, clusterId string, obj *unstructured.Unstructured) error
+}
+
+// PolicyChecker 插件在apply/delete(operation为 OperationApply/OperationDelete)前检查对象, 返回错误将拒绝该操作.
+// 检查在所有ObjectMutator执行之后进行.
+type PolicyChecker interface {
+	Plugin
+	CheckObject(ctx context.Context, clusterId, operation string, obj *unstructured.Unstructured) error
+}
+
+// KitEvent 为工具包操作成功后产生的事件
+type KitEvent struct {
+	ClusterId string `json:"clusterId"`
+	// Reason 同Kubernetes Event的Reason, 如 AppliedByKit, DeletedByKit
+	Reason   string `json:"reason"`
+	Message  string `json:"message"`
+	BundleID string `json:"bundleId,omitempty"`
+	// RequestID 为操作的请求ID(见 EnsureRequestID), 用于关联同一次操作在各集群中的请求与日志
+	RequestID string                     `json:"requestId,omitempty"`
+	Object    *unstructured.Unstructured `json:"object,omitempty"`
+	Time      time.Time                  `json:"time"`
+}
+
+// EventSink 插件接收工具包操作事件, 在操作goroutine中同步调用, 实现方应避免阻塞
+type EventSink interface {
+	Plugin
+	HandleEvent(ctx context.Context, event KitEvent)
+}
+
+var (
+	pluginsLock sync.RWMutex
+	plugins     []Plugin
+)
+
+// RegisterPlugin 全局注册插件, 作用于之后创建的所有客户端. 同名插件将被替换.
+func RegisterPlugin(p Plugin) {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+	for i, existing := range plugins {
+		if existing.Name() == p.Name() {
+			plugins[i] = p
+			return
+		}
+	}
+	plugins = append(plugins, p)
+}
+
+// RegisteredPlugins 返回全局注册的插件
+func RegisteredPlugins() []Plugin {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+	return append([]Plugin{}, plugins...)
+}
+
+// WithPlugins 为客户端注册插件, 在全局注册的插件之后生效
+func WithPlugins(ps ...Plugin) Option {
+	return func(o *clientOptions) {
+		o.plugins = append(o.plugins, ps...)
+	}
+}
+
+// Plugins 返回客户端生效的全部插件(全局注册的在前)
+func (c *GenericK8sClient) Plugins() []Plugin {
+	return c.plugins
+}
+
+// resolvePlugins 合并全局注册的插件与选项中的插件, 并接入transport与凭证扩展点
+func (o *clientOptions) resolvePlugins() {
+	o.plugins = append(RegisteredPlugins(), o.plugins...)
+	for _, p := range o.plugins {
+		if provider, ok := p.(CredentialProvider); ok && o.credentialProvider == nil {
+			WithCredentialProvider(provider)(o)
+		}
+	}
+}
+
+// wrapPluginTransports 按插件顺序包装transport, 先注册的插件最先处理请求
+func (o *clientOptions) wrapPluginTransports(clusterId string) func(rt http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		for i := len(o.plugins) - 1; i >= 0; i-- {
+			if middleware, ok := o.plugins[i].(TransportMiddleware); ok {
+				rt = middleware.WrapTransport(clusterId, rt)
+			}
+		}
+		return rt
+	}
+}
+
+// runObjectPlugins 依次执行ObjectMutator与PolicyChecker
+func (c *GenericK8sClient) runObjectPlugins(ctx context.Context, operation string, obj *unstructured.Unstructured) error {
+	if operation == OperationApply {
+		for _, p := range c.plugins {
+			if mutator, ok := p.(ObjectMutator); ok {
+				if err := mutator.MutateObject(ctx, c.TargetK8sApiServerId, obj); err != nil {
+					return errors.Wrap(err, "插件 "+p.Name()+" 修改对象失败")
+				}
+			}
+		}
+	}
+	for _, p := range c.plugins {
+		if checker, ok := p.(PolicyChecker); ok {
+			if err := checker.CheckObject(ctx, c.TargetK8sApiServerId, operation, obj); err != nil {
+				return errors.Wrap(err, "插件 "+p.Name()+" 拒绝了 "+operation+" 操作")
+			}
+		}
+	}
+	return nil
+}
+
+// dispatchKitEvent 将事件发送给所有EventSink插件
+func (c *GenericK8sClient) dispatchKitEvent(ctx context.Context, event KitEvent) {
+	for _, p := range c.plugins {
+		if sink, ok := p.(EventSink); ok {
+			sink.HandleEvent(ctx, event)
+		}
+	}
+}
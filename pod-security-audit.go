@@ -0,0 +1,268 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PodSecurityLevel 为Pod Security Standards定义的安全级别
+type PodSecurityLevel string
+
+const (
+	PodSecurityBaseline   PodSecurityLevel = "baseline"
+	PodSecurityRestricted PodSecurityLevel = "restricted"
+)
+
+// baseline级别允许额外添加的capabilities
+var baselineAllowedCapabilities = map[corev1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true, "KILL": true, "MKNOD": true,
+	"NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true, "SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+}
+
+// PodSecurityViolation 描述一项违反Pod Security Standards的配置
+type PodSecurityViolation struct {
+	// Level 为该检查项所属的最低安全级别
+	Level PodSecurityLevel `json:"level"`
+	// Check 为检查项名称, 如 privileged, hostPath, runAsNonRoot
+	Check string `json:"check"`
+	// Container 为违规的容器名, Pod级别的配置为空
+	Container string `json:"container,omitempty"`
+	Message   string `json:"message"`
+}
+
+// WorkloadSecurityReport 为单个工作负载(Deployment, StatefulSet, DaemonSet, Job等, 无控制器时为Pod本身)的审计结果
+type WorkloadSecurityReport struct {
+	Namespace  string                 `json:"namespace"`
+	Kind       string                 `json:"kind"`
+	Name       string                 `json:"name"`
+	Pods       []string               `json:"pods"`
+	Violations []PodSecurityViolation `json:"violations"`
+}
+
+// AuditPodSecurity 按Pod Security Standards的 `level` 级别审计namespace中(空字符串表示全部namespace)匹配selector的Pod,
+// 按所属工作负载汇总违规项, 仅返回存在违规的工作负载.
+//
+// 优先从controller-runtime缓存读取(需已Start), 缓存未启动时直接请求APIServer.
+func (c *GenericK8sClient) AuditPodSecurity(ctx context.Context, namespace string, selector labels.Selector, level PodSecurityLevel) ([]*WorkloadSecurityReport, error) {
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	pods := &corev1.PodList{}
+	if err := c.readList(ctx, pods, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.Wrap(err, "无法列出Pod")
+	}
+
+	reports := map[string]*WorkloadSecurityReport{}
+	seen := map[string]map[PodSecurityViolation]bool{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		violations := CheckPodSecurity(&pod.Spec, level)
+		if len(violations) == 0 {
+			continue
+		}
+		kind, name := c.podWorkload(ctx, pod)
+		key := pod.Namespace + "/" + kind + "/" + name
+		report, ok := reports[key]
+		if !ok {
+			report = &WorkloadSecurityReport{Namespace: pod.Namespace, Kind: kind, Name: name}
+			reports[key] = report
+			seen[key] = map[PodSecurityViolation]bool{}
+		}
+		report.Pods = append(report.Pods, pod.Name)
+		// 同一工作负载的多个副本违规项相同, 去重
+		for _, v := range violations {
+			if !seen[key][v] {
+				seen[key][v] = true
+				report.Violations = append(report.Violations, v)
+			}
+		}
+	}
+
+	result := make([]*WorkloadSecurityReport, 0, len(reports))
+	for _, report := range reports {
+		result = append(result, report)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// readList 优先从缓存读取列表, 缓存未启动时退化为直接请求APIServer
+func (c *GenericK8sClient) readList(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	err := c.GetRuntimeCluster().GetClient().List(ctx, list, opts...)
+	if isCacheNotStarted(err) {
+		return c.GetRuntimeCluster().GetAPIReader().List(ctx, list, opts...)
+	}
+	return err
+}
+
+// readObject 优先从缓存读取对象, 缓存未启动时退化为直接请求APIServer
+func (c *GenericK8sClient) readObject(ctx context.Context, key types.NamespacedName, obj client.Object) error {
+	err := c.GetRuntimeCluster().GetClient().Get(ctx, key, obj)
+	if isCacheNotStarted(err) {
+		return c.GetRuntimeCluster().GetAPIReader().Get(ctx, key, obj)
+	}
+	return err
+}
+
+func isCacheNotStarted(err error) bool {
+	var notStarted *cache.ErrCacheNotStarted
+	return errors.As(err, &notStarted)
+}
+
+// podWorkload 返回Pod所属的工作负载, ReplicaSet会进一步解析到其所属的Deployment
+func (c *GenericK8sClient) podWorkload(ctx context.Context, pod *corev1.Pod) (kind, name string) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return "Pod", pod.Name
+	}
+	if owner.Kind == "ReplicaSet" {
+		rs := &appsv1.ReplicaSet{}
+		if err := c.readObject(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: owner.Name}, rs); err == nil {
+			if rsOwner := metav1.GetControllerOf(rs); rsOwner != nil {
+				return rsOwner.Kind, rsOwner.Name
+			}
+		}
+	}
+	return owner.Kind, owner.Name
+}
+
+// CheckPodSecurity 按Pod Security Standards检查PodSpec, level为restricted时同时包含baseline检查项
+func CheckPodSecurity(spec *corev1.PodSpec, level PodSecurityLevel) []PodSecurityViolation {
+	var violations []PodSecurityViolation
+	add := func(l PodSecurityLevel, check, container, message string) {
+		violations = append(violations, PodSecurityViolation{Level: l, Check: check, Container: container, Message: message})
+	}
+	restricted := level == PodSecurityRestricted
+
+	// baseline: Pod级别
+	if spec.HostNetwork {
+		add(PodSecurityBaseline, "hostNetwork", "", "使用了hostNetwork")
+	}
+	if spec.HostPID {
+		add(PodSecurityBaseline, "hostPID", "", "使用了hostPID")
+	}
+	if spec.HostIPC {
+		add(PodSecurityBaseline, "hostIPC", "", "使用了hostIPC")
+	}
+	for _, volume := range spec.Volumes {
+		if volume.HostPath != nil {
+			add(PodSecurityBaseline, "hostPath", "", "volume "+volume.Name+" 使用了hostPath: "+volume.HostPath.Path)
+		} else if restricted && !restrictedVolumeAllowed(volume) {
+			add(PodSecurityRestricted, "volumeTypes", "", "volume "+volume.Name+" 的类型不在restricted允许范围内")
+		}
+	}
+	podSC := spec.SecurityContext
+	if podSC == nil {
+		podSC = &corev1.PodSecurityContext{}
+	}
+	if podSC.SeccompProfile != nil && podSC.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		add(PodSecurityBaseline, "seccompProfile", "", "Pod的seccompProfile为Unconfined")
+	}
+	if podSC.RunAsUser != nil && *podSC.RunAsUser == 0 && restricted {
+		add(PodSecurityRestricted, "runAsUser", "", "Pod以root用户(UID 0)运行")
+	}
+
+	for _, container := range allContainers(spec) {
+		sc := container.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+		if sc.Privileged != nil && *sc.Privileged {
+			add(PodSecurityBaseline, "privileged", container.Name, "容器以特权模式运行")
+		}
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				add(PodSecurityBaseline, "hostPorts", container.Name, "容器使用了hostPort")
+				break
+			}
+		}
+		if sc.ProcMount != nil && *sc.ProcMount != corev1.DefaultProcMount {
+			add(PodSecurityBaseline, "procMount", container.Name, "容器使用了非默认的procMount")
+		}
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			add(PodSecurityBaseline, "seccompProfile", container.Name, "容器的seccompProfile为Unconfined")
+		}
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Add {
+				switch {
+				case !baselineAllowedCapabilities[capability]:
+					add(PodSecurityBaseline, "capabilities", container.Name, "容器添加了capability "+string(capability))
+				case restricted && capability != "NET_BIND_SERVICE":
+					add(PodSecurityRestricted, "capabilities", container.Name, "restricted级别仅允许添加NET_BIND_SERVICE, 容器添加了 "+string(capability))
+				}
+			}
+		}
+		if !restricted {
+			continue
+		}
+
+		// restricted: 容器级别
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			add(PodSecurityRestricted, "allowPrivilegeEscalation", container.Name, "容器未设置allowPrivilegeEscalation=false")
+		}
+		runAsNonRoot := podSC.RunAsNonRoot
+		if sc.RunAsNonRoot != nil {
+			runAsNonRoot = sc.RunAsNonRoot
+		}
+		if runAsNonRoot == nil || !*runAsNonRoot {
+			add(PodSecurityRestricted, "runAsNonRoot", container.Name, "容器未设置runAsNonRoot=true")
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			add(PodSecurityRestricted, "runAsUser", container.Name, "容器以root用户(UID 0)运行")
+		}
+		seccomp := podSC.SeccompProfile
+		if sc.SeccompProfile != nil {
+			seccomp = sc.SeccompProfile
+		}
+		if seccomp == nil || (seccomp.Type != corev1.SeccompProfileTypeRuntimeDefault && seccomp.Type != corev1.SeccompProfileTypeLocalhost) {
+			add(PodSecurityRestricted, "seccompProfile", container.Name, "容器未设置RuntimeDefault或Localhost类型的seccompProfile")
+		}
+		if sc.Capabilities == nil || !dropsAllCapabilities(sc.Capabilities.Drop) {
+			add(PodSecurityRestricted, "capabilities", container.Name, "容器未drop ALL capabilities")
+		}
+	}
+	return violations
+}
+
+func allContainers(spec *corev1.PodSpec) []corev1.Container {
+	containers := append([]corev1.Container{}, spec.InitContainers...)
+	containers = append(containers, spec.Containers...)
+	for _, ephemeral := range spec.EphemeralContainers {
+		containers = append(containers, corev1.Container(ephemeral.EphemeralContainerCommon))
+	}
+	return containers
+}
+
+func dropsAllCapabilities(drop []corev1.Capability) bool {
+	for _, capability := range drop {
+		if strings.EqualFold(string(capability), "ALL") {
+			return true
+		}
+	}
+	return false
+}
+
+// restrictedVolumeAllowed 判断volume类型是否在restricted级别允许范围内
+func restrictedVolumeAllowed(volume corev1.Volume) bool {
+	v := volume.VolumeSource
+	return v.ConfigMap != nil || v.CSI != nil || v.DownwardAPI != nil || v.EmptyDir != nil || v.Ephemeral != nil ||
+		v.PersistentVolumeClaim != nil || v.Projected != nil || v.Secret != nil
+}
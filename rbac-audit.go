@@ -0,0 +1,240 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RBACCheck 为一项待检查的权限, 对应SubjectAccessReview的ResourceAttributes
+type RBACCheck struct {
+	Verb        string `json:"verb"`
+	Group       string `json:"group,omitempty"`
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource,omitempty"`
+	// Namespace 为空时检查集群范围的权限
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// String 返回如 `list apps/deployments in default` 的描述
+func (c RBACCheck) String() string {
+	s := c.Verb + " "
+	if len(c.Group) > 0 {
+		s += c.Group + "/"
+	}
+	s += c.Resource
+	if len(c.Subresource) > 0 {
+		s += "/" + c.Subresource
+	}
+	if len(c.Name) > 0 {
+		s += " " + c.Name
+	}
+	if len(c.Namespace) > 0 {
+		s += " in " + c.Namespace
+	}
+	return s
+}
+
+func (c RBACCheck) resourceAttributes() *authorizationv1.ResourceAttributes {
+	return &authorizationv1.ResourceAttributes{
+		Verb:        c.Verb,
+		Group:       c.Group,
+		Resource:    c.Resource,
+		Subresource: c.Subresource,
+		Namespace:   c.Namespace,
+		Name:        c.Name,
+	}
+}
+
+// RBACSubject 为被审计的主体
+type RBACSubject struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// ServiceAccountSubject 返回ServiceAccount对应的主体, 包括其隐含的用户组
+func ServiceAccountSubject(namespace, name string) *RBACSubject {
+	return &RBACSubject{
+		User:   "system:serviceaccount:" + namespace + ":" + name,
+		Groups: []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace, "system:authenticated"},
+	}
+}
+
+// RBACAuditOptions 为 AuditRBAC 的参数
+type RBACAuditOptions struct {
+	// ClusterIDs 为空时审计全部已注册集群
+	ClusterIDs []string
+	// Subject 为空时审计各集群客户端自身的凭证(SelfSubjectAccessReview), 否则以SubjectAccessReview审计该主体,
+	// 此时客户端凭证需要create subjectaccessreviews的权限
+	Subject *RBACSubject
+	// Checks 为逐项检查的权限, 结果构成集群×权限的矩阵
+	Checks []RBACCheck
+	// RulesNamespaces 非空时同时以SelfSubjectRulesReview获取客户端自身凭证在这些namespace中的全部规则, 仅在Subject为空时有效
+	RulesNamespaces []string
+}
+
+// RBACCheckResult 为一项权限在一个集群中的检查结果
+type RBACCheckResult struct {
+	Allowed bool `json:"allowed"`
+	// Denied 表示鉴权器明确拒绝, 为false且Allowed为false时表示没有鉴权器给出意见
+	Denied          bool   `json:"denied,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	EvaluationError string `json:"evaluationError,omitempty"`
+}
+
+// RBACNamespaceRules 为SelfSubjectRulesReview返回的一个namespace中的规则
+type RBACNamespaceRules struct {
+	ResourceRules    []authorizationv1.ResourceRule    `json:"resourceRules"`
+	NonResourceRules []authorizationv1.NonResourceRule `json:"nonResourceRules,omitempty"`
+	// Incomplete 表示鉴权器(如webhook)无法列出全部规则, 实际权限可能多于规则
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// RBACAuditMatrix 为 AuditRBAC 的结果
+type RBACAuditMatrix struct {
+	// Subject 为空表示审计的是各集群客户端自身的凭证
+	Subject *RBACSubject `json:"subject,omitempty"`
+	// Clusters 为成功审计的集群, 按标识排序
+	Clusters []string    `json:"clusters"`
+	Checks   []RBACCheck `json:"checks"`
+	// Results 以集群标识为key, 每个元素与Checks一一对应
+	Results map[string][]RBACCheckResult `json:"results"`
+	// Rules 以集群标识及namespace为key
+	Rules map[string]map[string]*RBACNamespaceRules `json:"rules,omitempty"`
+	// Errors 为审计失败的集群及其错误, 这些集群不出现在Clusters中
+	Errors map[string]error `json:"-"`
+}
+
+// Allowed 返回集群中第i项权限是否被允许, 集群未审计时返回false
+func (m *RBACAuditMatrix) Allowed(clusterID string, i int) bool {
+	results, ok := m.Results[clusterID]
+	return ok && i < len(results) && results[i].Allowed
+}
+
+// Missing 返回每个集群中未被允许的权限, 没有缺失权限的集群不出现在结果中
+func (m *RBACAuditMatrix) Missing() map[string][]RBACCheck {
+	missing := map[string][]RBACCheck{}
+	for _, id := range m.Clusters {
+		for i, result := range m.Results[id] {
+			if !result.Allowed {
+				missing[id] = append(missing[id], m.Checks[i])
+			}
+		}
+	}
+	return missing
+}
+
+// Inconsistent 返回在各集群间结果不一致(部分集群允许, 部分不允许)的权限
+func (m *RBACAuditMatrix) Inconsistent() []RBACCheck {
+	var inconsistent []RBACCheck
+	for i, check := range m.Checks {
+		allowed := 0
+		for _, id := range m.Clusters {
+			if m.Allowed(id, i) {
+				allowed++
+			}
+		}
+		if allowed > 0 && allowed < len(m.Clusters) {
+			inconsistent = append(inconsistent, check)
+		}
+	}
+	return inconsistent
+}
+
+// AuditRBAC 在多个已注册集群中并行检查同一组权限, 返回集群×权限的矩阵, 用于在批量下发ServiceAccount及其授权后核对各集群的实际权限.
+// 部分集群失败时仍返回其余集群的结果, 同时返回汇总错误.
+func (r *ClusterRegistry) AuditRBAC(ctx context.Context, opts RBACAuditOptions) (*RBACAuditMatrix, error) {
+	matrix := &RBACAuditMatrix{
+		Subject: opts.Subject,
+		Checks:  opts.Checks,
+		Results: map[string][]RBACCheckResult{},
+	}
+	var lock sync.Mutex
+	exec, err := r.FleetExec(ctx, opts.ClusterIDs, func(ctx context.Context, cli *GenericK8sClient) error {
+		results, err := cli.checkAccess(ctx, opts.Subject, opts.Checks)
+		if err != nil {
+			return err
+		}
+		var rules map[string]*RBACNamespaceRules
+		if opts.Subject == nil && len(opts.RulesNamespaces) > 0 {
+			if rules, err = cli.selfRules(ctx, opts.RulesNamespaces); err != nil {
+				return err
+			}
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		matrix.Results[cli.TargetK8sApiServerId] = results
+		if rules != nil {
+			if matrix.Rules == nil {
+				matrix.Rules = map[string]map[string]*RBACNamespaceRules{}
+			}
+			matrix.Rules[cli.TargetK8sApiServerId] = rules
+		}
+		return nil
+	}, FleetExecOptions{})
+	matrix.Clusters = exec.Succeeded
+	matrix.Errors = exec.Failed
+	sort.Strings(matrix.Clusters)
+	return matrix, err
+}
+
+// checkAccess 逐项检查权限, subject为空时检查客户端自身的凭证
+func (c *GenericK8sClient) checkAccess(ctx context.Context, subject *RBACSubject, checks []RBACCheck) ([]RBACCheckResult, error) {
+	authz := c.GetStandardClient().AuthorizationV1()
+	results := make([]RBACCheckResult, 0, len(checks))
+	for _, check := range checks {
+		var status authorizationv1.SubjectAccessReviewStatus
+		if subject == nil {
+			review, err := authz.SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: check.resourceAttributes()},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				return nil, c.clusterError(errors.Wrap(err, "无法检查权限 "+check.String()), authorizationv1.SchemeGroupVersion.WithKind("SelfSubjectAccessReview"))
+			}
+			status = review.Status
+		} else {
+			review, err := authz.SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+				Spec: authorizationv1.SubjectAccessReviewSpec{
+					User:               subject.User,
+					Groups:             subject.Groups,
+					ResourceAttributes: check.resourceAttributes(),
+				},
+			}, metav1.CreateOptions{})
+			if err != nil {
+				return nil, c.clusterError(errors.Wrap(err, "无法检查权限 "+check.String()), authorizationv1.SchemeGroupVersion.WithKind("SubjectAccessReview"))
+			}
+			status = review.Status
+		}
+		results = append(results, RBACCheckResult{
+			Allowed:         status.Allowed,
+			Denied:          status.Denied,
+			Reason:          status.Reason,
+			EvaluationError: status.EvaluationError,
+		})
+	}
+	return results, nil
+}
+
+// selfRules 以SelfSubjectRulesReview获取客户端自身凭证在各namespace中的规则
+func (c *GenericK8sClient) selfRules(ctx context.Context, namespaces []string) (map[string]*RBACNamespaceRules, error) {
+	rules := make(map[string]*RBACNamespaceRules, len(namespaces))
+	for _, ns := range namespaces {
+		review, err := c.GetStandardClient().AuthorizationV1().SelfSubjectRulesReviews().Create(ctx, &authorizationv1.SelfSubjectRulesReview{
+			Spec: authorizationv1.SelfSubjectRulesReviewSpec{Namespace: ns},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, c.clusterError(errors.Wrap(err, "无法获取namespace "+ns+" 的权限规则"), authorizationv1.SchemeGroupVersion.WithKind("SelfSubjectRulesReview"))
+		}
+		rules[ns] = &RBACNamespaceRules{
+			ResourceRules:    review.Status.ResourceRules,
+			NonResourceRules: review.Status.NonResourceRules,
+			Incomplete:       review.Status.Incomplete,
+		}
+	}
+	return rules, nil
+}
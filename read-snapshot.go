@@ -0,0 +1,122 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ReadSnapshot 将一次操作(如bundle的diff/apply)期间的所有读取固定在同一个resourceVersion上,
+// 避免集群快速变化时基于新旧混杂的状态做出决策.
+// 第一次list以quorum read方式读取最新状态并记录其resourceVersion, 之后的读取均使用该resourceVersion精确读取(resourceVersionMatch=Exact).
+type ReadSnapshot struct {
+	lock            sync.Mutex
+	resourceVersion string
+	// pinLock 保证并发读取时只有一个请求执行quorum read并固定resourceVersion
+	pinLock sync.Mutex
+}
+
+type readSnapshotKey struct{}
+
+// ContextWithReadSnapshot 返回开启一致性快照读取的ctx, 使用该ctx调用的 GetUnstructuredObj, ListAcrossNamespaces
+// 读取同一时刻的集群状态. 也可传入已固定resourceVersion的快照, 使多个操作共享同一视图.
+// 快照依赖APIServer保留的历史版本(etcd compaction窗口, 默认5分钟), 过期后读取返回 ErrSnapshotExpired.
+func ContextWithReadSnapshot(ctx context.Context, snapshot *ReadSnapshot) (context.Context, *ReadSnapshot) {
+	if snapshot == nil {
+		snapshot = &ReadSnapshot{}
+	}
+	return context.WithValue(ctx, readSnapshotKey{}, snapshot), snapshot
+}
+
+// NewReadSnapshotAt 创建固定在指定resourceVersion上的快照, 通常为之前某次list返回的resourceVersion
+func NewReadSnapshotAt(resourceVersion string) *ReadSnapshot {
+	return &ReadSnapshot{resourceVersion: resourceVersion}
+}
+
+// ReadSnapshotFromContext 读取ctx中的快照
+func ReadSnapshotFromContext(ctx context.Context) (*ReadSnapshot, bool) {
+	snapshot, ok := ctx.Value(readSnapshotKey{}).(*ReadSnapshot)
+	return snapshot, ok && snapshot != nil
+}
+
+// ResourceVersion 返回快照固定的resourceVersion, 尚未发生读取时为空
+func (s *ReadSnapshot) ResourceVersion() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.resourceVersion
+}
+
+// pin 在快照尚未固定时记录resourceVersion, 返回最终固定的值
+func (s *ReadSnapshot) pin(resourceVersion string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.resourceVersion) == 0 {
+		s.resourceVersion = resourceVersion
+	}
+	return s.resourceVersion
+}
+
+// ErrSnapshotExpired 表示快照的resourceVersion已被etcd压缩, 需要开启新的快照重新读取
+var ErrSnapshotExpired = errors.New("一致性快照已过期")
+
+// snapshotList 在ctx开启快照时按快照resourceVersion执行list, 否则直接list.
+// 快照尚未固定时, 首个请求以quorum read读取并固定其返回的resourceVersion.
+func snapshotList(ctx context.Context, list func(opts metav1.ListOptions) (*unstructured.UnstructuredList, error), opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	snapshot, ok := ReadSnapshotFromContext(ctx)
+	if !ok || len(opts.Continue) > 0 {
+		// 分页请求的continue token本身已固定resourceVersion
+		return list(opts)
+	}
+
+	if len(snapshot.ResourceVersion()) == 0 {
+		snapshot.pinLock.Lock()
+		defer snapshot.pinLock.Unlock()
+	}
+	if rv := snapshot.ResourceVersion(); len(rv) > 0 {
+		opts.ResourceVersion = rv
+		opts.ResourceVersionMatch = metav1.ResourceVersionMatchExact
+	} else {
+		opts.ResourceVersion = ""
+		opts.ResourceVersionMatch = ""
+	}
+	result, err := list(opts)
+	if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+		return nil, errors.Wrap(ErrSnapshotExpired, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot.pin(result.GetResourceVersion())
+	return result, nil
+}
+
+// GetUnstructuredObj 读取gvk类型的指定对象. ctx开启了一致性快照(ContextWithReadSnapshot)时,
+// 以字段选择器list的方式读取快照时刻的对象状态, 对象在该时刻不存在时返回NotFound错误.
+func (c *GenericK8sClient) GetUnstructuredObj(ctx context.Context, gvk schema.GroupVersionKind, namespace, name string) (*unstructured.Unstructured, error) {
+	gvr, err := c.GvkToGvr(gvk)
+	if err != nil {
+		return nil, err
+	}
+	ri := namespacedResource(c.GetDynamicClient(), gvr, namespace)
+	if _, ok := ReadSnapshotFromContext(ctx); !ok {
+		obj, err := ri.Get(ctx, name, metav1.GetOptions{})
+		return obj, c.clusterError(err, gvk)
+	}
+
+	list, err := snapshotList(ctx, func(opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+		return ri.List(ctx, opts)
+	}, metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String()})
+	if err != nil {
+		return nil, c.clusterError(err, gvk)
+	}
+	if len(list.Items) == 0 {
+		return nil, c.clusterError(apierrors.NewNotFound(gvr.GroupResource(), name), gvk)
+	}
+	return &list.Items[0], nil
+}
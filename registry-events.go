@@ -0,0 +1,175 @@
+package k8sclientkit
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultRegistryMonitorInterval 为ClusterRegistry检查各集群健康与凭证状态的默认间隔
+const DefaultRegistryMonitorInterval = 10 * time.Second
+
+// ClusterEventType 为集群生命周期事件类型
+type ClusterEventType string
+
+const (
+	// ClusterRegistered 集群被注册到registry
+	ClusterRegistered ClusterEventType = "ClusterRegistered"
+	// ClusterRemoved 集群从registry中移除(Remove或StopAll)
+	ClusterRemoved ClusterEventType = "ClusterRemoved"
+	// ClusterUnhealthy 健康探测由正常(或未知)变为失败, 需要客户端设置 WithHealthProbe
+	ClusterUnhealthy ClusterEventType = "ClusterUnhealthy"
+	// ClusterHealthy 健康探测由失败恢复正常
+	ClusterHealthy ClusterEventType = "ClusterHealthy"
+	// ClusterCredentialExpired bearer token已过期, 或APIServer拒绝了当前凭证
+	ClusterCredentialExpired ClusterEventType = "ClusterCredentialExpired"
+	// ClusterRepointed 集群标识对应的集群UID发生变化, 即入口被指向了另一个集群(见 ClusterRegistry.Fingerprints)
+	ClusterRepointed ClusterEventType = "ClusterRepointed"
+	// ClusterIdleEvicted 客户端空闲超过TTL, runtime cluster已停止(见 WithIdleEviction)
+	ClusterIdleEvicted ClusterEventType = "ClusterIdleEvicted"
+)
+
+// ClusterEvent 为registry发出的集群生命周期事件
+type ClusterEvent struct {
+	Type      ClusterEventType
+	ClusterId string
+	Time      time.Time
+	// Client 为注册的客户端, 仅 ClusterRegistered 事件设置
+	Client *GenericK8sClient
+	// Health 为事件发生时的健康状态, 仅健康与凭证相关事件设置
+	Health *HealthStatus
+	// Err 为导致事件的错误, 可能为nil
+	Err error
+	// Shutdown 为true表示 ClusterRemoved 由 StopAll 产生, 而不是通过 Remove 移除
+	Shutdown bool
+	// Replaced 为true表示 ClusterRegistered 由 Replace 产生, 替换了同一标识下已注册的客户端
+	Replaced bool
+}
+
+// RegistryOption 用于调整ClusterRegistry的行为
+type RegistryOption func(r *ClusterRegistry)
+
+// WithRegistryMonitorInterval 设置检查各集群健康与凭证状态的间隔, 默认为 DefaultRegistryMonitorInterval
+func WithRegistryMonitorInterval(interval time.Duration) RegistryOption {
+	return func(r *ClusterRegistry) {
+		if interval > 0 {
+			r.monitorInterval = interval
+		}
+	}
+}
+
+// clusterMonitorState 记录上次检查时的状态, 用于只在状态变化时发出事件
+type clusterMonitorState struct {
+	unhealthy bool
+	expired   bool
+	// uid 为最近一次获取的集群UID
+	uid string
+}
+
+// Subscribe 订阅集群生命周期事件. buffer 为channel缓冲大小, 订阅方处理不及时导致缓冲区满时丢弃事件.
+// 调用返回的cancel取消订阅并关闭channel. 存在订阅者时registry在后台检查各集群的健康与凭证状态, 直到 StopAll.
+func (r *ClusterRegistry) Subscribe(buffer int) (<-chan ClusterEvent, func()) {
+	ch := make(chan ClusterEvent, buffer)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	id := r.nextSubscriber
+	r.nextSubscriber++
+	r.subscribers[id] = ch
+	r.ensureMonitor()
+
+	return ch, func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		if _, ok := r.subscribers[id]; ok {
+			delete(r.subscribers, id)
+			close(ch)
+		}
+	}
+}
+
+// ensureMonitor 在存在订阅者或回调(或启用了空闲回收)且检查未运行时启动检查, 调用方需持有r.lock
+func (r *ClusterRegistry) ensureMonitor() {
+	if r.monitor != nil || (len(r.subscribers) == 0 && len(r.hooks) == 0 && r.idleTTL <= 0) {
+		return
+	}
+	r.monitor = newRunGroup(context.Background(), "cluster-registry", nil)
+	r.monitor.goRoutine("registry-monitor", r.monitorLoop)
+}
+
+// publish 向所有订阅者与回调发送事件, 调用方需持有r.lock
+func (r *ClusterRegistry) publish(event ClusterEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, hook := range r.hooks {
+		hook.enqueue(event)
+	}
+	for _, ch := range r.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// monitorLoop 定期检查各集群状态, 在健康或凭证状态变化时发出事件, 并回收空闲的runtime cluster
+func (r *ClusterRegistry) monitorLoop(ctx context.Context) error {
+	ticker := time.NewTicker(r.monitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		for _, cli := range r.List() {
+			r.checkCluster(cli)
+			if r.idleTTL > 0 && time.Since(cli.LastUsed()) > r.idleTTL && cli.evictRuntimeCluster() {
+				r.lock.Lock()
+				r.publish(ClusterEvent{Type: ClusterIdleEvicted, ClusterId: cli.TargetK8sApiServerId})
+				r.lock.Unlock()
+			}
+		}
+	}
+}
+
+func (r *ClusterRegistry) checkCluster(cli *GenericK8sClient) {
+	id := cli.TargetK8sApiServerId
+	health := cli.HealthStatus()
+	probed := !health.LastProbe.IsZero()
+	unhealthy := probed && !(health.Reachable && health.Ready)
+
+	var expiredErr error
+	if expiresAt, err := cli.TokenExpiresAt(); err == nil && time.Now().After(expiresAt) {
+		expiredErr = errors.New("bearer token已于 " + expiresAt.Format(time.RFC3339) + " 过期")
+	} else if errors.Is(health.LastError, ErrAuthExpired) {
+		expiredErr = health.LastError
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, registered := r.clients[id]; !registered {
+		return
+	}
+	state, ok := r.monitorStates[id]
+	if !ok {
+		state = &clusterMonitorState{}
+		r.monitorStates[id] = state
+	}
+	if unhealthy != state.unhealthy && probed {
+		state.unhealthy = unhealthy
+		eventType := ClusterHealthy
+		if unhealthy {
+			eventType = ClusterUnhealthy
+		}
+		r.publish(ClusterEvent{Type: eventType, ClusterId: id, Health: &health, Err: health.LastError})
+	}
+	expired := expiredErr != nil
+	if expired != state.expired {
+		state.expired = expired
+		if expired {
+			r.publish(ClusterEvent{Type: ClusterCredentialExpired, ClusterId: id, Health: &health, Err: expiredErr})
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package k8sclientkit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// registryHook 按顺序异步执行回调. 事件进入无界队列, 不会像 Subscribe 的channel那样在处理不及时时丢弃,
+// 也不会阻塞registry的其他操作.
+type registryHook struct {
+	fn func(ClusterEvent)
+
+	lock   sync.Mutex
+	cond   *sync.Cond
+	queue  []ClusterEvent
+	closed bool
+	done   chan struct{}
+}
+
+func newRegistryHook(fn func(ClusterEvent)) *registryHook {
+	h := &registryHook{fn: fn, done: make(chan struct{})}
+	h.cond = sync.NewCond(&h.lock)
+	go h.run()
+	return h
+}
+
+func (h *registryHook) enqueue(event ClusterEvent) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.closed {
+		return
+	}
+	h.queue = append(h.queue, event)
+	h.cond.Signal()
+}
+
+// close 停止接收新事件, 已入队的事件执行完后退出
+func (h *registryHook) close() {
+	h.lock.Lock()
+	h.closed = true
+	h.cond.Signal()
+	h.lock.Unlock()
+}
+
+func (h *registryHook) run() {
+	defer close(h.done)
+	for {
+		h.lock.Lock()
+		for len(h.queue) == 0 && !h.closed {
+			h.cond.Wait()
+		}
+		if len(h.queue) == 0 {
+			h.lock.Unlock()
+			return
+		}
+		event := h.queue[0]
+		h.queue = h.queue[1:]
+		h.lock.Unlock()
+		h.fn(event)
+	}
+}
+
+// OnClusterEvent 注册集群生命周期事件的回调, 返回取消注册的函数. 回调在独立的goroutine中按事件顺序执行,
+// 事件不会因回调处理慢而丢弃, 回调中可以调用registry的方法. 注册时已存在的集群会先收到 ClusterRegistered 事件,
+// 便于下游控制器统一在回调中为每个集群启动worker. 取消注册后已入队的事件仍会执行.
+func (r *ClusterRegistry) OnClusterEvent(fn func(event ClusterEvent)) func() {
+	hook := newRegistryHook(fn)
+	r.lock.Lock()
+	ids := make([]string, 0, len(r.clients))
+	for id := range r.clients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		hook.enqueue(ClusterEvent{Type: ClusterRegistered, ClusterId: id, Time: time.Now(), Client: r.clients[id]})
+	}
+	id := r.nextSubscriber
+	r.nextSubscriber++
+	r.hooks[id] = hook
+	r.ensureMonitor()
+	r.lock.Unlock()
+
+	return func() {
+		r.lock.Lock()
+		delete(r.hooks, id)
+		r.lock.Unlock()
+		hook.close()
+	}
+}
+
+// OnClusterAdded 在集群注册时调用fn, 注册回调时已存在的集群同样会调用, 返回取消注册的函数
+func (r *ClusterRegistry) OnClusterAdded(fn func(cli *GenericK8sClient)) func() {
+	return r.OnClusterEvent(func(event ClusterEvent) {
+		if event.Type == ClusterRegistered {
+			fn(event.Client)
+		}
+	})
+}
+
+// OnClusterRemoved 在集群被移除(Remove或StopAll)时调用fn, 返回取消注册的函数
+func (r *ClusterRegistry) OnClusterRemoved(fn func(clusterId string)) func() {
+	return r.OnClusterEvent(func(event ClusterEvent) {
+		if event.Type == ClusterRemoved {
+			fn(event.ClusterId)
+		}
+	})
+}
+
+// OnClusterUnhealthy 在集群健康探测由正常变为失败时调用fn(需要客户端设置 WithHealthProbe), 返回取消注册的函数
+func (r *ClusterRegistry) OnClusterUnhealthy(fn func(clusterId string, health HealthStatus)) func() {
+	return r.OnClusterEvent(func(event ClusterEvent) {
+		if event.Type == ClusterUnhealthy && event.Health != nil {
+			fn(event.ClusterId, *event.Health)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package k8sclientkit
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	// DefaultRegistryLeaseName 为registry选主使用的Lease的默认名称
+	DefaultRegistryLeaseName = "k8s-client-kit-registry"
+	// 选主的默认时间参数, 与controller-runtime一致
+	DefaultLeaderElectionLeaseDuration = 15 * time.Second
+	DefaultLeaderElectionRenewDeadline = 10 * time.Second
+	DefaultLeaderElectionRetryPeriod   = 2 * time.Second
+)
+
+// ErrLeadershipLost 表示 RunLeaderElected 在ctx结束前失去了leader身份
+var ErrLeadershipLost = errors.New("已失去leader身份")
+
+// LeaderElectionOptions 为 RunLeaderElected 的参数, 除Client外零值均使用默认值
+type LeaderElectionOptions struct {
+	// Client 为Lease所在的管理集群的客户端, 不应是registry中的客户端(失去leader身份时registry中的客户端会被关闭)
+	Client *GenericK8sClient
+	// Namespace 为Lease所在namespace, 默认为 `default`
+	Namespace string
+	// LeaseName 为Lease名称, 默认为 DefaultRegistryLeaseName. 同一服务的各副本必须使用相同的名称
+	LeaseName string
+	// Identity 为当前副本的标识, 默认为 `<hostname>-<随机uuid>`
+	Identity string
+	// LeaseDuration, RenewDeadline, RetryPeriod 含义同client-go leaderelection
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+	// OnNewLeader 在观察到leader变化时调用, 包括当前副本成为leader
+	OnNewLeader func(identity string)
+}
+
+// IsLeader 返回当前副本是否为leader, 未通过 RunLeaderElected 运行时始终为false
+func (r *ClusterRegistry) IsLeader() bool {
+	return r.leading.Load()
+}
+
+// RunLeaderElected 基于管理集群中的Lease选主, 保证同一服务的多个副本中只有leader在下游集群上运行watcher与控制器.
+// 阻塞直至ctx结束, 失去leader身份或run返回:
+//
+//   - 成为leader后调用 StartAll, 并以leader身份对应的ctx运行run, run 通常运行 ClusterController 或 FleetWatcher, 应随ctx结束返回
+//   - 失去leader身份或ctx结束时取消run的ctx, 等待run返回后调用 StopAll 关闭全部下游客户端, 之后释放Lease以便其他副本立即接管
+//
+// 失去leader身份时返回 ErrLeadershipLost, 调用方可以重新调用以参与下一轮选主(registry由run重新填充), 或直接退出进程.
+// run 返回的错误会使当前副本放弃leader身份并原样返回.
+func (r *ClusterRegistry) RunLeaderElected(ctx context.Context, opts LeaderElectionOptions, run func(ctx context.Context) error) error {
+	if opts.Client == nil {
+		return errors.New("选主必须指定管理集群的客户端")
+	}
+	if len(opts.Namespace) == 0 {
+		opts.Namespace = metav1.NamespaceDefault
+	}
+	if len(opts.LeaseName) == 0 {
+		opts.LeaseName = DefaultRegistryLeaseName
+	}
+	if len(opts.Identity) == 0 {
+		hostname, _ := os.Hostname()
+		opts.Identity = hostname + "-" + string(uuid.NewUUID())
+	}
+	if opts.LeaseDuration <= 0 {
+		opts.LeaseDuration = DefaultLeaderElectionLeaseDuration
+	}
+	if opts.RenewDeadline <= 0 {
+		opts.RenewDeadline = DefaultLeaderElectionRenewDeadline
+	}
+	if opts.RetryPeriod <= 0 {
+		opts.RetryPeriod = DefaultLeaderElectionRetryPeriod
+	}
+
+	// 选主不直接随ctx结束: 否则ctx结束时client-go释放Lease与 StopAll 同时进行, 其他副本可能在下游客户端停止前接管.
+	// 由本函数在 StopAll 返回后取消
+	electionCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	started := make(chan context.Context, 1)
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      opts.LeaseName,
+				Namespace: opts.Namespace,
+				Labels:    map[string]string{ManagedByLabel: ManagedByValue},
+			},
+			Client:     opts.Client.GetStandardClient().CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: opts.Identity},
+		},
+		LeaseDuration:   opts.LeaseDuration,
+		RenewDeadline:   opts.RenewDeadline,
+		RetryPeriod:     opts.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            opts.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leaderCtx context.Context) {
+				started <- leaderCtx
+			},
+			OnStoppedLeading: func() {},
+			OnNewLeader:      opts.OnNewLeader,
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "无法创建选主器")
+	}
+	electorDone := make(chan struct{})
+	go func() {
+		defer close(electorDone)
+		elector.Run(electionCtx)
+	}()
+
+	var leaderCtx context.Context
+	select {
+	case leaderCtx = <-started:
+	case <-ctx.Done():
+		// 未运行run, 无需清理registry
+		cancel()
+		<-electorDone
+		return ctx.Err()
+	case <-electorDone:
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return errors.Wrap(ErrLeadershipLost, "Lease "+opts.Namespace+"/"+opts.LeaseName)
+	}
+
+	// run 的ctx在失去leader身份或调用方ctx结束时取消
+	runCtx, runCancel := context.WithCancel(leaderCtx)
+	defer runCancel()
+	stopRunOnDone := context.AfterFunc(ctx, runCancel)
+	defer stopRunOnDone()
+
+	r.leading.Store(true)
+	r.StartAll()
+	runErr := run(runCtx)
+	lost := leaderCtx.Err() != nil && ctx.Err() == nil
+	// 先停止下游客户端再释放Lease, 避免其他副本接管后与仍在运行的watcher/控制器同时工作
+	r.leading.Store(false)
+	r.StopAll()
+	// StopAll 返回后才放弃leader身份, 等待Lease释放
+	cancel()
+	<-electorDone
+
+	switch {
+	case lost:
+		return errors.Wrap(ErrLeadershipLost, "Lease "+opts.Namespace+"/"+opts.LeaseName)
+	case runErr != nil:
+		return runErr
+	default:
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,435 @@
+package k8sclientkit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// RegistryClusterLabel 标记 SecretRegistryStore 管理的Secret
+	RegistryClusterLabel = "k8s-client-kit.io/registry-cluster"
+	// ClusterIDAnnotation 在Secret上记录集群标识, 不存在时以Secret名称作为集群标识
+	ClusterIDAnnotation = "k8s-client-kit.io/cluster-id"
+)
+
+// registryStoreTimeout 为持久化单个集群的超时时间
+const registryStoreTimeout = 30 * time.Second
+
+// kubeConfigFileExtensions 为 DirRegistryStore 识别的文件扩展名, 集群标识为去掉扩展名后的文件名
+var kubeConfigFileExtensions = []string{".kubeconfig", ".yaml", ".yml", ".conf"}
+
+// RegistryStore 持久化registry中的集群定义, 内容为每个集群的kubeconfig
+type RegistryStore interface {
+	// Load 返回全部集群的kubeconfig, key为集群标识. 部分条目无法读取时返回其余条目与汇总错误
+	Load(ctx context.Context) (map[string][]byte, error)
+	// Save 保存集群的kubeconfig, 内容未变化时不做修改
+	Save(ctx context.Context, clusterId string, kubeConfig []byte) error
+	// Delete 删除集群, 不存在时不返回错误
+	Delete(ctx context.Context, clusterId string) error
+}
+
+// ExportKubeConfig 以当前凭证导出只包含一个context的kubeconfig, 用于持久化客户端以便重启后恢复.
+// CA, 证书与token文件以路径形式保留; 通过 CredentialProvider 续期的token只导出当前值, 恢复时需重新传入对应的Option.
+// 凭证仅由transport包装器提供(如 NewGenericK8sClientWithEKSContext 等云厂商认证)时无法导出, 返回错误.
+func (c *GenericK8sClient) ExportKubeConfig() ([]byte, error) {
+	c.configLock.RLock()
+	config := c.baseRestConfig
+	c.configLock.RUnlock()
+	if len(config.Host) == 0 {
+		return nil, errors.New("集群 " + c.TargetK8sApiServerId + " 未设置APIServer地址")
+	}
+
+	authInfo := &clientcmdapi.AuthInfo{
+		ClientCertificate:     config.TLSClientConfig.CertFile,
+		ClientCertificateData: config.TLSClientConfig.CertData,
+		ClientKey:             config.TLSClientConfig.KeyFile,
+		ClientKeyData:         config.TLSClientConfig.KeyData,
+		Token:                 config.BearerToken,
+		TokenFile:             config.BearerTokenFile,
+		Username:              config.Username,
+		Password:              config.Password,
+		Exec:                  config.ExecProvider,
+		AuthProvider:          config.AuthProvider,
+	}
+	if c.token != nil {
+		authInfo.Token = c.token.current()
+	}
+	if config.WrapTransport != nil && !hasExportableCredentials(authInfo) {
+		// 凭证由transport包装器注入(如EKS/GKE/AKS的token source), 无法写入kubeconfig, 导出结果恢复后无法认证
+		return nil, errors.New("集群 " + c.TargetK8sApiServerId + " 的凭证由transport包装器(" + c.AuthType + ")提供, 无法导出为kubeconfig")
+	}
+	if len(config.Impersonate.UserName) > 0 {
+		authInfo.Impersonate = config.Impersonate.UserName
+		authInfo.ImpersonateUID = config.Impersonate.UID
+		authInfo.ImpersonateGroups = config.Impersonate.Groups
+		authInfo.ImpersonateUserExtra = config.Impersonate.Extra
+	}
+	kubeConfig := singleClusterKubeConfig(&clientcmdapi.Cluster{
+		Server:                   config.Host,
+		TLSServerName:            config.TLSClientConfig.ServerName,
+		InsecureSkipTLSVerify:    config.TLSClientConfig.Insecure,
+		CertificateAuthority:     config.TLSClientConfig.CAFile,
+		CertificateAuthorityData: config.TLSClientConfig.CAData,
+	}, authInfo)
+	data, err := clientcmd.Write(*kubeConfig)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法序列化集群 "+c.TargetK8sApiServerId+" 的kubeconfig")
+	}
+	return data, nil
+}
+
+// hasExportableCredentials 判断authInfo中是否包含任一可写入kubeconfig的凭证
+func hasExportableCredentials(authInfo *clientcmdapi.AuthInfo) bool {
+	return len(authInfo.ClientCertificate) > 0 || len(authInfo.ClientCertificateData) > 0 ||
+		len(authInfo.Token) > 0 || len(authInfo.TokenFile) > 0 || len(authInfo.Username) > 0 ||
+		authInfo.Exec != nil || authInfo.AuthProvider != nil
+}
+
+// WithRegistryStore 将registry中集群的注册与移除持久化到store: 注册时保存 ExportKubeConfig 的结果, Remove 时删除.
+// StopAll(包括 RunLeaderElected 失去leader身份)产生的移除不会删除已持久化的集群. 通过 LoadFromStore 加载的客户端不会重复保存.
+// 持久化在registry的事件回调中按顺序异步执行, 失败时调用onError(可以为nil). 凭证无法导出的客户端(见 ExportKubeConfig)
+// 不会被保存, 同样通过onError报告.
+// 由 ClusterController 维护的registry以Cluster资源为准, 不应再使用store.
+func WithRegistryStore(store RegistryStore, onError func(clusterId string, err error)) RegistryOption {
+	return func(r *ClusterRegistry) {
+		report := func(id string, err error) {
+			if err != nil && onError != nil {
+				onError(id, err)
+			}
+		}
+		hook := newRegistryHook(func(event ClusterEvent) {
+			ctx, cancel := context.WithTimeout(context.Background(), registryStoreTimeout)
+			defer cancel()
+			switch {
+			case event.Type == ClusterRegistered:
+				r.lock.Lock()
+				loaded := r.storeLoaded[event.ClusterId] == event.Client
+				if loaded {
+					// 标记仅用于跳过首次保存, 消费后删除, 避免引用客户端
+					delete(r.storeLoaded, event.ClusterId)
+				}
+				r.lock.Unlock()
+				if loaded {
+					return
+				}
+				// 以其他客户端重新注册, 清除旧客户端的标记
+				r.dropStoreLoaded(event.ClusterId)
+				kubeConfig, err := event.Client.ExportKubeConfig()
+				if err == nil {
+					err = store.Save(ctx, event.ClusterId, kubeConfig)
+				}
+				report(event.ClusterId, err)
+			case event.Type == ClusterRemoved:
+				r.dropStoreLoaded(event.ClusterId)
+				if !event.Shutdown {
+					report(event.ClusterId, store.Delete(ctx, event.ClusterId))
+				}
+			}
+		})
+		r.hooks[r.nextSubscriber] = hook
+		r.nextSubscriber++
+	}
+}
+
+// dropStoreLoaded 删除已不在registry中的客户端的加载标记(集群被移除或以新客户端重新注册).
+// 事件异步处理期间同一标识可能已由 LoadFromStore 重新加载并注册, 此时保留标记
+func (r *ClusterRegistry) dropStoreLoaded(id string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if cli, ok := r.storeLoaded[id]; ok && r.clients[id] != cli {
+		delete(r.storeLoaded, id)
+	}
+}
+
+// LoadFromStore 为store中的每个集群创建客户端并注册, 用于服务启动时恢复集群. 已注册的集群被跳过.
+// 部分集群无法创建或注册时继续处理其余集群, 返回成功注册的集群标识与汇总错误. 客户端的生命周期由registry管理, 不随ctx结束.
+func (r *ClusterRegistry) LoadFromStore(ctx context.Context, store RegistryStore, opts ...Option) ([]string, error) {
+	entries, err := store.Load(ctx)
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	var loaded []string
+	clientCtx := context.WithoutCancel(ctx)
+	for _, id := range sortedKeys(entries) {
+		if _, exists := r.Get(id); exists {
+			continue
+		}
+		cli, err := NewGenericK8sClientWithKubeConfigBytesContext(clientCtx, id, entries[id], "", nil, opts...)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "无法恢复集群 "+id))
+			continue
+		}
+		r.lock.Lock()
+		r.storeLoaded[id] = cli
+		r.lock.Unlock()
+		if err := r.Register(cli); err != nil {
+			r.lock.Lock()
+			if r.storeLoaded[id] == cli {
+				delete(r.storeLoaded, id)
+			}
+			r.lock.Unlock()
+			cli.Close()
+			errs = append(errs, err)
+			continue
+		}
+		loaded = append(loaded, id)
+	}
+	return loaded, utilerrors.NewAggregate(errs)
+}
+
+// DirRegistryStore 以目录中的kubeconfig文件保存集群, 每个文件一个集群.
+// 加载时集群标识为去掉扩展名(.kubeconfig, .yaml, .yml, .conf)后的文件名, 忽略子目录与隐藏文件;
+// 保存时优先覆盖该集群已有的文件, 否则创建 `<集群标识>.kubeconfig`(标识经过路径转义).
+type DirRegistryStore struct {
+	Dir string
+}
+
+func (s *DirRegistryStore) Load(ctx context.Context) (map[string][]byte, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return map[string][]byte{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "无法读取目录:"+s.Dir)
+	}
+	result := map[string][]byte{}
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		id, err := url.PathUnescape(trimKubeConfigExtension(entry.Name()))
+		if err != nil {
+			id = trimKubeConfigExtension(entry.Name())
+		}
+		data, err := os.ReadFile(filepath.Join(s.Dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "无法读取kubeconfig文件:"+entry.Name()))
+			continue
+		}
+		result[id] = data
+	}
+	return result, utilerrors.NewAggregate(errs)
+}
+
+func (s *DirRegistryStore) Save(ctx context.Context, clusterId string, kubeConfig []byte) error {
+	path := s.existingPath(clusterId)
+	if len(path) == 0 {
+		path = filepath.Join(s.Dir, url.PathEscape(clusterId)+kubeConfigFileExtensions[0])
+	} else if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, kubeConfig) {
+		return nil
+	}
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return errors.Wrap(err, "无法创建目录:"+s.Dir)
+	}
+	// 先写入临时文件再重命名, 避免进程退出时留下不完整的文件
+	tmp, err := os.CreateTemp(s.Dir, ".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "无法创建临时文件")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(kubeConfig); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "无法写入kubeconfig文件")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "无法写入kubeconfig文件")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrap(err, "无法保存kubeconfig文件:"+path)
+	}
+	return nil
+}
+
+func (s *DirRegistryStore) Delete(ctx context.Context, clusterId string) error {
+	path := s.existingPath(clusterId)
+	if len(path) == 0 {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "无法删除kubeconfig文件:"+path)
+	}
+	return nil
+}
+
+// existingPath 返回集群已有的kubeconfig文件路径, 不存在时返回空字符串
+func (s *DirRegistryStore) existingPath(clusterId string) string {
+	for _, name := range []string{url.PathEscape(clusterId), clusterId} {
+		for _, ext := range kubeConfigFileExtensions {
+			path := filepath.Join(s.Dir, name+ext)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+func trimKubeConfigExtension(name string) string {
+	for _, ext := range kubeConfigFileExtensions {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// SecretRegistryStore 以管理集群中带有标签的Secret保存集群, 每个Secret一个集群, kubeconfig保存在Key中.
+// 集群标识记录在 ClusterIDAnnotation 中, 因此已有的Secret只需添加标签(及可选的注解)即可被加载.
+type SecretRegistryStore struct {
+	Client    *GenericK8sClient
+	Namespace string
+	// LabelSelector 为加载时的标签选择器, 默认为 `k8s-client-kit.io/registry-cluster=true`. 保存的Secret总是带有该标签
+	LabelSelector string
+	// Key 为kubeconfig在Secret data中的key, 默认为 DefaultKubeConfigSecretKey
+	Key string
+}
+
+func (s *SecretRegistryStore) Load(ctx context.Context) (map[string][]byte, error) {
+	secrets, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string][]byte{}
+	var errs []error
+	for i := range secrets {
+		secret := &secrets[i]
+		data, ok := secret.Data[s.key()]
+		if !ok {
+			errs = append(errs, errors.New("Secret "+secret.Namespace+"/"+secret.Name+" 中没有 "+s.key()))
+			continue
+		}
+		result[secretClusterID(secret)] = data
+	}
+	return result, utilerrors.NewAggregate(errs)
+}
+
+func (s *SecretRegistryStore) Save(ctx context.Context, clusterId string, kubeConfig []byte) error {
+	secrets := s.Client.GetStandardClient().CoreV1().Secrets(s.Namespace)
+	existing, err := s.find(ctx, clusterId)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if bytes.Equal(existing.Data[s.key()], kubeConfig) {
+			return nil
+		}
+		existing = existing.DeepCopy()
+		if existing.Data == nil {
+			existing.Data = map[string][]byte{}
+		}
+		existing.Data[s.key()] = kubeConfig
+		if _, err := secrets.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return errors.Wrap(err, "无法更新集群 "+clusterId+" 的Secret")
+		}
+		return nil
+	}
+	_, err = secrets.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      registrySecretName(clusterId),
+			Namespace: s.Namespace,
+			Labels: map[string]string{
+				RegistryClusterLabel: "true",
+				ManagedByLabel:       ManagedByValue,
+			},
+			Annotations: map[string]string{ClusterIDAnnotation: clusterId},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{s.key(): kubeConfig},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "无法创建集群 "+clusterId+" 的Secret")
+	}
+	return nil
+}
+
+func (s *SecretRegistryStore) Delete(ctx context.Context, clusterId string) error {
+	existing, err := s.find(ctx, clusterId)
+	if err != nil || existing == nil {
+		return err
+	}
+	err = s.Client.GetStandardClient().CoreV1().Secrets(s.Namespace).Delete(ctx, existing.Name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &existing.UID},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "无法删除集群 "+clusterId+" 的Secret")
+	}
+	return nil
+}
+
+func (s *SecretRegistryStore) key() string {
+	if len(s.Key) == 0 {
+		return DefaultKubeConfigSecretKey
+	}
+	return s.Key
+}
+
+func (s *SecretRegistryStore) list(ctx context.Context) ([]corev1.Secret, error) {
+	selector := s.LabelSelector
+	if len(selector) == 0 {
+		selector = RegistryClusterLabel + "=true"
+	}
+	list, err := s.Client.GetStandardClient().CoreV1().Secrets(s.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出namespace "+s.Namespace+" 中的集群Secret")
+	}
+	return list.Items, nil
+}
+
+// find 返回集群对应的Secret, 不存在时返回nil
+func (s *SecretRegistryStore) find(ctx context.Context, clusterId string) (*corev1.Secret, error) {
+	secrets, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range secrets {
+		if secretClusterID(&secrets[i]) == clusterId {
+			return &secrets[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func secretClusterID(secret *corev1.Secret) string {
+	if id := secret.Annotations[ClusterIDAnnotation]; len(id) > 0 {
+		return id
+	}
+	return secret.Name
+}
+
+// registrySecretName 由集群标识生成合法的Secret名称: 规范化后的标识加上标识hash的前8位, 避免不同标识规范化后冲突
+func registrySecretName(clusterId string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(clusterId) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	name := strings.Trim(b.String(), "-")
+	if len(name) > 40 {
+		name = strings.TrimRight(name[:40], "-")
+	}
+	sum := sha256.Sum256([]byte(clusterId))
+	if len(name) == 0 {
+		return "cluster-" + hex.EncodeToString(sum[:])[:8]
+	}
+	return "cluster-" + name + "-" + hex.EncodeToString(sum[:])[:8]
+}
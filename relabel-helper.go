@@ -0,0 +1,161 @@
+package k8sclientkit
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// RelabelOptions 为RelabelResources的可选参数
+type RelabelOptions struct {
+	// Namespace 为空时处理所有namespace
+	Namespace string
+	// Concurrency 最大并发patch数, 默认为 DefaultListConcurrency
+	Concurrency int
+	// DryRun 以server-side dry-run方式执行patch, 不实际修改对象
+	DryRun bool
+	// Progress 每处理完一个对象调用一次(串行调用)
+	Progress func(progress RelabelProgress)
+}
+
+// RelabelProgress 为RelabelResources的进度信息
+type RelabelProgress struct {
+	// Object 为刚处理完的对象标识(ObjectIdentity)
+	Object string
+	// Changed 表示对象标签需要修改(DryRun时表示将被修改)
+	Changed bool
+	Err     error
+	Done    int
+	Total   int
+}
+
+// RelabelResult 为RelabelResources的结果汇总
+type RelabelResult struct {
+	Matched   int
+	Patched   int
+	Unchanged int
+	// Failed 以ObjectIdentity为key记录patch失败的对象
+	Failed map[string]error
+}
+
+// RelabelResources 为gvrs中所有匹配labelSelector的对象批量添加addLabels并移除removeLabels中的标签,
+// 标签已符合要求的对象不会被patch. 单个对象失败不会中断其余对象, 失败记录在结果的Failed中.
+func (c *GenericK8sClient) RelabelResources(ctx context.Context, gvrs []schema.GroupVersionResource, labelSelector string, addLabels map[string]string, removeLabels []string, opts RelabelOptions) (*RelabelResult, error) {
+	ctx, _ = EnsureRequestID(ctx)
+	type relabelTarget struct {
+		gvr schema.GroupVersionResource
+		obj *unstructured.Unstructured
+	}
+	var targets []relabelTarget
+	for _, gvr := range gvrs {
+		items, err := c.listAllPages(ctx, gvr, opts.Namespace, metav1.ListOptions{LabelSelector: labelSelector})
+		if err != nil {
+			return nil, errors.Wrap(err, "无法列出 "+gvr.String())
+		}
+		for i := range items {
+			targets = append(targets, relabelTarget{gvr: gvr, obj: &items[i]})
+		}
+	}
+
+	patch, err := json.Marshal(relabelPatch(addLabels, removeLabels))
+	if err != nil {
+		return nil, errors.Wrap(err, "无法生成标签patch")
+	}
+	patchOpts := metav1.PatchOptions{}
+	if opts.DryRun {
+		patchOpts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultListConcurrency
+	}
+	result := &RelabelResult{Matched: len(targets), Failed: map[string]error{}}
+	lock := sync.Mutex{}
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for _, target := range targets {
+		obj := target.obj
+		if !needsRelabel(obj, addLabels, removeLabels) {
+			lock.Lock()
+			result.Unchanged++
+			reportRelabel(opts, result, RelabelProgress{Object: ObjectIdentity(obj)})
+			lock.Unlock()
+			continue
+		}
+
+		// 先获取并发槽位再启动goroutine, ctx结束后其余对象记为失败
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			lock.Lock()
+			id := ObjectIdentity(obj)
+			result.Failed[id] = ctx.Err()
+			reportRelabel(opts, result, RelabelProgress{Object: id, Err: ctx.Err()})
+			lock.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(gvr schema.GroupVersionResource) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			_, err := namespacedResource(c.GetDynamicClient(), gvr, obj.GetNamespace()).Patch(ctx, obj.GetName(), types.MergePatchType, patch, patchOpts)
+
+			lock.Lock()
+			defer lock.Unlock()
+			id := ObjectIdentity(obj)
+			if err != nil {
+				result.Failed[id] = err
+			} else {
+				result.Patched++
+			}
+			reportRelabel(opts, result, RelabelProgress{Object: id, Changed: true, Err: err})
+		}(target.gvr)
+	}
+	wg.Wait()
+	return result, nil
+}
+
+// reportRelabel 在持有锁的情况下调用进度回调
+func reportRelabel(opts RelabelOptions, result *RelabelResult, progress RelabelProgress) {
+	if opts.Progress == nil {
+		return
+	}
+	progress.Done = result.Patched + result.Unchanged + len(result.Failed)
+	progress.Total = result.Matched
+	opts.Progress(progress)
+}
+
+// relabelPatch 生成JSON merge patch, 待移除的标签置为null
+func relabelPatch(addLabels map[string]string, removeLabels []string) map[string]interface{} {
+	labels := map[string]interface{}{}
+	for _, key := range removeLabels {
+		labels[key] = nil
+	}
+	for key, value := range addLabels {
+		labels[key] = value
+	}
+	return map[string]interface{}{"metadata": map[string]interface{}{"labels": labels}}
+}
+
+func needsRelabel(obj *unstructured.Unstructured, addLabels map[string]string, removeLabels []string) bool {
+	current := obj.GetLabels()
+	for key, value := range addLabels {
+		if existing, ok := current[key]; !ok || existing != value {
+			return true
+		}
+	}
+	for _, key := range removeLabels {
+		if _, ok := current[key]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package k8sclientkit
+
+import (
+	"context"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// DefaultRequestIDHeader 为 WithRequestID 未指定header名称时使用的请求ID头
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// RequestIDFunc 从请求ctx中提取请求ID, 返回空字符串表示ctx中没有请求ID
+type RequestIDFunc func(ctx context.Context) string
+
+// WithRequestHeaders 为客户端发出的所有请求添加固定的header, 作用于标准客户端, 动态客户端, metrics客户端以及controller-runtime cluster.
+// 请求中已存在的同名header(如认证, Content-Type)不会被覆盖. 可多次调用, 后设置的值覆盖先设置的同名header.
+func WithRequestHeaders(headers map[string]string) Option {
+	return func(o *clientOptions) {
+		if o.requestHeaders == nil {
+			o.requestHeaders = http.Header{}
+		}
+		for k, v := range headers {
+			o.requestHeaders.Set(k, v)
+		}
+	}
+}
+
+// WithRequestID 为客户端发出的所有请求添加请求ID头, 便于在审计网关及APIServer审计日志中关联同一次操作的请求.
+// header 为空时使用 DefaultRequestIDHeader; fromContext 为空时读取 ContextWithRequestID 设置的请求ID.
+// ctx中没有请求ID的请求(如informer的list/watch)使用随机生成的UUID, 保证每个请求都携带该header.
+func WithRequestID(header string, fromContext RequestIDFunc) Option {
+	return func(o *clientOptions) {
+		if len(header) == 0 {
+			header = DefaultRequestIDHeader
+		}
+		if fromContext == nil {
+			fromContext = RequestIDFromContext
+		}
+		o.requestIDHeader = header
+		o.requestIDFunc = fromContext
+	}
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID 在ctx中记录请求ID, 使用该ctx发出的请求将携带此ID(见 WithRequestID)
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext 读取 ContextWithRequestID 记录的请求ID
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// EnsureRequestID 返回带有请求ID的ctx及该ID: ctx中已有请求ID时沿用, 否则生成新的UUID.
+// 工具包的操作(apply, delete, 多集群操作等)在入口处调用, 使一次操作的所有请求, 重试以及fan-out到各集群的请求
+// 共享同一个ID, 并出现在请求头, 调试日志, 指标exemplar以及EventSink收到的 KitEvent 中.
+func EnsureRequestID(ctx context.Context) (context.Context, string) {
+	if requestID := RequestIDFromContext(ctx); len(requestID) > 0 {
+		return ctx, requestID
+	}
+	requestID := string(uuid.NewUUID())
+	return ContextWithRequestID(ctx, requestID), requestID
+}
+
+// requestIDOf 返回请求携带的请求ID: 配置了请求ID头时读取header(由 WithRequestID 写入), 否则读取ctx
+func requestIDOf(req *http.Request, header string) string {
+	if len(header) > 0 {
+		if requestID := req.Header.Get(header); len(requestID) > 0 {
+			return requestID
+		}
+	}
+	return RequestIDFromContext(req.Context())
+}
+
+// requestHeaderRoundTripper 为请求添加固定header与请求ID
+type requestHeaderRoundTripper struct {
+	headers         http.Header
+	requestIDHeader string
+	requestIDFunc   RequestIDFunc
+	next            http.RoundTripper
+}
+
+func (rt *requestHeaderRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTripper不应修改原请求
+	req = req.Clone(req.Context())
+	for k, v := range rt.headers {
+		if len(req.Header.Values(k)) == 0 {
+			req.Header[k] = append([]string{}, v...)
+		}
+	}
+	if len(rt.requestIDHeader) > 0 && len(req.Header.Get(rt.requestIDHeader)) == 0 {
+		requestID := rt.requestIDFunc(req.Context())
+		if len(requestID) == 0 {
+			requestID = string(uuid.NewUUID())
+		}
+		req.Header.Set(rt.requestIDHeader, requestID)
+	} else if len(rt.requestIDHeader) == 0 {
+		// 未设置 WithRequestID 时, 仅转发ctx中已有的请求ID
+		if requestID := RequestIDFromContext(req.Context()); len(requestID) > 0 && len(req.Header.Get(DefaultRequestIDHeader)) == 0 {
+			req.Header.Set(DefaultRequestIDHeader, requestID)
+		}
+	}
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,428 @@
+package k8sclientkit
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ResultSchemaVersion 为结果类型JSON格式的版本. 同一版本内只会新增可选字段, 不会删除或修改已有字段的含义;
+// 不兼容的修改将使用新的版本号
+const ResultSchemaVersion = "k8s-client-kit.io/v1"
+
+// 结果类型的kind, 序列化后位于 `kind` 字段
+const (
+	ResultKindApply   = "ApplyResult"
+	ResultKindDiff    = "DiffResult"
+	ResultKindCluster = "ClusterReport"
+	ResultKindDrift   = "DriftReport"
+	ResultKindRollout = "RolloutStatus"
+)
+
+// ErrUnsupportedResultSchema 结果的schemaVersion或kind不受支持
+var ErrUnsupportedResultSchema = errors.New("不支持的结果格式")
+
+// ResultMeta 为所有结果类型共有的元数据, 供非Go系统识别结果类型与格式版本
+type ResultMeta struct {
+	SchemaVersion string    `json:"schemaVersion"`
+	Kind          string    `json:"kind"`
+	GeneratedAt   time.Time `json:"generatedAt"`
+}
+
+func (m *ResultMeta) resultMeta() *ResultMeta {
+	return m
+}
+
+// VersionedResult 为带有 ResultMeta 的结果类型: ApplyResult, DiffResult, ClusterReport, DriftReport, RolloutStatus
+type VersionedResult interface {
+	resultMeta() *ResultMeta
+}
+
+func newResultMeta(kind string) ResultMeta {
+	return ResultMeta{SchemaVersion: ResultSchemaVersion, Kind: kind, GeneratedAt: time.Now().UTC()}
+}
+
+// ResultObjectRef 标识结果中的一个对象
+type ResultObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+func newResultObjectRef(obj *unstructured.Unstructured) ResultObjectRef {
+	return ResultObjectRef{APIVersion: obj.GetAPIVersion(), Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}
+}
+
+// errorString 将error转换为结果中的错误描述, nil时为空
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// ApplyResultItem 为单个对象的apply结果
+type ApplyResultItem struct {
+	Object ResultObjectRef `json:"object"`
+	// Action 为 created, configured, unchanged, pruned, applied(不区分创建与修改时) 或 failed
+	Action   string   `json:"action"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ApplyResult 为一个集群的apply结果
+type ApplyResult struct {
+	ResultMeta
+	ClusterId string `json:"clusterId"`
+	// Error 为集群级别的错误(如集群未注册), 此时Items为空
+	Error string            `json:"error,omitempty"`
+	Items []ApplyResultItem `json:"items"`
+}
+
+// Succeeded 所有对象均apply成功
+func (r *ApplyResult) Succeeded() bool {
+	if len(r.Error) > 0 {
+		return false
+	}
+	for _, item := range r.Items {
+		if item.Action == "failed" {
+			return false
+		}
+	}
+	return true
+}
+
+// NewApplyResult 由 ApplyUnstructuredObjs 等的结果生成 ApplyResult
+func NewApplyResult(clusterId string, results []*UnstructuredApplyResult) *ApplyResult {
+	result := &ApplyResult{ResultMeta: newResultMeta(ResultKindApply), ClusterId: clusterId, Items: []ApplyResultItem{}}
+	for _, r := range results {
+		item := ApplyResultItem{Action: "applied", Error: errorString(r.Error), Warnings: r.Warnings}
+		if r.ResultObject != nil {
+			item.Object = newResultObjectRef(r.ResultObject)
+		} else {
+			item.Object = ResultObjectRef{APIVersion: r.Gvk.GroupVersion().String(), Kind: r.Gvk.Kind}
+		}
+		if !r.Success {
+			item.Action = "failed"
+		}
+		result.Items = append(result.Items, item)
+	}
+	return result
+}
+
+// NewApplyResultsFromMultiCluster 由 ApplyUnstructuredObjsMultiCluster 的结果生成每个集群的 ApplyResult, 按集群标识排序
+func NewApplyResultsFromMultiCluster(results map[string]*MultiClusterApplyResult) []*ApplyResult {
+	applyResults := make([]*ApplyResult, 0, len(results))
+	for _, id := range sortedKeys(results) {
+		r := results[id]
+		result := NewApplyResult(id, append(append([]*UnstructuredApplyResult{}, r.SuccessfulResults...), r.FailedResults...))
+		result.Error = errorString(r.Err)
+		applyResults = append(applyResults, result)
+	}
+	return applyResults
+}
+
+// NewApplyResultFromPlan 由 Apply 执行计划的结果生成 ApplyResult
+func NewApplyResultFromPlan(clusterId string, results []*PlannedChangeResult) *ApplyResult {
+	result := &ApplyResult{ResultMeta: newResultMeta(ResultKindApply), ClusterId: clusterId, Items: []ApplyResultItem{}}
+	for _, r := range results {
+		item := ApplyResultItem{Object: plannedChangeRef(r.Change), Action: r.Change.Action.Verb()}
+		if r.Err != nil {
+			item.Action = "failed"
+			item.Error = r.Err.Error()
+		}
+		result.Items = append(result.Items, item)
+	}
+	return result
+}
+
+func plannedChangeRef(change *PlannedChange) ResultObjectRef {
+	return ResultObjectRef{APIVersion: change.APIVersion, Kind: change.Kind, Namespace: change.Namespace, Name: change.Name}
+}
+
+// DiffResultEntry 为一个存在差异的对象
+type DiffResultEntry struct {
+	Object ResultObjectRef `json:"object"`
+	// Status 为 onlyInSource, onlyInTarget, different(比较两个集群时), 或 create, patch, delete(比较manifest与集群时)
+	Status string `json:"status"`
+	// Changes 为字段变更描述, 格式见 DiffObjects
+	Changes []string `json:"changes,omitempty"`
+}
+
+// DiffResult 为两组对象的比较结果: 两个集群之间(见 DiffClusters), 或manifest与集群之间(见 Plan)
+type DiffResult struct {
+	ResultMeta
+	// Source 与 Target 为比较双方, 比较manifest与集群时Source为空
+	Source    string            `json:"source,omitempty"`
+	Target    string            `json:"target"`
+	Entries   []DiffResultEntry `json:"entries"`
+	Identical int               `json:"identical"`
+	// Errors 为比较失败的资源及原因
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// NewDiffResultFromClusterDiff 由 DiffClusters 的结果生成 DiffResult, Source为集群A, Target为集群B
+func NewDiffResultFromClusterDiff(report *ClusterDiffReport) *DiffResult {
+	result := &DiffResult{
+		ResultMeta: newResultMeta(ResultKindDiff),
+		Source:     report.ClusterA,
+		Target:     report.ClusterB,
+		Entries:    []DiffResultEntry{},
+		Identical:  report.Identical,
+	}
+	add := func(entries []ClusterDiffEntry, status string) {
+		for _, entry := range entries {
+			result.Entries = append(result.Entries, DiffResultEntry{Object: clusterDiffEntryRef(entry), Status: status, Changes: entry.Changes})
+		}
+	}
+	add(report.OnlyInA, "onlyInSource")
+	add(report.OnlyInB, "onlyInTarget")
+	add(report.Different, "different")
+	if len(report.Errors) > 0 {
+		result.Errors = map[string]string{}
+		for resource, err := range report.Errors {
+			result.Errors[resource] = err.Error()
+		}
+	}
+	return result
+}
+
+// clusterDiffEntryRef 由gvr字符串(`apps/v1, Resource=deployments`)与ObjectIdentity还原对象引用
+func clusterDiffEntryRef(entry ClusterDiffEntry) ResultObjectRef {
+	ref := ResultObjectRef{Namespace: entry.Namespace, Name: entry.Name}
+	if parts := strings.SplitN(entry.Identity, "/", 3); len(parts) == 3 {
+		ref.Kind = parts[1]
+	}
+	if groupVersion, _, found := strings.Cut(entry.Resource, ", Resource="); found {
+		ref.APIVersion = strings.TrimPrefix(groupVersion, "/")
+	}
+	return ref
+}
+
+// NewDiffResultFromPlan 由 Plan 的结果生成 DiffResult, 不包含no-op的对象
+func NewDiffResultFromPlan(plan *ApplyPlan) *DiffResult {
+	result := &DiffResult{ResultMeta: newResultMeta(ResultKindDiff), Target: plan.ClusterId, Entries: []DiffResultEntry{}}
+	for _, change := range plan.Changes {
+		if change.Action == PlanNoOp {
+			result.Identical++
+			continue
+		}
+		result.Entries = append(result.Entries, DiffResultEntry{Object: plannedChangeRef(change), Status: string(change.Action), Changes: change.Changes})
+	}
+	return result
+}
+
+// ClusterReport 为一个集群的状态报告
+type ClusterReport struct {
+	ResultMeta
+	ClusterId           string     `json:"clusterId"`
+	Reachable           bool       `json:"reachable"`
+	Ready               bool       `json:"ready"`
+	ServerVersion       string     `json:"serverVersion,omitempty"`
+	LatencyMillis       int64      `json:"latencyMillis"`
+	LastProbe           *time.Time `json:"lastProbe,omitempty"`
+	LastSuccess         *time.Time `json:"lastSuccess,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	Error               string     `json:"error,omitempty"`
+	TunnelError         string     `json:"tunnelError,omitempty"`
+}
+
+// NewClusterReport 由健康探测结果(见 HealthStatus)生成 ClusterReport
+func NewClusterReport(clusterId string, status HealthStatus) *ClusterReport {
+	report := &ClusterReport{
+		ResultMeta:          newResultMeta(ResultKindCluster),
+		ClusterId:           clusterId,
+		Reachable:           status.Reachable,
+		Ready:               status.Ready,
+		ServerVersion:       status.ServerVersion,
+		LatencyMillis:       status.Latency.Milliseconds(),
+		ConsecutiveFailures: status.ConsecutiveFailures,
+		Error:               errorString(status.LastError),
+		TunnelError:         errorString(status.TunnelError),
+	}
+	if !status.LastProbe.IsZero() {
+		report.LastProbe = &status.LastProbe
+	}
+	if !status.LastSuccess.IsZero() {
+		report.LastSuccess = &status.LastSuccess
+	}
+	return report
+}
+
+// DriftEntry 为一个偏离期望状态的对象
+type DriftEntry struct {
+	Object ResultObjectRef `json:"object"`
+	// Drift 为 modified(集群中的对象被修改), missing(集群中不存在) 或 unexpected(不在期望状态中但匹配prune条件)
+	Drift   string   `json:"drift"`
+	Changes []string `json:"changes,omitempty"`
+}
+
+// DriftReport 为集群相对期望状态(manifest)的偏离报告
+type DriftReport struct {
+	ResultMeta
+	ClusterId string       `json:"clusterId"`
+	Drifted   []DriftEntry `json:"drifted"`
+	InSync    int          `json:"inSync"`
+}
+
+// HasDrift 集群是否偏离期望状态
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Drifted) > 0
+}
+
+// NewDriftReport 由以期望状态计算的 Plan 结果生成 DriftReport: 需要patch的对象为modified, 需要create的为missing, 需要prune的为unexpected
+func NewDriftReport(plan *ApplyPlan) *DriftReport {
+	report := &DriftReport{ResultMeta: newResultMeta(ResultKindDrift), ClusterId: plan.ClusterId, Drifted: []DriftEntry{}}
+	for _, change := range plan.Changes {
+		entry := DriftEntry{Object: plannedChangeRef(change), Changes: change.Changes}
+		switch change.Action {
+		case PlanNoOp:
+			report.InSync++
+			continue
+		case PlanCreate:
+			entry.Drift = "missing"
+		case PlanDelete:
+			entry.Drift = "unexpected"
+		default:
+			entry.Drift = "modified"
+		}
+		report.Drifted = append(report.Drifted, entry)
+	}
+	return report
+}
+
+// RolloutStatus 为工作负载(Deployment, StatefulSet, DaemonSet等)的发布状态
+type RolloutStatus struct {
+	ResultMeta
+	ClusterId  string          `json:"clusterId,omitempty"`
+	Object     ResultObjectRef `json:"object"`
+	Generation int64           `json:"generation"`
+	// ObservedGeneration 小于Generation时控制器尚未处理最新spec
+	ObservedGeneration int64 `json:"observedGeneration"`
+	Desired            int64 `json:"desired"`
+	Updated            int64 `json:"updated"`
+	Ready              int64 `json:"ready"`
+	Available          int64 `json:"available"`
+	// Complete 表示所有副本均已更新到最新版本且就绪
+	Complete bool `json:"complete"`
+	// Conditions 为 `<类型>=<状态>` 形式的condition, 按类型排序
+	Conditions []string `json:"conditions,omitempty"`
+}
+
+// NewRolloutStatus 读取工作负载对象的发布状态. DaemonSet使用 desiredNumberScheduled, updatedNumberScheduled,
+// numberReady, numberAvailable; 其他资源使用 spec.replicas 与 status 中的 *Replicas 字段.
+// 其他资源的Complete由 IsReady 判断
+func NewRolloutStatus(clusterId string, obj *unstructured.Unstructured) *RolloutStatus {
+	status := &RolloutStatus{
+		ResultMeta: newResultMeta(ResultKindRollout),
+		ClusterId:  clusterId,
+		Object:     newResultObjectRef(obj),
+		Generation: obj.GetGeneration(),
+	}
+	status.ObservedGeneration, _ = GetObservedGeneration(obj)
+	nested := func(fields ...string) int64 {
+		value, _, _ := unstructured.NestedInt64(obj.Object, fields...)
+		return value
+	}
+	hasReplicas := false
+	switch obj.GetKind() {
+	case "DaemonSet":
+		status.Desired = nested("status", "desiredNumberScheduled")
+		status.Updated = nested("status", "updatedNumberScheduled")
+		status.Ready = nested("status", "numberReady")
+		status.Available = nested("status", "numberAvailable")
+		hasReplicas = true
+	default:
+		replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if found {
+			status.Desired = replicas
+			status.Updated = nested("status", "updatedReplicas")
+			status.Ready = nested("status", "readyReplicas")
+			status.Available = nested("status", "availableReplicas")
+			hasReplicas = true
+		}
+	}
+	for _, condition := range GetConditions(obj) {
+		status.Conditions = append(status.Conditions, condition.Type+"="+string(condition.Status))
+	}
+	sort.Strings(status.Conditions)
+
+	observed := status.ObservedGeneration >= status.Generation
+	if hasReplicas {
+		status.Complete = observed && status.Updated >= status.Desired && status.Ready >= status.Desired && status.Available >= status.Desired
+	} else {
+		status.Complete = IsReady(obj)
+	}
+	return status
+}
+
+// MarshalResult 将结果序列化为JSON. 未设置的 ResultMeta 字段(如直接构造结构体时)在序列化前补全
+func MarshalResult(result VersionedResult) ([]byte, error) {
+	meta := result.resultMeta()
+	if len(meta.Kind) == 0 {
+		meta.Kind = resultKindOf(result)
+	}
+	if len(meta.SchemaVersion) == 0 {
+		meta.SchemaVersion = ResultSchemaVersion
+	}
+	if meta.GeneratedAt.IsZero() {
+		meta.GeneratedAt = time.Now().UTC()
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法序列化"+meta.Kind)
+	}
+	return data, nil
+}
+
+func resultKindOf(result VersionedResult) string {
+	switch result.(type) {
+	case *ApplyResult:
+		return ResultKindApply
+	case *DiffResult:
+		return ResultKindDiff
+	case *ClusterReport:
+		return ResultKindCluster
+	case *DriftReport:
+		return ResultKindDrift
+	case *RolloutStatus:
+		return ResultKindRollout
+	}
+	return ""
+}
+
+// UnmarshalResult 根据JSON中的 `kind` 解析结果, 返回对应的结果类型指针(如 *ApplyResult).
+// schemaVersion不是 ResultSchemaVersion 或kind未知时返回 ErrUnsupportedResultSchema
+func UnmarshalResult(data []byte) (VersionedResult, error) {
+	meta := ResultMeta{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, errors.Wrap(err, "无法解析结果")
+	}
+	if meta.SchemaVersion != ResultSchemaVersion {
+		return nil, errors.Wrap(ErrUnsupportedResultSchema, "schemaVersion "+meta.SchemaVersion)
+	}
+	var result VersionedResult
+	switch meta.Kind {
+	case ResultKindApply:
+		result = &ApplyResult{}
+	case ResultKindDiff:
+		result = &DiffResult{}
+	case ResultKindCluster:
+		result = &ClusterReport{}
+	case ResultKindDrift:
+		result = &DriftReport{}
+	case ResultKindRollout:
+		result = &RolloutStatus{}
+	default:
+		return nil, errors.Wrap(ErrUnsupportedResultSchema, "kind "+meta.Kind)
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, errors.Wrap(err, "无法解析"+meta.Kind)
+	}
+	return result, nil
+}
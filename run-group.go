@@ -0,0 +1,187 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// backgroundErrorBuffer 为 GenericK8sClient.Errors 返回的channel缓冲大小, 缓冲区满时丢弃新的错误
+const backgroundErrorBuffer = 16
+
+// BackgroundError 描述客户端后台goroutine(cluster, token续期, 认证目录重新加载, watcher等)的异常退出
+type BackgroundError struct {
+	ClusterId string
+	// Routine 为后台goroutine名称, 如 `cluster`, `token-expiry`, `watcher:apps/v1, Resource=deployments`
+	Routine string
+	Err     error
+}
+
+func (e *BackgroundError) Error() string {
+	return "集群 " + e.ClusterId + " 后台任务 " + e.Routine + " 异常退出: " + e.Err.Error()
+}
+
+func (e *BackgroundError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BackgroundError) Cause() error {
+	return e.Err
+}
+
+// BackgroundErrorHandler 在后台goroutine返回错误或panic时被调用
+type BackgroundErrorHandler func(err *BackgroundError)
+
+// WithBackgroundErrorHandler 设置后台goroutine异常退出时的回调, 与 GenericK8sClient.Errors 同时生效.
+// 回调在出错的goroutine中同步执行, 不应阻塞, 也不应在回调中直接调用 Close(会等待该goroutine自身退出).
+func WithBackgroundErrorHandler(handler BackgroundErrorHandler) Option {
+	return func(o *clientOptions) {
+		o.backgroundErrorHandler = handler
+	}
+}
+
+// runGroup 跟踪客户端启动的所有后台goroutine: 每个goroutine具有名称, 错误与panic汇总到同一出口,
+// stop 取消ctx并等待全部goroutine退出.
+type runGroup struct {
+	clusterId string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	handler   BackgroundErrorHandler
+	errCh     chan error
+
+	wg      sync.WaitGroup
+	lock    sync.Mutex
+	stopped bool
+	running map[string]int
+}
+
+func newRunGroup(ctx context.Context, clusterId string, handler BackgroundErrorHandler) *runGroup {
+	ctx, cancel := context.WithCancel(ctx)
+	return &runGroup{
+		clusterId: clusterId,
+		ctx:       ctx,
+		cancel:    cancel,
+		handler:   handler,
+		errCh:     make(chan error, backgroundErrorBuffer),
+		running:   map[string]int{},
+	}
+}
+
+// goRoutine 以name启动受跟踪的goroutine, fn 应在ctx结束后尽快返回.
+// 返回的channel在fn返回后关闭; group已停止时不再启动, 直接返回已关闭的channel.
+func (g *runGroup) goRoutine(name string, fn func(ctx context.Context) error) <-chan struct{} {
+	done := make(chan struct{})
+	g.lock.Lock()
+	if g.stopped {
+		g.lock.Unlock()
+		close(done)
+		return done
+	}
+	g.running[name]++
+	g.wg.Add(1)
+	g.lock.Unlock()
+
+	go func() {
+		defer close(done)
+		defer g.wg.Done()
+		defer func() {
+			g.lock.Lock()
+			if g.running[name]--; g.running[name] <= 0 {
+				delete(g.running, name)
+			}
+			g.lock.Unlock()
+		}()
+		defer func() {
+			if r := recover(); r != nil {
+				g.report(name, errors.Errorf("panic: %v", r))
+			}
+		}()
+
+		if err := fn(g.ctx); err != nil && g.ctx.Err() == nil {
+			g.report(name, err)
+		}
+	}()
+	return done
+}
+
+func (g *runGroup) report(name string, err error) {
+	bgErr := &BackgroundError{ClusterId: g.clusterId, Routine: name, Err: err}
+	if g.handler != nil {
+		g.handler(bgErr)
+	}
+	select {
+	case g.errCh <- bgErr:
+	default:
+	}
+}
+
+// names 返回正在运行的goroutine名称, 同名goroutine只出现一次
+func (g *runGroup) names() []string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	names := make([]string, 0, len(g.running))
+	for name := range g.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stop 取消ctx, 拒绝启动新的goroutine并等待已启动的全部退出
+func (g *runGroup) stop() {
+	g.lock.Lock()
+	g.stopped = true
+	g.lock.Unlock()
+	g.cancel()
+	g.wg.Wait()
+}
+
+// Errors 返回接收后台goroutine异常退出错误(*BackgroundError)的channel, 缓冲区满时丢弃新的错误
+func (c *GenericK8sClient) Errors() <-chan error {
+	return c.group.errCh
+}
+
+// BackgroundRoutines 返回当前运行中的后台goroutine名称, 用于诊断
+func (c *GenericK8sClient) BackgroundRoutines() []string {
+	return c.group.names()
+}
+
+// RunWatcher 在客户端的后台任务中运行watcher, watcher随客户端 Close/Stop 停止, 无需再调用 watcher.Stop.
+func (c *GenericK8sClient) RunWatcher(w *K8sResourceWatcher) {
+	c.group.goRoutine("watcher:"+w.Gvr.String(), func(ctx context.Context) error {
+		c.watchers.add(w)
+		defer c.watchers.remove(w)
+		w.informer.Informer().Run(ctx.Done())
+		return nil
+	})
+}
+
+// runningWatchers 记录通过 RunWatcher 运行中的watcher, 用于统计缓存对象数与队列长度
+type runningWatchers struct {
+	lock     sync.Mutex
+	watchers map[*K8sResourceWatcher]struct{}
+}
+
+func (s *runningWatchers) add(w *K8sResourceWatcher) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.watchers[w] = struct{}{}
+}
+
+func (s *runningWatchers) remove(w *K8sResourceWatcher) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.watchers, w)
+}
+
+func (s *runningWatchers) list() []*K8sResourceWatcher {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	result := make([]*K8sResourceWatcher, 0, len(s.watchers))
+	for w := range s.watchers {
+		result = append(result, w)
+	}
+	return result
+}
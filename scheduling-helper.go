@@ -0,0 +1,142 @@
+package k8sclientkit
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Bind 通过 `pods/binding` 子资源将Pod绑定到指定节点, 与kube-scheduler的绑定动作一致.
+// 仅对尚未调度(spec.nodeName为空)的Pod有效.
+func (c *GenericK8sClient) Bind(ctx context.Context, pod *corev1.Pod, nodeName string) error {
+	binding := &corev1.Binding{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			UID:       pod.UID,
+		},
+		Target: corev1.ObjectReference{
+			Kind: "Node",
+			Name: nodeName,
+		},
+	}
+	err := c.GetStandardClient().CoreV1().Pods(pod.Namespace).Bind(ctx, binding, metav1.CreateOptions{})
+	if err != nil {
+		return errors.Wrap(err, "绑定Pod "+pod.Namespace+"/"+pod.Name+" 到节点 "+nodeName+" 失败")
+	}
+	return nil
+}
+
+// EvictPod 通过 `pods/eviction` 子资源驱逐Pod, 驱逐行为受PodDisruptionBudget约束.
+//
+//	gracePeriodSeconds: 可选的优雅终止时间, nil 表示使用Pod自身设置
+func (c *GenericK8sClient) EvictPod(ctx context.Context, namespace, name string, gracePeriodSeconds *int64) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		DeleteOptions: &metav1.DeleteOptions{GracePeriodSeconds: gracePeriodSeconds},
+	}
+	err := c.GetStandardClient().PolicyV1().Evictions(namespace).Evict(ctx, eviction)
+	if err != nil {
+		return errors.Wrap(err, "驱逐Pod "+namespace+"/"+name+" 失败")
+	}
+	return nil
+}
+
+// GetPodPriority 获取Pod的有效优先级.
+// 优先使用 spec.priority (已由准入控制填充), 否则根据 priorityClassName 或集群默认PriorityClass解析; 均不存在时为0.
+func (c *GenericK8sClient) GetPodPriority(ctx context.Context, pod *corev1.Pod) (int32, error) {
+	if pod.Spec.Priority != nil {
+		return *pod.Spec.Priority, nil
+	}
+
+	if len(pod.Spec.PriorityClassName) > 0 {
+		pc, err := c.GetStandardClient().SchedulingV1().PriorityClasses().Get(ctx, pod.Spec.PriorityClassName, metav1.GetOptions{})
+		if err != nil {
+			return 0, errors.Wrap(err, "无法获取PriorityClass:"+pod.Spec.PriorityClassName)
+		}
+		return pc.Value, nil
+	}
+
+	pcs, err := c.GetStandardClient().SchedulingV1().PriorityClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, errors.Wrap(err, "无法列出PriorityClass")
+	}
+	for _, pc := range pcs.Items {
+		if pc.GlobalDefault {
+			return pc.Value, nil
+		}
+	}
+	return 0, nil
+}
+
+// ListPreemptionCandidates 列出指定节点上优先级低于 `priority` 且仍在运行的Pod, 作为抢占的候选牺牲者.
+// 结果按优先级升序排列, 同优先级时启动时间较晚的Pod排在前面(与kube-scheduler选择牺牲者的顺序一致).
+func (c *GenericK8sClient) ListPreemptionCandidates(ctx context.Context, nodeName string, priority int32) ([]corev1.Pod, error) {
+	pods, err := c.GetStandardClient().CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出节点 "+nodeName+" 上的Pod")
+	}
+
+	var candidates []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if podPriority(&pod) < priority {
+			candidates = append(candidates, pod)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		pi, pj := podPriority(&candidates[i]), podPriority(&candidates[j])
+		if pi != pj {
+			return pi < pj
+		}
+		ti, tj := candidates[i].Status.StartTime, candidates[j].Status.StartTime
+		if ti == nil || tj == nil {
+			return ti == nil && tj != nil
+		}
+		return tj.Before(ti)
+	})
+	return candidates, nil
+}
+
+// SetNominatedNodeName 更新Pod的 status.nominatedNodeName, 用于抢占时标记Pod将被调度到的节点.
+// nodeName 为空时清除该字段.
+func (c *GenericK8sClient) SetNominatedNodeName(ctx context.Context, pod *corev1.Pod, nodeName string) error {
+	var nominated interface{}
+	if len(nodeName) > 0 {
+		nominated = nodeName
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{"nominatedNodeName": nominated},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.GetStandardClient().CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	if err != nil {
+		return errors.Wrap(err, "无法更新Pod "+pod.Namespace+"/"+pod.Name+" 的nominatedNodeName")
+	}
+	return nil
+}
+
+func podPriority(pod *corev1.Pod) int32 {
+	if pod.Spec.Priority != nil {
+		return *pod.Spec.Priority
+	}
+	return 0
+}
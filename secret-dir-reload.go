@@ -0,0 +1,64 @@
+package k8sclientkit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"time"
+
+	"k8s.io/client-go/rest"
+)
+
+// DefaultSecretReloadInterval 为 NewGenericK8sClientWithSecretDir 重新读取token与CA的默认间隔
+const DefaultSecretReloadInterval = time.Minute
+
+// WithSecretReloadInterval 设置 NewGenericK8sClientWithSecretDir 重新读取认证目录的间隔, 默认为 DefaultSecretReloadInterval.
+// projected service-account token 通常每小时轮换一次, 间隔应远小于token有效期.
+func WithSecretReloadInterval(interval time.Duration) Option {
+	return func(o *clientOptions) {
+		o.secretReloadInterval = interval
+	}
+}
+
+// watchSecretDir 定期重新读取认证目录中的token与ca.crt, 内容变化时原地替换客户端凭证. 随ctx结束退出.
+// 读取或替换失败时保留原凭证, 在下一周期重试.
+func (c *GenericK8sClient) watchSecretDir(ctx context.Context, dir string, token, caCert []byte, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSecretReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		newToken, err := os.ReadFile(dir + "/token")
+		if err != nil {
+			continue
+		}
+		newCaCert, err := os.ReadFile(dir + "/ca.crt")
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case !bytes.Equal(newCaCert, caCert):
+			err = c.rotateCredentials(func(config *rest.Config) {
+				config.BearerToken = string(newToken)
+				config.BearerTokenFile = ""
+				config.TLSClientConfig.CAData = newCaCert
+				config.TLSClientConfig.CAFile = ""
+			})
+		case !bytes.Equal(newToken, token):
+			err = c.RotateToken(string(newToken))
+		default:
+			continue
+		}
+		if err == nil {
+			token, caCert = newToken, newCaCert
+		}
+	}
+}
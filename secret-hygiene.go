@@ -0,0 +1,235 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// HygieneFindingType 为凭证卫生扫描发现的问题类型
+type HygieneFindingType string
+
+const (
+	// HygieneLongLivedToken 为长期有效的ServiceAccount token Secret(kubernetes.io/service-account-token)
+	HygieneLongLivedToken HygieneFindingType = "LongLivedToken"
+	// HygieneUnusedServiceAccount 为未被任何Pod或工作负载使用的ServiceAccount
+	HygieneUnusedServiceAccount HygieneFindingType = "UnusedServiceAccount"
+	// HygieneUnreferencedSecret 为未被任何Pod, 工作负载, ServiceAccount或Ingress引用的Secret
+	HygieneUnreferencedSecret HygieneFindingType = "UnreferencedSecret"
+)
+
+// hygieneIgnoredSecretTypes 为不参与未引用检查的Secret类型(由其他机制使用)
+var hygieneIgnoredSecretTypes = map[corev1.SecretType]bool{
+	corev1.SecretTypeServiceAccountToken: true,
+	corev1.SecretTypeBootstrapToken:      true,
+	"helm.sh/release.v1":                 true,
+}
+
+// HygieneFinding 为一项凭证卫生问题
+type HygieneFinding struct {
+	Type      HygieneFindingType `json:"type"`
+	Namespace string             `json:"namespace"`
+	Name      string             `json:"name"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Message   string             `json:"message"`
+}
+
+// ScanSecretHygiene 扫描namespace(空字符串表示全部namespace)中的凭证卫生问题: 长期有效的ServiceAccount token Secret,
+// 未被使用的ServiceAccount(default除外), 以及未被引用的Secret. 结果按类型, namespace, 名称排序.
+//
+// 引用关系来自Pod以及Deployment, StatefulSet, DaemonSet, ReplicaSet, Job, CronJob的Pod模板,
+// 包括serviceAccountName, volume, projected volume, env, envFrom, imagePullSecrets; 以及ServiceAccount与Ingress TLS对Secret的引用.
+func (c *GenericK8sClient) ScanSecretHygiene(ctx context.Context, namespace string) ([]*HygieneFinding, error) {
+	specs, err := c.listPodSpecs(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	usedServiceAccounts := map[string]bool{}
+	referencedSecrets := map[string]bool{}
+	for _, s := range specs {
+		saName := s.spec.ServiceAccountName
+		if len(saName) == 0 {
+			saName = "default"
+		}
+		usedServiceAccounts[s.namespace+"/"+saName] = true
+		for _, name := range podSpecSecretRefs(s.spec) {
+			referencedSecrets[s.namespace+"/"+name] = true
+		}
+	}
+
+	sc := c.GetStandardClient()
+	serviceAccounts, err := sc.CoreV1().ServiceAccounts(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出ServiceAccount")
+	}
+	ingresses, err := sc.NetworkingV1().Ingresses(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出Ingress")
+	}
+	secrets, err := sc.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出Secret")
+	}
+
+	var findings []*HygieneFinding
+	for _, sa := range serviceAccounts.Items {
+		for _, ref := range sa.Secrets {
+			referencedSecrets[sa.Namespace+"/"+ref.Name] = true
+		}
+		for _, ref := range sa.ImagePullSecrets {
+			referencedSecrets[sa.Namespace+"/"+ref.Name] = true
+		}
+		if sa.Name == "default" || usedServiceAccounts[sa.Namespace+"/"+sa.Name] {
+			continue
+		}
+		findings = append(findings, &HygieneFinding{
+			Type:      HygieneUnusedServiceAccount,
+			Namespace: sa.Namespace,
+			Name:      sa.Name,
+			CreatedAt: sa.CreationTimestamp.Time,
+			Message:   "ServiceAccount未被任何Pod或工作负载使用",
+		})
+	}
+	for _, ingress := range ingresses.Items {
+		for _, tls := range ingress.Spec.TLS {
+			referencedSecrets[ingress.Namespace+"/"+tls.SecretName] = true
+		}
+	}
+
+	for _, secret := range secrets.Items {
+		if secret.Type == corev1.SecretTypeServiceAccountToken {
+			findings = append(findings, &HygieneFinding{
+				Type:      HygieneLongLivedToken,
+				Namespace: secret.Namespace,
+				Name:      secret.Name,
+				CreatedAt: secret.CreationTimestamp.Time,
+				Message:   "ServiceAccount " + secret.Annotations[corev1.ServiceAccountNameKey] + " 的长期token, 建议改用TokenRequest签发的短期token",
+			})
+			continue
+		}
+		if hygieneIgnoredSecretTypes[secret.Type] || referencedSecrets[secret.Namespace+"/"+secret.Name] {
+			continue
+		}
+		findings = append(findings, &HygieneFinding{
+			Type:      HygieneUnreferencedSecret,
+			Namespace: secret.Namespace,
+			Name:      secret.Name,
+			CreatedAt: secret.CreationTimestamp.Time,
+			Message:   "Secret未被任何Pod, 工作负载, ServiceAccount或Ingress引用",
+		})
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return findings, nil
+}
+
+type namespacedPodSpec struct {
+	namespace string
+	spec      *corev1.PodSpec
+}
+
+// listPodSpecs 收集Pod及各类工作负载Pod模板中的PodSpec
+func (c *GenericK8sClient) listPodSpecs(ctx context.Context, namespace string) ([]namespacedPodSpec, error) {
+	sc := c.GetStandardClient()
+	var specs []namespacedPodSpec
+	add := func(ns string, spec *corev1.PodSpec) {
+		specs = append(specs, namespacedPodSpec{namespace: ns, spec: spec})
+	}
+
+	pods, err := sc.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出Pod")
+	}
+	for i := range pods.Items {
+		add(pods.Items[i].Namespace, &pods.Items[i].Spec)
+	}
+	deployments, err := sc.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出Deployment")
+	}
+	for i := range deployments.Items {
+		add(deployments.Items[i].Namespace, &deployments.Items[i].Spec.Template.Spec)
+	}
+	statefulSets, err := sc.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出StatefulSet")
+	}
+	for i := range statefulSets.Items {
+		add(statefulSets.Items[i].Namespace, &statefulSets.Items[i].Spec.Template.Spec)
+	}
+	daemonSets, err := sc.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出DaemonSet")
+	}
+	for i := range daemonSets.Items {
+		add(daemonSets.Items[i].Namespace, &daemonSets.Items[i].Spec.Template.Spec)
+	}
+	replicaSets, err := sc.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出ReplicaSet")
+	}
+	for i := range replicaSets.Items {
+		add(replicaSets.Items[i].Namespace, &replicaSets.Items[i].Spec.Template.Spec)
+	}
+	jobs, err := sc.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出Job")
+	}
+	for i := range jobs.Items {
+		add(jobs.Items[i].Namespace, &jobs.Items[i].Spec.Template.Spec)
+	}
+	cronJobs, err := sc.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法列出CronJob")
+	}
+	for i := range cronJobs.Items {
+		add(cronJobs.Items[i].Namespace, &cronJobs.Items[i].Spec.JobTemplate.Spec.Template.Spec)
+	}
+	return specs, nil
+}
+
+// podSpecSecretRefs 返回PodSpec中引用的所有Secret名称
+func podSpecSecretRefs(spec *corev1.PodSpec) []string {
+	var names []string
+	for _, ref := range spec.ImagePullSecrets {
+		names = append(names, ref.Name)
+	}
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil {
+			names = append(names, volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					names = append(names, source.Secret.Name)
+				}
+			}
+		}
+	}
+	for _, container := range allContainers(spec) {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				names = append(names, env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				names = append(names, envFrom.SecretRef.Name)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,107 @@
+package k8sclientkit
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// MinServiceAccountTokenTTL 为TokenRequest API允许的最短token有效期
+const MinServiceAccountTokenTTL = 10 * time.Minute
+
+// RequestServiceAccountToken 通过TokenRequest API为指定ServiceAccount签发有效期为ttl的短期token,
+// 返回token及其过期时间. audiences 为空时使用APIServer默认audience.
+func (c *GenericK8sClient) RequestServiceAccountToken(ctx context.Context, namespace, serviceAccount string, ttl time.Duration, audiences ...string) (string, time.Time, error) {
+	if ttl < MinServiceAccountTokenTTL {
+		return "", time.Time{}, errors.New("token有效期不能小于 " + MinServiceAccountTokenTTL.String())
+	}
+	seconds := int64(ttl.Seconds())
+	tr, err := c.GetStandardClient().CoreV1().ServiceAccounts(namespace).CreateToken(ctx, serviceAccount, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			Audiences:         audiences,
+			ExpirationSeconds: &seconds,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "无法为ServiceAccount "+namespace+"/"+serviceAccount+" 签发token")
+	}
+	return tr.Status.Token, tr.Status.ExpirationTimestamp.Time, nil
+}
+
+// ServiceAccountTokenProvider 返回通过TokenRequest API为指定ServiceAccount持续签发短期token的CredentialProvider,
+// 可与 WithCredentialProvider 配合使用.
+func (c *GenericK8sClient) ServiceAccountTokenProvider(namespace, serviceAccount string, ttl time.Duration, audiences ...string) CredentialProvider {
+	return CredentialProviderFunc(func(ctx context.Context) (string, error) {
+		token, _, err := c.RequestServiceAccountToken(ctx, namespace, serviceAccount, ttl, audiences...)
+		return token, err
+	})
+}
+
+// AsServiceAccount 派生一个以指定ServiceAccount的短期token认证的新客户端, token在过期前通过当前客户端自动续签.
+// 派生客户端继承当前客户端的Option(opts追加在其后, WithImpersonation 除外), 拥有独立的生命周期, 需要单独Start/Stop.
+func (c *GenericK8sClient) AsServiceAccount(ctx context.Context, namespace, serviceAccount string, ttl time.Duration, opts ...Option) (*GenericK8sClient, error) {
+	token, _, err := c.RequestServiceAccountToken(ctx, namespace, serviceAccount, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	c.configLock.RLock()
+	config := rest.AnonymousClientConfig(c.baseRestConfig)
+	c.configLock.RUnlock()
+	config.BearerToken = token
+
+	// 继承的impersonation会使请求以被模拟的身份而不是ServiceAccount执行, 因此清除; opts中仍可重新指定
+	derivedOpts := append(append([]Option{}, c.options...), withoutImpersonation(),
+		WithCredentialProvider(c.ServiceAccountTokenProvider(namespace, serviceAccount, ttl)))
+	derivedOpts = append(derivedOpts, opts...)
+	return newGenericK8sClientWithRestConfig(ctx, c.TargetK8sApiServerId, AuthTypeToken, config, derivedOpts)
+}
+
+// ServiceAccountKubeConfig 为指定ServiceAccount签发短期token, 返回使用该token访问当前集群的kubeconfig及token过期时间.
+// kubeconfig的默认namespace为ServiceAccount所在namespace.
+func (c *GenericK8sClient) ServiceAccountKubeConfig(ctx context.Context, namespace, serviceAccount string, ttl time.Duration) ([]byte, time.Time, error) {
+	token, expiresAt, err := c.RequestServiceAccountToken(ctx, namespace, serviceAccount, ttl)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	kubeConfig, err := c.tokenKubeConfig(namespace, token)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := clientcmd.Write(*kubeConfig)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrap(err, "无法序列化kubeconfig")
+	}
+	return data, expiresAt, nil
+}
+
+// tokenKubeConfig 构建使用token访问当前集群的kubeconfig, CA内联到kubeconfig中
+func (c *GenericK8sClient) tokenKubeConfig(namespace, token string) (*clientcmdapi.Config, error) {
+	config := c.currentRestConfig()
+	caData := config.TLSClientConfig.CAData
+	if len(caData) == 0 && len(config.TLSClientConfig.CAFile) > 0 {
+		data, err := os.ReadFile(config.TLSClientConfig.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "无法读取CA文件:"+config.TLSClientConfig.CAFile)
+		}
+		caData = data
+	}
+
+	kubeConfig := singleClusterKubeConfig(&clientcmdapi.Cluster{
+		Server:                   config.Host,
+		TLSServerName:            config.TLSClientConfig.ServerName,
+		InsecureSkipTLSVerify:    config.TLSClientConfig.Insecure,
+		CertificateAuthorityData: caData,
+	}, &clientcmdapi.AuthInfo{
+		Token: token,
+	})
+	kubeConfig.Contexts[kubeConfig.CurrentContext].Namespace = namespace
+	return kubeConfig, nil
+}
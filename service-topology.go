@@ -0,0 +1,229 @@
+package k8sclientkit
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// TopologyEndpoint 为服务的一个后端, 合并自EndpointSlice, 其指向的Pod及所在节点
+type TopologyEndpoint struct {
+	Addresses   []string                `json:"addresses"`
+	AddressType discoveryv1.AddressType `json:"addressType"`
+	// Ready, Serving, Terminating 为EndpointSlice中的状态, 未设置的conditions按Kubernetes约定视为true(Terminating为false)
+	Ready       bool `json:"ready"`
+	Serving     bool `json:"serving"`
+	Terminating bool `json:"terminating"`
+	// NodeName 与 Zone 优先取自EndpointSlice, 缺失时从Pod与节点label补全
+	NodeName string `json:"nodeName,omitempty"`
+	Zone     string `json:"zone,omitempty"`
+	// HintZones 为拓扑感知路由的提示(endpoint hints), 未启用时为空
+	HintZones []string `json:"hintZones,omitempty"`
+	// PodName 为后端Pod名称, 后端不是Pod时为空
+	PodName   string `json:"podName,omitempty"`
+	SliceName string `json:"sliceName"`
+}
+
+// ZoneTopology 为一个可用区内的后端统计
+type ZoneTopology struct {
+	Zone     string   `json:"zone"`
+	Nodes    []string `json:"nodes"`
+	Ready    int      `json:"ready"`
+	NotReady int      `json:"notReady"`
+}
+
+// ServiceTopology 为服务后端在节点与可用区上的分布
+type ServiceTopology struct {
+	Namespace string                     `json:"namespace"`
+	Name      string                     `json:"name"`
+	Ports     []discoveryv1.EndpointPort `json:"ports,omitempty"`
+	// Endpoints 按可用区, 节点, 地址排序
+	Endpoints []TopologyEndpoint `json:"endpoints"`
+}
+
+// ReadyEndpoints 返回Ready的后端
+func (t *ServiceTopology) ReadyEndpoints() []TopologyEndpoint {
+	var ready []TopologyEndpoint
+	for _, endpoint := range t.Endpoints {
+		if endpoint.Ready {
+			ready = append(ready, endpoint)
+		}
+	}
+	return ready
+}
+
+// ByNode 按节点分组后端, 节点未知的后端key为空字符串
+func (t *ServiceTopology) ByNode() map[string][]TopologyEndpoint {
+	grouped := map[string][]TopologyEndpoint{}
+	for _, endpoint := range t.Endpoints {
+		grouped[endpoint.NodeName] = append(grouped[endpoint.NodeName], endpoint)
+	}
+	return grouped
+}
+
+// Zones 返回各可用区的后端统计, 按可用区名称排序, 可用区未知的后端归入空名称的可用区
+func (t *ServiceTopology) Zones() []ZoneTopology {
+	zones := map[string]*ZoneTopology{}
+	nodes := map[string]map[string]bool{}
+	for _, endpoint := range t.Endpoints {
+		zone, ok := zones[endpoint.Zone]
+		if !ok {
+			zone = &ZoneTopology{Zone: endpoint.Zone}
+			zones[endpoint.Zone] = zone
+			nodes[endpoint.Zone] = map[string]bool{}
+		}
+		if endpoint.Ready {
+			zone.Ready++
+		} else {
+			zone.NotReady++
+		}
+		if len(endpoint.NodeName) > 0 && !nodes[endpoint.Zone][endpoint.NodeName] {
+			nodes[endpoint.Zone][endpoint.NodeName] = true
+			zone.Nodes = append(zone.Nodes, endpoint.NodeName)
+		}
+	}
+	result := make([]ZoneTopology, 0, len(zones))
+	for _, zone := range zones {
+		sort.Strings(zone.Nodes)
+		result = append(result, *zone)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Zone < result[j].Zone
+	})
+	return result
+}
+
+// GetServiceTopology 合并服务的EndpointSlice, 后端Pod所在节点及节点的可用区label, 得到后端的拓扑分布.
+// 不使用已废弃的Endpoints API. EndpointSlice中缺少nodeName或zone时, 分别从Pod与节点的
+// topology.kubernetes.io/zone label补全, 因此需要pods与nodes的list权限, 无权限时对应字段保持为空.
+func (c *GenericK8sClient) GetServiceTopology(ctx context.Context, namespace, service string) (*ServiceTopology, error) {
+	sc := c.GetStandardClient()
+	serviceGVK := corev1.SchemeGroupVersion.WithKind("Service")
+	if _, err := sc.CoreV1().Services(namespace).Get(ctx, service, metav1.GetOptions{}); err != nil {
+		return nil, c.clusterError(err, serviceGVK)
+	}
+	slices, err := sc.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service}).String(),
+	})
+	if err != nil {
+		return nil, c.clusterError(err, discoveryv1.SchemeGroupVersion.WithKind("EndpointSlice"))
+	}
+
+	topology := &ServiceTopology{Namespace: namespace, Name: service}
+	ports := map[string]bool{}
+	missingNode, missingZone := false, false
+	for _, slice := range slices.Items {
+		for _, port := range slice.Ports {
+			key := portKey(port)
+			if !ports[key] {
+				ports[key] = true
+				topology.Ports = append(topology.Ports, port)
+			}
+		}
+		for _, endpoint := range slice.Endpoints {
+			item := TopologyEndpoint{
+				Addresses:   endpoint.Addresses,
+				AddressType: slice.AddressType,
+				Ready:       endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready,
+				Serving:     endpoint.Conditions.Serving == nil || *endpoint.Conditions.Serving,
+				Terminating: endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating,
+				SliceName:   slice.Name,
+			}
+			if endpoint.NodeName != nil {
+				item.NodeName = *endpoint.NodeName
+			}
+			if endpoint.Zone != nil {
+				item.Zone = *endpoint.Zone
+			}
+			if endpoint.Hints != nil {
+				for _, zone := range endpoint.Hints.ForZones {
+					item.HintZones = append(item.HintZones, zone.Name)
+				}
+			}
+			if ref := endpoint.TargetRef; ref != nil && ref.Kind == "Pod" {
+				item.PodName = ref.Name
+			}
+			missingNode = missingNode || (len(item.NodeName) == 0 && len(item.PodName) > 0)
+			missingZone = missingZone || len(item.Zone) == 0
+			topology.Endpoints = append(topology.Endpoints, item)
+		}
+	}
+
+	if missingNode {
+		c.fillEndpointNodes(ctx, namespace, topology.Endpoints)
+	}
+	if missingZone {
+		c.fillEndpointZones(ctx, topology.Endpoints)
+	}
+	sort.SliceStable(topology.Endpoints, func(i, j int) bool {
+		a, b := topology.Endpoints[i], topology.Endpoints[j]
+		if a.Zone != b.Zone {
+			return a.Zone < b.Zone
+		}
+		if a.NodeName != b.NodeName {
+			return a.NodeName < b.NodeName
+		}
+		return firstAddress(a) < firstAddress(b)
+	})
+	return topology, nil
+}
+
+// fillEndpointNodes 从后端Pod的spec.nodeName补全节点, 读取失败时保持为空
+func (c *GenericK8sClient) fillEndpointNodes(ctx context.Context, namespace string, endpoints []TopologyEndpoint) {
+	pods, err := c.GetStandardClient().CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return
+	}
+	podNodes := make(map[string]string, len(pods.Items))
+	for _, pod := range pods.Items {
+		podNodes[pod.Name] = pod.Spec.NodeName
+	}
+	for i := range endpoints {
+		if len(endpoints[i].NodeName) == 0 && len(endpoints[i].PodName) > 0 {
+			endpoints[i].NodeName = podNodes[endpoints[i].PodName]
+		}
+	}
+}
+
+// fillEndpointZones 从节点的 topology.kubernetes.io/zone label补全可用区, 读取失败时保持为空
+func (c *GenericK8sClient) fillEndpointZones(ctx context.Context, endpoints []TopologyEndpoint) {
+	nodes, err := c.GetStandardClient().CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return
+	}
+	nodeZones := make(map[string]string, len(nodes.Items))
+	for _, node := range nodes.Items {
+		nodeZones[node.Name] = node.Labels[corev1.LabelTopologyZone]
+	}
+	for i := range endpoints {
+		if len(endpoints[i].Zone) == 0 {
+			endpoints[i].Zone = nodeZones[endpoints[i].NodeName]
+		}
+	}
+}
+
+func portKey(port discoveryv1.EndpointPort) string {
+	key := ""
+	if port.Name != nil {
+		key += *port.Name
+	}
+	if port.Protocol != nil {
+		key += "/" + string(*port.Protocol)
+	}
+	if port.Port != nil {
+		key += "/" + strconv.Itoa(int(*port.Port))
+	}
+	return key
+}
+
+func firstAddress(endpoint TopologyEndpoint) string {
+	if len(endpoint.Addresses) == 0 {
+		return ""
+	}
+	return endpoint.Addresses[0]
+}
@@ -0,0 +1,159 @@
+package k8sclientkit
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshDialTimeout 为连接SSH跳板机的超时时间
+const sshDialTimeout = 15 * time.Second
+
+// WithSSHTunnel 通过SSH跳板机访问仅在内网可达的APIServer: 所有到APIServer的TCP连接都经由跳板机转发.
+//
+//	host: 跳板机地址, 如 `bastion.example.com` 或 `10.0.0.1:2222`, 未指定端口时使用22
+//	user: SSH用户名
+//	keyPEM: PEM编码的SSH私钥
+//
+// SSH连接在首次请求时建立, 断开后自动重连, 并在客户端Stop时关闭.
+// 默认不校验跳板机host key, 生产环境应通过 WithSSHHostKeyCallback 指定校验方式.
+func WithSSHTunnel(host, user string, keyPEM []byte) Option {
+	return func(o *clientOptions) {
+		o.sshHost = host
+		o.sshUser = user
+		o.sshKeyPEM = keyPEM
+	}
+}
+
+// WithSSHHostKeyCallback 设置SSH跳板机host key校验方式, 如 `ssh.FixedHostKey(pub)` 或 knownhosts.New(...)
+func WithSSHHostKeyCallback(callback ssh.HostKeyCallback) Option {
+	return func(o *clientOptions) {
+		o.sshHostKeyCallback = callback
+	}
+}
+
+// newSSHTunnel 根据选项创建SSH隧道, 未配置时返回nil
+func (o *clientOptions) newSSHTunnel() (*sshTunnel, error) {
+	if len(o.sshHost) == 0 {
+		return nil, nil
+	}
+	signer, err := ssh.ParsePrivateKey(o.sshKeyPEM)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法解析SSH私钥")
+	}
+	hostKeyCallback := o.sshHostKeyCallback
+	if hostKeyCallback == nil {
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	}
+
+	host := o.sshHost
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "22")
+	}
+	return &sshTunnel{
+		host: host,
+		config: &ssh.ClientConfig{
+			User:            o.sshUser,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         sshDialTimeout,
+		},
+	}, nil
+}
+
+// sshTunnel 维护到跳板机的SSH连接, 并通过其转发TCP连接
+type sshTunnel struct {
+	host   string
+	config *ssh.ClientConfig
+
+	lock   sync.Mutex
+	client *ssh.Client
+	closed bool
+}
+
+// DialContext 经由跳板机建立到addr的连接, 可直接用作rest.Config.Dial
+func (t *sshTunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.Dial(network, addr)
+	if err == nil {
+		return conn, nil
+	}
+
+	// SSH连接可能已失效, 重连后重试一次
+	t.reset(client)
+	client, err = t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err = client.Dial(network, addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法通过SSH跳板机 "+t.host+" 连接 "+addr)
+	}
+	return conn, nil
+}
+
+func (t *sshTunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed {
+		return nil, errors.New("SSH隧道已关闭")
+	}
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	dialer := &net.Dialer{Timeout: sshDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", t.host)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法连接SSH跳板机:"+t.host)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, t.host, t.config)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "SSH跳板机握手失败:"+t.host)
+	}
+	t.client = ssh.NewClient(sshConn, chans, reqs)
+	return t.client, nil
+}
+
+// CheckHealth 确认到跳板机的SSH连接可用: 未连接时建立连接, 已连接时发送keepalive请求, 失败时丢弃连接以便下次重连
+func (t *sshTunnel) CheckHealth(ctx context.Context) error {
+	client, err := t.connect(ctx)
+	if err != nil {
+		return err
+	}
+	if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+		t.reset(client)
+		return errors.Wrap(err, "SSH跳板机 "+t.host+" 连接已断开")
+	}
+	return nil
+}
+
+func (t *sshTunnel) reset(broken *ssh.Client) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.client == broken {
+		t.client.Close()
+		t.client = nil
+	}
+}
+
+// Close 关闭SSH连接, 之后的拨号请求均失败
+func (t *sshTunnel) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.closed = true
+	if t.client == nil {
+		return nil
+	}
+	err := t.client.Close()
+	t.client = nil
+	return err
+}
@@ -0,0 +1,105 @@
+package k8sclientkit
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// 常见的表示就绪的condition类型, 按优先级排列
+var readyConditionTypes = []string{"Ready", "Available", "Established", "Succeeded"}
+
+// GetConditions 读取对象的 status.conditions, 统一转换为 metav1.Condition.
+// 兼容旧式condition结构: 缺少lastTransitionTime时依次使用 lastUpdateTime, lastProbeTime, lastHeartbeatTime;
+// 缺少observedGeneration时使用 status.observedGeneration.
+func GetConditions(obj *unstructured.Unstructured) []metav1.Condition {
+	items, found, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil || !found {
+		return nil
+	}
+	statusGeneration, _ := GetObservedGeneration(obj)
+
+	conditions := make([]metav1.Condition, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		condition := metav1.Condition{
+			Type:               nestedString(m, "type"),
+			Status:             metav1.ConditionStatus(nestedString(m, "status")),
+			Reason:             nestedString(m, "reason"),
+			Message:            nestedString(m, "message"),
+			ObservedGeneration: statusGeneration,
+		}
+		if len(condition.Type) == 0 {
+			continue
+		}
+		if generation, found, _ := unstructured.NestedInt64(m, "observedGeneration"); found {
+			condition.ObservedGeneration = generation
+		}
+		for _, field := range []string{"lastTransitionTime", "lastUpdateTime", "lastProbeTime", "lastHeartbeatTime"} {
+			if t, err := time.Parse(time.RFC3339, nestedString(m, field)); err == nil {
+				condition.LastTransitionTime = metav1.NewTime(t)
+				break
+			}
+		}
+		conditions = append(conditions, condition)
+	}
+	return conditions
+}
+
+// GetCondition 获取指定类型的condition
+func GetCondition(obj *unstructured.Unstructured, conditionType string) (*metav1.Condition, bool) {
+	conditions := GetConditions(obj)
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i], true
+		}
+	}
+	return nil, false
+}
+
+// GetObservedGeneration 读取 status.observedGeneration
+func GetObservedGeneration(obj *unstructured.Unstructured) (int64, bool) {
+	generation, found, err := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if err != nil || !found {
+		return 0, false
+	}
+	return generation, true
+}
+
+// IsReady 判断对象是否就绪:
+//
+//  1. status.observedGeneration 落后于 metadata.generation 时视为未就绪(控制器尚未处理最新spec)
+//  2. 存在 Ready/Available/Established/Succeeded 类型的condition时以其状态为准
+//  3. 存在 spec.replicas 时比较 status.readyReplicas
+//  4. 没有status的对象(如ConfigMap)视为就绪
+func IsReady(obj *unstructured.Unstructured) bool {
+	if observed, found := GetObservedGeneration(obj); found && observed < obj.GetGeneration() {
+		return false
+	}
+
+	conditions := GetConditions(obj)
+	for _, conditionType := range readyConditionTypes {
+		for _, condition := range conditions {
+			if condition.Type == conditionType {
+				return condition.Status == metav1.ConditionTrue
+			}
+		}
+	}
+
+	if replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); found {
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		return ready >= replicas
+	}
+
+	_, hasStatus := obj.Object["status"]
+	return !hasStatus || len(conditions) == 0
+}
+
+func nestedString(m map[string]interface{}, field string) string {
+	s, _, _ := unstructured.NestedString(m, field)
+	return s
+}
@@ -0,0 +1,71 @@
+package k8sclientkit
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// SubresourceClient 用于访问指定资源对象的子资源, 如 `/status`, `/scale`, `/approval` 以及CRD中定义的自定义子资源.
+// 基于dynamic client实现, 因此对任意GVR均可用.
+type SubresourceClient struct {
+	Gvr         schema.GroupVersionResource
+	Namespace   string
+	Subresource string
+
+	resource               dynamic.ResourceInterface
+	disableServerSideApply bool
+}
+
+// Subresource 创建一个作用于 `gvr` 资源在 `namespace` 中的 `subresource` 子资源的客户端.
+// 集群级别资源 namespace 传空字符串.
+func (c *GenericK8sClient) Subresource(gvr schema.GroupVersionResource, namespace, subresource string) *SubresourceClient {
+	return &SubresourceClient{
+		Gvr:         gvr,
+		Namespace:   namespace,
+		Subresource: subresource,
+		resource:    namespacedResource(c.GetDynamicClient(), gvr, namespace),
+
+		disableServerSideApply: c.overrides.DisableServerSideApply,
+	}
+}
+
+// SubresourceFor 根据对象自身的GVK与namespace创建子资源客户端
+func (c *GenericK8sClient) SubresourceFor(obj *unstructured.Unstructured, subresource string) (*SubresourceClient, error) {
+	gvr, err := c.GvkToGvr(obj.GroupVersionKind())
+	if err != nil {
+		return nil, errors.Wrap(err, "无法解析对象GVR")
+	}
+	return c.Subresource(gvr, obj.GetNamespace(), subresource), nil
+}
+
+// Get 读取指定对象的子资源
+func (s *SubresourceClient) Get(ctx context.Context, name string) (*unstructured.Unstructured, error) {
+	return s.resource.Get(ctx, name, metav1.GetOptions{}, s.Subresource)
+}
+
+// Create 向子资源提交一个新对象, 如 `pods/eviction`, `serviceaccounts/token`.
+// 请求路径中的对象名称取自 `obj.GetName()`.
+func (s *SubresourceClient) Create(ctx context.Context, obj *unstructured.Unstructured, fieldManager string) (*unstructured.Unstructured, error) {
+	return s.resource.Create(ctx, obj, metav1.CreateOptions{FieldManager: fieldManager}, s.Subresource)
+}
+
+// Update 使用obj整体更新子资源, obj 中需包含有效的resourceVersion
+func (s *SubresourceClient) Update(ctx context.Context, obj *unstructured.Unstructured, fieldManager string) (*unstructured.Unstructured, error) {
+	return s.resource.Update(ctx, obj, metav1.UpdateOptions{FieldManager: fieldManager}, s.Subresource)
+}
+
+// Patch 使用指定patch类型修改子资源
+func (s *SubresourceClient) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, fieldManager string) (*unstructured.Unstructured, error) {
+	return s.resource.Patch(ctx, name, pt, data, metav1.PatchOptions{FieldManager: fieldManager}, s.Subresource)
+}
+
+// Apply 对子资源执行server-side apply, 集群禁用SSA时(见 ClusterOverrides)退化为JSON merge patch
+func (s *SubresourceClient) Apply(ctx context.Context, obj *unstructured.Unstructured, fieldManager string, force bool) (*unstructured.Unstructured, error) {
+	return applyObject(ctx, s.resource, obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: force}, s.disableServerSideApply, s.Subresource)
+}
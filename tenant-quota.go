@@ -0,0 +1,231 @@
+package k8sclientkit
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// ErrTenantQuotaExceeded 租户超出该类操作的速率配额
+var ErrTenantQuotaExceeded = errors.New("租户超出操作配额")
+
+// TenantOperation 为配额统计的操作类型
+type TenantOperation string
+
+const (
+	// TenantOperationRead 为get/list/watch
+	TenantOperationRead TenantOperation = "read"
+	// TenantOperationWrite 为create/update/patch/delete
+	TenantOperationWrite TenantOperation = "write"
+	// TenantOperationExec 为pods的exec, attach与portforward
+	TenantOperationExec TenantOperation = "exec"
+	// TenantOperationLogs 为pods/log
+	TenantOperationLogs TenantOperation = "logs"
+)
+
+// TenantLimit 为一类操作的速率限制, QPS小于等于0表示不限制
+type TenantLimit struct {
+	QPS float32
+	// Burst 为允许的突发请求数, 小于1时按1处理
+	Burst int
+}
+
+// TenantUsage 为租户某类操作的累计请求数
+type TenantUsage struct {
+	Allowed  int64
+	Rejected int64
+}
+
+// TenantQuotaOptions 为 NewTenantQuota 的参数
+type TenantQuotaOptions struct {
+	// Limits 为每个租户默认的各类操作限制, 未列出的操作类型不限制
+	Limits map[TenantOperation]TenantLimit
+	// Wait 为true时超出配额的请求等待至有可用配额(受请求ctx约束), 否则立即返回 ErrTenantQuotaExceeded
+	Wait bool
+}
+
+// TenantQuota 按逻辑租户统计并限制经由本库发出的请求, 租户通过 ContextWithTenant 从请求ctx中获取,
+// 未设置租户的请求不受限制也不计入统计. 同一个TenantQuota可通过 WithTenantQuota 共享给多个集群的客户端,
+// 从而在整个集群舰队范围内对租户限流, 用于在本库之上构建的多租户API中保证租户之间的公平性.
+type TenantQuota struct {
+	options TenantQuotaOptions
+
+	lock      sync.Mutex
+	overrides map[string]map[TenantOperation]TenantLimit
+	limiters  map[tenantOperationKey]flowcontrol.RateLimiter
+	usage     map[tenantOperationKey]*tenantCounters
+}
+
+type tenantOperationKey struct {
+	tenant    string
+	operation TenantOperation
+}
+
+type tenantCounters struct {
+	allowed  atomic.Int64
+	rejected atomic.Int64
+}
+
+// NewTenantQuota 创建租户配额
+func NewTenantQuota(opts TenantQuotaOptions) *TenantQuota {
+	return &TenantQuota{
+		options:   opts,
+		overrides: map[string]map[TenantOperation]TenantLimit{},
+		limiters:  map[tenantOperationKey]flowcontrol.RateLimiter{},
+		usage:     map[tenantOperationKey]*tenantCounters{},
+	}
+}
+
+// SetTenantLimits 为指定租户设置独立的限制, 取代默认限制; limits 为nil时恢复默认限制. 新的限制从下一个请求开始生效.
+func (q *TenantQuota) SetTenantLimits(tenant string, limits map[TenantOperation]TenantLimit) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if limits == nil {
+		delete(q.overrides, tenant)
+	} else {
+		q.overrides[tenant] = limits
+	}
+	for key := range q.limiters {
+		if key.tenant == tenant {
+			delete(q.limiters, key)
+		}
+	}
+}
+
+// Usage 返回租户各类操作的累计请求数
+func (q *TenantQuota) Usage(tenant string) map[TenantOperation]TenantUsage {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	result := map[TenantOperation]TenantUsage{}
+	for key, counters := range q.usage {
+		if key.tenant == tenant {
+			result[key.operation] = TenantUsage{Allowed: counters.allowed.Load(), Rejected: counters.rejected.Load()}
+		}
+	}
+	return result
+}
+
+// Tenants 返回已有请求记录的租户
+func (q *TenantQuota) Tenants() []string {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	seen := map[string]bool{}
+	var tenants []string
+	for key := range q.usage {
+		if !seen[key.tenant] {
+			seen[key.tenant] = true
+			tenants = append(tenants, key.tenant)
+		}
+	}
+	return tenants
+}
+
+// Acquire 为租户的一次操作获取配额, 超出配额时根据 TenantQuotaOptions.Wait 等待或返回 ErrTenantQuotaExceeded
+func (q *TenantQuota) Acquire(ctx context.Context, tenant string, operation TenantOperation) error {
+	limiter, counters := q.limiterFor(tenant, operation)
+	if limiter == nil {
+		counters.allowed.Add(1)
+		return nil
+	}
+	if q.options.Wait {
+		if err := limiter.Wait(ctx); err != nil {
+			counters.rejected.Add(1)
+			return errors.Wrapf(ErrTenantQuotaExceeded, "租户 %s 等待 %s 配额超时: %v", tenant, operation, err)
+		}
+	} else if !limiter.TryAccept() {
+		counters.rejected.Add(1)
+		return errors.Wrapf(ErrTenantQuotaExceeded, "租户 %s 的 %s 操作", tenant, operation)
+	}
+	counters.allowed.Add(1)
+	return nil
+}
+
+// limiterFor 返回租户该类操作的限流器(不限制时为nil)与计数器
+func (q *TenantQuota) limiterFor(tenant string, operation TenantOperation) (flowcontrol.RateLimiter, *tenantCounters) {
+	key := tenantOperationKey{tenant: tenant, operation: operation}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	counters, ok := q.usage[key]
+	if !ok {
+		counters = &tenantCounters{}
+		q.usage[key] = counters
+	}
+	if limiter, ok := q.limiters[key]; ok {
+		return limiter, counters
+	}
+	limits := q.options.Limits
+	if override, ok := q.overrides[tenant]; ok {
+		limits = override
+	}
+	limit, ok := limits[operation]
+	if !ok || limit.QPS <= 0 {
+		q.limiters[key] = nil
+		return nil, counters
+	}
+	burst := limit.Burst
+	if burst < 1 {
+		burst = 1
+	}
+	limiter := flowcontrol.NewTokenBucketRateLimiter(limit.QPS, burst)
+	q.limiters[key] = limiter
+	return limiter, counters
+}
+
+type tenantKey struct{}
+
+// ContextWithTenant 将租户标识附加到ctx, 使用该ctx发出的请求计入租户配额(见 WithTenantQuota)
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext 返回ctx中的租户标识
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok && len(tenant) > 0
+}
+
+// WithTenantQuota 使客户端发出的请求按ctx中的租户受TenantQuota约束
+func WithTenantQuota(quota *TenantQuota) Option {
+	return func(o *clientOptions) {
+		o.tenantQuota = quota
+	}
+}
+
+// tenantQuotaRoundTripper 在发送请求前获取租户配额
+type tenantQuotaRoundTripper struct {
+	quota *TenantQuota
+	next  http.RoundTripper
+}
+
+func (rt *tenantQuotaRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	tenant, ok := TenantFromContext(req.Context())
+	if !ok {
+		return rt.next.RoundTrip(req)
+	}
+	if err := rt.quota.Acquire(req.Context(), tenant, tenantOperationOf(req)); err != nil {
+		return nil, err
+	}
+	return rt.next.RoundTrip(req)
+}
+
+// tenantOperationOf 根据请求路径与方法判断操作类型
+func tenantOperationOf(req *http.Request) TenantOperation {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	if strings.Contains(path, "/pods/") {
+		switch path[strings.LastIndex(path, "/")+1:] {
+		case "exec", "attach", "portforward":
+			return TenantOperationExec
+		case "log":
+			return TenantOperationLogs
+		}
+	}
+	if isMutatingMethod(req.Method) {
+		return TenantOperationWrite
+	}
+	return TenantOperationRead
+}
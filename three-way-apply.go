@@ -0,0 +1,139 @@
+package k8sclientkit
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/mergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// LastAppliedConfigAnnotation 记录上次client-side apply的配置, 与 `kubectl apply` 使用同一注解, 两者可以交替使用
+const LastAppliedConfigAnnotation = corev1.LastAppliedConfigAnnotation
+
+// ApplyUnstructuredObjClientSide 以经典 `kubectl apply`(client-side apply)的方式应用obj: 在对象上记录
+// LastAppliedConfigAnnotation, 以上次应用的配置, 本次配置与集群中的当前对象计算三方合并patch.
+// 内置类型使用strategic merge patch(列表按patchMergeKey合并), 其他类型(如CRD)使用JSON merge patch.
+// 与server-side apply不同, 不记录字段归属, 从本次配置中删除的字段(仅限上次由apply设置的字段)会从对象中删除.
+// 对象不存在时创建, 没有变化时不发送写请求. obj被原地更新为APIServer返回的对象.
+// 集群设置了DisableServerSideApply时 ApplyUnstructuredObj 同样使用此方式.
+func (c *GenericK8sClient) ApplyUnstructuredObjClientSide(ctx context.Context, obj *unstructured.Unstructured, fieldManager string) (*UnstructuredApplyResult, error) {
+	ctx, _ = EnsureRequestID(ctx)
+	ctx, warnings := ContextWithWarningCollector(ctx)
+	var desiredHash string
+	err := c.runObjectPlugins(ctx, OperationApply, obj)
+	if err == nil {
+		desiredHash = ObjectHash(obj)
+		err = c.applyThreeWay(ctx, obj, fieldManager)
+	}
+	err = c.clusterError(err, obj.GroupVersionKind())
+	if err == nil {
+		c.recordOperationEvent(ctx, obj, EventReasonApplied, "对象已由 "+fieldManager+" 应用(client-side)")
+	}
+	return &UnstructuredApplyResult{
+		Gvk:          obj.GroupVersionKind(),
+		Error:        err,
+		Success:      err == nil,
+		ResultObject: obj,
+		Warnings:     warnings(),
+		DesiredHash:  desiredHash,
+	}, err
+}
+
+// applyThreeWay 计算并提交三方合并patch
+func (c *GenericK8sClient) applyThreeWay(ctx context.Context, obj *unstructured.Unstructured, fieldManager string) error {
+	modified, err := withLastAppliedConfig(obj)
+	if err != nil {
+		return err
+	}
+	cli := c.GetRuntimeCluster().GetClient()
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(obj.GroupVersionKind())
+	err = cli.Get(ctx, client.ObjectKeyFromObject(obj), live)
+	if apierrors.IsNotFound(err) {
+		modified.DeepCopyInto(obj)
+		return cli.Create(ctx, obj, client.FieldOwner(fieldManager))
+	}
+	if err != nil {
+		return err
+	}
+
+	original := []byte(live.GetAnnotations()[LastAppliedConfigAnnotation])
+	modifiedJson, err := json.Marshal(modified.Object)
+	if err != nil {
+		return errors.Wrap(err, "无法序列化对象")
+	}
+	currentJson, err := json.Marshal(live.Object)
+	if err != nil {
+		return errors.Wrap(err, "无法序列化对象")
+	}
+
+	var patch []byte
+	patchType := types.MergePatchType
+	if versioned, err := scheme.Scheme.New(obj.GroupVersionKind()); err == nil {
+		lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(versioned)
+		if err != nil {
+			return errors.Wrap(err, "无法获取 "+obj.GroupVersionKind().String()+" 的patch元数据")
+		}
+		patch, err = strategicpatch.CreateThreeWayMergePatch(original, modifiedJson, currentJson, lookupPatchMeta, true)
+		if err != nil {
+			return errors.Wrap(err, "无法计算strategic merge patch")
+		}
+		patchType = types.StrategicMergePatchType
+	} else if runtime.IsNotRegisteredError(err) {
+		// 非内置类型不支持strategic merge patch, 与kubectl一致使用JSON merge patch, 并要求apiVersion与kind不变
+		preconditions := []mergepatch.PreconditionFunc{
+			mergepatch.RequireKeyUnchanged("apiVersion"),
+			mergepatch.RequireKeyUnchanged("kind"),
+			mergepatch.RequireMetadataKeyUnchanged("name"),
+		}
+		patch, err = jsonmergepatch.CreateThreeWayJSONMergePatch(original, modifiedJson, currentJson, preconditions...)
+		if err != nil {
+			return errors.Wrap(err, "无法计算JSON merge patch")
+		}
+	} else {
+		return errors.Wrap(err, "无法识别类型 "+obj.GroupVersionKind().String())
+	}
+
+	if string(patch) == "{}" {
+		// 没有变化
+		live.DeepCopyInto(obj)
+		return nil
+	}
+	obj.Object = map[string]interface{}{}
+	obj.SetGroupVersionKind(live.GroupVersionKind())
+	obj.SetNamespace(live.GetNamespace())
+	obj.SetName(live.GetName())
+	return cli.Patch(ctx, obj, client.RawPatch(patchType, patch), client.FieldOwner(fieldManager))
+}
+
+// withLastAppliedConfig 返回设置了 LastAppliedConfigAnnotation 的副本, 注解内容为不含该注解及服务端字段的obj
+func withLastAppliedConfig(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	applied := obj.DeepCopy()
+	applied.SetResourceVersion("")
+	applied.SetManagedFields(nil)
+	annotations := applied.GetAnnotations()
+	delete(annotations, LastAppliedConfigAnnotation)
+	applied.SetAnnotations(annotations)
+	data, err := json.Marshal(applied.Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "无法序列化对象")
+	}
+
+	modified := applied.DeepCopy()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[LastAppliedConfigAnnotation] = string(data)
+	modified.SetAnnotations(annotations)
+	return modified, nil
+}
@@ -0,0 +1,210 @@
+package k8sclientkit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// 限流来源
+const (
+	ThrottleSourceClient = "client"
+	ThrottleSourceServer = "server"
+)
+
+// clientThrottleThreshold 客户端限流等待超过此时长时才通知ThrottleObserver
+const clientThrottleThreshold = 50 * time.Millisecond
+
+// defaultThrottleRetryWait 429响应未携带Retry-After时的重试等待时间
+const defaultThrottleRetryWait = time.Second
+
+// ThrottleEvent 描述一次请求被限流的情况
+type ThrottleEvent struct {
+	ClusterId string
+	// Source 为 ThrottleSourceClient(客户端限流器等待) 或 ThrottleSourceServer(APIServer返回429)
+	Source string
+	Method string
+	Path   string
+	// Wait 为等待时长: 客户端限流的实际等待时间, 或服务端429响应要求的重试等待时间
+	Wait time.Duration
+	// Attempt 为服务端限流时的重试次数, 从1开始
+	Attempt int
+	// FlowSchemaUID, PriorityLevelUID 为APIServer API Priority and Fairness 对该请求的分类
+	FlowSchemaUID    string
+	PriorityLevelUID string
+}
+
+// ThrottleObserver 在请求被限流时调用, 在请求goroutine中同步执行, 实现方应避免阻塞
+type ThrottleObserver func(event ThrottleEvent)
+
+// WithRateLimiter 使用自定义的客户端侧限流器代替QPS/Burst, 该限流器由客户端创建的所有客户端共享
+func WithRateLimiter(limiter flowcontrol.RateLimiter) Option {
+	return func(o *clientOptions) {
+		o.rateLimiter = limiter
+	}
+}
+
+// WithThrottleRetry 在APIServer返回429(API Priority and Fairness 限流)时按Retry-After等待并重试, 最多重试maxRetries次,
+// 单次等待不超过maxWait(小于等于0表示不限制). 重试耗尽后返回的429响应不再携带Retry-After, 避免client-go再次重试.
+// 请求体无法重放的请求不会重试.
+func WithThrottleRetry(maxRetries int, maxWait time.Duration) Option {
+	return func(o *clientOptions) {
+		o.throttleMaxRetries = maxRetries
+		o.throttleMaxWait = maxWait
+	}
+}
+
+// WithThrottleObserver 设置限流观察者, 用于统计或告警客户端侧与服务端的限流情况.
+// 未通过 WithRateLimiter 指定限流器时, 将根据QPS/Burst创建一个由所有客户端共享的限流器以便观察等待时间.
+func WithThrottleObserver(observer ThrottleObserver) Option {
+	return func(o *clientOptions) {
+		o.throttleObserver = observer
+	}
+}
+
+// applyThrottle 设置限流器并包装transport
+func (o *clientOptions) applyThrottle(clusterId string, config *rest.Config) {
+	observer := o.throttleObserver
+	if m := o.fleetMetrics; m != nil {
+		// 限流同时计入 WithFleetMetrics 的指标
+		observer = chainThrottleObservers(m.observeThrottle, o.throttleObserver)
+	}
+	limiter := o.rateLimiter
+	if limiter == nil && observer != nil && config.QPS >= 0 {
+		qps, burst := config.QPS, config.Burst
+		if qps == 0 {
+			qps = rest.DefaultQPS
+		}
+		if burst == 0 {
+			burst = rest.DefaultBurst
+		}
+		limiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	}
+	if limiter != nil && observer != nil {
+		limiter = &observingRateLimiter{RateLimiter: limiter, clusterId: clusterId, observer: observer}
+	}
+	if limiter != nil {
+		config.RateLimiter = limiter
+	}
+
+	if o.throttleMaxRetries > 0 || observer != nil {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &throttleRoundTripper{
+				clusterId:  clusterId,
+				maxRetries: o.throttleMaxRetries,
+				maxWait:    o.throttleMaxWait,
+				observer:   observer,
+				next:       rt,
+			}
+		})
+	}
+}
+
+// chainThrottleObservers 依次调用非空的observer
+func chainThrottleObservers(observers ...ThrottleObserver) ThrottleObserver {
+	return func(event ThrottleEvent) {
+		for _, observer := range observers {
+			if observer != nil {
+				observer(event)
+			}
+		}
+	}
+}
+
+// observingRateLimiter 记录客户端限流器的等待时间
+type observingRateLimiter struct {
+	flowcontrol.RateLimiter
+	clusterId string
+	observer  ThrottleObserver
+}
+
+func (l *observingRateLimiter) Accept() {
+	start := time.Now()
+	l.RateLimiter.Accept()
+	l.observe(time.Since(start))
+}
+
+func (l *observingRateLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	err := l.RateLimiter.Wait(ctx)
+	l.observe(time.Since(start))
+	return err
+}
+
+func (l *observingRateLimiter) observe(wait time.Duration) {
+	if wait >= clientThrottleThreshold {
+		l.observer(ThrottleEvent{ClusterId: l.clusterId, Source: ThrottleSourceClient, Wait: wait})
+	}
+}
+
+// throttleRoundTripper 处理APIServer返回的429响应
+type throttleRoundTripper struct {
+	clusterId  string
+	maxRetries int
+	maxWait    time.Duration
+	observer   ThrottleObserver
+	next       http.RoundTripper
+}
+
+func (rt *throttleRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := rt.next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+
+		wait := retryAfter(resp)
+		if rt.observer != nil {
+			rt.observer(ThrottleEvent{
+				ClusterId:        rt.clusterId,
+				Source:           ThrottleSourceServer,
+				Method:           req.Method,
+				Path:             req.URL.Path,
+				Wait:             wait,
+				Attempt:          attempt,
+				FlowSchemaUID:    resp.Header.Get("X-Kubernetes-PF-FlowSchema-UID"),
+				PriorityLevelUID: resp.Header.Get("X-Kubernetes-PF-PriorityLevel-UID"),
+			})
+		}
+		if rt.maxRetries <= 0 {
+			return resp, nil
+		}
+		if attempt > rt.maxRetries || (req.Body != nil && req.GetBody == nil) {
+			resp.Header.Del("Retry-After")
+			return resp, nil
+		}
+
+		if rt.maxWait > 0 && wait > rt.maxWait {
+			wait = rt.maxWait
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retryAfter 解析429响应的Retry-After(秒)
+func retryAfter(resp *http.Response) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultThrottleRetryWait
+}
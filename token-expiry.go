@@ -0,0 +1,181 @@
+package k8sclientkit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// CredentialProvider 用于在token即将过期时获取新的bearer token
+type CredentialProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// CredentialProviderFunc 使普通函数实现 CredentialProvider
+type CredentialProviderFunc func(ctx context.Context) (string, error)
+
+func (f CredentialProviderFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// TokenExpiryNotifier 在token即将过期(或已被续期)时被调用
+type TokenExpiryNotifier func(clusterId string, expiresAt time.Time)
+
+// tokenRenewRetryInterval 从CredentialProvider获取token失败(或续期未推后过期时间)后的初始重试间隔,
+// 此后每次加倍, 最长为 tokenRenewMaxRetryInterval
+const (
+	tokenRenewRetryInterval    = 30 * time.Second
+	tokenRenewMaxRetryInterval = 5 * time.Minute
+)
+
+// ParseTokenExpiry 解析JWT格式bearer token中的 `exp` 声明, 不校验签名.
+// 非JWT格式或不包含exp的token返回错误.
+func ParseTokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("token不是JWT格式")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "无法解码JWT payload")
+	}
+	claims := struct {
+		Exp *json.Number `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, errors.Wrap(err, "无法解析JWT payload")
+	}
+	if claims.Exp == nil {
+		return time.Time{}, errors.New("JWT中不包含exp声明")
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "无效的exp声明")
+	}
+	return time.Unix(int64(exp), 0), nil
+}
+
+// TokenExpiresAt 返回客户端当前使用的bearer token的过期时间
+func (c *GenericK8sClient) TokenExpiresAt() (time.Time, error) {
+	token, err := c.currentBearerToken()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ParseTokenExpiry(token)
+}
+
+func (c *GenericK8sClient) currentBearerToken() (string, error) {
+	if c.token != nil {
+		return c.token.current(), nil
+	}
+	config := c.currentRestConfig()
+	if len(config.BearerToken) > 0 {
+		return config.BearerToken, nil
+	}
+	if len(config.BearerTokenFile) > 0 {
+		token, err := os.ReadFile(config.BearerTokenFile)
+		if err != nil {
+			return "", errors.Wrap(err, "无法读取Token文件:"+config.BearerTokenFile)
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+	return "", errors.New("客户端未使用bearer token认证")
+}
+
+// watchTokenExpiry 在token过期前 `lead` 时间触发通知, 并在配置了CredentialProvider时自动续期.
+// 随ctx结束退出.
+func (c *GenericK8sClient) watchTokenExpiry(ctx context.Context, lead time.Duration, notifier TokenExpiryNotifier) {
+	for {
+		expiresAt, err := c.TokenExpiresAt()
+		if err != nil {
+			// 无法确定过期时间(如非JWT token), 不再继续监视
+			return
+		}
+
+		wait := time.Until(expiresAt.Add(-lead))
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+
+		if notifier != nil {
+			notifier(c.TargetK8sApiServerId, expiresAt)
+		}
+		if c.token == nil || c.token.provider == nil {
+			// 没有续期能力, 仅通知一次
+			return
+		}
+
+		retry := tokenRenewRetryInterval
+		for {
+			if err := c.token.renew(ctx); err == nil {
+				renewedAt, err := c.TokenExpiresAt()
+				if err != nil || (renewedAt.After(expiresAt) && time.Until(renewedAt.Add(-lead)) > 0) {
+					// 过期时间已推后到lead之外; 新token无法解析时由外层循环退出
+					break
+				}
+				// 新token没有推后过期时间(或仍在lead之内), 通知调用方后退避重试, 避免立即再次触发续期
+				if notifier != nil {
+					notifier(c.TargetK8sApiServerId, renewedAt)
+				}
+				expiresAt = renewedAt
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retry):
+			}
+			retry = min(retry*2, tokenRenewMaxRetryInterval)
+		}
+	}
+}
+
+// renewableToken 是可在运行时替换的bearer token来源, 实现 oauth2.TokenSource 以便注入client-go transport
+type renewableToken struct {
+	lock     sync.RWMutex
+	token    string
+	provider CredentialProvider
+}
+
+func (t *renewableToken) current() string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.token
+}
+
+func (t *renewableToken) set(token string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.token = strings.TrimSpace(token)
+}
+
+func (t *renewableToken) renew(ctx context.Context) error {
+	token, err := t.provider.Token(ctx)
+	if err != nil {
+		return errors.Wrap(err, "无法从CredentialProvider获取token")
+	}
+	t.set(token)
+	return nil
+}
+
+func (t *renewableToken) Token() (*oauth2.Token, error) {
+	token := t.current()
+	if len(token) == 0 && t.provider != nil {
+		if err := t.renew(context.Background()); err != nil {
+			return nil, err
+		}
+		token = t.current()
+	}
+	return &oauth2.Token{AccessToken: token, TokenType: "Bearer"}, nil
+}
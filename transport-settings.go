@@ -0,0 +1,103 @@
+package k8sclientkit
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/net/http2"
+	"k8s.io/client-go/rest"
+)
+
+// TransportSettings 为连接层面的参数, 零值字段保持client-go默认值
+// (拨号超时30s, TCP keepalive 30s, TLS握手超时10s, 每个host最多25个空闲连接, HTTP/2健康检查间隔30s).
+// 经高延迟广域网访问边缘集群时, 通常需要增大拨号与TLS握手超时, 并缩短keepalive间隔以尽快发现中间设备断开的连接.
+type TransportSettings struct {
+	// DialTimeout 为建立TCP连接的超时时间
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout 为TLS握手超时时间
+	TLSHandshakeTimeout time.Duration
+	// KeepAlive 为TCP keepalive探测间隔, 同时作为HTTP/2连接的健康检查(PING)间隔
+	KeepAlive time.Duration
+	// MaxIdleConnsPerHost 为每个host保留的最大空闲连接数
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout 为空闲连接的最长保留时间
+	IdleConnTimeout time.Duration
+}
+
+func (s TransportSettings) empty() bool {
+	return s == TransportSettings{}
+}
+
+// WithTransportSettings 调整连接层面的参数, 作用于该客户端创建的所有客户端(标准, 动态, metrics, controller-runtime cluster).
+// 设置了 WithSSHTunnel 或 WithDialer 时连接经由隧道建立, DialTimeout 与TCP keepalive 不生效.
+func WithTransportSettings(settings TransportSettings) Option {
+	return func(o *clientOptions) {
+		o.transportSettings = settings
+	}
+}
+
+// applyTransportSettings 将连接参数写入config. 拨号参数通过config.Dial设置, 以保留exec插件对连接的跟踪;
+// 其余参数在client-go创建的http.Transport副本上修改, 该包装位于所有WrapTransport的最内层.
+func (o *clientOptions) applyTransportSettings(config *rest.Config) {
+	s := o.transportSettings
+	if s.empty() {
+		return
+	}
+	if config.Dial == nil && (s.DialTimeout > 0 || s.KeepAlive > 0) {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		if s.DialTimeout > 0 {
+			dialer.Timeout = s.DialTimeout
+		}
+		if s.KeepAlive > 0 {
+			dialer.KeepAlive = s.KeepAlive
+		}
+		config.Dial = dialer.DialContext
+	}
+	if s.TLSHandshakeTimeout <= 0 && s.MaxIdleConnsPerHost <= 0 && s.IdleConnTimeout <= 0 && s.KeepAlive <= 0 {
+		return
+	}
+
+	next := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if t, ok := rt.(*http.Transport); ok {
+			rt = tuneTransport(t, s)
+		}
+		if next != nil {
+			return next(rt)
+		}
+		return rt
+	}
+}
+
+// tuneTransport 复制t并应用设置. client-go会在多个客户端间缓存复用同一个http.Transport, 因此不能直接修改t.
+func tuneTransport(t *http.Transport, s TransportSettings) *http.Transport {
+	tuned := t.Clone()
+	if s.TLSHandshakeTimeout > 0 {
+		tuned.TLSHandshakeTimeout = s.TLSHandshakeTimeout
+	}
+	if s.MaxIdleConnsPerHost > 0 {
+		tuned.MaxIdleConnsPerHost = s.MaxIdleConnsPerHost
+	}
+	if s.IdleConnTimeout > 0 {
+		tuned.IdleConnTimeout = s.IdleConnTimeout
+	}
+	if t.TLSNextProto == nil || len(os.Getenv("DISABLE_HTTP2")) > 0 {
+		// 原transport未启用HTTP/2
+		return tuned
+	}
+
+	// Clone复制的HTTP/2升级函数仍指向原transport的连接池, 需要重新配置
+	tuned.TLSNextProto = nil
+	h2, err := http2.ConfigureTransports(tuned)
+	if err != nil {
+		return tuned
+	}
+	h2.ReadIdleTimeout = 30 * time.Second
+	h2.PingTimeout = 15 * time.Second
+	if s.KeepAlive > 0 {
+		h2.ReadIdleTimeout = s.KeepAlive
+	}
+	return tuned
+}
@@ -0,0 +1,72 @@
+package k8sclientkit
+
+import (
+	"context"
+	"net"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+)
+
+// Dialer 建立到APIServer的TCP连接, 用于经由隧道服务(如云厂商托管的konnectivity, 自建SSH跳板)访问集群
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DialerHealthChecker 可由Dialer可选实现: 健康探测(WithHealthProbe, ProbeHealth)时先检查隧道本身,
+// 隧道不可用时集群直接判定为不可达, 错误记录在 HealthStatus.TunnelError 中, 便于区分隧道故障与APIServer故障.
+type DialerHealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// DialerFunc 将普通函数适配为Dialer
+type DialerFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f DialerFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// WithDialer 使到APIServer的所有连接经由dialer建立, 不能与 WithSSHTunnel 或 WithKonnectivity 同时使用.
+// dialer由调用方管理, 客户端Close时不会关闭它, 因此可在多个客户端间共享.
+func WithDialer(dialer Dialer) Option {
+	return func(o *clientOptions) {
+		o.dialer = dialer
+	}
+}
+
+// applyDialer 将 WithDialer, WithSSHTunnel 或 WithKonnectivity 设置的拨号器写入config, 并记录可用于健康检查的隧道
+func (o *clientOptions) applyDialer(config *rest.Config) error {
+	configured := 0
+	for _, set := range []bool{o.dialer != nil, len(o.sshHost) > 0, o.konnectivity != nil} {
+		if set {
+			configured++
+		}
+	}
+	if configured > 1 {
+		return errors.New("WithDialer, WithSSHTunnel 与 WithKonnectivity 不能同时使用")
+	}
+	if o.konnectivity != nil {
+		dialer, err := newKonnectivityDialer(*o.konnectivity)
+		if err != nil {
+			return err
+		}
+		config.Dial = dialer.DialContext
+		o.tunnelHealth = dialer
+		return nil
+	}
+	if o.dialer != nil {
+		config.Dial = o.dialer.DialContext
+		o.tunnelHealth, _ = o.dialer.(DialerHealthChecker)
+		return nil
+	}
+	tunnel, err := o.newSSHTunnel()
+	if err != nil {
+		return err
+	}
+	if tunnel != nil {
+		config.Dial = tunnel.DialContext
+		o.tunnelHealth = tunnel
+		o.cleanups = append(o.cleanups, func() { tunnel.Close() })
+	}
+	return nil
+}
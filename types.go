@@ -10,4 +10,9 @@ type UnstructuredApplyResult struct {
 	Success      bool
 	Error        error
 	ResultObject *unstructured.Unstructured
+	// Warnings 为APIServer在本次操作中返回的警告, 如API废弃提示
+	Warnings []string
+	// DesiredHash 为提交的期望对象(插件执行后)的ObjectHash. ResultObject 包含服务端默认值与其他控制器写入的内容,
+	// 比较多次apply的内容是否变化时应使用 DesiredHash
+	DesiredHash string
 }
@@ -0,0 +1,181 @@
+package k8sclientkit
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/version"
+	apimachineryversion "k8s.io/apimachinery/pkg/version"
+)
+
+// serverVersionTTL 为缓存APIServer版本的时长, 集群升级后最迟在此时间后生效
+const serverVersionTTL = 10 * time.Minute
+
+// VersionRequirementError 为集群不满足操作所需的版本或API时返回的错误, 可通过 errors.Is(err, ErrUnsupportedVersion) 判断
+type VersionRequirementError struct {
+	// Constraint 为版本约束(如 ">=1.25"), 检查API时为空
+	Constraint string
+	// ServerVersion 为APIServer的gitVersion
+	ServerVersion string
+	// MissingAPI 为集群未提供的组/版本或资源(如 "policy/v1" 或 "policy/v1/poddisruptionbudgets"), 检查版本时为空
+	MissingAPI string
+}
+
+func (e *VersionRequirementError) Error() string {
+	if len(e.MissingAPI) > 0 {
+		return "集群未提供 " + e.MissingAPI
+	}
+	return "集群版本 " + e.ServerVersion + " 不满足 " + e.Constraint
+}
+
+func (e *VersionRequirementError) Unwrap() error {
+	return ErrUnsupportedVersion
+}
+
+// serverVersionCache 缓存APIServer版本
+type serverVersionCache struct {
+	lock      sync.Mutex
+	info      *apimachineryversion.Info
+	fetchedAt time.Time
+}
+
+// ServerVersion 返回APIServer版本, 结果缓存 serverVersionTTL
+func (c *GenericK8sClient) ServerVersion(ctx context.Context) (*apimachineryversion.Info, error) {
+	c.versionCache.lock.Lock()
+	defer c.versionCache.lock.Unlock()
+	if c.versionCache.info != nil && time.Since(c.versionCache.fetchedAt) < serverVersionTTL {
+		return c.versionCache.info, nil
+	}
+	body, err := c.GetStandardClient().Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return nil, c.clusterError(errors.Wrap(err, "无法获取APIServer版本"), schema.GroupVersionKind{})
+	}
+	info := &apimachineryversion.Info{}
+	if err := json.Unmarshal(body, info); err != nil {
+		return nil, errors.Wrap(err, "无法解析APIServer版本信息")
+	}
+	c.versionCache.info = info
+	c.versionCache.fetchedAt = time.Now()
+	return info, nil
+}
+
+// RequireVersion 检查集群版本是否满足constraint, 不满足时返回 *VersionRequirementError, 用于同一代码路径面向多个版本的集群时
+// 在操作前给出明确的错误, 而不是APIServer返回的404.
+//
+// constraint 由逗号分隔的条件组成, 条件之间为"且"的关系, 如 ">=1.25", ">=1.21, <1.29", "=1.28".
+// 运算符为 >=, >, <=, <, =, !=, 省略时为 =. 省略patch版本时视为该minor版本的全部patch版本,
+// 如 "=1.28" 匹配 1.28.x, ">1.28" 要求 1.29 及以上.
+func (c *GenericK8sClient) RequireVersion(ctx context.Context, constraint string) error {
+	conditions, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return err
+	}
+	info, err := c.ServerVersion(ctx)
+	if err != nil {
+		return err
+	}
+	serverVersion, err := version.ParseGeneric(info.GitVersion)
+	if err != nil {
+		return errors.Wrap(err, "无法解析APIServer版本 "+info.GitVersion)
+	}
+	for _, cond := range conditions {
+		if !cond.matches(serverVersion) {
+			return c.clusterError(&VersionRequirementError{Constraint: constraint, ServerVersion: info.GitVersion}, schema.GroupVersionKind{})
+		}
+	}
+	return nil
+}
+
+// RequireAPI 检查集群是否提供groupVersion(如 "policy/v1", core组为 "v1"), 指定resources时同时检查这些资源,
+// 不满足时返回 *VersionRequirementError
+func (c *GenericK8sClient) RequireAPI(ctx context.Context, groupVersion string, resources ...string) error {
+	if _, err := schema.ParseGroupVersion(groupVersion); err != nil {
+		return errors.Wrap(err, "无效的groupVersion "+groupVersion)
+	}
+	list, err := c.GetStandardClient().Discovery().ServerResourcesForGroupVersion(groupVersion)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.clusterError(&VersionRequirementError{MissingAPI: groupVersion}, schema.GroupVersionKind{})
+		}
+		return c.clusterError(errors.Wrap(err, "无法获取 "+groupVersion+" 的资源"), schema.GroupVersionKind{})
+	}
+	served := map[string]bool{}
+	for _, resource := range list.APIResources {
+		served[resource.Name] = true
+	}
+	for _, resource := range resources {
+		if !served[resource] {
+			return c.clusterError(&VersionRequirementError{MissingAPI: groupVersion + "/" + resource}, schema.GroupVersionKind{})
+		}
+	}
+	return nil
+}
+
+// versionCondition 为版本约束中的单个条件
+type versionCondition struct {
+	op      string
+	version *version.Version
+}
+
+func (cond versionCondition) matches(v *version.Version) bool {
+	switch cond.op {
+	case ">=":
+		return v.AtLeast(cond.version)
+	case ">":
+		return v.AtLeast(cond.version) && !cond.equal(v)
+	case "<=":
+		return v.LessThan(cond.version) || cond.equal(v)
+	case "<":
+		return v.LessThan(cond.version)
+	case "!=":
+		return !cond.equal(v)
+	default:
+		return cond.equal(v)
+	}
+}
+
+// equal 只比较条件中给出的版本段
+func (cond versionCondition) equal(v *version.Version) bool {
+	actual, expected := v.Components(), cond.version.Components()
+	for i := range expected {
+		if i >= len(actual) || actual[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func parseVersionConstraint(constraint string) ([]versionCondition, error) {
+	var conditions []versionCondition
+	for _, term := range strings.Split(constraint, ",") {
+		term = strings.TrimSpace(term)
+		if len(term) == 0 {
+			continue
+		}
+		op := "="
+		for _, candidate := range []string{">=", "<=", "!=", "==", ">", "<", "="} {
+			if strings.HasPrefix(term, candidate) {
+				if candidate != "==" {
+					op = candidate
+				}
+				term = strings.TrimSpace(strings.TrimPrefix(term, candidate))
+				break
+			}
+		}
+		v, err := version.ParseGeneric(term)
+		if err != nil {
+			return nil, errors.Wrap(err, "无效的版本约束 "+constraint)
+		}
+		conditions = append(conditions, versionCondition{op: op, version: v})
+	}
+	if len(conditions) == 0 {
+		return nil, errors.New("版本约束为空")
+	}
+	return conditions, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
@@ -24,6 +25,8 @@ type K8sResourceWatcher struct {
 	queue     workqueue.RateLimitingInterface
 	informer  informers.GenericInformer
 	lister    cache.GenericLister
+	client    dynamic.Interface
+	resync    time.Duration
 
 	stop chan struct{}
 }
@@ -47,7 +50,10 @@ func NewDynamicWatcher(client dynamic.Interface, resource schema.GroupVersionRes
 	// informer.AddIndexers(cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
 
 	return &K8sResourceWatcher{
+		Gvr:       resource,
 		Namespace: namespace,
+		client:    client,
+		resync:    resync,
 		queue:     queue,
 		informer:  informer,
 		stop:      make(chan struct{}),
@@ -78,6 +84,11 @@ func (w *K8sResourceWatcher) Stop() {
 	w.stop <- struct{}{}
 }
 
+// AddEventHandler 注册事件处理函数.
+//
+// watch断开后informer重新list时, 以新的list结果与缓存对比: 缓存中已有的对象以Updated(而非Added)交给updateHandler,
+// 期间消失的对象以Deleted(cache.DeletedFinalStateUnknown)交给delHandler, 只有新对象为Added.
+// resync为0时, 重新list中resourceVersion未变化的对象不再交给updateHandler, 因此只输出重连期间的差异.
 func (w *K8sResourceWatcher) AddEventHandler(addHandler, delHandler func(obj interface{}), updateHandler func(oldObj, newObj interface{})) {
 	// 事件处理支持
 	// w.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -102,9 +113,31 @@ func (w *K8sResourceWatcher) AddEventHandler(addHandler, delHandler func(obj int
 	// 	},
 	// })
 
+	onUpdate := updateHandler
+	if updateHandler != nil && w.resync == 0 {
+		onUpdate = func(oldObj, newObj interface{}) {
+			if unchangedOnRelist(oldObj, newObj) {
+				return
+			}
+			updateHandler(oldObj, newObj)
+		}
+	}
 	w.informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    addHandler,
-		UpdateFunc: updateHandler,
+		UpdateFunc: onUpdate,
 		DeleteFunc: delHandler,
 	})
 }
+
+// unchangedOnRelist 判断Updated事件是否来自重新list中未变化的对象(resourceVersion相同)
+func unchangedOnRelist(oldObj, newObj interface{}) bool {
+	oldMeta, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	newMeta, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	return len(newMeta.GetResourceVersion()) > 0 && oldMeta.GetResourceVersion() == newMeta.GetResourceVersion()
+}
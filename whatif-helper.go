@@ -0,0 +1,126 @@
+package k8sclientkit
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// WhatIfFieldManager 是WhatIf预演时使用的field manager
+const WhatIfFieldManager = "k8s-client-kit-whatif"
+
+// WhatIfResult 描述以指定用户身份执行一次变更的预演结果
+type WhatIfResult struct {
+	User string
+	Gvk  schema.GroupVersionKind
+	// Verb 为鉴权时检查的动作, 对象不存在时为create, 否则为patch
+	Verb string
+	// Authorized 表示RBAC等鉴权是否允许该用户执行变更
+	Authorized bool
+	// Admitted 表示变更是否通过准入控制(mutating/validating webhook, schema校验等), 仅在Authorized时有意义
+	// 集群设置了 ClusterOverrides.SkipWebhookChecks 时不执行准入预演, Admitted 为true且ResultObject为空
+	Admitted bool
+	// Reason 为未通过鉴权或准入时的原因
+	Reason string
+	// ResultObject 为经过准入控制处理(默认值填充, mutating webhook)后的对象, 仅Admitted时有效
+	ResultObject *unstructured.Unstructured
+}
+
+// Allowed 表示该用户能够成功执行此变更
+func (r *WhatIfResult) Allowed() bool {
+	return r.Authorized && r.Admitted
+}
+
+// WhatIf 以 `asUser` (及可选的用户组)身份对obj执行server-side dry-run apply, 报告该用户是否有权限执行此变更,
+// 以及准入控制会如何处理该对象. 不会对集群产生任何实际修改.
+// 调用方凭证需具备impersonate权限.
+func (c *GenericK8sClient) WhatIf(ctx context.Context, asUser string, obj *unstructured.Unstructured, groups ...string) (*WhatIfResult, error) {
+	gvr, err := c.GvkToGvr(obj.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+
+	impersonated := rest.CopyConfig(c.currentRestConfig())
+	impersonated.Impersonate = rest.ImpersonationConfig{UserName: asUser, Groups: groups}
+
+	result := &WhatIfResult{
+		User: asUser,
+		Gvk:  obj.GroupVersionKind(),
+		Verb: "patch",
+	}
+
+	// 使用自身身份判断对象是否已存在, 以确定需要鉴权的动作
+	_, err = namespacedResource(c.GetDynamicClient(), gvr, obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		result.Verb = "create"
+	} else if err != nil {
+		return nil, errors.Wrap(err, "无法获取目标对象")
+	}
+
+	sc, err := kubernetes.NewForConfig(impersonated)
+	if err != nil {
+		return nil, errors.Wrap(err, "创建impersonated standard client失败")
+	}
+	review, err := sc.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: obj.GetNamespace(),
+				Verb:      result.Verb,
+				Group:     gvr.Group,
+				Version:   gvr.Version,
+				Resource:  gvr.Resource,
+				Name:      obj.GetName(),
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "无法以用户 "+asUser+" 身份执行SelfSubjectAccessReview")
+	}
+	result.Authorized = review.Status.Allowed
+	if !result.Authorized {
+		result.Reason = review.Status.Reason
+		return result, nil
+	}
+	if c.overrides.SkipWebhookChecks {
+		// 集群设置了跳过准入预演, 仅报告鉴权结果
+		result.Admitted = true
+		return result, nil
+	}
+
+	dc, err := dynamic.NewForConfig(impersonated)
+	if err != nil {
+		return nil, errors.Wrap(err, "创建impersonated dynamic client失败")
+	}
+	applyObj := obj.DeepCopy()
+	applyObj.SetManagedFields(nil)
+	applied, err := applyObject(ctx, namespacedResource(dc, gvr, obj.GetNamespace()), applyObj, metav1.ApplyOptions{
+		FieldManager: WhatIfFieldManager,
+		Force:        true,
+		DryRun:       []string{metav1.DryRunAll},
+	}, c.overrides.DisableServerSideApply)
+	if err != nil {
+		// 鉴权已通过, 此处的失败(包括webhook拒绝, ResourceQuota, PodSecurity等返回的Forbidden)均来自准入阶段
+		result.Reason = err.Error()
+		return result, nil
+	}
+
+	result.Admitted = true
+	result.ResultObject = applied
+	return result, nil
+}
+
+// namespacedResource 根据namespace是否为空返回对应作用域的ResourceInterface
+func namespacedResource(dc dynamic.Interface, gvr schema.GroupVersionResource, namespace string) dynamic.ResourceInterface {
+	if len(namespace) > 0 {
+		return dc.Resource(gvr).Namespace(namespace)
+	}
+	return dc.Resource(gvr)
+}
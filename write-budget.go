@@ -0,0 +1,125 @@
+package k8sclientkit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// WriteBudget 限制变更类请求(POST/PUT/PATCH/DELETE)的并发数, 包括全局上限与单集群上限.
+// 同一个WriteBudget可通过 WithWriteBudget 共享给多个集群的客户端, 从而在整个集群舰队范围内限制写入并发,
+// 避免批量apply同时触发各APIServer的 API Priority and Fairness 拒绝.
+type WriteBudget struct {
+	global        chan struct{}
+	perClusterMax int
+
+	lock       sync.Mutex
+	perCluster map[string]chan struct{}
+}
+
+// NewWriteBudget 创建写入预算. maxConcurrent 为所有集群合计的最大并发写入数,
+// maxConcurrentPerCluster 为单个集群的最大并发写入数; 小于等于0表示不限制.
+func NewWriteBudget(maxConcurrent, maxConcurrentPerCluster int) *WriteBudget {
+	b := &WriteBudget{
+		perClusterMax: maxConcurrentPerCluster,
+		perCluster:    map[string]chan struct{}{},
+	}
+	if maxConcurrent > 0 {
+		b.global = make(chan struct{}, maxConcurrent)
+	}
+	return b
+}
+
+// Acquire 为指定集群获取一个写入配额, 阻塞直至获得配额或ctx结束. 返回的release必须被调用以归还配额.
+func (b *WriteBudget) Acquire(ctx context.Context, clusterId string) (release func(), err error) {
+	// 先获取单集群配额, 避免占用全局配额时等待单集群配额
+	clusterSem := b.clusterSemaphore(clusterId)
+	if clusterSem != nil {
+		select {
+		case clusterSem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "等待集群 "+clusterId+" 写入配额超时")
+		}
+	}
+	if b.global != nil {
+		select {
+		case b.global <- struct{}{}:
+		case <-ctx.Done():
+			if clusterSem != nil {
+				<-clusterSem
+			}
+			return nil, errors.Wrap(ctx.Err(), "等待全局写入配额超时")
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if b.global != nil {
+				<-b.global
+			}
+			if clusterSem != nil {
+				<-clusterSem
+			}
+		})
+	}, nil
+}
+
+// InFlight 返回当前全局进行中的写入请求数, 未设置全局上限时返回-1
+func (b *WriteBudget) InFlight() int {
+	if b.global == nil {
+		return -1
+	}
+	return len(b.global)
+}
+
+func (b *WriteBudget) clusterSemaphore(clusterId string) chan struct{} {
+	if b.perClusterMax <= 0 {
+		return nil
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	sem, ok := b.perCluster[clusterId]
+	if !ok {
+		sem = make(chan struct{}, b.perClusterMax)
+		b.perCluster[clusterId] = sem
+	}
+	return sem
+}
+
+// WithWriteBudget 使客户端的所有变更类请求受WriteBudget约束
+func WithWriteBudget(budget *WriteBudget) Option {
+	return func(o *clientOptions) {
+		o.writeBudget = budget
+	}
+}
+
+// writeBudgetRoundTripper 在发送变更类请求前获取写入配额
+type writeBudgetRoundTripper struct {
+	budget    *WriteBudget
+	clusterId string
+	next      http.RoundTripper
+}
+
+func (rt *writeBudgetRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isMutatingMethod(req.Method) {
+		return rt.next.RoundTrip(req)
+	}
+	release, err := rt.budget.Acquire(req.Context(), rt.clusterId)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return rt.next.RoundTrip(req)
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}